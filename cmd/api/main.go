@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,10 +11,17 @@ import (
 	"syscall"
 	"time"
 
+	"nba-shots/internal/config"
+	"nba-shots/internal/database"
+	"nba-shots/internal/database/cache"
+	"nba-shots/internal/database/memory"
 	"nba-shots/internal/server"
 )
 
-func gracefulShutdown(apiServer *http.Server, done chan bool) {
+// the requests still running this long into the shutdown are cancelled
+const shutdownGrace = 4 * time.Second
+
+func gracefulShutdown(apiServer *http.Server, cancelRequests context.CancelFunc, done chan bool) {
 	// Create context that listens for the interrupt signal from the OS.
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
@@ -26,7 +35,7 @@ func gracefulShutdown(apiServer *http.Server, done chan bool) {
 	// the request it is currently handling
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	if err := apiServer.Shutdown(ctx); err != nil {
+	if err := server.Shutdown(ctx, apiServer, cancelRequests, shutdownGrace); err != nil {
 		log.Printf("Server forced to shutdown with error: %v", err)
 	}
 
@@ -37,16 +46,56 @@ func gracefulShutdown(apiServer *http.Server, done chan bool) {
 }
 
 func main() {
+	demo := flag.Bool("demo", false, "serve a few made up seasons from memory instead of postgres")
+	config.AddFlags(flag.CommandLine, config.DatabaseFlags, config.APIFlags, config.CacheFlags)
+	flag.Parse()
+
+	cfg, err := config.Load(flag.CommandLine)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = errors.Join(cfg.API.Validate(), cfg.Cache.Validate())
+	if err == nil && !*demo {
+		err = cfg.Database.Validate()
+	}
+	if err != nil {
+		log.Fatalf("invalid config:\n%v", err)
+	}
+
+	var db database.Service
+	if *demo {
+		db, err = memory.NewDemo()
+		if err != nil {
+			log.Fatalf("could not load the demo data: %v", err)
+		}
+		log.Println("Running in demo mode, no database is used")
+	} else {
+		db, err = database.New(context.Background(), cfg.Database)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	backend, err := cache.NewBackend(cfg.Cache)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if backend != nil {
+		db = cache.New(db, backend, cfg.Cache)
+		log.Printf("Caching reads with the %s backend\n", cfg.Cache.Backend)
+	}
 
-	server := server.NewServer()
+	requests, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	apiServer := server.NewServer(requests, cfg.API, db)
 
 	// Create a done channel to signal when the shutdown is complete
 	done := make(chan bool, 1)
 
 	// Run graceful shutdown in a separate goroutine
-	go gracefulShutdown(server, done)
+	go gracefulShutdown(apiServer, cancelRequests, done)
 
-	err := server.ListenAndServe()
+	err = apiServer.ListenAndServe()
 	if err != nil && err != http.ErrServerClosed {
 		panic(fmt.Sprintf("http server error: %s", err))
 	}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"nba-shots/internal/database"
+	"nba-shots/internal/types"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+func runLoad(ctx context.Context, args []string) error {
+	opts := &ingestOptions{}
+	fs := newFlagSet("load", opts)
+	addFileFlags(fs, opts)
+	fs.IntVar(&opts.BatchSize, "batch-size", 50000, "number of shots per COPY into the shot table")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "parse and validate the files and print row counts without touching the database")
+	fs.Parse(args)
+
+	if opts.BatchSize <= 0 {
+		return fmt.Errorf("batch-size must be positive, got %d", opts.BatchSize)
+	}
+	if opts.Workers <= 0 {
+		return fmt.Errorf("workers must be positive, got %d", opts.Workers)
+	}
+
+	return withProfiling(opts, func() error {
+		if opts.DryRun {
+			return loadShotFiles(ctx, nil, opts)
+		}
+
+		dbService, err := openDatabase(ctx, opts)
+		if err != nil {
+			return err
+		}
+		defer dbService.Close()
+
+		err = loadShotFiles(ctx, dbService, opts)
+		invalidateCache(ctx, opts)
+		return err
+	})
+}
+
+// runVerify checks that the loaded seasons make sense and writes a report, it fails when any check
+// does. With -compare-files it also parses the files and checks the season scoped tables have the
+// same number of rows the files would produce.
+func runVerify(ctx context.Context, args []string) error {
+	opts := &ingestOptions{}
+	fs := newFlagSet("verify", opts)
+	addFileFlags(fs, opts)
+	format := fs.String("format", "markdown", "format of the data quality report, markdown or json")
+	out := fs.String("out", "", "write the report to this file instead of stdout")
+	compareFiles := fs.Bool("compare-files", false, "also compare the row counts against the shot files")
+	fs.Parse(args)
+
+	if *format != "markdown" && *format != "json" {
+		return fmt.Errorf("unknown report format %q, expected markdown or json", *format)
+	}
+
+	return withProfiling(opts, func() error {
+		dbService, err := openDatabase(ctx, opts)
+		if err != nil {
+			return err
+		}
+		defer dbService.Close()
+
+		seasons := opts.Seasons
+		if len(seasons) == 0 {
+			allSeasons, err := dbService.GetAllSeasons(ctx)
+			if err != nil {
+				return fmt.Errorf("could not get seasons: %v", err)
+			}
+			for _, season := range allSeasons {
+				seasons = append(seasons, season.Year)
+			}
+			sort.Ints(seasons)
+		}
+
+		report := &qualityReport{GeneratedAt: time.Now().UTC()}
+		for _, season := range seasons {
+			quality, err := dbService.GetSeasonQuality(ctx, season)
+			if err != nil {
+				return fmt.Errorf("could not check season %d: %v", season, err)
+			}
+			report.add(checkSeasonQuality(quality))
+		}
+
+		w := io.Writer(os.Stdout)
+		if *out != "" {
+			f, err := os.Create(*out)
+			if err != nil {
+				return fmt.Errorf("could not create report: %v", err)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		if *format == "json" {
+			err = report.writeJSON(w)
+		} else {
+			err = report.writeMarkdown(w)
+		}
+		if err != nil {
+			return fmt.Errorf("could not write report: %v", err)
+		}
+
+		mismatches := 0
+		if *compareFiles {
+			mismatches, err = compareFileCounts(ctx, dbService, opts)
+			if err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case report.Failures > 0 && mismatches > 0:
+			return fmt.Errorf("%d data quality checks failed and found %d mismatched row counts", report.Failures, mismatches)
+		case report.Failures > 0:
+			return fmt.Errorf("%d data quality checks failed", report.Failures)
+		case mismatches > 0:
+			return fmt.Errorf("found %d mismatched row counts", mismatches)
+		}
+
+		log.Printf("Verified %d seasons, %d warnings\n", len(report.Seasons), report.Warnings)
+		return nil
+	})
+}
+
+// compareFileCounts parses the files and returns how many season scoped tables have a different
+// number of rows in the database than the files would produce
+func compareFileCounts(ctx context.Context, dbService database.IngestWriter, opts *ingestOptions) (int, error) {
+	sources, err := listShotSources(opts)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	pipeline := startFilePipeline(ctx, sources, opts.Workers, prepareForLoad(opts, nil))
+	defer pipeline.Wait()
+	defer cancel()
+
+	mismatches := 0
+	for {
+		prepared, ok, err := pipeline.Next(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+
+		if prepared.Err != nil {
+			return 0, prepared.Err
+		}
+
+		if prepared.Skip != "" {
+			prepared.logSkip()
+			continue
+		}
+
+		file, batch := prepared.Path, prepared.Batch
+
+		// files usually hold a single season, but sum the database counts in case they don't
+		var loaded []types.TableCount
+		for _, season := range prepared.Seasons {
+			counts, err := dbService.GetSeasonTableCounts(ctx, season.Year)
+			if err != nil {
+				return 0, fmt.Errorf("could not count rows for season %d: %v", season.Year, err)
+			}
+			loaded = addTableCounts(loaded, counts)
+		}
+
+		expected := make(map[string]int)
+		for _, count := range batchRowCounts(batch) {
+			expected[count.Table] = count.Rows
+		}
+
+		for _, count := range loaded {
+			if count.Rows != expected[count.Table] {
+				mismatches++
+				fmt.Printf("%s: %s has %d rows, expected %d\n", file, count.Table, count.Rows, expected[count.Table])
+			}
+		}
+	}
+
+	if mismatches == 0 {
+		log.Println("Database matches the shot files")
+	}
+	return mismatches, nil
+}
+
+func runStats(ctx context.Context, args []string) error {
+	opts := &ingestOptions{}
+	fs := newFlagSet("stats", opts)
+	fs.Var((*seasonsFlag)(&opts.Seasons), "seasons", "comma separated season end years to count, ex. 2004,2005 (default all)")
+	fs.Parse(args)
+
+	return withProfiling(opts, func() error {
+		dbService, err := openDatabase(ctx, opts)
+		if err != nil {
+			return err
+		}
+		defer dbService.Close()
+
+		seasons := opts.Seasons
+		if len(seasons) == 0 {
+			allSeasons, err := dbService.GetAllSeasons(ctx)
+			if err != nil {
+				return fmt.Errorf("could not get seasons: %v", err)
+			}
+			for _, season := range allSeasons {
+				seasons = append(seasons, season.Year)
+			}
+		}
+
+		if len(seasons) == 0 {
+			log.Println("No seasons loaded")
+			return nil
+		}
+
+		var totals []types.TableCount
+		for _, season := range seasons {
+			counts, err := dbService.GetSeasonTableCounts(ctx, season)
+			if err != nil {
+				return fmt.Errorf("could not count rows for season %d: %v", season, err)
+			}
+			printTableCounts(os.Stdout, fmt.Sprintf("season %d", season), counts)
+			totals = addTableCounts(totals, counts)
+		}
+		printTableCounts(os.Stdout, "total", totals)
+		return nil
+	})
+}
+
+func runDropSeason(ctx context.Context, args []string) error {
+	opts := &ingestOptions{}
+	var season int
+	fs := newFlagSet("drop-season", opts)
+	fs.IntVar(&season, "season", 0, "end year of the season to delete, ex. 2004")
+	fs.Parse(args)
+
+	if season == 0 {
+		return errors.New("missing required -season flag")
+	}
+
+	return withProfiling(opts, func() error {
+		dbService, err := openDatabase(ctx, opts)
+		if err != nil {
+			return err
+		}
+		defer dbService.Close()
+
+		err = dbService.DeleteSeason(ctx, season)
+		invalidateCache(ctx, opts)
+		if err != nil {
+			return fmt.Errorf("could not delete season %d: %v", season, err)
+		}
+
+		log.Printf("Deleted season %d\n", season)
+		return nil
+	})
+}
+
+// runReplace reloads one season from the shot files, the old rows are deleted and the new ones
+// inserted in the same transaction so the season is never missing from the database
+func runReplace(ctx context.Context, args []string) error {
+	opts := &ingestOptions{}
+	var season int
+	fs := newFlagSet("replace", opts)
+	addFileFlags(fs, opts)
+	fs.IntVar(&season, "season", 0, "end year of the season to replace, ex. 2004")
+	fs.IntVar(&opts.BatchSize, "batch-size", 50000, "number of shots per COPY into the shot table")
+	fs.Parse(args)
+
+	if season == 0 {
+		return errors.New("missing required -season flag")
+	}
+	if opts.BatchSize <= 0 {
+		return fmt.Errorf("batch-size must be positive, got %d", opts.BatchSize)
+	}
+	opts.Seasons = []int{season}
+
+	return withProfiling(opts, func() error {
+		sources, err := listShotSources(opts)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		pipeline := startFilePipeline(ctx, sources, opts.Workers, prepareForLoad(opts, nil))
+		defer pipeline.Wait()
+		defer cancel()
+
+		// the season has to come from a single file, game ids are only resolved within a file
+		var found *preparedFile
+		for {
+			prepared, ok, err := pipeline.Next(ctx)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				break
+			}
+
+			if prepared.Err != nil {
+				return prepared.Err
+			}
+			if prepared.Skip != "" {
+				continue
+			}
+			if found != nil {
+				return fmt.Errorf("season %d is in both %s and %s, replace reads it from one file", season, found.Path, prepared.Path)
+			}
+			found = prepared
+		}
+
+		if found == nil {
+			return fmt.Errorf("no shots for season %d in the files", season)
+		}
+
+		dbService, err := openDatabase(ctx, opts)
+		if err != nil {
+			return err
+		}
+		defer dbService.Close()
+
+		log.Printf("Replacing season %d with %d shots from %s\n", season, len(found.Batch.Shots), found.Path)
+		err = dbService.ReplaceSeason(ctx, season, found.Batch, opts.BatchSize)
+		invalidateCache(ctx, opts)
+		if err != nil {
+			return fmt.Errorf("could not replace season %d: %v", season, err)
+		}
+
+		printTableCounts(os.Stdout, fmt.Sprintf("season %d", season), batchRowCounts(found.Batch))
+		log.Printf("Replaced season %d\n", season)
+		return nil
+	})
+}
+
+func printTableCounts(w io.Writer, title string, counts []types.TableCount) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t\n", title)
+	for _, count := range counts {
+		fmt.Fprintf(tw, "%s\t%d\t\n", count.Table, count.Rows)
+	}
+	tw.Flush()
+}
+
+// addTableCounts sums counts into totals, matching tables by name
+func addTableCounts(totals []types.TableCount, counts []types.TableCount) []types.TableCount {
+	for _, count := range counts {
+		found := false
+		for i := range totals {
+			if totals[i].Table == count.Table {
+				totals[i].Rows += count.Rows
+				found = true
+				break
+			}
+		}
+		if !found {
+			totals = append(totals, count)
+		}
+	}
+	return totals
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// synthetic ids sit above every real nba game id (those are 8 digits at most)
+// so a reassigned game can never collide with one from the dataset
+const (
+	syntheticGameIDBase     = 1_000_000_000
+	maxGameIdentityVariants = 9
+)
+
+// gameIdentity is the set of fields that tells two games apart in the raw data
+type gameIdentity struct {
+	GameID   int
+	GameDate time.Time
+	HomeTeam string
+	AwayTeam string
+}
+
+type gameReassignment struct {
+	gameIdentity
+	NewGameID int
+	Shots     int
+}
+
+type gameIdentityReport struct {
+	Games         int
+	Reassignments []gameReassignment
+}
+
+// Some game ids in the dataset are shared by different games, ex. in 2020-21:
+/*
+GAME_ID	GAME_DATE	HOME_TEAM	AWAY_TEAM	SEASON_1	SEASON_2
+3575310	22000000	2020-12-25	MIA	NOP	2021	2020-21
+3576179	22000000	2020-12-23	PHX	DAL	2021	2020-21
+3576180	22000000	2020-12-23	BOS	MIL	2021	2020-21
+3578254	22000000	2020-12-22	BKN	GSW	2021	2020-21
+3578256	22000000	2020-12-22	LAL	LAC	2021	2020-21
+*/
+// resolveGameIdentities finds game ids that map to more than one
+// (date, home, away) tuple and gives every conflicting game its own synthetic id.
+// The shots are rewritten in place, so every table derived from them afterwards
+// (game, shot, player_game, team_game, game_season) picks up the new ids.
+// Variants are ordered by date, home and away so the same file always gets the same ids.
+func resolveGameIdentities(data *[]shotRecord) (*gameIdentityReport, error) {
+	variants := make(map[int][]gameIdentity)
+	shotCounts := make(map[gameIdentity]int)
+
+	for _, shot := range *data {
+		identity := gameIdentity{
+			GameID:   shot.GameID,
+			GameDate: shot.GameDate,
+			HomeTeam: shot.HomeTeam,
+			AwayTeam: shot.AwayTeam,
+		}
+		if _, seen := shotCounts[identity]; !seen {
+			variants[shot.GameID] = append(variants[shot.GameID], identity)
+		}
+		shotCounts[identity]++
+	}
+
+	report := &gameIdentityReport{Games: len(shotCounts)}
+	newIDs := make(map[gameIdentity]int)
+
+	for gameID, identities := range variants {
+		if len(identities) < 2 {
+			continue
+		}
+		if len(identities) > maxGameIdentityVariants {
+			return nil, fmt.Errorf("game id %d is shared by %d games, can only resolve up to %d", gameID, len(identities), maxGameIdentityVariants)
+		}
+
+		sort.Slice(identities, func(i, j int) bool {
+			a, b := identities[i], identities[j]
+			if !a.GameDate.Equal(b.GameDate) {
+				return a.GameDate.Before(b.GameDate)
+			}
+			if a.HomeTeam != b.HomeTeam {
+				return a.HomeTeam < b.HomeTeam
+			}
+			return a.AwayTeam < b.AwayTeam
+		})
+
+		for i, identity := range identities {
+			newID := syntheticGameID(gameID, i+1)
+			newIDs[identity] = newID
+			report.Reassignments = append(report.Reassignments, gameReassignment{
+				gameIdentity: identity,
+				NewGameID:    newID,
+				Shots:        shotCounts[identity],
+			})
+		}
+	}
+
+	if len(newIDs) == 0 {
+		return report, nil
+	}
+
+	for i := range *data {
+		shot := &(*data)[i]
+		identity := gameIdentity{
+			GameID:   shot.GameID,
+			GameDate: shot.GameDate,
+			HomeTeam: shot.HomeTeam,
+			AwayTeam: shot.AwayTeam,
+		}
+		if newID, ok := newIDs[identity]; ok {
+			shot.GameID = newID
+		}
+	}
+
+	sort.Slice(report.Reassignments, func(i, j int) bool {
+		return report.Reassignments[i].NewGameID < report.Reassignments[j].NewGameID
+	})
+
+	return report, nil
+}
+
+func syntheticGameID(gameID int, variant int) int {
+	return syntheticGameIDBase + (gameID * 10) + variant
+}
+
+func (r *gameIdentityReport) log(path string) {
+	if len(r.Reassignments) == 0 {
+		log.Printf("Game identity check for %s: %d games, no conflicting game ids\n", path, r.Games)
+		return
+	}
+
+	log.Printf("Game identity check for %s: %d games, %d reassigned to synthetic ids\n", path, r.Games, len(r.Reassignments))
+	for _, ra := range r.Reassignments {
+		log.Printf("  game_id %d (%s %s vs %s, %d shots) -> %d\n",
+			ra.GameID,
+			ra.GameDate.Format("2006-01-02"),
+			ra.HomeTeam,
+			ra.AwayTeam,
+			ra.Shots,
+			ra.NewGameID,
+		)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolveGameIdentities(t *testing.T) {
+	dec22 := time.Date(2020, 12, 22, 0, 0, 0, 0, time.UTC)
+	dec23 := time.Date(2020, 12, 23, 0, 0, 0, 0, time.UTC)
+
+	data := []shotRecord{
+		{GameID: 22000000, GameDate: dec23, HomeTeam: "PHX", AwayTeam: "DAL"},
+		{GameID: 22000000, GameDate: dec22, HomeTeam: "LAL", AwayTeam: "LAC"},
+		{GameID: 22000000, GameDate: dec22, HomeTeam: "BKN", AwayTeam: "GSW"},
+		{GameID: 22000000, GameDate: dec22, HomeTeam: "LAL", AwayTeam: "LAC"},
+		{GameID: 22000001, GameDate: dec23, HomeTeam: "BOS", AwayTeam: "MIL"},
+	}
+
+	report, err := resolveGameIdentities(&data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if report.Games != 4 {
+		t.Errorf("expected 4 games, got %d", report.Games)
+	}
+
+	if len(report.Reassignments) != 3 {
+		t.Fatalf("expected 3 reassignments, got %d", len(report.Reassignments))
+	}
+
+	// ordered by date, then home team, then away team
+	expected := []int{
+		syntheticGameID(22000000, 3), // PHX vs DAL on the 23rd
+		syntheticGameID(22000000, 2), // LAL vs LAC on the 22nd
+		syntheticGameID(22000000, 1), // BKN vs GSW on the 22nd
+		syntheticGameID(22000000, 2),
+		22000001,
+	}
+	for i, shot := range data {
+		if shot.GameID != expected[i] {
+			t.Errorf("shot %d: expected game id %d, got %d", i, expected[i], shot.GameID)
+		}
+	}
+
+	if report.Reassignments[1].Shots != 2 {
+		t.Errorf("expected LAL vs LAC to have 2 shots, got %d", report.Reassignments[1].Shots)
+	}
+}
+
+func TestResolveGameIdentitiesNoConflicts(t *testing.T) {
+	date := time.Date(2004, 4, 14, 0, 0, 0, 0, time.UTC)
+	data := []shotRecord{
+		{GameID: 20301187, GameDate: date, HomeTeam: "POR", AwayTeam: "LAL"},
+		{GameID: 20301187, GameDate: date, HomeTeam: "POR", AwayTeam: "LAL"},
+	}
+
+	report, err := resolveGameIdentities(&data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(report.Reassignments) != 0 {
+		t.Errorf("expected no reassignments, got %d", len(report.Reassignments))
+	}
+
+	for _, shot := range data {
+		if shot.GameID != 20301187 {
+			t.Errorf("expected game id to be unchanged, got %d", shot.GameID)
+		}
+	}
+}
@@ -1,297 +1,364 @@
 package main
 
 import (
+	"context"
 	"encoding/csv"
+	"fmt"
+	"io"
 	"log"
 	"nba-shots/internal/database"
 	"nba-shots/internal/types"
 	"os"
-	"path/filepath"
-	"runtime/pprof"
 	"strconv"
+	"strings"
 	"time"
 )
 
-// toggle whether to insert or not
-// const uploadData = true
-
-type rawShotData struct {
-	PlayerID      int       `db:"player_id"`
-	PlayerName    string    `db:"player_name"`
-	GameID        int       `db:"game_id"`
-	TeamID        int       `db:"team_id"`
-	TeamName      string    `db:"team_name"`
-	SeasonEndYear int       `db:"season_end_year"`
-	SeasonYears   string    `db:"season_years"`
-	EventType     string    `db:"event_type"`
-	ShotMade      bool      `db:"shot_made"`
-	ActionType    string    `db:"action_type"`
-	ShotType      string    `db:"shot_type"`
-	BasicZone     string    `db:"basic_zone"`
-	ZoneName      string    `db:"zone_name"`
-	ZoneABB       string    `db:"zone_abb"`
-	ZoneRange     string    `db:"zone_range"`
-	LocX          float64   `db:"loc_x"`
-	LocY          float64   `db:"loc_y"`
-	ShotDistance  int       `db:"shot_distance"`
-	Quarter       int       `db:"qtr"`
-	MinsLeft      int       `db:"mins_left"`
-	SecsLeft      int       `db:"secs_left"`
-	Position      string    `db:"position"`
-	PositionGroup string    `db:"position_group"`
-	GameDate      time.Time `db:"game_date"`
-	HomeTeam      string    `db:"home_team"`
-	AwayTeam      string    `db:"away_team"`
+// shotFile is the shots read from one source, usually a whole season
+type shotFile struct {
+	Path    string
+	Shots   []shotRecord
+	Seasons []types.Season
 }
 
-func main() {
-	x, _ := os.Create("mem.pprof")
-	defer pprof.WriteHeapProfile(x)
-
-	log.Println("Starting ingest script 1")
-
-	dbService := database.New()
-	defer dbService.Close()
-
-	// query the database to confirm that the tables are empty
-	dbEmpty, err := dbService.IsEmptyDatabase()
-
-	if err != nil {
-		log.Fatalf("error checking if database is empty: %v", err)
-	}
-
-	if !dbEmpty {
-		log.Printf("Database is populated, no need to ingest: %v", dbEmpty)
-		return
+// newShotFile collects the seasons the shots belong to, in the order they first appear
+func newShotFile(path string, shots []shotRecord) *shotFile {
+	parsed := &shotFile{Path: path, Shots: shots}
+	seenSeasons := make(map[int]bool)
+	for _, shot := range shots {
+		if !seenSeasons[shot.SeasonEndYear] {
+			seenSeasons[shot.SeasonEndYear] = true
+			parsed.Seasons = append(parsed.Seasons, types.Season{
+				Year:        shot.SeasonEndYear,
+				SeasonYears: shot.SeasonYears,
+			})
+		}
 	}
+	return parsed
+}
 
-	err = readAndParseShotsCSV(dbService)
+// column names of the kaggle csv, see the bottom of this file for an example row
+var shotCSVColumns = []string{
+	"SEASON_1", "SEASON_2", "TEAM_ID", "TEAM_NAME", "PLAYER_ID", "PLAYER_NAME",
+	"POSITION_GROUP", "POSITION", "GAME_DATE", "GAME_ID", "HOME_TEAM", "AWAY_TEAM",
+	"EVENT_TYPE", "SHOT_MADE", "ACTION_TYPE", "SHOT_TYPE", "BASIC_ZONE", "ZONE_NAME",
+	"ZONE_ABB", "ZONE_RANGE", "LOC_X", "LOC_Y", "SHOT_DISTANCE", "QUARTER",
+	"MINS_LEFT", "SECS_LEFT",
+}
 
+// loadShotFiles parses the files on opts.Workers workers and loads them into the database
+// one at a time, in file order. With opts.DryRun the files are only parsed and validated,
+// and the rows that would have been inserted are printed per table.
+func loadShotFiles(ctx context.Context, dbService database.Service, opts *ingestOptions) error {
+	sources, err := listShotSources(opts)
 	if err != nil {
-		log.Fatalf("error parsing all files: %v", err)
+		return err
 	}
-}
 
-func readAndParseShotsCSV(dbService database.Service) error {
-	// need to fix something with docker taking up a lot of disk space and it might be related to these files
-	// docker system prune -a -> this command removed 65gb lol
-	dataDir := filepath.Join("raw_data", "nbashots")
-	files, err := filepath.Glob(filepath.Join(dataDir, "*.csv"))
-
-	log.Println("Files in filepath: ", files)
-	if err != nil {
-		log.Fatalf("could not read CSV files from directory %s. Err: %v", dataDir, err)
+	// seasons that are already in the database are skipped, they have to be dropped first to reload them
+	loadedSeasons := make(map[int]bool)
+	if !opts.DryRun {
+		seasons, err := dbService.GetAllSeasons(ctx)
+		if err != nil {
+			return fmt.Errorf("could not get the loaded seasons: %v", err)
+		}
+		for _, season := range seasons {
+			loadedSeasons[season.Year] = true
+		}
 	}
 
-	// need to combine all the shot records from one csv into a single slice
-	// then from there we will process the data for player, team, season, game
-	// once those tables are populated then shot can be populated
-	for _, file := range files {
-		log.Println("Opening file: ", file)
-		f, err := os.Open(file)
+	ctx, cancel := context.WithCancel(ctx)
+
+	pipeline := startFilePipeline(ctx, sources, opts.Workers, prepareForLoad(opts, loadedSeasons))
+	defer pipeline.Wait()
+	defer cancel()
 
+	var totals []types.TableCount
+	invalidFiles := 0
+	// workers parse and derive the player, team, season, game etc. rows for a whole file,
+	// this loop is the only thing that writes to the database so the dimension tables
+	// are always populated before the shots that reference them
+	for {
+		prepared, ok, err := pipeline.Next(ctx)
 		if err != nil {
-			log.Fatalf("could not open file %s. Err: %v", file, err)
+			return err
+		}
+		if !ok {
+			break
 		}
-		defer f.Close()
 
-		reader := csv.NewReader(f)
-		records, err := reader.ReadAll()
-		// log.Println("Reading records: ", len(records))
-		if err != nil {
-			log.Fatalf("could not read CSV file %s. Err: %v", file, err)
+		if prepared.Err != nil && opts.DryRun {
+			// keep going so a dry run reports every bad file at once
+			log.Println(prepared.Err)
+			invalidFiles++
+			continue
+		}
+		if prepared.Err != nil {
+			return prepared.Err
 		}
 
-		var all_data []rawShotData
-		var seasons []types.Season
-		for j, r := range records[1:] {
-			typedRow := parseShotRow(r)
-			all_data = append(all_data, typedRow)
+		if prepared.Skip != "" {
+			prepared.logSkip()
+			continue
+		}
 
-			// get the season data from this file since it will be the same for all records
-			if j == 0 {
-				seasons = append(seasons, types.Season{
-					Year:        typedRow.SeasonEndYear,
-					SeasonYears: typedRow.SeasonYears,
-				})
-			}
+		if opts.DryRun {
+			counts := batchRowCounts(prepared.Batch)
+			printTableCounts(os.Stdout, prepared.Path, counts)
+			totals = addTableCounts(totals, counts)
+			continue
 		}
-		log.Println("This files slice length: ", len(all_data))
-		err = uploadBatchShotData(dbService, &all_data, &seasons)
+
+		err = uploadBatchShotData(ctx, dbService, prepared.Batch, opts.BatchSize)
 
 		if err != nil {
-			log.Fatalf("Error uploading for file %s. Err: %v", file, err)
+			return fmt.Errorf("error uploading for file %s: %v", prepared.Path, err)
+		}
+	}
+
+	if opts.DryRun {
+		printTableCounts(os.Stdout, "total", totals)
+		if invalidFiles > 0 {
+			return fmt.Errorf("%d of %d files failed validation", invalidFiles, len(sources))
 		}
-		f.Close()
+		log.Println("Dry run complete, nothing was written to the database")
+		return nil
 	}
-	log.Println("Completed csv reading")
+
+	log.Println("Completed shot loading")
 	return nil
 }
 
-func uploadBatchShotData(dbService database.Service, allData *[]rawShotData, seasons *[]types.Season) error {
+func alreadyLoaded(parsed *shotFile, loadedSeasons map[int]bool) bool {
+	for _, season := range parsed.Seasons {
+		if !loadedSeasons[season.Year] {
+			return false
+		}
+	}
+	return true
+}
 
-	// get all unique players
-	players := allPlayers(allData)
-	log.Println("Total parsed players: ", len(*players))
-	// insert into db
-	log.Println("Inserting players to the database...")
-	err := dbService.InsertPlayers(*players)
-	// log.Println("Commit transaction error for players: ", err)
+// prepareSeasonBatch resolves the game ids in the file and derives the rows for every table
+func prepareSeasonBatch(parsed *shotFile) (*types.SeasonBatch, error) {
+	identityReport, err := resolveGameIdentities(&parsed.Shots)
 	if err != nil {
-		log.Fatalf("error inserting players: %v", err)
+		return nil, fmt.Errorf("could not resolve game ids for file %s: %v", parsed.Path, err)
 	}
-	log.Printf("Inserted %v players to the players table\n", len(*players))
-
-	// get all unique teams
-	teams := allTeams(allData)
-	log.Println("Total teams: ", len(*teams))
+	identityReport.log(parsed.Path)
 
-	// insert into db
-	log.Println("Inserting teams to the database...")
-	err = dbService.InsertTeams(*teams)
+	batch := buildSeasonBatch(&parsed.Shots, parsed.Seasons, buildTeamDirectory(&parsed.Shots))
+	err = checkGameTeams(batch)
 	if err != nil {
-		log.Fatalf("error inserting teams: %v", err)
+		return nil, fmt.Errorf("file %s: %v", parsed.Path, err)
 	}
-	log.Printf("Inserted %v teams to the database\n", len(*teams))
+	return batch, nil
+}
 
-	// all unique seasons
-	log.Println("Total seasons: ", len(*seasons))
-	log.Println("Inserting seasons to the database...")
-	err = dbService.InsertSeasons(*seasons)
-	if err != nil {
-		log.Fatalf("error inserting seasons: %v", err)
+// checkGameTeams fails when a game's home or away abbreviation didn't match a team that took a shot
+// in the same season, it would be inserted with team id 0 which the foreign keys reject
+func checkGameTeams(batch *types.SeasonBatch) error {
+	var unresolved []string
+	for _, game := range batch.Games {
+		if game.HomeTeamID == 0 || game.AwayTeamID == 0 {
+			unresolved = append(unresolved, strconv.Itoa(game.ID))
+		}
 	}
-	log.Printf("Inserted %v seasons to the database\n", len(*seasons))
+	if len(unresolved) == 0 {
+		return nil
+	}
+	if len(unresolved) > maxListedIDs {
+		unresolved = append(unresolved[:maxListedIDs], fmt.Sprintf("and %d more", len(unresolved)-maxListedIDs))
+	}
+	return fmt.Errorf("could not resolve the home or away team of games %s", strings.Join(unresolved, ", "))
+}
 
-	// get all unique games
-	games := allGames(allData)
-	log.Println("Total games: ", len(*games))
-	// insert into db
-	log.Println("Inserting games to the database...")
-	err = dbService.InsertGames(*games)
+func parseShotsCSV(ctx context.Context, r io.Reader, seasons []int) ([]shotRecord, error) {
+	keepSeason := seasonFilter(seasons)
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(shotCSVColumns)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
 	if err != nil {
-		log.Fatalf("error inserting games: %v", err)
+		return nil, fmt.Errorf("could not read header: %v", err)
 	}
-	log.Printf("Inserted %v games to the database\n", len(*games))
-
-	// get cleaned shots data
-	shots := allShots(allData)
-	log.Println("Total shots: ", len(*shots))
-	// insert into db
-	log.Println("Inserting shots to the database...")
-	err = dbService.InsertShots(*shots)
+	err = validateShotCSVHeader(header)
 	if err != nil {
-		log.Fatalf("error inserting shots: %v", err)
+		return nil, err
 	}
-	log.Printf("Inserted %v shots to the database\n", len(*shots))
 
-	playerTeams := allPlayerTeams(allData)
-	log.Println("Total playerTeams: ", len(*playerTeams))
-	// insert into db
-	log.Println("Inserting shots to the database...")
-	err = dbService.InsertPlayerTeams(*playerTeams)
-	if err != nil {
-		log.Fatalf("error inserting playerSeasons: %v", err)
+	var shots []shotRecord
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// bail out of big files early when the load is cancelled
+		if line%10000 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		typedRow, err := parseShotRow(record)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		if !keepSeason(typedRow.SeasonEndYear) {
+			continue
+		}
+		shots = append(shots, typedRow)
 	}
-	log.Printf("Inserted %v player teams to the database\n", len(*playerTeams))
 
-	playerSeasons := allPlayerSeasons(allData)
-	log.Println("Total playerSeasons: ", len(*playerSeasons))
-	// insert into db
-	log.Println("Inserting player seasons to the database...")
-	err = dbService.InsertPlayerSeasons(*playerSeasons)
-	if err != nil {
-		log.Fatalf("error inserting playerSeasons: %v", err)
+	return shots, nil
+}
+
+func validateShotCSVHeader(header []string) error {
+	if len(header) != len(shotCSVColumns) {
+		return fmt.Errorf("expected %d columns, got %d", len(shotCSVColumns), len(header))
 	}
-	log.Printf("Inserted %v player seasons to the database\n", len(*playerSeasons))
+	for i, column := range shotCSVColumns {
+		if !strings.EqualFold(strings.TrimSpace(header[i]), column) {
+			return fmt.Errorf("expected column %d to be %s, got %s", i, column, header[i])
+		}
+	}
+	return nil
+}
 
-	playerGames := allPlayerGames(allData)
-	log.Println("Total playerGames: ", len(*playerGames))
-	// insert into db
-	log.Println("Inserting playerGames to the database...")
-	err = dbService.InsertPlayerGames(*playerGames)
-	if err != nil {
-		log.Fatalf("error inserting playerGames: %v", err)
+// buildSeasonBatch derives the rows of every table from the shots, teamDir has to cover
+// the seasons of the shots, see buildTeamDirectory
+func buildSeasonBatch(allData *[]shotRecord, seasons []types.Season, teamDir *teamDirectory) *types.SeasonBatch {
+	return &types.SeasonBatch{
+		Players:       *allPlayers(allData),
+		Teams:         *allTeams(allData, teamDir),
+		Seasons:       seasons,
+		Games:         *allGames(allData, teamDir),
+		Shots:         *allShots(allData, teamDir),
+		PlayerTeams:   *allPlayerTeams(allData),
+		PlayerSeasons: *allPlayerSeasons(allData),
+		PlayerGames:   *allPlayerGames(allData),
+		TeamSeasons:   *allTeamSeasons(allData, teamDir),
+		TeamGames:     *allTeamGames(allData),
+		GameSeasons:   *allGameSeasons(allData),
 	}
-	log.Printf("Inserted %v player games to the database\n", len(*playerGames))
+}
 
-	teamSeasons := allTeamSeasons(allData)
-	log.Println("Total teamSeasons: ", len(*teamSeasons))
-	// insert into db
-	log.Println("Inserting teamSeasons to the database...")
-	err = dbService.InsertTeamSeasons(*teamSeasons)
-	if err != nil {
-		log.Fatalf("error inserting teamSeasons: %v", err)
+func batchRowCounts(b *types.SeasonBatch) []types.TableCount {
+	return []types.TableCount{
+		{Table: "player", Rows: len(b.Players)},
+		{Table: "team", Rows: len(b.Teams)},
+		{Table: "season", Rows: len(b.Seasons)},
+		{Table: "game", Rows: len(b.Games)},
+		{Table: "shot", Rows: len(b.Shots)},
+		{Table: "player_team", Rows: len(b.PlayerTeams)},
+		{Table: "player_season", Rows: len(b.PlayerSeasons)},
+		{Table: "player_game", Rows: len(b.PlayerGames)},
+		{Table: "team_season", Rows: len(b.TeamSeasons)},
+		{Table: "team_game", Rows: len(b.TeamGames)},
+		{Table: "game_season", Rows: len(b.GameSeasons)},
 	}
-	log.Printf("Inserted %v team seasons to the database\n", len(*teamSeasons))
+}
+
+// uploadBatchShotData loads a whole file in one transaction, nothing from the file is kept if it fails or ctx is cancelled
+func uploadBatchShotData(ctx context.Context, dbService database.IngestWriter, batch *types.SeasonBatch, batchSize int) error {
+	counts := batchRowCounts(batch)
+	log.Printf("Inserting %v shots and their players, teams and games to the database...\n", len(batch.Shots))
 
-	teamGames := allTeamGames(allData)
-	log.Println("Total teamGames: ", len(*teamGames))
-	// insert into db
-	log.Println("Inserting teamGames to the database...")
-	err = dbService.InsertTeamGames(*teamGames)
+	err := dbService.InsertSeasonBatch(ctx, batch, batchSize)
 	if err != nil {
-		log.Fatalf("error inserting teamGames: %v", err)
+		return err
 	}
-	log.Printf("Inserted %v team games to the database\n", len(*teamGames))
 
-	gameSeasons := allGameSeasons(allData)
-	log.Println("Total gameSeasons: ", len(*gameSeasons))
-	// insert into db
-	log.Println("Inserting gameSeasons to the database...")
-	err = dbService.InsertGameSeasons(*gameSeasons)
-	if err != nil {
-		log.Fatalf("error inserting gameSeasons: %v", err)
+	for _, count := range counts {
+		log.Printf("Inserted %v rows to the %s table\n", count.Rows, count.Table)
 	}
-	log.Printf("Inserted %v game seasons to the database\n", len(*gameSeasons))
 	return nil
 }
 
-func parseShotRow(row []string) rawShotData {
-	seasonEndYear, _ := strconv.Atoi(row[0])
-	teamID, _ := strconv.Atoi(row[2])
-	playerID, _ := strconv.Atoi(row[4])
-	gameID, _ := strconv.Atoi(row[9])
-	shotMade, _ := strconv.ParseBool(row[13])
-	locX, _ := strconv.ParseFloat(row[20], 64)
-	locY, _ := strconv.ParseFloat(row[21], 64)
-	shotDistance, _ := strconv.Atoi(row[22])
-	quarter, _ := strconv.Atoi(row[23])
-	minsLeft, _ := strconv.Atoi(row[24])
-	secsLeft, _ := strconv.Atoi(row[25])
-	gameDate, _ := time.Parse("01-02-2006", row[8])
-
-	return rawShotData{
-		SeasonEndYear: seasonEndYear,
+func parseShotRow(row []string) (shotRecord, error) {
+	p := &rowParser{row: row}
+
+	shot := shotRecord{
+		SeasonEndYear: p.int(0),
 		SeasonYears:   row[1],
-		TeamID:        teamID,
+		TeamID:        p.int(2),
 		TeamName:      row[3],
-		PlayerID:      playerID,
+		PlayerID:      p.int(4),
 		PlayerName:    row[5],
 		PositionGroup: row[6],
 		Position:      row[7],
-		GameDate:      gameDate,
-		GameID:        gameID,
+		GameDate:      p.date(8, "01-02-2006"),
+		GameID:        p.int(9),
 		HomeTeam:      row[10],
 		AwayTeam:      row[11],
 		EventType:     row[12],
-		ShotMade:      shotMade,
+		ShotMade:      p.bool(13),
 		ActionType:    row[14],
 		ShotType:      row[15],
 		BasicZone:     row[16],
 		ZoneName:      row[17],
 		ZoneABB:       row[18],
 		ZoneRange:     row[19],
-		LocX:          locX,
-		LocY:          locY,
-		ShotDistance:  shotDistance,
-		Quarter:       quarter,
-		MinsLeft:      minsLeft,
-		SecsLeft:      secsLeft,
+		LocX:          p.float(20),
+		LocY:          p.float(21),
+		ShotDistance:  p.int(22),
+		Quarter:       p.int(23),
+		MinsLeft:      p.int(24),
+		SecsLeft:      p.int(25),
 	}
+
+	return shot, p.err
 }
 
-func allPlayers(data *[]rawShotData) *[]types.Player {
+// rowParser converts csv fields and keeps the first error it runs into
+type rowParser struct {
+	row []string
+	err error
+}
+
+func (p *rowParser) fail(col int, err error) {
+	if p.err == nil {
+		p.err = fmt.Errorf("invalid %s %q: %v", shotCSVColumns[col], p.row[col], err)
+	}
+}
+
+func (p *rowParser) int(col int) int {
+	v, err := strconv.Atoi(p.row[col])
+	if err != nil {
+		p.fail(col, err)
+	}
+	return v
+}
+
+func (p *rowParser) float(col int) float64 {
+	v, err := strconv.ParseFloat(p.row[col], 64)
+	if err != nil {
+		p.fail(col, err)
+	}
+	return v
+}
+
+func (p *rowParser) bool(col int) bool {
+	v, err := strconv.ParseBool(p.row[col])
+	if err != nil {
+		p.fail(col, err)
+	}
+	return v
+}
+
+func (p *rowParser) date(col int, layout string) time.Time {
+	v, err := time.Parse(layout, p.row[col])
+	if err != nil {
+		p.fail(col, err)
+	}
+	return v
+}
+
+func allPlayers(data *[]shotRecord) *[]types.Player {
 	seenPlayers := make(map[int]bool)
 	var uniquePlayers []types.Player
 	for _, shot := range *data {
@@ -306,37 +373,32 @@ func allPlayers(data *[]rawShotData) *[]types.Player {
 	return &uniquePlayers
 }
 
-// for now i will keep the latest team name with the team
-func allTeams(data *[]rawShotData) *[]types.Team {
-	seenTeams := make(map[int]string)
+// the team table keeps the name and abbreviation from the latest season in the data,
+// older ones are kept in team_identity
+func allTeams(data *[]shotRecord, teamDir *teamDirectory) *[]types.Team {
+	latestSeason := make(map[int]int)
+	latestName := make(map[int]string)
 
 	var uniqueTeams []types.Team
 	for _, shot := range *data {
-		if seenTeams[shot.TeamID] != shot.TeamName {
-			seenTeams[shot.TeamID] = shot.TeamName
+		if shot.SeasonEndYear >= latestSeason[shot.TeamID] {
+			latestSeason[shot.TeamID] = shot.SeasonEndYear
+			latestName[shot.TeamID] = shot.TeamName
 		}
 	}
 
-	for teamID, teamName := range seenTeams {
+	for teamID, teamName := range latestName {
 		uniqueTeams = append(uniqueTeams, types.Team{
 			ID:           teamID,
 			Name:         teamName,
-			Abbreviation: teamIDAbbrev[teamID],
+			Abbreviation: teamDir.abbreviation(teamID, latestSeason[teamID]),
 		})
 	}
 	return &uniqueTeams
 }
 
-// theres 5 games in 2021 with the same game_id - i'll deal with those later
-/*
-GAME_ID	GAME_DATE	HOME_TEAM	AWAY_TEAM	SEASON_1	SEASON_2
-3575310	22000000	2020-12-25	MIA	NOP	2021	2020-21
-3576179	22000000	2020-12-23	PHX	DAL	2021	2020-21
-3576180	22000000	2020-12-23	BOS	MIL	2021	2020-21
-3578254	22000000	2020-12-22	BKN	GSW	2021	2020-21
-3578256	22000000	2020-12-22	LAL	LAC	2021	2020-21
-*/
-func allGames(data *[]rawShotData) *[]types.Game {
+// game ids are unique by the time this runs, see resolveGameIdentities
+func allGames(data *[]shotRecord, teamDir *teamDirectory) *[]types.Game {
 	seenGames := make(map[int]bool)
 	var uniqueGames []types.Game
 	for _, shot := range *data {
@@ -344,8 +406,8 @@ func allGames(data *[]rawShotData) *[]types.Game {
 			seenGames[shot.GameID] = true
 			uniqueGames = append(uniqueGames, types.Game{
 				ID:         shot.GameID,
-				HomeTeamID: teamAbbrevID[shot.HomeTeam],
-				AwayTeamID: teamAbbrevID[shot.AwayTeam],
+				HomeTeamID: teamDir.teamID(shot.HomeTeam, shot.SeasonEndYear),
+				AwayTeamID: teamDir.teamID(shot.AwayTeam, shot.SeasonEndYear),
 				SeasonYear: shot.SeasonEndYear,
 				GameDate:   shot.GameDate,
 			})
@@ -354,15 +416,15 @@ func allGames(data *[]rawShotData) *[]types.Game {
 	return &uniqueGames
 }
 
-func allShots(data *[]rawShotData) *[]types.Shot {
+func allShots(data *[]shotRecord, teamDir *teamDirectory) *[]types.Shot {
 	var formattedShots []types.Shot
 	for _, shot := range *data {
 		formattedShots = append(formattedShots, types.Shot{
 			PlayerID:          shot.PlayerID,
 			GameID:            shot.GameID,
 			TeamID:            shot.TeamID,
-			HomeTeamID:        teamAbbrevID[shot.HomeTeam],
-			AwayTeamID:        teamAbbrevID[shot.AwayTeam],
+			HomeTeamID:        teamDir.teamID(shot.HomeTeam, shot.SeasonEndYear),
+			AwayTeamID:        teamDir.teamID(shot.AwayTeam, shot.SeasonEndYear),
 			SeasonYear:        shot.SeasonEndYear,
 			EventType:         shot.EventType,
 			ShotMade:          shot.ShotMade,
@@ -391,7 +453,7 @@ func generateTotalTimeInSecs(minLeft int, secLeft int) int {
 	return (minLeft * 60) + secLeft
 }
 
-func allPlayerTeams(data *[]rawShotData) *[]types.PlayerTeam {
+func allPlayerTeams(data *[]shotRecord) *[]types.PlayerTeam {
 	var playerTeams []types.PlayerTeam
 
 	// for each team check if that player has played for them
@@ -417,7 +479,7 @@ func allPlayerTeams(data *[]rawShotData) *[]types.PlayerTeam {
 	return &playerTeams
 }
 
-func allPlayerSeasons(data *[]rawShotData) *[]types.PlayerSeason {
+func allPlayerSeasons(data *[]shotRecord) *[]types.PlayerSeason {
 	// for each player check if they have played in that season
 	playerSeasons := make(map[int]map[int]bool)
 	for _, shot := range *data {
@@ -441,7 +503,7 @@ func allPlayerSeasons(data *[]rawShotData) *[]types.PlayerSeason {
 	return &playerSeason
 }
 
-func allPlayerGames(data *[]rawShotData) *[]types.PlayerGame {
+func allPlayerGames(data *[]shotRecord) *[]types.PlayerGame {
 	playerGames := make(map[int]map[int]time.Time)
 
 	for _, shot := range *data {
@@ -465,7 +527,7 @@ func allPlayerGames(data *[]rawShotData) *[]types.PlayerGame {
 	return &playerGame
 }
 
-func allTeamSeasons(data *[]rawShotData) *[]types.TeamSeason {
+func allTeamSeasons(data *[]shotRecord, teamDir *teamDirectory) *[]types.TeamSeason {
 	teamSeasons := make(map[int]map[int]string)
 
 	for _, shot := range *data {
@@ -480,9 +542,10 @@ func allTeamSeasons(data *[]rawShotData) *[]types.TeamSeason {
 		for year, name := range seasons {
 			if name != "" {
 				teamSeason = append(teamSeason, types.TeamSeason{
-					TeamID:     teamId,
-					SeasonYear: year,
-					TeamName:   name,
+					TeamID:       teamId,
+					SeasonYear:   year,
+					TeamName:     name,
+					Abbreviation: teamDir.abbreviation(teamId, year),
 				})
 			}
 		}
@@ -490,7 +553,7 @@ func allTeamSeasons(data *[]rawShotData) *[]types.TeamSeason {
 	return &teamSeason
 }
 
-func allTeamGames(data *[]rawShotData) *[]types.TeamGame {
+func allTeamGames(data *[]shotRecord) *[]types.TeamGame {
 	teamGames := make(map[int]map[int]time.Time)
 
 	for _, shot := range *data {
@@ -513,7 +576,7 @@ func allTeamGames(data *[]rawShotData) *[]types.TeamGame {
 	return &teamGame
 }
 
-func allGameSeasons(data *[]rawShotData) *[]types.GameSeason {
+func allGameSeasons(data *[]shotRecord) *[]types.GameSeason {
 	gameSeasons := make(map[int]map[int]bool)
 	for _, shot := range *data {
 		if gameSeasons[shot.GameID] == nil {
@@ -536,76 +599,6 @@ func allGameSeasons(data *[]rawShotData) *[]types.GameSeason {
 	return &gameSeasonsList
 }
 
-var teamIDAbbrev = map[int]string{
-	1610612747: "LAL",
-	1610612757: "POR",
-	1610612737: "ATL",
-	1610612738: "BOS",
-	1610612743: "DEN",
-	1610612759: "SAS",
-	1610612762: "UTA",
-	1610612763: "MEM",
-	1610612741: "CHI",
-	1610612751: "BKN",
-	1610612750: "MIN",
-	1610612748: "MIA",
-	1610612756: "PHX",
-	1610612745: "HOU",
-	1610612755: "PHI",
-	1610612764: "WAS",
-	1610612754: "IND",
-	1610612740: "NOP",
-	1610612746: "LAC",
-	1610612760: "OKC",
-	1610612758: "SAC",
-	1610612742: "DAL",
-	1610612744: "GSW",
-	1610612753: "ORL",
-	1610612749: "MIL",
-	1610612761: "TOR",
-	1610612752: "NYK",
-	1610612739: "CLE",
-	1610612765: "DET",
-	1610612766: "CHA",
-}
-
-var teamAbbrevID = map[string]int{
-	"LAL": 1610612747,
-	"POR": 1610612757,
-	"ATL": 1610612737,
-	"BOS": 1610612738,
-	"DEN": 1610612743,
-	"SAS": 1610612759,
-	"UTA": 1610612762,
-	"MEM": 1610612763,
-	"CHI": 1610612741,
-	"BKN": 1610612751,
-	"MIN": 1610612750,
-	"MIA": 1610612748,
-	"PHX": 1610612756,
-	"HOU": 1610612745,
-	"PHI": 1610612755,
-	"WAS": 1610612764,
-	"IND": 1610612754,
-	"NOP": 1610612740,
-	"LAC": 1610612746,
-	"OKC": 1610612760,
-	"SAC": 1610612758,
-	"DAL": 1610612742,
-	"GSW": 1610612744,
-	"ORL": 1610612753,
-	"MIL": 1610612749,
-	"TOR": 1610612761,
-	"NYK": 1610612752,
-	"CLE": 1610612739,
-	"DET": 1610612765,
-	"CHA": 1610612766,
-	"SEA": 1610612760,
-	"NOH": 1610612740,
-	"NJN": 1610612751,
-	"NOK": 1610612740,
-}
-
 // [ .csv format of ingest data
 // 0 SEASON_1: 2004
 // 1 SEASON_2: 2003-04
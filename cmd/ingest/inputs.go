@@ -0,0 +1,217 @@
+package main
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// shotInput is one file to ingest. It is either a plain or gzipped file on disk,
+// or an entry inside a zip archive, so the dataset never has to be extracted.
+type shotInput struct {
+	Path  string // file on disk
+	Entry string // name of the file inside the zip at Path, empty for plain files
+}
+
+func (in shotInput) String() string {
+	if in.Entry == "" {
+		return in.Path
+	}
+	return in.Path + ":" + in.Entry
+}
+
+// open returns a reader over the uncompressed file
+func (in shotInput) open() (io.ReadCloser, error) {
+	if in.Entry != "" {
+		return openZipEntry(in.Path, in.Entry)
+	}
+
+	f, err := os.Open(in.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isGzip(in.Path) {
+		return f, nil
+	}
+	return newGzipReadCloser(f)
+}
+
+func isGzip(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".gz")
+}
+
+func isZip(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".zip")
+}
+
+// inputExtensions are the file extensions read for each source, optionally followed by .gz
+var inputExtensions = map[string]string{
+	sourceCSV:       ".csv",
+	sourceStatsJSON: ".json",
+}
+
+func hasExtension(name string, ext string) bool {
+	name = strings.TrimSuffix(strings.ToLower(name), ".gz")
+	return strings.HasSuffix(name, ext)
+}
+
+// listShotInputs returns the files of the given source format in the data dir matching the glob, sorted by name.
+// Zip archives are expanded into their entries of that format, for csvs only the ones with the shot csv header are kept.
+func listShotInputs(opts *ingestOptions, source string) ([]shotInput, error) {
+	ext := inputExtensions[source]
+
+	files, err := filepath.Glob(filepath.Join(opts.DataDir, opts.FileGlob))
+	if err != nil {
+		return nil, fmt.Errorf("could not read files from directory %s: %v", opts.DataDir, err)
+	}
+
+	var inputs []shotInput
+	for _, file := range files {
+		switch {
+		case isZip(file):
+			entries, err := listZipShotEntries(file, source)
+			if err != nil {
+				return nil, err
+			}
+			inputs = append(inputs, entries...)
+		case hasExtension(file, ext):
+			inputs = append(inputs, shotInput{Path: file})
+		default:
+			log.Printf("Ignoring %s, only %s, %s.gz and .zip files are read\n", file, ext, ext)
+		}
+	}
+
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no shot files in %s match %s", opts.DataDir, opts.FileGlob)
+	}
+
+	log.Println("Files in filepath: ", inputs)
+	return inputs, nil
+}
+
+func listZipShotEntries(path string, source string) ([]shotInput, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open archive %s: %v", path, err)
+	}
+	defer archive.Close()
+
+	var inputs []shotInput
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() || !hasExtension(f.Name, inputExtensions[source]) {
+			continue
+		}
+
+		input := shotInput{Path: path, Entry: f.Name}
+		if source != sourceCSV {
+			inputs = append(inputs, input)
+			continue
+		}
+
+		ok, err := hasShotCSVHeader(input)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %v", input, err)
+		}
+		if !ok {
+			log.Printf("Ignoring %s, it is not a shot csv\n", input)
+			continue
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs, nil
+}
+
+func hasShotCSVHeader(input shotInput) (bool, error) {
+	r, err := input.open()
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+
+	header, err := csv.NewReader(r).Read()
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return validateShotCSVHeader(header) == nil, nil
+}
+
+// zipEntryReader closes the archive along with the entry
+type zipEntryReader struct {
+	io.ReadCloser
+	archive *zip.ReadCloser
+}
+
+func (z *zipEntryReader) Close() error {
+	err := z.ReadCloser.Close()
+	if aerr := z.archive.Close(); err == nil {
+		err = aerr
+	}
+	return err
+}
+
+func openZipEntry(path string, name string) (io.ReadCloser, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry *zip.File
+	for _, f := range archive.File {
+		if f.Name == name {
+			entry = f
+			break
+		}
+	}
+	if entry == nil {
+		archive.Close()
+		return nil, fmt.Errorf("%s is not in the archive", name)
+	}
+
+	r, err := entry.Open()
+	if err != nil {
+		archive.Close()
+		return nil, err
+	}
+
+	if isGzip(name) {
+		r, err = newGzipReadCloser(r)
+		if err != nil {
+			archive.Close()
+			return nil, err
+		}
+	}
+	return &zipEntryReader{ReadCloser: r, archive: archive}, nil
+}
+
+// gzipReadCloser closes the underlying file along with the gzip reader
+type gzipReadCloser struct {
+	*gzip.Reader
+	src io.Closer
+}
+
+func (g *gzipReadCloser) Close() error {
+	err := g.Reader.Close()
+	if serr := g.src.Close(); err == nil {
+		err = serr
+	}
+	return err
+}
+
+func newGzipReadCloser(src io.ReadCloser) (io.ReadCloser, error) {
+	gz, err := gzip.NewReader(src)
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	return &gzipReadCloser{Reader: gz, src: src}, nil
+}
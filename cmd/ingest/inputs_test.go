@@ -0,0 +1,76 @@
+package main
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testShotCSV = `SEASON_1,SEASON_2,TEAM_ID,TEAM_NAME,PLAYER_ID,PLAYER_NAME,POSITION_GROUP,POSITION,GAME_DATE,GAME_ID,HOME_TEAM,AWAY_TEAM,EVENT_TYPE,SHOT_MADE,ACTION_TYPE,SHOT_TYPE,BASIC_ZONE,ZONE_NAME,ZONE_ABB,ZONE_RANGE,LOC_X,LOC_Y,SHOT_DISTANCE,QUARTER,MINS_LEFT,SECS_LEFT
+2004,2003-04,1610612747,Los Angeles Lakers,977,Kobe Bryant,G,SG,04-14-2004,20301187,POR,LAL,Made Shot,TRUE,Jump Shot,3PT Field Goal,Above the Break 3,Left Side Center,LC,24+ ft.,20,21.35,25,4,0,10
+`
+
+func TestListShotInputsCompressed(t *testing.T) {
+	dir := t.TempDir()
+
+	// a gzipped csv
+	gzFile, err := os.Create(filepath.Join(dir, "NBA_2004_Shots.csv.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(gzFile)
+	gz.Write([]byte(testShotCSV))
+	gz.Close()
+	gzFile.Close()
+
+	// a zip with a shot csv, a gzipped shot csv and a csv that isn't shots
+	zipFile, err := os.Create(filepath.Join(dir, "archive.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(zipFile)
+	w, _ := zw.Create("nbashots/NBA_2004_Shots.csv")
+	w.Write([]byte(testShotCSV))
+	w, _ = zw.Create("nbashots/NBA_2004_Shots_again.csv.gz")
+	gz = gzip.NewWriter(w)
+	gz.Write([]byte(testShotCSV))
+	gz.Close()
+	w, _ = zw.Create("nbashots/players.csv")
+	w.Write([]byte("PLAYER_ID,PLAYER_NAME\n977,Kobe Bryant\n"))
+	zw.Close()
+	zipFile.Close()
+
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("not data"), 0o644)
+
+	inputs, err := listShotInputs(&ingestOptions{DataDir: dir, FileGlob: "*"}, sourceCSV)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "NBA_2004_Shots.csv.gz"),
+		filepath.Join(dir, "archive.zip") + ":nbashots/NBA_2004_Shots.csv",
+		filepath.Join(dir, "archive.zip") + ":nbashots/NBA_2004_Shots_again.csv.gz",
+	}
+	if len(inputs) != len(expected) {
+		t.Fatalf("expected %d inputs, got %v", len(expected), inputs)
+	}
+
+	for i, input := range inputs {
+		if input.String() != expected[i] {
+			t.Errorf("expected input %d to be %s, got %s", i, expected[i], input)
+		}
+
+		shots, err := csvSource{input}.Shots(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("could not parse %s: %v", input, err)
+		}
+		if len(shots) != 1 || !strings.EqualFold(shots[0].PlayerName, "Kobe Bryant") {
+			t.Errorf("unexpected shots from %s: %+v", input, shots)
+		}
+	}
+}
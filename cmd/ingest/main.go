@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"nba-shots/internal/config"
+	"nba-shots/internal/database"
+	"nba-shots/internal/database/cache"
+)
+
+const usage = `Usage: ingest [command] [flags]
+
+Commands:
+  load         parse the shot files and load them into the database (default)
+  verify       check the loaded data makes sense and write a data quality report
+  stats        print per-season row counts from the database
+  drop-season  delete every row belonging to a season
+  replace      delete a season and load it again from the shot files in one transaction
+  watch        keep loading new games from the sources on a schedule
+  migrate      apply, revert or list the schema migrations (up, down, status)
+  query-report list the most common shot query filters from the query history
+
+Run 'ingest <command> -h' to see the flags for a command.
+`
+
+type ingestOptions struct {
+	Source     string
+	DataDir    string
+	FileGlob   string
+	StatsURL   string
+	Seasons    []int
+	BatchSize  int
+	Workers    int
+	DryRun     bool
+	CPUProfile string
+	MemProfile string
+
+	// the command's flags, the database config is read from them
+	flags *flag.FlagSet
+}
+
+// seasonsFlag parses a comma separated list of season end years, ex. 2004,2005
+type seasonsFlag []int
+
+func (f *seasonsFlag) String() string {
+	years := make([]string, len(*f))
+	for i, year := range *f {
+		years[i] = strconv.Itoa(year)
+	}
+	return strings.Join(years, ",")
+}
+
+func (f *seasonsFlag) Set(value string) error {
+	for _, s := range strings.Split(value, ",") {
+		year, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("invalid season %q, expected the end year ex. 2004", s)
+		}
+		*f = append(*f, year)
+	}
+	return nil
+}
+
+func newFlagSet(name string, opts *ingestOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.StringVar(&opts.CPUProfile, "cpuprofile", "", "write a cpu profile to this file")
+	fs.StringVar(&opts.MemProfile, "memprofile", "", "write a heap profile to this file")
+	config.AddFlags(fs, config.DatabaseFlags, config.CacheFlags)
+	opts.flags = fs
+	return fs
+}
+
+// addFileFlags registers the flags for commands that read the shot files
+func addFileFlags(fs *flag.FlagSet, opts *ingestOptions) {
+	fs.StringVar(&opts.Source, "source", sourceCSV, "format of the shot data, csv (kaggle) or stats-json (nba stats shotchartdetail responses)")
+	fs.StringVar(&opts.DataDir, "data-dir", filepath.Join("raw_data", "nbashots"), "directory containing the shot files")
+	fs.StringVar(&opts.FileGlob, "glob", "*", "pattern of the files to read within the data dir, plain, .gz or .zip")
+	fs.StringVar(&opts.StatsURL, "stats-url", "", "with -source stats-json, fetch the -seasons from the shotchartdetail endpoint under this url instead of reading files")
+	fs.Var((*seasonsFlag)(&opts.Seasons), "seasons", "comma separated season end years to ingest, ex. 2004,2005 (default all)")
+	fs.IntVar(&opts.Workers, "workers", runtime.NumCPU(), "number of files to parse in parallel")
+}
+
+// openDatabase connects with the config from the command's flags, the environment and the
+// config file, the caller closes it
+func openDatabase(ctx context.Context, opts *ingestOptions) (database.Service, error) {
+	cfg, err := config.Load(opts.flags)
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.Database.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid config:\n%v", err)
+	}
+	dbService, err := database.New(ctx, cfg.Database)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to the database: %v", err)
+	}
+	return dbService, nil
+}
+
+// invalidateCache drops the api's cached results once a command changed the database. Only a
+// shared backend can be reached from here, the api's lru cache expires on its own or with
+// POST /admin/cache/invalidate.
+func invalidateCache(ctx context.Context, opts *ingestOptions) {
+	cfg, err := config.Load(opts.flags)
+	if err != nil {
+		log.Printf("could not invalidate the cache: %v", err)
+		return
+	}
+	if cfg.Cache.Backend != "redis" {
+		return
+	}
+
+	backend, err := cache.NewBackend(cfg.Cache)
+	if err != nil {
+		log.Printf("could not invalidate the cache: %v", err)
+		return
+	}
+	defer backend.Close()
+
+	// the load may have been cancelled, the rows it committed are still in the database
+	err = backend.Invalidate(context.WithoutCancel(ctx))
+	if err != nil {
+		log.Printf("could not invalidate the cache: %v", err)
+		return
+	}
+	log.Println("Invalidated the api cache")
+}
+
+func main() {
+	log.Println("Starting ingest script 1")
+
+	command := "load"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	// ctrl+c stops the workers and rolls back the file being loaded
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch command {
+	case "load":
+		err = runLoad(ctx, args)
+	case "verify":
+		err = runVerify(ctx, args)
+	case "stats":
+		err = runStats(ctx, args)
+	case "drop-season":
+		err = runDropSeason(ctx, args)
+	case "replace":
+		err = runReplace(ctx, args)
+	case "watch":
+		err = runWatch(ctx, args)
+	case "migrate":
+		err = runMigrate(ctx, args)
+	case "query-report":
+		err = runQueryReport(ctx, args)
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		stop()
+		log.Fatalf("%s: %v", command, err)
+	}
+}
+
+// withProfiling runs fn while recording the profiles requested in opts
+func withProfiling(opts *ingestOptions, fn func() error) error {
+	if opts.CPUProfile != "" {
+		f, err := os.Create(opts.CPUProfile)
+		if err != nil {
+			return fmt.Errorf("could not create cpu profile: %v", err)
+		}
+		defer f.Close()
+
+		err = pprof.StartCPUProfile(f)
+		if err != nil {
+			return fmt.Errorf("could not start cpu profile: %v", err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	err := fn()
+
+	if opts.MemProfile != "" {
+		f, perr := os.Create(opts.MemProfile)
+		if perr != nil {
+			log.Printf("could not create heap profile: %v", perr)
+			return err
+		}
+		defer f.Close()
+
+		perr = pprof.WriteHeapProfile(f)
+		if perr != nil {
+			log.Printf("could not write heap profile: %v", perr)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+const migrateUsage = `Usage: ingest migrate <up|down|status> [flags]
+
+  up      apply every pending migration
+  down    revert the last -steps migrations (default 1)
+  status  list the migrations and when they were applied
+`
+
+// runMigrate applies, reverts or lists the schema migrations embedded in the binary
+func runMigrate(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return fmt.Errorf("missing migrate direction")
+	}
+	direction, args := args[0], args[1:]
+
+	opts := &ingestOptions{}
+	fs := newFlagSet("migrate "+direction, opts)
+	steps := fs.Int("steps", 1, "number of migrations to revert with down")
+	fs.Parse(args)
+
+	return withProfiling(opts, func() error {
+		switch direction {
+		case "up":
+			dbService, err := openDatabase(ctx, opts)
+			if err != nil {
+				return err
+			}
+			defer dbService.Close()
+
+			applied, err := dbService.MigrateUp(ctx)
+			if err != nil {
+				return err
+			}
+			if len(applied) == 0 {
+				log.Println("Database is up to date")
+			}
+			for _, version := range applied {
+				log.Println("Applied migration:", version)
+			}
+			return nil
+
+		case "down":
+			if *steps <= 0 {
+				return fmt.Errorf("steps must be positive, got %d", *steps)
+			}
+
+			dbService, err := openDatabase(ctx, opts)
+			if err != nil {
+				return err
+			}
+			defer dbService.Close()
+
+			reverted, err := dbService.MigrateDown(ctx, *steps)
+			if err != nil {
+				return err
+			}
+			for _, version := range reverted {
+				log.Println("Reverted migration:", version)
+			}
+			return nil
+
+		case "status":
+			dbService, err := openDatabase(ctx, opts)
+			if err != nil {
+				return err
+			}
+			defer dbService.Close()
+
+			statuses, err := dbService.MigrationStatus(ctx)
+			if err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(tw, "version\tstatus\tapplied at\t\n")
+			for _, status := range statuses {
+				appliedAt := ""
+				if status.AppliedAt != nil {
+					appliedAt = status.AppliedAt.Local().Format("2006-01-02 15:04:05")
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t\n", status.Version, status.Status, appliedAt)
+			}
+			return tw.Flush()
+		}
+
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return fmt.Errorf("unknown migrate direction %q", direction)
+	})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"log"
+	"nba-shots/internal/types"
+	"sync"
+)
+
+// preparedFile is a shot source after a worker has read it and derived its rows
+type preparedFile struct {
+	Path    string
+	Seasons []types.Season
+	Batch   *types.SeasonBatch
+	Skip    string // reason the file doesn't need to be loaded, Batch is nil when set
+	Err     error
+}
+
+type prepareFunc func(ctx context.Context, src shotSource) *preparedFile
+
+// filePipeline parses files on a bounded pool of workers and hands them back in file order.
+// A worker only picks up a new file once the coordinator has taken one off its hands, so at
+// most `workers` prepared files are held in memory while the coordinator is busy loading.
+type filePipeline struct {
+	results []chan *preparedFile
+	slots   chan struct{}
+	next    int
+	wg      sync.WaitGroup
+}
+
+func startFilePipeline(ctx context.Context, files []shotSource, workers int, prepare prepareFunc) *filePipeline {
+	workers = max(1, min(workers, len(files)))
+
+	p := &filePipeline{
+		results: make([]chan *preparedFile, len(files)),
+		slots:   make(chan struct{}, workers),
+	}
+	for i := range p.results {
+		p.results[i] = make(chan *preparedFile, 1)
+	}
+
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i := range files {
+			select {
+			case <-ctx.Done():
+				return
+			case p.slots <- struct{}{}:
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- i:
+			}
+		}
+	}()
+
+	p.wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer p.wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					p.results[i] <- &preparedFile{Path: files[i].String(), Err: ctx.Err()}
+					continue
+				}
+				p.results[i] <- prepare(ctx, files[i])
+			}
+		}()
+	}
+
+	return p
+}
+
+// Next blocks until the next file in order is prepared. It returns false once every file has been handed out.
+// Errors are returned in file order too, so a failure in a later file never hides one in an earlier file.
+func (p *filePipeline) Next(ctx context.Context) (*preparedFile, bool, error) {
+	if p.next >= len(p.results) {
+		return nil, false, nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	case prepared := <-p.results[p.next]:
+		p.next++
+		<-p.slots
+		return prepared, true, nil
+	}
+}
+
+// Wait blocks until every worker has exited, the context passed to startFilePipeline
+// has to be cancelled first if not every file was taken with Next
+func (p *filePipeline) Wait() {
+	p.wg.Wait()
+}
+
+// prepareForLoad returns the prepareFunc used by load and verify, files whose seasons
+// are all in skipSeasons aren't turned into rows
+func prepareForLoad(opts *ingestOptions, skipSeasons map[int]bool) prepareFunc {
+	return func(ctx context.Context, src shotSource) *preparedFile {
+		shots, err := src.Shots(ctx, opts.Seasons)
+		if err != nil {
+			return &preparedFile{Path: src.String(), Err: err}
+		}
+		parsed := newShotFile(src.String(), shots)
+
+		prepared := &preparedFile{Path: parsed.Path, Seasons: parsed.Seasons}
+
+		if len(parsed.Shots) == 0 {
+			prepared.Skip = "no shots for the requested seasons"
+			return prepared
+		}
+
+		if alreadyLoaded(parsed, skipSeasons) {
+			prepared.Skip = "seasons are already loaded"
+			return prepared
+		}
+
+		prepared.Batch, err = prepareSeasonBatch(parsed)
+		if err != nil {
+			prepared.Err = err
+		}
+		return prepared
+	}
+}
+
+func (pf *preparedFile) logSkip() {
+	log.Printf("Skipping %s: %s\n", pf.Path, pf.Skip)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFilePipelineOrder(t *testing.T) {
+	files := make([]shotSource, 20)
+	for i := range files {
+		files[i] = csvSource{shotInput{Path: fmt.Sprintf("file_%02d.csv", i)}}
+	}
+
+	var inFlight, maxInFlight atomic.Int32
+	prepare := func(ctx context.Context, src shotSource) *preparedFile {
+		n := inFlight.Add(1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		// later files finish first so the pipeline has to put them back in order
+		var idx int
+		fmt.Sscanf(src.String(), "file_%d.csv", &idx)
+		time.Sleep(time.Duration(len(files)-idx) * time.Millisecond)
+		inFlight.Add(-1)
+		return &preparedFile{Path: src.String()}
+	}
+
+	ctx := context.Background()
+	pipeline := startFilePipeline(ctx, files, 4, prepare)
+	defer pipeline.Wait()
+
+	for i := 0; ; i++ {
+		prepared, ok, err := pipeline.Next(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !ok {
+			if i != len(files) {
+				t.Fatalf("expected %d files, got %d", len(files), i)
+			}
+			break
+		}
+		if prepared.Path != files[i].String() {
+			t.Fatalf("expected %s at position %d, got %s", files[i], i, prepared.Path)
+		}
+	}
+
+	if maxInFlight.Load() > 4 {
+		t.Errorf("expected at most 4 files in flight, got %d", maxInFlight.Load())
+	}
+}
+
+func TestFilePipelineFirstErrorWins(t *testing.T) {
+	var files []shotSource
+	for _, path := range []string{"a", "b", "c", "d", "e"} {
+		files = append(files, csvSource{shotInput{Path: path}})
+	}
+	errEarly, errLate := errors.New("early"), errors.New("late")
+
+	prepare := func(ctx context.Context, src shotSource) *preparedFile {
+		switch src.String() {
+		case "b":
+			time.Sleep(20 * time.Millisecond)
+			return &preparedFile{Path: src.String(), Err: errEarly}
+		case "c":
+			return &preparedFile{Path: src.String(), Err: errLate}
+		}
+		return &preparedFile{Path: src.String()}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pipeline := startFilePipeline(ctx, files, 3, prepare)
+	defer pipeline.Wait()
+	defer cancel()
+
+	var firstErr error
+	for firstErr == nil {
+		prepared, ok, err := pipeline.Next(ctx)
+		if err != nil || !ok {
+			t.Fatalf("pipeline ended before an error: %v", err)
+		}
+		firstErr = prepared.Err
+	}
+
+	if !errors.Is(firstErr, errEarly) {
+		t.Errorf("expected the error from the earlier file, got %v", firstErr)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"nba-shots/internal/types"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	qualityPass = "pass"
+	qualityWarn = "warn"
+	qualityFail = "fail"
+)
+
+// thresholds of the data quality checks
+const (
+	// a regulation game has around 170 field goal attempts, overtimes push it past 200
+	minShotsPerGame = 120
+	maxShotsPerGame = 260
+	// shortened seasons (2012, 2020) still have every team within a few games of each other
+	minTeamGamesRatio = 0.8
+	// some disagreement between shot_type and the zones is in the source data, a lot of it means a bad load
+	maxBadShotsRatio = 0.01
+	// ids listed in a check result before it is cut off
+	maxListedIDs = 10
+)
+
+// qualityCheck is the outcome of one data quality check for a season
+type qualityCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Result string `json:"result"`
+}
+
+type seasonQualityReport struct {
+	Season int            `json:"season"`
+	Shots  int            `json:"shots"`
+	Games  int            `json:"games"`
+	Checks []qualityCheck `json:"checks"`
+}
+
+type qualityReport struct {
+	GeneratedAt time.Time             `json:"generated_at"`
+	Seasons     []seasonQualityReport `json:"seasons"`
+	Warnings    int                   `json:"warnings"`
+	Failures    int                   `json:"failures"`
+}
+
+func (r *qualityReport) add(season seasonQualityReport) {
+	for _, check := range season.Checks {
+		switch check.Status {
+		case qualityWarn:
+			r.Warnings++
+		case qualityFail:
+			r.Failures++
+		}
+	}
+	r.Seasons = append(r.Seasons, season)
+}
+
+// checkSeasonQuality runs every check against the numbers gathered for a season
+func checkSeasonQuality(q *types.SeasonQuality) seasonQualityReport {
+	return seasonQualityReport{
+		Season: q.Year,
+		Shots:  q.Shots,
+		Games:  len(q.ShotsPerGame),
+		Checks: []qualityCheck{
+			checkShotsPerGame(q.ShotsPerGame),
+			checkGamesPerTeam(q.GamesPerTeam),
+			checkIDs("players without shots", q.PlayersWithoutShots, "player_season rows"),
+			checkRatio("shot type vs zone and distance", q.ShotTypeMismatches, q.Shots),
+			checkRatio("shots outside the half court", q.OutOfBoundsShots, q.Shots),
+			checkIDs("games without home or away team", q.GamesWithoutTeams, "games"),
+		},
+	}
+}
+
+func checkShotsPerGame(shotsPerGame map[int]int) qualityCheck {
+	check := qualityCheck{Name: "shots per game", Status: qualityPass}
+	if len(shotsPerGame) == 0 {
+		check.Status, check.Result = qualityFail, "no games"
+		return check
+	}
+
+	counts := make([]int, 0, len(shotsPerGame))
+	empty, outside := 0, 0
+	for _, shots := range shotsPerGame {
+		counts = append(counts, shots)
+		if shots == 0 {
+			empty++
+		}
+		if shots < minShotsPerGame || shots > maxShotsPerGame {
+			outside++
+		}
+	}
+	sort.Ints(counts)
+
+	check.Result = fmt.Sprintf("min %d, median %d, max %d", counts[0], counts[len(counts)/2], counts[len(counts)-1])
+	switch {
+	case empty > 0:
+		check.Status = qualityFail
+		check.Result += fmt.Sprintf(", %d games have no shots", empty)
+	case outside > 0:
+		check.Status = qualityWarn
+		check.Result += fmt.Sprintf(", %d games outside %d-%d", outside, minShotsPerGame, maxShotsPerGame)
+	}
+	return check
+}
+
+func checkGamesPerTeam(gamesPerTeam map[int]int) qualityCheck {
+	check := qualityCheck{Name: "games per team", Status: qualityPass}
+	if len(gamesPerTeam) == 0 {
+		check.Status, check.Result = qualityFail, "no teams"
+		return check
+	}
+
+	fewest, most := -1, 0
+	for _, games := range gamesPerTeam {
+		if fewest == -1 || games < fewest {
+			fewest = games
+		}
+		most = max(most, games)
+	}
+
+	var short []int
+	for teamID, games := range gamesPerTeam {
+		if float64(games) < float64(most)*minTeamGamesRatio {
+			short = append(short, teamID)
+		}
+	}
+	sort.Ints(short)
+
+	check.Result = fmt.Sprintf("%d teams, %d-%d games", len(gamesPerTeam), fewest, most)
+	if len(short) > 0 {
+		check.Status = qualityWarn
+		check.Result += fmt.Sprintf(", teams with under %.0f%% of the most games: %s", minTeamGamesRatio*100, listIDs(short))
+	}
+	return check
+}
+
+// checkIDs fails when there are any ids, they can only come from a broken load
+func checkIDs(name string, ids []int, what string) qualityCheck {
+	if len(ids) == 0 {
+		return qualityCheck{Name: name, Status: qualityPass, Result: "none"}
+	}
+	return qualityCheck{
+		Name:   name,
+		Status: qualityFail,
+		Result: fmt.Sprintf("%d %s: %s", len(ids), what, listIDs(ids)),
+	}
+}
+
+// checkRatio warns about any bad shots and fails once they are more than maxBadShotsRatio of the season
+func checkRatio(name string, bad int, total int) qualityCheck {
+	check := qualityCheck{Name: name, Status: qualityPass, Result: "none"}
+	if bad == 0 {
+		return check
+	}
+
+	ratio := 1.0
+	if total > 0 {
+		ratio = float64(bad) / float64(total)
+	}
+	check.Result = fmt.Sprintf("%d shots (%.2f%%)", bad, ratio*100)
+	check.Status = qualityWarn
+	if ratio > maxBadShotsRatio {
+		check.Status = qualityFail
+	}
+	return check
+}
+
+func listIDs(ids []int) string {
+	listed := make([]string, 0, maxListedIDs)
+	for i, id := range ids {
+		if i == maxListedIDs {
+			listed = append(listed, fmt.Sprintf("and %d more", len(ids)-maxListedIDs))
+			break
+		}
+		listed = append(listed, fmt.Sprint(id))
+	}
+	return strings.Join(listed, ", ")
+}
+
+func (r *qualityReport) writeJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+func (r *qualityReport) writeMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Data quality report\n\n")
+	fmt.Fprintf(&b, "Generated %s, %d failures, %d warnings.\n", r.GeneratedAt.Format(time.RFC3339), r.Failures, r.Warnings)
+
+	for _, season := range r.Seasons {
+		fmt.Fprintf(&b, "\n## Season %d\n\n", season.Season)
+		fmt.Fprintf(&b, "%d shots in %d games.\n\n", season.Shots, season.Games)
+		fmt.Fprintf(&b, "| Check | Status | Result |\n")
+		fmt.Fprintf(&b, "| --- | --- | --- |\n")
+		for _, check := range season.Checks {
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", check.Name, check.Status, check.Result)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
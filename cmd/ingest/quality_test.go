@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"nba-shots/internal/types"
+	"strings"
+	"testing"
+)
+
+func TestCheckSeasonQuality(t *testing.T) {
+	q := &types.SeasonQuality{
+		Year:               2004,
+		Shots:              1000,
+		ShotsPerGame:       map[int]int{1: 170, 2: 165, 3: 300},
+		GamesPerTeam:       map[int]int{10: 82, 11: 81, 12: 40},
+		ShotTypeMismatches: 5,
+		OutOfBoundsShots:   50,
+		GamesWithoutTeams:  []int{3},
+	}
+
+	report := &qualityReport{}
+	report.add(checkSeasonQuality(q))
+
+	expected := map[string]string{
+		"shots per game":                  qualityWarn,
+		"games per team":                  qualityWarn,
+		"players without shots":           qualityPass,
+		"shot type vs zone and distance":  qualityWarn,
+		"shots outside the half court":    qualityFail,
+		"games without home or away team": qualityFail,
+	}
+
+	checks := report.Seasons[0].Checks
+	if len(checks) != len(expected) {
+		t.Fatalf("expected %d checks, got %+v", len(expected), checks)
+	}
+	for _, check := range checks {
+		if check.Status != expected[check.Name] {
+			t.Errorf("expected %s to %s, got %s (%s)", check.Name, expected[check.Name], check.Status, check.Result)
+		}
+	}
+
+	if report.Warnings != 3 || report.Failures != 2 {
+		t.Errorf("expected 3 warnings and 2 failures, got %d and %d", report.Warnings, report.Failures)
+	}
+
+	var b bytes.Buffer
+	err := report.writeMarkdown(&b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(b.String(), "| games without home or away team | fail | 1 games: 3 |") {
+		t.Errorf("expected the failed check in the markdown report, got:\n%s", b.String())
+	}
+}
+
+func TestCheckShotsPerGameEmptyGame(t *testing.T) {
+	check := checkShotsPerGame(map[int]int{1: 170, 2: 0})
+	if check.Status != qualityFail {
+		t.Errorf("expected a game without shots to fail, got %+v", check)
+	}
+}
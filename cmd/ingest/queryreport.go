@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"nba-shots/internal/types"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// queryUsage is a combination of filters from query_history and the index the shot query should use for it
+type queryUsage struct {
+	types.QueryFilterUsage
+	Share float64 `json:"share"`
+	Index string  `json:"index"`
+}
+
+type queryReport struct {
+	GeneratedAt  time.Time    `json:"generated_at"`
+	Queries      int          `json:"queries"`
+	Combinations []queryUsage `json:"combinations"`
+}
+
+func newQueryReport(usage []types.QueryFilterUsage) *queryReport {
+	report := &queryReport{GeneratedAt: time.Now().UTC()}
+	for _, u := range usage {
+		report.Queries += u.Queries
+	}
+	for _, u := range usage {
+		report.Combinations = append(report.Combinations, queryUsage{
+			QueryFilterUsage: u,
+			Share:            float64(u.Queries) / float64(report.Queries),
+			Index:            shotQueryIndex(u.Filters),
+		})
+	}
+	return report
+}
+
+// shotQueryIndex is the index from the migrations a shot query with these filters is expected to use,
+// the covering ones answer it without reading the table
+func shotQueryIndex(filters []string) string {
+	switch {
+	case slices.Contains(filters, types.FilterPlayer):
+		return "idx_shot_player_season (covering)"
+	case slices.Contains(filters, types.FilterTeam):
+		return "idx_shot_team_season (covering)"
+	case slices.Contains(filters, types.FilterSeason):
+		return "season partition scan"
+	case slices.Contains(filters, types.FilterGameDate):
+		return "idx_shot_game_date"
+	default:
+		return "none"
+	}
+}
+
+func runQueryReport(ctx context.Context, args []string) error {
+	opts := &ingestOptions{}
+	fs := newFlagSet("query-report", opts)
+	limit := fs.Int("limit", 20, "number of filter combinations to list")
+	format := fs.String("format", "markdown", "format of the report, markdown or json")
+	out := fs.String("out", "", "write the report to this file instead of stdout")
+	fs.Parse(args)
+
+	if *format != "markdown" && *format != "json" {
+		return fmt.Errorf("unknown report format %q, expected markdown or json", *format)
+	}
+
+	return withProfiling(opts, func() error {
+		dbService, err := openDatabase(ctx, opts)
+		if err != nil {
+			return err
+		}
+		defer dbService.Close()
+
+		usage, err := dbService.GetQueryFilterUsage(ctx, *limit)
+		if err != nil {
+			return fmt.Errorf("could not get the query history: %v", err)
+		}
+		report := newQueryReport(usage)
+
+		w := io.Writer(os.Stdout)
+		if *out != "" {
+			f, err := os.Create(*out)
+			if err != nil {
+				return fmt.Errorf("could not create report: %v", err)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		if *format == "json" {
+			err = report.writeJSON(w)
+		} else {
+			err = report.writeMarkdown(w)
+		}
+		if err != nil {
+			return fmt.Errorf("could not write report: %v", err)
+		}
+		return nil
+	})
+}
+
+func (r *queryReport) writeJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+func (r *queryReport) writeMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Shot query filters\n\n")
+	fmt.Fprintf(&b, "Generated %s from %d logged queries.\n\n", r.GeneratedAt.Format(time.RFC3339), r.Queries)
+	fmt.Fprintf(&b, "| Filters | Queries | Share | Avg shots | Last queried | Index |\n")
+	fmt.Fprintf(&b, "| --- | --- | --- | --- | --- | --- |\n")
+	for _, c := range r.Combinations {
+		filters := "none"
+		if len(c.Filters) > 0 {
+			filters = strings.Join(c.Filters, ", ")
+		}
+		fmt.Fprintf(&b, "| %s | %d | %.1f%% | %.0f | %s | %s |\n",
+			filters, c.Queries, c.Share*100, c.AvgShots, c.LastQueried.Format(time.DateOnly), c.Index)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"nba-shots/internal/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryReport(t *testing.T) {
+	last := time.Date(2024, 4, 14, 0, 0, 0, 0, time.UTC)
+	report := newQueryReport([]types.QueryFilterUsage{
+		{Filters: []string{"player", "season"}, Queries: 6, AvgShots: 1200, LastQueried: last},
+		{Filters: []string{"team", "season", "quarter"}, Queries: 3, AvgShots: 800, LastQueried: last},
+		{Filters: []string{}, Queries: 1, AvgShots: 200000, LastQueried: last},
+	})
+
+	if report.Queries != 10 {
+		t.Fatalf("expected 10 queries, got %d", report.Queries)
+	}
+	expected := []string{"idx_shot_player_season (covering)", "idx_shot_team_season (covering)", "none"}
+	for i, c := range report.Combinations {
+		if c.Index != expected[i] {
+			t.Errorf("expected %v to use %s, got %s", c.Filters, expected[i], c.Index)
+		}
+	}
+
+	var b bytes.Buffer
+	err := report.writeMarkdown(&b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(b.String(), "| player, season | 6 | 60.0% | 1200 | 2024-04-14 | idx_shot_player_season (covering) |") {
+		t.Errorf("expected the player season row in the markdown report, got:\n%s", b.String())
+	}
+}
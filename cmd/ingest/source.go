@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// formats that -source accepts
+const (
+	sourceCSV       = "csv"
+	sourceStatsJSON = "stats-json"
+)
+
+// shotRecord is one shot attempt, normalized to the kaggle csv conventions no matter
+// which source it came from. Locations are in feet with the hoop at (0, 5.25).
+type shotRecord struct {
+	PlayerID      int       `db:"player_id"`
+	PlayerName    string    `db:"player_name"`
+	GameID        int       `db:"game_id"`
+	TeamID        int       `db:"team_id"`
+	TeamName      string    `db:"team_name"`
+	SeasonEndYear int       `db:"season_end_year"`
+	SeasonYears   string    `db:"season_years"`
+	EventType     string    `db:"event_type"`
+	ShotMade      bool      `db:"shot_made"`
+	ActionType    string    `db:"action_type"`
+	ShotType      string    `db:"shot_type"`
+	BasicZone     string    `db:"basic_zone"`
+	ZoneName      string    `db:"zone_name"`
+	ZoneABB       string    `db:"zone_abb"`
+	ZoneRange     string    `db:"zone_range"`
+	LocX          float64   `db:"loc_x"`
+	LocY          float64   `db:"loc_y"`
+	ShotDistance  int       `db:"shot_distance"`
+	Quarter       int       `db:"qtr"`
+	MinsLeft      int       `db:"mins_left"`
+	SecsLeft      int       `db:"secs_left"`
+	Position      string    `db:"position"`
+	PositionGroup string    `db:"position_group"`
+	GameDate      time.Time `db:"game_date"`
+	HomeTeam      string    `db:"home_team"`
+	AwayTeam      string    `db:"away_team"`
+}
+
+// shotSource is anything the shots can be read from. Every source is read in full by
+// one worker and turned into one batch, so a source is usually a season.
+type shotSource interface {
+	// String identifies the source in logs and reports
+	String() string
+	// Shots reads every shot in the source, only keeping the given seasons when there are any
+	Shots(ctx context.Context, seasons []int) ([]shotRecord, error)
+}
+
+// csvSource reads a kaggle csv
+type csvSource struct {
+	shotInput
+}
+
+func (s csvSource) Shots(ctx context.Context, seasons []int) ([]shotRecord, error) {
+	log.Println("Opening file: ", s)
+	f, err := s.open()
+	if err != nil {
+		return nil, fmt.Errorf("could not open file %s: %v", s, err)
+	}
+	defer f.Close()
+
+	shots, err := parseShotsCSV(ctx, f, seasons)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse file %s: %v", s, err)
+	}
+
+	log.Println("This files slice length: ", len(shots))
+	return shots, nil
+}
+
+// listShotSources returns the sources described by the -source, -data-dir, -glob and -stats-url flags
+func listShotSources(opts *ingestOptions) ([]shotSource, error) {
+	switch opts.Source {
+	case sourceCSV, "":
+		inputs, err := listShotInputs(opts, sourceCSV)
+		if err != nil {
+			return nil, err
+		}
+		sources := make([]shotSource, len(inputs))
+		for i, input := range inputs {
+			sources[i] = csvSource{input}
+		}
+		return sources, nil
+
+	case sourceStatsJSON:
+		// one request per season, the endpoint doesn't return more than one at a time
+		if opts.StatsURL != "" {
+			if len(opts.Seasons) == 0 {
+				return nil, fmt.Errorf("-stats-url needs -seasons to know which seasons to fetch")
+			}
+			sources := make([]shotSource, len(opts.Seasons))
+			for i, season := range opts.Seasons {
+				sources[i] = newStatsHTTPSource(opts.StatsURL, season)
+			}
+			return sources, nil
+		}
+
+		inputs, err := listShotInputs(opts, sourceStatsJSON)
+		if err != nil {
+			return nil, err
+		}
+		sources := make([]shotSource, len(inputs))
+		for i, input := range inputs {
+			sources[i] = statsFileSource{input}
+		}
+		return sources, nil
+	}
+
+	return nil, fmt.Errorf("unknown source %q, expected %s or %s", opts.Source, sourceCSV, sourceStatsJSON)
+}
+
+// seasonFilter reports whether a season should be kept, every season is kept when seasons is empty
+func seasonFilter(seasons []int) func(int) bool {
+	keep := make(map[int]bool)
+	for _, season := range seasons {
+		keep[season] = true
+	}
+	return func(season int) bool {
+		return len(keep) == 0 || keep[season]
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// name of the result set with the shots in a shotchartdetail response,
+// the other one (LeagueAverages) is ignored
+const statsShotResultSet = "Shot_Chart_Detail"
+
+// columns of the shot result set that are read, every one of them has to be in the headers
+var statsShotColumns = []string{
+	"GAME_ID", "PLAYER_ID", "PLAYER_NAME", "TEAM_ID", "TEAM_NAME", "PERIOD",
+	"MINUTES_REMAINING", "SECONDS_REMAINING", "EVENT_TYPE", "ACTION_TYPE", "SHOT_TYPE",
+	"SHOT_ZONE_BASIC", "SHOT_ZONE_AREA", "SHOT_ZONE_RANGE", "SHOT_DISTANCE",
+	"LOC_X", "LOC_Y", "SHOT_MADE_FLAG", "GAME_DATE", "HTM", "VTM",
+}
+
+// shotChartDetail is the part of the stats.nba.com shotchartdetail response we use, ex.
+/*
+{
+  "resource": "shotchartdetail",
+  "parameters": {"Season": "2023-24", "SeasonType": "Regular Season", ...},
+  "resultSets": [
+    {
+      "name": "Shot_Chart_Detail",
+      "headers": ["GRID_TYPE", "GAME_ID", "GAME_EVENT_ID", "PLAYER_ID", "PLAYER_NAME", "TEAM_ID", "TEAM_NAME", "PERIOD", ...],
+      "rowSet": [["Shot Chart Detail", "0022300061", 7, 2544, "LeBron James", 1610612747, "Los Angeles Lakers", 1, ...]]
+    },
+    {"name": "LeagueAverages", ...}
+  ]
+}
+*/
+type shotChartDetail struct {
+	Parameters struct {
+		Season string `json:"Season"`
+	} `json:"parameters"`
+	ResultSets []struct {
+		Name    string   `json:"name"`
+		Headers []string `json:"headers"`
+		RowSet  [][]any  `json:"rowSet"`
+	} `json:"resultSets"`
+}
+
+// statsFileSource reads a saved shotchartdetail response
+type statsFileSource struct {
+	shotInput
+}
+
+func (s statsFileSource) Shots(ctx context.Context, seasons []int) ([]shotRecord, error) {
+	log.Println("Opening file: ", s)
+	f, err := s.open()
+	if err != nil {
+		return nil, fmt.Errorf("could not open file %s: %v", s, err)
+	}
+	defer f.Close()
+
+	shots, err := parseShotChartDetail(ctx, f, seasons)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse file %s: %v", s, err)
+	}
+
+	log.Println("This files slice length: ", len(shots))
+	return shots, nil
+}
+
+// statsHTTPSource fetches a regular season's shots for every player from the shotchartdetail
+// endpoint under BaseURL, ex. https://stats.nba.com/stats or a local stub serving saved responses
+type statsHTTPSource struct {
+	BaseURL string
+	Season  int
+	Client  *http.Client
+}
+
+func newStatsHTTPSource(baseURL string, season int) *statsHTTPSource {
+	return &statsHTTPSource{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Season:  season,
+		Client:  &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+func (s *statsHTTPSource) String() string {
+	return s.url()
+}
+
+func (s *statsHTTPSource) url() string {
+	params := url.Values{}
+	params.Set("Season", statsSeason(s.Season))
+	params.Set("SeasonType", "Regular Season")
+	params.Set("LeagueID", "00")
+	params.Set("TeamID", "0")
+	params.Set("PlayerID", "0")
+	params.Set("ContextMeasure", "FGA")
+	params.Set("OpponentTeamID", "0")
+	params.Set("Period", "0")
+	params.Set("Month", "0")
+	params.Set("LastNGames", "0")
+	return s.BaseURL + "/shotchartdetail?" + params.Encode()
+}
+
+func (s *statsHTTPSource) Shots(ctx context.Context, seasons []int) ([]shotRecord, error) {
+	if !seasonFilter(seasons)(s.Season) {
+		return nil, nil
+	}
+
+	log.Println("Fetching: ", s)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url(), nil)
+	if err != nil {
+		return nil, err
+	}
+	// stats.nba.com doesn't answer requests that don't look like they come from the website
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0")
+	req.Header.Set("Referer", "https://www.nba.com/")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch season %d: %v", s.Season, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not fetch season %d: %s", s.Season, resp.Status)
+	}
+
+	shots, err := parseShotChartDetail(ctx, resp.Body, seasons)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse season %d: %v", s.Season, err)
+	}
+
+	log.Println("This files slice length: ", len(shots))
+	return shots, nil
+}
+
+// parseShotChartDetail reads a shotchartdetail response and converts its shots to the kaggle conventions.
+// Columns are looked up by name so extra or reordered columns don't matter.
+func parseShotChartDetail(ctx context.Context, r io.Reader, seasons []int) ([]shotRecord, error) {
+	decoder := json.NewDecoder(r)
+	// keep numbers as json.Number so 10 digit ids don't go through a float
+	decoder.UseNumber()
+
+	var detail shotChartDetail
+	err := decoder.Decode(&detail)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode response: %v", err)
+	}
+
+	resultSet := -1
+	for i, rs := range detail.ResultSets {
+		if rs.Name == statsShotResultSet {
+			resultSet = i
+			break
+		}
+	}
+	if resultSet == -1 {
+		return nil, fmt.Errorf("response has no %s result set", statsShotResultSet)
+	}
+	headers, rows := detail.ResultSets[resultSet].Headers, detail.ResultSets[resultSet].RowSet
+
+	columns := make(map[string]int)
+	for i, header := range headers {
+		columns[strings.ToUpper(header)] = i
+	}
+	for _, column := range statsShotColumns {
+		if _, ok := columns[column]; !ok {
+			return nil, fmt.Errorf("result set is missing column %s", column)
+		}
+	}
+
+	// responses are for a single season, only fall back to the game id when the request didn't say which
+	seasonEndYear, seasonYears := 0, ""
+	if detail.Parameters.Season != "" {
+		seasonEndYear, err = parseStatsSeason(detail.Parameters.Season)
+		if err != nil {
+			return nil, err
+		}
+		seasonYears = detail.Parameters.Season
+	}
+
+	keepSeason := seasonFilter(seasons)
+	var shots []shotRecord
+	for i, row := range rows {
+		if i%10000 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if len(row) != len(headers) {
+			return nil, fmt.Errorf("row %d: expected %d values, got %d", i, len(headers), len(row))
+		}
+
+		shot, err := parseStatsShotRow(row, columns)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %v", i, err)
+		}
+
+		if seasonEndYear != 0 {
+			shot.SeasonEndYear, shot.SeasonYears = seasonEndYear, seasonYears
+		} else {
+			shot.SeasonEndYear = seasonFromGameID(shot.GameID)
+			shot.SeasonYears = statsSeason(shot.SeasonEndYear)
+		}
+
+		if !keepSeason(shot.SeasonEndYear) {
+			continue
+		}
+		shots = append(shots, shot)
+	}
+
+	return shots, nil
+}
+
+func parseStatsShotRow(row []any, columns map[string]int) (shotRecord, error) {
+	p := &statsRowParser{row: row, columns: columns}
+
+	zoneName, zoneABB := splitZoneArea(p.string("SHOT_ZONE_AREA"))
+	shot := shotRecord{
+		GameID:       p.int("GAME_ID"),
+		PlayerID:     p.int("PLAYER_ID"),
+		PlayerName:   p.string("PLAYER_NAME"),
+		TeamID:       p.int("TEAM_ID"),
+		TeamName:     p.string("TEAM_NAME"),
+		Quarter:      p.int("PERIOD"),
+		MinsLeft:     p.int("MINUTES_REMAINING"),
+		SecsLeft:     p.int("SECONDS_REMAINING"),
+		EventType:    p.string("EVENT_TYPE"),
+		ActionType:   p.string("ACTION_TYPE"),
+		ShotType:     p.string("SHOT_TYPE"),
+		BasicZone:    p.string("SHOT_ZONE_BASIC"),
+		ZoneName:     zoneName,
+		ZoneABB:      zoneABB,
+		ZoneRange:    p.string("SHOT_ZONE_RANGE"),
+		ShotDistance: p.int("SHOT_DISTANCE"),
+		// the api uses tenths of a foot with the hoop at the origin,
+		// the kaggle data is in feet measured from the baseline
+		LocX:     p.float("LOC_X") / 10,
+		LocY:     p.float("LOC_Y")/10 + 5.25,
+		ShotMade: p.int("SHOT_MADE_FLAG") == 1,
+		GameDate: p.date("GAME_DATE", "20060102"),
+		HomeTeam: p.string("HTM"),
+		AwayTeam: p.string("VTM"),
+		// the shot chart has no positions, they stay empty for these seasons
+	}
+
+	return shot, p.err
+}
+
+// statsRowParser converts the values of a result set row and keeps the first error it runs into
+type statsRowParser struct {
+	row     []any
+	columns map[string]int
+	err     error
+}
+
+func (p *statsRowParser) fail(column string, err error) {
+	if p.err == nil {
+		p.err = fmt.Errorf("invalid %s %v: %v", column, p.row[p.columns[column]], err)
+	}
+}
+
+func (p *statsRowParser) string(column string) string {
+	switch v := p.row[p.columns[column]].(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (p *statsRowParser) int(column string) int {
+	v, err := strconv.Atoi(p.string(column))
+	if err != nil {
+		p.fail(column, err)
+	}
+	return v
+}
+
+func (p *statsRowParser) float(column string) float64 {
+	v, err := strconv.ParseFloat(p.string(column), 64)
+	if err != nil {
+		p.fail(column, err)
+	}
+	return v
+}
+
+func (p *statsRowParser) date(column string, layout string) time.Time {
+	v, err := time.Parse(layout, p.string(column))
+	if err != nil {
+		p.fail(column, err)
+	}
+	return v
+}
+
+// splitZoneArea splits "Left Side Center(LC)" into the zone name and abbreviation the kaggle data has
+func splitZoneArea(area string) (string, string) {
+	open := strings.LastIndex(area, "(")
+	if open == -1 || !strings.HasSuffix(area, ")") {
+		return area, ""
+	}
+	return strings.TrimSpace(area[:open]), area[open+1 : len(area)-1]
+}
+
+// statsSeason formats a season end year the way the api (and SEASON_2 in the kaggle data) does, ex. 2024 -> 2023-24
+func statsSeason(endYear int) string {
+	return fmt.Sprintf("%d-%02d", endYear-1, endYear%100)
+}
+
+func parseStatsSeason(season string) (int, error) {
+	var start, end int
+	_, err := fmt.Sscanf(season, "%4d-%2d", &start, &end)
+	if err != nil || (start+1)%100 != end {
+		return 0, fmt.Errorf("invalid season %q, expected ex. 2023-24", season)
+	}
+	return start + 1, nil
+}
+
+// seasonFromGameID reads the season out of a game id, ex. 0022300061 is from the season starting in 2023
+func seasonFromGameID(gameID int) int {
+	startYear := (gameID / 100000) % 100
+	if startYear >= 46 {
+		return 1900 + startYear + 1
+	}
+	return 2000 + startYear + 1
+}
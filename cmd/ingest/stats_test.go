@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testShotChartDetail = `{
+  "resource": "shotchartdetail",
+  "parameters": {"Season": "2024-25", "SeasonType": "Regular Season"},
+  "resultSets": [
+    {
+      "name": "Shot_Chart_Detail",
+      "headers": ["GRID_TYPE", "GAME_ID", "GAME_EVENT_ID", "PLAYER_ID", "PLAYER_NAME", "TEAM_ID", "TEAM_NAME", "PERIOD", "MINUTES_REMAINING", "SECONDS_REMAINING", "EVENT_TYPE", "ACTION_TYPE", "SHOT_TYPE", "SHOT_ZONE_BASIC", "SHOT_ZONE_AREA", "SHOT_ZONE_RANGE", "SHOT_DISTANCE", "LOC_X", "LOC_Y", "SHOT_ATTEMPTED_FLAG", "SHOT_MADE_FLAG", "GAME_DATE", "HTM", "VTM"],
+      "rowSet": [
+        ["Shot Chart Detail", "0022400061", 7, 2544, "LeBron James", 1610612747, "Los Angeles Lakers", 1, 11, 22, "Made Shot", "Driving Layup Shot", "2PT Field Goal", "Restricted Area", "Center(C)", "Less Than 8 ft.", 0, -5, 2, 1, 1, "20241022", "LAL", "MIN"],
+        ["Shot Chart Detail", "0022400061", 12, 2544, "LeBron James", 1610612747, "Los Angeles Lakers", 2, 3, 5, "Missed Shot", "Jump Shot", "3PT Field Goal", "Above the Break 3", "Left Side Center(LC)", "24+ ft.", 25, 200, 161, 1, 0, "20241022", "LAL", "MIN"],
+        ["Shot Chart Detail", "0022400061", 15, 1626157, "Karl-Anthony Towns", 1610612750, "Minnesota Timberwolves", 2, 1, 40, "Made Shot", "Jump Shot", "2PT Field Goal", "Mid-Range", "Right Side(R)", "8-16 ft.", 12, -110, 60, 1, 1, "20241022", "LAL", "MIN"],
+        ["Shot Chart Detail", "0022400075", 20, 1626157, "Karl-Anthony Towns", 1610612750, "Minnesota Timberwolves", 1, 8, 2, "Missed Shot", "Hook Shot", "2PT Field Goal", "In The Paint (Non-RA)", "Center(C)", "8-16 ft.", 9, 10, 90, 1, 0, "20241024", "MIN", "PHX"],
+        ["Shot Chart Detail", "0022400075", 31, 1626164, "Devin Booker", 1610612756, "Phoenix Suns", 1, 6, 12, "Made Shot", "Pullup Jump shot", "3PT Field Goal", "Left Corner 3", "Left Side(L)", "24+ ft.", 22, 220, 10, 1, 1, "20241024", "MIN", "PHX"],
+        ["Shot Chart Detail", "0022400090", 9, 1626164, "Devin Booker", 1610612756, "Phoenix Suns", 1, 10, 30, "Missed Shot", "Jump Shot", "2PT Field Goal", "Mid-Range", "Right Side Center(RC)", "16-24 ft.", 18, -130, 125, 1, 0, "20241028", "PHX", "LAL"],
+        ["Shot Chart Detail", "0022400090", 14, 2544, "LeBron James", 1610612747, "Los Angeles Lakers", 1, 9, 45, "Made Shot", "Cutting Dunk Shot", "2PT Field Goal", "Restricted Area", "Center(C)", "Less Than 8 ft.", 0, 3, 4, 1, 1, "20241028", "PHX", "LAL"]
+      ]
+    },
+    {"name": "LeagueAverages", "headers": ["GRID_TYPE"], "rowSet": [["League Averages"]]}
+  ]
+}`
+
+func TestParseShotChartDetail(t *testing.T) {
+	shots, err := parseShotChartDetail(context.Background(), strings.NewReader(testShotChartDetail), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(shots) != 7 {
+		t.Fatalf("expected 7 shots, got %d", len(shots))
+	}
+
+	expected := shotRecord{
+		PlayerID:      2544,
+		PlayerName:    "LeBron James",
+		GameID:        22400061,
+		TeamID:        1610612747,
+		TeamName:      "Los Angeles Lakers",
+		SeasonEndYear: 2025,
+		SeasonYears:   "2024-25",
+		EventType:     "Missed Shot",
+		ShotMade:      false,
+		ActionType:    "Jump Shot",
+		ShotType:      "3PT Field Goal",
+		BasicZone:     "Above the Break 3",
+		ZoneName:      "Left Side Center",
+		ZoneABB:       "LC",
+		ZoneRange:     "24+ ft.",
+		LocX:          20,
+		LocY:          21.35,
+		ShotDistance:  25,
+		Quarter:       2,
+		MinsLeft:      3,
+		SecsLeft:      5,
+		GameDate:      time.Date(2024, 10, 22, 0, 0, 0, 0, time.UTC),
+		HomeTeam:      "LAL",
+		AwayTeam:      "MIN",
+	}
+	if shots[1] != expected {
+		t.Errorf("expected %+v, got %+v", expected, shots[1])
+	}
+	if !shots[0].ShotMade || shots[0].ZoneABB != "C" {
+		t.Errorf("unexpected first shot %+v", shots[0])
+	}
+
+	shots, err = parseShotChartDetail(context.Background(), strings.NewReader(testShotChartDetail), []int{2024})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(shots) != 0 {
+		t.Errorf("expected the 2025 shots to be filtered out, got %d", len(shots))
+	}
+}
+
+func TestStatsHTTPSource(t *testing.T) {
+	var query string
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/stats/shotchartdetail" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.RawQuery
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testShotChartDetail))
+	}))
+	defer stub.Close()
+
+	sources, err := listShotSources(&ingestOptions{
+		Source:   sourceStatsJSON,
+		StatsURL: stub.URL + "/stats/",
+		Seasons:  []int{2025},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sources) != 1 {
+		t.Fatalf("expected a source per season, got %v", sources)
+	}
+
+	shots, err := sources[0].Shots(context.Background(), []int{2025})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(shots) != 7 {
+		t.Errorf("expected 7 shots, got %d", len(shots))
+	}
+	if !strings.Contains(query, "Season=2024-25") {
+		t.Errorf("expected the season in the query, got %s", query)
+	}
+
+	_, err = listShotSources(&ingestOptions{Source: sourceStatsJSON, StatsURL: stub.URL})
+	if err == nil {
+		t.Error("expected an error without -seasons")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"log"
+	"sort"
+)
+
+// teamDirectory maps between team ids and the abbreviations they used in each season.
+// The raw data only has abbreviations for the home and away teams, so they are
+// inferred: a team's own abbreviation shows up in every one of its games while
+// any single opponent only shows up in a few of them.
+type teamDirectory struct {
+	abbrevByID map[int]map[int]string // season -> team id -> abbreviation
+	idByAbbrev map[int]map[string]int // season -> abbreviation -> team id
+}
+
+func buildTeamDirectory(data *[]shotRecord) *teamDirectory {
+	type teamSeasonKey struct {
+		SeasonYear int
+		TeamID     int
+	}
+
+	// count each abbreviation once per game the team played in
+	seenGames := make(map[teamSeasonKey]map[int]bool)
+	counts := make(map[teamSeasonKey]map[string]int)
+	for _, shot := range *data {
+		key := teamSeasonKey{SeasonYear: shot.SeasonEndYear, TeamID: shot.TeamID}
+		if seenGames[key] == nil {
+			seenGames[key] = make(map[int]bool)
+			counts[key] = make(map[string]int)
+		}
+		if seenGames[key][shot.GameID] {
+			continue
+		}
+		seenGames[key][shot.GameID] = true
+		counts[key][shot.HomeTeam]++
+		counts[key][shot.AwayTeam]++
+	}
+
+	dir := &teamDirectory{
+		abbrevByID: make(map[int]map[int]string),
+		idByAbbrev: make(map[int]map[string]int),
+	}
+
+	for key, abbrevCounts := range counts {
+		abbrev := mostFrequentAbbrev(abbrevCounts)
+
+		if dir.abbrevByID[key.SeasonYear] == nil {
+			dir.abbrevByID[key.SeasonYear] = make(map[int]string)
+			dir.idByAbbrev[key.SeasonYear] = make(map[string]int)
+		}
+
+		if otherID, taken := dir.idByAbbrev[key.SeasonYear][abbrev]; taken {
+			log.Printf("Teams %d and %d both resolved to %s in %d\n", otherID, key.TeamID, abbrev, key.SeasonYear)
+		}
+
+		dir.abbrevByID[key.SeasonYear][key.TeamID] = abbrev
+		dir.idByAbbrev[key.SeasonYear][abbrev] = key.TeamID
+	}
+
+	return dir
+}
+
+// ties go to the alphabetically first abbreviation so the result doesn't depend on map order
+func mostFrequentAbbrev(counts map[string]int) string {
+	abbrevs := make([]string, 0, len(counts))
+	for abbrev := range counts {
+		abbrevs = append(abbrevs, abbrev)
+	}
+	sort.Strings(abbrevs)
+
+	best := ""
+	for _, abbrev := range abbrevs {
+		if best == "" || counts[abbrev] > counts[best] {
+			best = abbrev
+		}
+	}
+	return best
+}
+
+// abbreviation returns the abbreviation the team used in the season, or "" if it is unknown
+func (d *teamDirectory) abbreviation(teamID int, seasonYear int) string {
+	return d.abbrevByID[seasonYear][teamID]
+}
+
+// teamID returns the id of the team that used the abbreviation in the season, or 0 if it is unknown
+func (d *teamDirectory) teamID(abbrev string, seasonYear int) int {
+	return d.idByAbbrev[seasonYear][abbrev]
+}
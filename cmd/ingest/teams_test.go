@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestBuildTeamDirectory(t *testing.T) {
+	const sonics, lakers, celtics = 1610612760, 1610612747, 1610612738
+
+	// the sonics play two games, so SEA shows up twice for them and each opponent once
+	data := []shotRecord{
+		{SeasonEndYear: 2008, TeamID: sonics, GameID: 1, HomeTeam: "SEA", AwayTeam: "LAL"},
+		{SeasonEndYear: 2008, TeamID: sonics, GameID: 1, HomeTeam: "SEA", AwayTeam: "LAL"},
+		{SeasonEndYear: 2008, TeamID: sonics, GameID: 2, HomeTeam: "BOS", AwayTeam: "SEA"},
+		{SeasonEndYear: 2008, TeamID: lakers, GameID: 1, HomeTeam: "SEA", AwayTeam: "LAL"},
+		{SeasonEndYear: 2008, TeamID: lakers, GameID: 3, HomeTeam: "LAL", AwayTeam: "BOS"},
+		{SeasonEndYear: 2008, TeamID: celtics, GameID: 2, HomeTeam: "BOS", AwayTeam: "SEA"},
+		{SeasonEndYear: 2008, TeamID: celtics, GameID: 3, HomeTeam: "LAL", AwayTeam: "BOS"},
+		{SeasonEndYear: 2009, TeamID: sonics, GameID: 4, HomeTeam: "OKC", AwayTeam: "LAL"},
+		{SeasonEndYear: 2009, TeamID: sonics, GameID: 5, HomeTeam: "BOS", AwayTeam: "OKC"},
+	}
+
+	dir := buildTeamDirectory(&data)
+
+	tests := []struct {
+		teamID int
+		season int
+		abbrev string
+	}{
+		{sonics, 2008, "SEA"},
+		{lakers, 2008, "LAL"},
+		{celtics, 2008, "BOS"},
+		{sonics, 2009, "OKC"},
+	}
+
+	for _, tt := range tests {
+		if got := dir.abbreviation(tt.teamID, tt.season); got != tt.abbrev {
+			t.Errorf("abbreviation(%d, %d) = %q, want %q", tt.teamID, tt.season, got, tt.abbrev)
+		}
+		if got := dir.teamID(tt.abbrev, tt.season); got != tt.teamID {
+			t.Errorf("teamID(%q, %d) = %d, want %d", tt.abbrev, tt.season, got, tt.teamID)
+		}
+	}
+
+	if got := dir.teamID("SEA", 2009); got != 0 {
+		t.Errorf("expected SEA to be unknown in 2009, got %d", got)
+	}
+}
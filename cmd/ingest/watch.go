@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"nba-shots/internal/database"
+	"nba-shots/internal/types"
+	"os"
+	"time"
+)
+
+// runWatch refreshes the database on a schedule, loading the games that aren't in it yet
+func runWatch(ctx context.Context, args []string) error {
+	opts := &ingestOptions{}
+	fs := newFlagSet("watch", opts)
+	addFileFlags(fs, opts)
+	fs.IntVar(&opts.BatchSize, "batch-size", 50000, "number of shots per COPY into the shot table")
+	interval := fs.Duration("interval", time.Hour, "time between refreshes")
+	once := fs.Bool("once", false, "run a single refresh and exit")
+	fs.Parse(args)
+
+	if opts.BatchSize <= 0 {
+		return fmt.Errorf("batch-size must be positive, got %d", opts.BatchSize)
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("interval must be positive, got %s", *interval)
+	}
+
+	dbService, err := openDatabase(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer dbService.Close()
+
+	w := &watcher{db: dbService, opts: opts}
+	for {
+		run := w.refresh(ctx)
+		if run.GamesAdded > 0 {
+			invalidateCache(ctx, opts)
+		}
+		if *once {
+			if run.Status != types.RefreshSucceeded {
+				return fmt.Errorf("refresh %d failed: %s", run.ID, run.Error)
+			}
+			return nil
+		}
+
+		log.Printf("Next refresh in %s\n", *interval)
+		select {
+		case <-ctx.Done():
+			log.Println("Stopping refresher")
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// watcher loads new games from the sources in opts. Files are only read again once they
+// have changed since the last successful refresh, http sources are fetched every time.
+type watcher struct {
+	db   watchDB
+	opts *ingestOptions
+
+	lastSuccess time.Time
+}
+
+// watchDB loads the new games and records every refresh run
+type watchDB interface {
+	database.IngestWriter
+	database.RefreshLog
+}
+
+// modTimeSource is implemented by the file backed sources
+type modTimeSource interface {
+	modifiedSince(time.Time) (bool, error)
+}
+
+// refresh runs one pass over the sources and records it in the refresh_run table.
+// A failed run is recorded too, the watcher carries on with the next one.
+func (w *watcher) refresh(ctx context.Context) *types.RefreshRun {
+	started := time.Now()
+	run := &types.RefreshRun{StartedAt: started}
+
+	id, err := w.db.StartRefreshRun(ctx)
+	if err != nil {
+		// without a run row there's nowhere to record the outcome, try again next time
+		log.Println(err)
+		run.Status, run.Error = types.RefreshFailed, err.Error()
+		return run
+	}
+	run.ID = id
+	log.Printf("Starting refresh %d\n", run.ID)
+
+	err = w.loadNewGames(ctx, run)
+	if err != nil {
+		run.Status, run.Error = types.RefreshFailed, err.Error()
+		log.Printf("Refresh %d failed: %v\n", run.ID, err)
+	} else {
+		run.Status = types.RefreshSucceeded
+		w.lastSuccess = started
+		log.Printf("Refresh %d added %d games and %d shots from %d sources\n", run.ID, run.GamesAdded, run.ShotsAdded, run.Sources)
+	}
+
+	// a run cut short by shutting down is still recorded as failed
+	err = w.db.FinishRefreshRun(context.WithoutCancel(ctx), run)
+	if err != nil {
+		log.Println(err)
+	}
+	return run
+}
+
+func (w *watcher) loadNewGames(ctx context.Context, run *types.RefreshRun) error {
+	sources, err := listShotSources(w.opts)
+	if err != nil {
+		return err
+	}
+
+	var changed []shotSource
+	for _, src := range sources {
+		if f, ok := src.(modTimeSource); ok && !w.lastSuccess.IsZero() {
+			modified, err := f.modifiedSince(w.lastSuccess)
+			if err != nil {
+				return err
+			}
+			if !modified {
+				continue
+			}
+		}
+		changed = append(changed, src)
+	}
+	run.Sources = len(changed)
+
+	if len(changed) == 0 {
+		log.Println("No sources changed since the last refresh")
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	pipeline := startFilePipeline(ctx, changed, w.opts.Workers, prepareNewGames(w.opts, w.db))
+	defer pipeline.Wait()
+	defer cancel()
+
+	for {
+		prepared, ok, err := pipeline.Next(ctx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+
+		if prepared.Err != nil {
+			return prepared.Err
+		}
+		if prepared.Skip != "" {
+			prepared.logSkip()
+			continue
+		}
+
+		err = uploadBatchShotData(ctx, w.db, prepared.Batch, w.opts.BatchSize)
+		if err != nil {
+			return fmt.Errorf("error uploading for file %s: %v", prepared.Path, err)
+		}
+		run.GamesAdded += len(prepared.Batch.Games)
+		run.ShotsAdded += len(prepared.Batch.Shots)
+	}
+}
+
+// prepareNewGames returns the prepareFunc used by watch, only the shots from games
+// that aren't in the database yet are turned into rows
+func prepareNewGames(opts *ingestOptions, dbService database.IngestWriter) prepareFunc {
+	return func(ctx context.Context, src shotSource) *preparedFile {
+		shots, err := src.Shots(ctx, opts.Seasons)
+		if err != nil {
+			return &preparedFile{Path: src.String(), Err: err}
+		}
+		parsed := newShotFile(src.String(), shots)
+		prepared := &preparedFile{Path: parsed.Path, Seasons: parsed.Seasons}
+
+		if len(parsed.Shots) == 0 {
+			prepared.Skip = "no shots for the requested seasons"
+			return prepared
+		}
+
+		// resolve the ids over the whole file first so loaded games keep the ids they were loaded with
+		identityReport, err := resolveGameIdentities(&parsed.Shots)
+		if err != nil {
+			prepared.Err = fmt.Errorf("could not resolve game ids for file %s: %v", parsed.Path, err)
+			return prepared
+		}
+		identityReport.log(parsed.Path)
+
+		years := make([]int, len(parsed.Seasons))
+		for i, season := range parsed.Seasons {
+			years[i] = season.Year
+		}
+		loadedIDs, err := dbService.GetGameIDsForSeasons(ctx, years)
+		if err != nil {
+			prepared.Err = fmt.Errorf("could not get the loaded games for file %s: %v", parsed.Path, err)
+			return prepared
+		}
+		loaded := make(map[int]bool)
+		for _, id := range loadedIDs {
+			loaded[id] = true
+		}
+
+		var newShots []shotRecord
+		for _, shot := range parsed.Shots {
+			if !loaded[shot.GameID] {
+				newShots = append(newShots, shot)
+			}
+		}
+		if len(newShots) == 0 {
+			prepared.Skip = "no new games"
+			return prepared
+		}
+
+		// abbreviations are inferred from every game in the file, a handful of new games isn't enough to tell teams apart
+		prepared.Batch = buildSeasonBatch(&newShots, parsed.Seasons, buildTeamDirectory(&parsed.Shots))
+		err = checkGameTeams(prepared.Batch)
+		if err != nil {
+			prepared.Batch, prepared.Err = nil, fmt.Errorf("file %s: %v", parsed.Path, err)
+		}
+		return prepared
+	}
+}
+
+func (in shotInput) modifiedSince(t time.Time) (bool, error) {
+	info, err := os.Stat(in.Path)
+	if err != nil {
+		return false, err
+	}
+	return info.ModTime().After(t), nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"nba-shots/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// refreshDB keeps the games and runs the watcher writes, anything else it's asked for panics
+type refreshDB struct {
+	watchDB
+	games []int
+	runs  []types.RefreshRun
+}
+
+func (db *refreshDB) StartRefreshRun(ctx context.Context) (int, error) {
+	return len(db.runs) + 1, nil
+}
+
+func (db *refreshDB) FinishRefreshRun(ctx context.Context, run *types.RefreshRun) error {
+	db.runs = append(db.runs, *run)
+	return nil
+}
+
+func (db *refreshDB) GetGameIDsForSeasons(ctx context.Context, seasons []int) ([]int, error) {
+	return db.games, nil
+}
+
+func (db *refreshDB) InsertSeasonBatch(ctx context.Context, batch *types.SeasonBatch, batchSize int) error {
+	for _, game := range batch.Games {
+		db.games = append(db.games, game.ID)
+	}
+	return nil
+}
+
+func TestWatcherLoadsNewGames(t *testing.T) {
+	requests := 0
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(testShotChartDetail))
+	}))
+	defer stub.Close()
+
+	// the first game is already loaded
+	db := &refreshDB{games: []int{22400061}}
+	w := &watcher{db: db, opts: &ingestOptions{
+		Source:    sourceStatsJSON,
+		StatsURL:  stub.URL,
+		Seasons:   []int{2025},
+		BatchSize: 100,
+		Workers:   1,
+	}}
+
+	run := w.refresh(context.Background())
+	if run.Status != types.RefreshSucceeded {
+		t.Fatalf("expected the first refresh to succeed, got %+v", run)
+	}
+	if run.GamesAdded != 2 || run.ShotsAdded != 4 {
+		t.Errorf("expected the 2 new games and their 4 shots, got %+v", run)
+	}
+
+	// the endpoint returns the same games again, there's nothing new to load
+	run = w.refresh(context.Background())
+	if run.Status != types.RefreshSucceeded || run.GamesAdded != 0 || run.ShotsAdded != 0 {
+		t.Errorf("expected an empty refresh, got %+v", run)
+	}
+
+	if requests != 2 {
+		t.Errorf("expected the endpoint to be polled on every refresh, got %d requests", requests)
+	}
+	if len(db.runs) != 2 {
+		t.Errorf("expected 2 recorded runs, got %d", len(db.runs))
+	}
+
+	stub.Close()
+	run = w.refresh(context.Background())
+	if run.Status != types.RefreshFailed || run.Error == "" {
+		t.Errorf("expected the refresh to fail once the endpoint is gone, got %+v", run)
+	}
+	if len(db.runs) != 3 || db.runs[2].Status != types.RefreshFailed {
+		t.Errorf("expected the failed run to be recorded, got %+v", db.runs)
+	}
+}
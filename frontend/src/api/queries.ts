@@ -12,11 +12,14 @@ import {
   TeamResponse,
 } from "@/types"
 import { format } from "date-fns"
+import { decodeShots, SHOTS_PROTO_CONTENT_TYPE } from "./shotsproto"
 
 function getBackendUrl() {
-  return import.meta.env.VITE_ENV === "prod"
-    ? import.meta.env.VITE_BACKEND_URL_PROD
-    : import.meta.env.VITE_BACKEND_URL_DEV
+  const url =
+    import.meta.env.VITE_ENV === "prod"
+      ? import.meta.env.VITE_BACKEND_URL_PROD
+      : import.meta.env.VITE_BACKEND_URL_DEV
+  return `${url}/v1`
 }
 
 export async function fetchPlayersByName(name: string) {
@@ -125,13 +128,23 @@ export async function fetchShotsWithFilters(
     }
   })
 
-  const response = await fetch(queryString)
-  const data: ShotResponseMetadata = await response.json()
+  // the columnar protobuf shots are a fraction of the json for long careers
+  const response = await fetch(queryString, {
+    headers: {
+      Accept: `${SHOTS_PROTO_CONTENT_TYPE}, application/json;q=0.9`,
+    },
+  })
 
   if (!response.ok) {
     throw new Error("Failed to fetch teams")
   }
 
+  const data: ShotResponseMetadata = response.headers
+    .get("Content-Type")
+    ?.startsWith(SHOTS_PROTO_CONTENT_TYPE)
+    ? decodeShots(await response.arrayBuffer())
+    : await response.json()
+
   const pctTotal =
     data.shots.length > 0
       ? (data.total_made_shots / data.shots.length) * 100
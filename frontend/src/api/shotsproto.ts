@@ -0,0 +1,168 @@
+import { ShotResponse, ShotResponseMetadata } from "@/types"
+
+// decodes the nbashots.shots.v1.Shots message of proto/shots/v1/shots.proto,
+// the columnar /shots response sent for Accept: application/x-protobuf
+
+export const SHOTS_PROTO_CONTENT_TYPE = "application/x-protobuf"
+
+const SCHEMA_VERSION = 1
+
+const SHOT_TYPES: Record<number, string> = {
+  1: "2PT Field Goal",
+  2: "3PT Field Goal",
+}
+
+class Reader {
+  buf: Uint8Array
+  pos = 0
+
+  constructor(buf: Uint8Array) {
+    this.buf = buf
+  }
+
+  done() {
+    return this.pos >= this.buf.length
+  }
+
+  // plain arithmetic instead of bit operators, those truncate to 32 bits
+  varint() {
+    let value = 0
+    let scale = 1
+    for (;;) {
+      if (this.done()) throw new Error("truncated varint")
+      const b = this.buf[this.pos++]
+      value += (b & 0x7f) * scale
+      if (b < 0x80) return value
+      scale *= 128
+    }
+  }
+
+  bytes() {
+    const length = this.varint()
+    const end = this.pos + length
+    if (end > this.buf.length) throw new Error("truncated field")
+    const bytes = this.buf.subarray(this.pos, end)
+    this.pos = end
+    return bytes
+  }
+}
+
+const zigzag = (n: number) => (n % 2 === 1 ? -(n + 1) / 2 : n / 2)
+
+// calls field with the value of every varint field and the bytes of every
+// length delimited one
+function readFields(
+  buf: Uint8Array,
+  field: (num: number, value: number, bytes: Uint8Array) => void,
+) {
+  const r = new Reader(buf)
+  while (!r.done()) {
+    const tag = r.varint()
+    const num = Math.floor(tag / 8)
+    switch (tag % 8) {
+      case 0:
+        field(num, r.varint(), new Uint8Array())
+        break
+      case 2:
+        field(num, 0, r.bytes())
+        break
+      default:
+        throw new Error(`unexpected wire type ${tag % 8} for field ${num}`)
+    }
+  }
+}
+
+function readPacked(bytes: Uint8Array) {
+  const r = new Reader(bytes)
+  const values: number[] = []
+  while (!r.done()) {
+    values.push(r.varint())
+  }
+  return values
+}
+
+export function decodeShots(buf: ArrayBuffer): ShotResponseMetadata {
+  const data: ShotResponseMetadata = {
+    total_made_shots: 0,
+    total_missed_shots: 0,
+    made_2pt_shots: 0,
+    missed_2pt_shots: 0,
+    made_3pt_shots: 0,
+    missed_3pt_shots: 0,
+    shots: [],
+  }
+  let count = 0
+  let ids: number[] = []
+  let locX: number[] = []
+  let locY: number[] = []
+  let shotTypes: number[] = []
+  let made = new Uint8Array()
+
+  readFields(new Uint8Array(buf), (num, value, bytes) => {
+    switch (num) {
+      case 1:
+        if (value !== SCHEMA_VERSION) {
+          throw new Error(`unknown shots schema version ${value}`)
+        }
+        break
+      case 2:
+        readFields(bytes, (num, value) => {
+          const key = (
+            [
+              "total_made_shots",
+              "total_missed_shots",
+              "made_2pt_shots",
+              "missed_2pt_shots",
+              "made_3pt_shots",
+              "missed_3pt_shots",
+            ] as const
+          )[num - 1]
+          if (key) data[key] = value
+        })
+        break
+      case 4:
+        count = value
+        break
+      case 5:
+        ids = readPacked(bytes)
+        break
+      case 6:
+        locX = readPacked(bytes)
+        break
+      case 7:
+        locY = readPacked(bytes)
+        break
+      case 8:
+        made = bytes
+        break
+      case 9:
+        shotTypes = readPacked(bytes)
+        break
+    }
+  })
+
+  if (
+    ids.length !== count ||
+    locX.length !== count ||
+    locY.length !== count ||
+    shotTypes.length !== count ||
+    made.length !== Math.ceil(count / 8)
+  ) {
+    throw new Error("the shot columns don't have the same length")
+  }
+
+  let id = 0
+  const shots: ShotResponse[] = new Array(count)
+  for (let i = 0; i < count; i++) {
+    id += zigzag(ids[i])
+    shots[i] = {
+      id: id,
+      loc_x: zigzag(locX[i]) / 100,
+      loc_y: zigzag(locY[i]) / 100,
+      shot_made: (made[i >> 3] & (1 << (i & 7))) !== 0,
+      shot_type: SHOT_TYPES[shotTypes[i]] ?? "",
+    }
+  }
+  data.shots = shots
+  return data
+}
@@ -0,0 +1,428 @@
+// Package config loads the settings of the api and ingest binaries. Every setting can come from
+// the YAML file -config (or CONFIG_FILE) points at, an environment variable or a flag, flags win
+// over the environment which wins over the file.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/joho/godotenv/autoload"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Database Database `yaml:"database"`
+	API      API      `yaml:"api"`
+	Cache    Cache    `yaml:"cache"`
+}
+
+// Database is how to reach postgres and how big the connection pool gets
+type Database struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	Schema   string `yaml:"schema"`
+
+	// one of SSLModes, the cert files are optional, verify-ca and verify-full fall back to the
+	// system roots without SSLRootCert
+	SSLMode     string `yaml:"sslmode"`
+	SSLRootCert string `yaml:"sslrootcert"`
+	SSLCert     string `yaml:"sslcert"`
+	SSLKey      string `yaml:"sslkey"`
+
+	// 0 keeps the pgxpool defaults
+	MaxConns        int32         `yaml:"max_conns"`
+	MinConns        int32         `yaml:"min_conns"`
+	MaxConnLifetime time.Duration `yaml:"max_conn_lifetime"`
+	MaxConnIdleTime time.Duration `yaml:"max_conn_idle_time"`
+
+	// apply pending migrations when connecting
+	AutoMigrate bool `yaml:"auto_migrate"`
+	// postgres cancels any statement that runs longer than this, 0 keeps the server's setting
+	StatementTimeout time.Duration `yaml:"statement_timeout"`
+}
+
+type API struct {
+	Port int `yaml:"port"`
+	// bearer token for the /admin routes, they are disabled when empty
+	AdminToken string `yaml:"admin_token"`
+	// the database calls of a request are cancelled after this long, 0 never cancels them
+	QueryTimeout time.Duration `yaml:"query_timeout"`
+}
+
+// Cache is the read-through cache in front of the database, see internal/database/cache
+type Cache struct {
+	// one of CacheBackends
+	Backend       string `yaml:"backend"`
+	RedisAddr     string `yaml:"redis_addr"`
+	RedisPassword string `yaml:"redis_password"`
+
+	// the lru backend evicts the least recently used entries past these
+	MaxEntries int   `yaml:"max_entries"`
+	MaxBytes   int64 `yaml:"max_bytes"`
+	// larger results aren't cached, ex. whole careers of shots
+	MaxEntryBytes int64 `yaml:"max_entry_bytes"`
+
+	CatalogTTL time.Duration `yaml:"catalog_ttl"`
+	ShotsTTL   time.Duration `yaml:"shots_ttl"`
+}
+
+// CacheBackends are the values of Cache.Backend, none turns the cache off
+var CacheBackends = []string{"none", "lru", "redis"}
+
+// SSLModes are the sslmode values postgres accepts
+var SSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// Default is the config before the file, environment and flags are applied
+func Default() *Config {
+	return &Config{
+		Database: Database{
+			Host:    "localhost",
+			Port:    5432,
+			Schema:  "public",
+			SSLMode: "prefer",
+		},
+		API: API{
+			Port:         8080,
+			QueryTimeout: 10 * time.Second,
+		},
+		Cache: Cache{
+			Backend:       "none",
+			MaxEntries:    10000,
+			MaxBytes:      256 << 20,
+			MaxEntryBytes: 16 << 20,
+			CatalogTTL:    time.Hour,
+			ShotsTTL:      10 * time.Minute,
+		},
+	}
+}
+
+// Group picks the flags AddFlags registers
+type Group int
+
+const (
+	DatabaseFlags Group = iota
+	APIFlags
+	CacheFlags
+)
+
+// setting is one value of the config, flag is empty for the secrets so they don't end up in
+// the process list
+type setting struct {
+	group Group
+	key   string
+	env   string
+	flag  string
+	usage string
+	set   setter
+}
+
+// setter parses a value of a setting into the config, the flags of boolean settings can be
+// passed without a value to turn them on
+type setter struct {
+	parse   func(c *Config, v string) error
+	boolean bool
+}
+
+var settings = []setting{
+	{DatabaseFlags, "database.host", "DB_HOST", "db-host", "postgres host", setString(func(c *Config) *string { return &c.Database.Host })},
+	{DatabaseFlags, "database.port", "DB_PORT", "db-port", "postgres port", setInt(func(c *Config) *int { return &c.Database.Port })},
+	{DatabaseFlags, "database.user", "DB_USERNAME", "db-user", "postgres user", setString(func(c *Config) *string { return &c.Database.User })},
+	{DatabaseFlags, "database.password", "DB_PASSWORD", "", "", setString(func(c *Config) *string { return &c.Database.Password })},
+	{DatabaseFlags, "database.name", "DB_DATABASE", "db-name", "postgres database", setString(func(c *Config) *string { return &c.Database.Name })},
+	{DatabaseFlags, "database.schema", "DB_SCHEMA", "db-schema", "schema the tables live in", setString(func(c *Config) *string { return &c.Database.Schema })},
+	{DatabaseFlags, "database.sslmode", "DB_SSLMODE", "db-sslmode", "one of " + strings.Join(SSLModes, ", "), setString(func(c *Config) *string { return &c.Database.SSLMode })},
+	{DatabaseFlags, "database.sslrootcert", "DB_SSLROOTCERT", "db-sslrootcert", "CA certificate the server's certificate is checked against", setString(func(c *Config) *string { return &c.Database.SSLRootCert })},
+	{DatabaseFlags, "database.sslcert", "DB_SSLCERT", "db-sslcert", "client certificate", setString(func(c *Config) *string { return &c.Database.SSLCert })},
+	{DatabaseFlags, "database.sslkey", "DB_SSLKEY", "db-sslkey", "key of the client certificate", setString(func(c *Config) *string { return &c.Database.SSLKey })},
+	{DatabaseFlags, "database.max_conns", "DB_MAX_CONNS", "db-max-conns", "largest size of the connection pool", setInt32(func(c *Config) *int32 { return &c.Database.MaxConns })},
+	{DatabaseFlags, "database.min_conns", "DB_MIN_CONNS", "db-min-conns", "connections the pool keeps open", setInt32(func(c *Config) *int32 { return &c.Database.MinConns })},
+	{DatabaseFlags, "database.max_conn_lifetime", "DB_MAX_CONN_LIFETIME", "db-max-conn-lifetime", "connections are closed once they are this old", setDuration(func(c *Config) *time.Duration { return &c.Database.MaxConnLifetime })},
+	{DatabaseFlags, "database.max_conn_idle_time", "DB_MAX_CONN_IDLE_TIME", "db-max-conn-idle-time", "idle connections are closed after this long", setDuration(func(c *Config) *time.Duration { return &c.Database.MaxConnIdleTime })},
+	{DatabaseFlags, "database.auto_migrate", "DB_AUTO_MIGRATE", "db-auto-migrate", "apply pending migrations when connecting", setBool(func(c *Config) *bool { return &c.Database.AutoMigrate })},
+	{DatabaseFlags, "database.statement_timeout", "DB_STATEMENT_TIMEOUT", "db-statement-timeout", "postgres cancels statements running longer than this", setDuration(func(c *Config) *time.Duration { return &c.Database.StatementTimeout })},
+
+	{APIFlags, "api.port", "PORT", "port", "port the api listens on", setInt(func(c *Config) *int { return &c.API.Port })},
+	{APIFlags, "api.admin_token", "ADMIN_TOKEN", "", "", setString(func(c *Config) *string { return &c.API.AdminToken })},
+	{APIFlags, "api.query_timeout", "QUERY_TIMEOUT", "query-timeout", "the database calls of a request are cancelled after this long", setDuration(func(c *Config) *time.Duration { return &c.API.QueryTimeout })},
+
+	{CacheFlags, "cache.backend", "CACHE_BACKEND", "cache", "one of " + strings.Join(CacheBackends, ", "), setString(func(c *Config) *string { return &c.Cache.Backend })},
+	{CacheFlags, "cache.redis_addr", "CACHE_REDIS_ADDR", "cache-redis-addr", "host:port of the redis compatible server", setString(func(c *Config) *string { return &c.Cache.RedisAddr })},
+	{CacheFlags, "cache.redis_password", "CACHE_REDIS_PASSWORD", "", "", setString(func(c *Config) *string { return &c.Cache.RedisPassword })},
+	{CacheFlags, "cache.max_entries", "CACHE_MAX_ENTRIES", "cache-max-entries", "entries the lru cache holds", setInt(func(c *Config) *int { return &c.Cache.MaxEntries })},
+	{CacheFlags, "cache.max_bytes", "CACHE_MAX_BYTES", "cache-max-bytes", "bytes the lru cache holds", setInt64(func(c *Config) *int64 { return &c.Cache.MaxBytes })},
+	{CacheFlags, "cache.max_entry_bytes", "CACHE_MAX_ENTRY_BYTES", "cache-max-entry-bytes", "larger results aren't cached", setInt64(func(c *Config) *int64 { return &c.Cache.MaxEntryBytes })},
+	{CacheFlags, "cache.catalog_ttl", "CACHE_CATALOG_TTL", "cache-catalog-ttl", "players, teams, seasons and games are cached this long", setDuration(func(c *Config) *time.Duration { return &c.Cache.CatalogTTL })},
+	{CacheFlags, "cache.shots_ttl", "CACHE_SHOTS_TTL", "cache-shots-ttl", "shot queries are cached this long", setDuration(func(c *Config) *time.Duration { return &c.Cache.ShotsTTL })},
+}
+
+// AddFlags registers -config and the flags of the groups on fs, Load reads them back once fs
+// is parsed
+func AddFlags(fs *flag.FlagSet, groups ...Group) {
+	fs.String("config", "", "YAML config file, defaults to $CONFIG_FILE")
+	for _, s := range settings {
+		if s.flag == "" || !slices.Contains(groups, s.group) {
+			continue
+		}
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		if s.set.boolean {
+			fs.Bool(s.flag, false, usage)
+			continue
+		}
+		fs.String(s.flag, "", usage)
+	}
+}
+
+// Load builds the config from the defaults, the config file, the environment and the flags set
+// on fs, in that order. fs is parsed and went through AddFlags, a nil fs only reads the file in
+// CONFIG_FILE and the environment. Load doesn't validate, see Validate.
+func Load(fs *flag.FlagSet) (*Config, error) {
+	cfg := Default()
+
+	path := os.Getenv("CONFIG_FILE")
+	if fs != nil {
+		if f := fs.Lookup("config"); f != nil && f.Value.String() != "" {
+			path = f.Value.String()
+		}
+	}
+	if path != "" {
+		err := cfg.loadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for _, s := range settings {
+		v, ok := os.LookupEnv(s.env)
+		if !ok || v == "" {
+			continue
+		}
+		if err := s.set.parse(cfg, v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+		}
+	}
+
+	if fs != nil {
+		fs.Visit(func(f *flag.Flag) {
+			for _, s := range settings {
+				if s.flag != f.Name {
+					continue
+				}
+				if err := s.set.parse(cfg, f.Value.String()); err != nil {
+					errs = append(errs, fmt.Errorf("-%s: %w", s.flag, err))
+				}
+			}
+		})
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open the config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	// a misspelt key would otherwise be silently ignored
+	dec.KnownFields(true)
+	err = dec.Decode(c)
+	if err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate checks the database settings, the errors name where each one can be set
+func (d Database) Validate() error {
+	var errs []error
+	if d.Host == "" {
+		errs = append(errs, missing("database.host"))
+	}
+	if d.User == "" {
+		errs = append(errs, missing("database.user"))
+	}
+	if d.Name == "" {
+		errs = append(errs, missing("database.name"))
+	}
+	if d.Port < 1 || d.Port > 65535 {
+		errs = append(errs, invalid("database.port", "%d is not a port", d.Port))
+	}
+
+	if !slices.Contains(SSLModes, d.SSLMode) {
+		errs = append(errs, invalid("database.sslmode", "%q is not one of %s", d.SSLMode, strings.Join(SSLModes, ", ")))
+	}
+	if (d.SSLCert == "") != (d.SSLKey == "") {
+		errs = append(errs, invalid("database.sslcert", "the client certificate and key (database.sslkey) are set together"))
+	}
+	for _, file := range []struct{ key, path string }{
+		{"database.sslrootcert", d.SSLRootCert},
+		{"database.sslcert", d.SSLCert},
+		{"database.sslkey", d.SSLKey},
+	} {
+		if file.path == "" {
+			continue
+		}
+		if _, err := os.Stat(file.path); err != nil {
+			errs = append(errs, invalid(file.key, "%v", err))
+		}
+	}
+
+	if d.MaxConns < 0 {
+		errs = append(errs, invalid("database.max_conns", "%d is negative", d.MaxConns))
+	}
+	if d.MinConns < 0 {
+		errs = append(errs, invalid("database.min_conns", "%d is negative", d.MinConns))
+	}
+	if d.MaxConns > 0 && d.MinConns > d.MaxConns {
+		errs = append(errs, invalid("database.min_conns", "%d is more than the %d max connections", d.MinConns, d.MaxConns))
+	}
+	if d.MaxConnLifetime < 0 {
+		errs = append(errs, invalid("database.max_conn_lifetime", "%s is negative", d.MaxConnLifetime))
+	}
+	if d.MaxConnIdleTime < 0 {
+		errs = append(errs, invalid("database.max_conn_idle_time", "%s is negative", d.MaxConnIdleTime))
+	}
+	if d.StatementTimeout < 0 {
+		errs = append(errs, invalid("database.statement_timeout", "%s is negative", d.StatementTimeout))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks the api settings, the errors name where each one can be set
+func (a API) Validate() error {
+	var errs []error
+	if a.Port < 1 || a.Port > 65535 {
+		errs = append(errs, invalid("api.port", "%d is not a port", a.Port))
+	}
+	if a.QueryTimeout < 0 {
+		errs = append(errs, invalid("api.query_timeout", "%s is negative", a.QueryTimeout))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks the cache settings, the errors name where each one can be set
+func (c Cache) Validate() error {
+	var errs []error
+	if !slices.Contains(CacheBackends, c.Backend) {
+		errs = append(errs, invalid("cache.backend", "%q is not one of %s", c.Backend, strings.Join(CacheBackends, ", ")))
+	}
+	if c.Backend == "redis" && c.RedisAddr == "" {
+		errs = append(errs, missing("cache.redis_addr"))
+	}
+	if c.MaxEntries <= 0 {
+		errs = append(errs, invalid("cache.max_entries", "%d is not positive", c.MaxEntries))
+	}
+	if c.MaxBytes <= 0 {
+		errs = append(errs, invalid("cache.max_bytes", "%d is not positive", c.MaxBytes))
+	}
+	if c.MaxEntryBytes <= 0 {
+		errs = append(errs, invalid("cache.max_entry_bytes", "%d is not positive", c.MaxEntryBytes))
+	}
+	if c.CatalogTTL <= 0 {
+		errs = append(errs, invalid("cache.catalog_ttl", "%s is not positive", c.CatalogTTL))
+	}
+	if c.ShotsTTL <= 0 {
+		errs = append(errs, invalid("cache.shots_ttl", "%s is not positive", c.ShotsTTL))
+	}
+	return errors.Join(errs...)
+}
+
+func missing(key string) error {
+	return fmt.Errorf("%s is required, set %s", key, sources(key))
+}
+
+func invalid(key, format string, args ...any) error {
+	return fmt.Errorf("invalid %s (%s): %s", key, sources(key), fmt.Sprintf(format, args...))
+}
+
+// sources lists where the setting with key can be set, ex. "DB_HOST, -db-host or database.host"
+func sources(key string) string {
+	for _, s := range settings {
+		if s.key != key {
+			continue
+		}
+		if s.flag == "" {
+			return fmt.Sprintf("%s or %s", s.env, s.key)
+		}
+		return fmt.Sprintf("%s, -%s or %s", s.env, s.flag, s.key)
+	}
+	return key
+}
+
+func setString(field func(*Config) *string) setter {
+	return setter{parse: func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}}
+}
+
+func setInt(field func(*Config) *int) setter {
+	return setter{parse: func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", v)
+		}
+		*field(c) = n
+		return nil
+	}}
+}
+
+func setInt32(field func(*Config) *int32) setter {
+	return setter{parse: func(c *Config, v string) error {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", v)
+		}
+		*field(c) = int32(n)
+		return nil
+	}}
+}
+
+func setInt64(field func(*Config) *int64) setter {
+	return setter{parse: func(c *Config, v string) error {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", v)
+		}
+		*field(c) = n
+		return nil
+	}}
+}
+
+func setBool(field func(*Config) *bool) setter {
+	return setter{parse: func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", v)
+		}
+		*field(c) = b
+		return nil
+	}, boolean: true}
+}
+
+func setDuration(field func(*Config) *time.Duration) setter {
+	return setter{parse: func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration, ex. 30s", v)
+		}
+		*field(c) = d
+		return nil
+	}}
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv unsets every variable Load reads for the rest of the test
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, s := range append(settings, setting{env: "CONFIG_FILE"}) {
+		t.Setenv(s.env, "")
+	}
+}
+
+func newFlags(t *testing.T, args ...string) *flag.FlagSet {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	AddFlags(fs, DatabaseFlags, APIFlags)
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("could not parse the flags: %v", err)
+	}
+	return fs
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(file, []byte(`
+database:
+  host: file-host
+  port: 6543
+  name: shots
+  max_conns: 8
+  statement_timeout: 45s
+api:
+  port: 9000
+  query_timeout: 3s
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("DB_HOST", "env-host")
+	t.Setenv("DB_USERNAME", "jokic")
+	t.Setenv("PORT", "9001")
+
+	cfg, err := Load(newFlags(t, "-config", file, "-port", "9002", "-db-sslmode", "verify-full", "-db-auto-migrate"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := Default()
+	want.Database.Host = "env-host"
+	want.Database.Port = 6543
+	want.Database.User = "jokic"
+	want.Database.Name = "shots"
+	want.Database.SSLMode = "verify-full"
+	want.Database.AutoMigrate = true
+	want.Database.MaxConns = 8
+	want.Database.StatementTimeout = 45 * time.Second
+	want.API.Port = 9002
+	want.API.QueryTimeout = 3 * time.Second
+	if *cfg != *want {
+		t.Errorf("Load() = %+v, want %+v", *cfg, *want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		file string
+		want []string
+	}{
+		{
+			name: "invalid port",
+			env:  map[string]string{"PORT": "eighty"},
+			want: []string{`PORT: "eighty" is not a number`},
+		},
+		{
+			name: "every bad value",
+			env:  map[string]string{"DB_MAX_CONNS": "lots", "QUERY_TIMEOUT": "10", "DB_AUTO_MIGRATE": "sure"},
+			args: []string{"-db-max-conn-lifetime", "forever"},
+			want: []string{"DB_MAX_CONNS", "QUERY_TIMEOUT", `DB_AUTO_MIGRATE: "sure" is not true or false`, `-db-max-conn-lifetime: "forever" is not a duration`},
+		},
+		{
+			name: "unknown key in the file",
+			file: "database:\n  hostname: localhost\n",
+			want: []string{"hostname not found"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(tt.file), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("CONFIG_FILE", path)
+			}
+
+			_, err := Load(newFlags(t, tt.args...))
+			if err == nil {
+				t.Fatal("Load() error = nil")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Load() error = %q, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Default().Database
+	valid.User = "jokic"
+	valid.Name = "nba-shots"
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	d := Default().Database
+	d.SSLMode = "on"
+	d.SSLCert = "client.crt"
+	d.MaxConns = 2
+	d.MinConns = 4
+	err := d.Validate()
+	if err == nil {
+		t.Fatal("Validate() error = nil")
+	}
+	for _, want := range []string{
+		"database.user is required, set DB_USERNAME, -db-user or database.user",
+		"database.name is required",
+		`"on" is not one of disable, allow, prefer, require, verify-ca, verify-full`,
+		"key (database.sslkey) are set together",
+		"invalid database.sslcert",
+		"4 is more than the 2 max connections",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error = %q, want it to contain %q", err, want)
+		}
+	}
+
+	c := Default().Cache
+	c.Backend = "redis"
+	c.ShotsTTL = 0
+	err = c.Validate()
+	if err == nil || !strings.Contains(err.Error(), "cache.redis_addr is required") || !strings.Contains(err.Error(), "invalid cache.shots_ttl") {
+		t.Errorf("Cache.Validate() error = %v", err)
+	}
+
+	api := Default().API
+	api.Port = 0
+	if err := api.Validate(); err == nil || !strings.Contains(err.Error(), "invalid api.port (PORT, -port or api.port)") {
+		t.Errorf("API.Validate() error = %v", err)
+	}
+}
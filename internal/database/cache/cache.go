@@ -0,0 +1,232 @@
+// Package cache is a read-through cache in front of a database.Service. The catalog and shot
+// reads are answered from a Backend, everything else goes straight to the database.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"nba-shots/internal/config"
+	"nba-shots/internal/database"
+	"nba-shots/internal/types"
+)
+
+// Backend stores the encoded results, it's shared by every Service using it
+type Backend interface {
+	// Get returns the value under key, ok is false when there is none or it expired
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Invalidate drops every value, the Service calls it after its own writes and ingest after
+	// loading into the database
+	Invalidate(ctx context.Context) error
+	Close() error
+}
+
+// NewBackend opens the backend cfg picks, it returns nil when the cache is turned off
+func NewBackend(cfg config.Cache) (Backend, error) {
+	switch cfg.Backend {
+	case "none":
+		return nil, nil
+	case "lru":
+		return NewLRU(cfg.MaxEntries, cfg.MaxBytes), nil
+	case "redis":
+		return NewRedis(cfg.RedisAddr, cfg.RedisPassword), nil
+	}
+	return nil, fmt.Errorf("unknown cache backend %q", cfg.Backend)
+}
+
+// Service answers the reads of the database.Service it wraps from the backend. Writes made
+// through it invalidate the backend once they succeed, the ones made by another process have to
+// call Invalidate on the same backend or change the dataset version.
+type Service struct {
+	database.Service
+
+	backend       Backend
+	maxEntryBytes int64
+	catalogTTL    time.Duration
+	shotsTTL      time.Duration
+
+	// the newest dataset version GetDatasetVersion read, the keys of the reads made without one
+	// in their context are made with it
+	version atomic.Int64
+}
+
+var _ database.Service = (*Service)(nil)
+
+func New(db database.Service, backend Backend, cfg config.Cache) *Service {
+	return &Service{
+		Service:       db,
+		backend:       backend,
+		maxEntryBytes: cfg.MaxEntryBytes,
+		catalogTTL:    cfg.CatalogTTL,
+		shotsTTL:      cfg.ShotsTTL,
+	}
+}
+
+// Invalidate drops every cached result, the next reads go to the database
+func (s *Service) Invalidate(ctx context.Context) error {
+	return s.backend.Invalidate(ctx)
+}
+
+// Close closes the backend and the database
+func (s *Service) Close() {
+	if err := s.backend.Close(); err != nil {
+		log.Printf("could not close the cache: %v", err)
+	}
+	s.Service.Close()
+}
+
+// GetDatasetVersion reads the version from the database, a version another process loaded
+// moves the reads after it to new keys. Concurrent calls finishing out of order don't move it
+// back to an older version.
+func (s *Service) GetDatasetVersion(ctx context.Context) (*types.DatasetVersion, error) {
+	version, err := s.Service.GetDatasetVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		seen := s.version.Load()
+		if version.Version <= seen || s.version.CompareAndSwap(seen, version.Version) {
+			break
+		}
+	}
+	return version, nil
+}
+
+// versionedKey is key under the dataset version the request is answered for, the one
+// database.WithDatasetVersion put in ctx or else the newest one seen. The ETag of a response
+// is made of the same version as the results in its body.
+func (s *Service) versionedKey(ctx context.Context, key string) string {
+	version, ok := database.DatasetVersionFrom(ctx)
+	if !ok {
+		version = s.version.Load()
+	}
+	return fmt.Sprintf("v%d:%s", version, key)
+}
+
+// readThrough returns the result cached under key, or loads and caches it. The backend failing
+// only costs the cache, the result still comes from the database.
+func readThrough[T any](ctx context.Context, s *Service, key string, ttl time.Duration, load func() (T, error)) (T, error) {
+	key = s.versionedKey(ctx, key)
+	cached, ok, err := s.backend.Get(ctx, key)
+	if err != nil {
+		log.Printf("cache get %s: %v", key, err)
+	}
+	if ok {
+		var result T
+		err = json.Unmarshal(cached, &result)
+		if err == nil {
+			return result, nil
+		}
+		log.Printf("cache decode %s: %v", key, err)
+	}
+
+	result, err := load()
+	if err != nil {
+		return result, err
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("cache encode %s: %v", key, err)
+		return result, nil
+	}
+	if int64(len(encoded)) > s.maxEntryBytes {
+		return result, nil
+	}
+	err = s.backend.Set(ctx, key, encoded, ttl)
+	if err != nil {
+		log.Printf("cache set %s: %v", key, err)
+	}
+	return result, nil
+}
+
+// idsKey is the same for every order of the same ids, like the keys of the shot queries
+func idsKey(ids []int) string {
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	return fmt.Sprint(slices.Compact(ids))
+}
+
+func (s *Service) GetShots(ctx context.Context, args *types.RequestShotParams) ([]types.ReturnShot, error) {
+	return readThrough(ctx, s, "shots:"+args.Key(), s.shotsTTL, func() ([]types.ReturnShot, error) {
+		return s.Service.GetShots(ctx, args)
+	})
+}
+
+func (s *Service) GetShotSummary(ctx context.Context, args *types.RequestShotParams) (*types.ShotSummary, error) {
+	return readThrough(ctx, s, "summary:"+args.Key(), s.shotsTTL, func() (*types.ShotSummary, error) {
+		return s.Service.GetShotSummary(ctx, args)
+	})
+}
+
+func (s *Service) GetPlayerByID(ctx context.Context, id int) (*types.Player, error) {
+	return readThrough(ctx, s, fmt.Sprintf("player:%d", id), s.catalogTTL, func() (*types.Player, error) {
+		return s.Service.GetPlayerByID(ctx, id)
+	})
+}
+
+func (s *Service) GetPlayersByIDs(ctx context.Context, ids []int) ([]types.Player, error) {
+	return readThrough(ctx, s, "players:"+idsKey(ids), s.catalogTTL, func() ([]types.Player, error) {
+		return s.Service.GetPlayersByIDs(ctx, ids)
+	})
+}
+
+func (s *Service) GetPlayersByName(ctx context.Context, name string) ([]types.Player, error) {
+	return readThrough(ctx, s, fmt.Sprintf("players:name:%q", name), s.catalogTTL, func() ([]types.Player, error) {
+		return s.Service.GetPlayersByName(ctx, name)
+	})
+}
+
+func (s *Service) GetTeamByID(ctx context.Context, id int) (*types.Team, error) {
+	return readThrough(ctx, s, fmt.Sprintf("team:%d", id), s.catalogTTL, func() (*types.Team, error) {
+		return s.Service.GetTeamByID(ctx, id)
+	})
+}
+
+func (s *Service) GetTeamByIDForSeason(ctx context.Context, id, year int) (*types.Team, error) {
+	return readThrough(ctx, s, fmt.Sprintf("team:%d:season:%d", id, year), s.catalogTTL, func() (*types.Team, error) {
+		return s.Service.GetTeamByIDForSeason(ctx, id, year)
+	})
+}
+
+func (s *Service) GetTeamIdentities(ctx context.Context, teamIDs, years []int) ([]types.TeamIdentity, error) {
+	return readThrough(ctx, s, "identities:"+idsKey(teamIDs)+":"+idsKey(years), s.catalogTTL, func() ([]types.TeamIdentity, error) {
+		return s.Service.GetTeamIdentities(ctx, teamIDs, years)
+	})
+}
+
+func (s *Service) GetAllTeams(ctx context.Context) ([]types.Team, error) {
+	return readThrough(ctx, s, "teams", s.catalogTTL, func() ([]types.Team, error) {
+		return s.Service.GetAllTeams(ctx)
+	})
+}
+
+func (s *Service) GetSeasonByYear(ctx context.Context, year int) (*types.Season, error) {
+	return readThrough(ctx, s, fmt.Sprintf("season:%d", year), s.catalogTTL, func() (*types.Season, error) {
+		return s.Service.GetSeasonByYear(ctx, year)
+	})
+}
+
+func (s *Service) GetAllSeasons(ctx context.Context) ([]types.Season, error) {
+	return readThrough(ctx, s, "seasons", s.catalogTTL, func() ([]types.Season, error) {
+		return s.Service.GetAllSeasons(ctx)
+	})
+}
+
+func (s *Service) GetGameByID(ctx context.Context, id int) (*types.Game, error) {
+	return readThrough(ctx, s, fmt.Sprintf("game:%d", id), s.catalogTTL, func() (*types.Game, error) {
+		return s.Service.GetGameByID(ctx, id)
+	})
+}
+
+func (s *Service) GetLastXGames(ctx context.Context, n int) ([]types.Game, error) {
+	return readThrough(ctx, s, fmt.Sprintf("games:last:%d", n), s.catalogTTL, func() ([]types.Game, error) {
+		return s.Service.GetLastXGames(ctx, n)
+	})
+}
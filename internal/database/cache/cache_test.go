@@ -0,0 +1,450 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nba-shots/internal/config"
+	"nba-shots/internal/database"
+	"nba-shots/internal/database/memory"
+	"nba-shots/internal/database/servicetest"
+	"nba-shots/internal/types"
+)
+
+func TestConformance(t *testing.T) {
+	cfg := config.Default().Cache
+	servicetest.Run(t, New(memory.New(), NewLRU(cfg.MaxEntries, cfg.MaxBytes), cfg))
+}
+
+func TestConformanceRedis(t *testing.T) {
+	cfg := config.Default().Cache
+	servicetest.Run(t, New(memory.New(), NewRedis(startRedisStub(t), ""), cfg))
+}
+
+// countingDB counts the reads that reach the database
+type countingDB struct {
+	database.Service
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (db *countingDB) count(name string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.calls[name]++
+}
+
+func (db *countingDB) GetShots(ctx context.Context, args *types.RequestShotParams) ([]types.ReturnShot, error) {
+	db.count("shots")
+	return db.Service.GetShots(ctx, args)
+}
+
+func (db *countingDB) GetAllTeams(ctx context.Context) ([]types.Team, error) {
+	db.count("teams")
+	return db.Service.GetAllTeams(ctx)
+}
+
+func (db *countingDB) GetAllSeasons(ctx context.Context) ([]types.Season, error) {
+	db.count("seasons")
+	return db.Service.GetAllSeasons(ctx)
+}
+
+func (db *countingDB) GetPlayersByIDs(ctx context.Context, ids []int) ([]types.Player, error) {
+	db.count("players")
+	return db.Service.GetPlayersByIDs(ctx, ids)
+}
+
+func (db *countingDB) GetPlayerByID(ctx context.Context, id int) (*types.Player, error) {
+	db.count("player")
+	return db.Service.GetPlayerByID(ctx, id)
+}
+
+func TestReadThrough(t *testing.T) {
+	ctx := context.Background()
+	inner, err := memory.NewWithBatches(servicetest.Fixture())
+	if err != nil {
+		t.Fatal(err)
+	}
+	counting := &countingDB{Service: inner, calls: map[string]int{}}
+	cfg := config.Default().Cache
+	db := New(counting, NewLRU(cfg.MaxEntries, cfg.MaxBytes), cfg)
+
+	args := servicetest.Args()
+	args.PlayerIDs = []int{servicetest.Curry, servicetest.LeBron}
+	args.StartTimeLeftSecs = 900 // outside a quarter, ignored like -1
+	first, err := db.GetShots(ctx, &args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same query with the ids reordered and repeated
+	same := servicetest.Args()
+	same.PlayerIDs = []int{servicetest.LeBron, servicetest.Curry, servicetest.LeBron}
+	second, err := db.GetShots(ctx, &same)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counting.calls["shots"] != 1 || len(first) == 0 || len(first) != len(second) {
+		t.Errorf("expected one database query for %d shots, got %d queries, %d and %d shots", len(first), counting.calls["shots"], len(first), len(second))
+	}
+
+	for range 3 {
+		if _, err := db.GetAllTeams(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if counting.calls["teams"] != 1 {
+		t.Errorf("expected the teams to be read once, got %d", counting.calls["teams"])
+	}
+
+	for _, ids := range [][]int{{servicetest.Curry, servicetest.Kawhi}, {servicetest.Kawhi, servicetest.Curry}} {
+		if _, err := db.GetPlayersByIDs(ctx, ids); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if counting.calls["players"] != 1 {
+		t.Errorf("expected the same players in another order to be read once, got %d", counting.calls["players"])
+	}
+
+	// lookups that fail aren't cached
+	for range 2 {
+		if _, err := db.GetPlayerByID(ctx, 1); err == nil {
+			t.Fatal("expected an error for an unknown player")
+		}
+	}
+	if counting.calls["player"] != 2 {
+		t.Errorf("expected both failed lookups to reach the database, got %d", counting.calls["player"])
+	}
+
+	// a season deleted through the cache isn't served from it anymore
+	if _, err := db.GetAllSeasons(ctx); err != nil {
+		t.Fatal(err)
+	}
+	err = db.DeleteSeason(ctx, servicetest.Year)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seasons, err := db.GetAllSeasons(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counting.calls["seasons"] != 2 || len(seasons) != 0 {
+		t.Errorf("expected the seasons to be read again after the delete, got %d reads of %v", counting.calls["seasons"], seasons)
+	}
+}
+
+// TestReadThroughNewVersion loads into the database without going through the cache, like
+// ingest does from another process
+func TestReadThroughNewVersion(t *testing.T) {
+	ctx := context.Background()
+	inner, err := memory.NewWithBatches(servicetest.Fixture())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default().Cache
+	db := New(inner, NewLRU(cfg.MaxEntries, cfg.MaxBytes), cfg)
+
+	if _, err := db.GetDatasetVersion(ctx); err != nil {
+		t.Fatal(err)
+	}
+	seasons, err := db.GetAllSeasons(ctx)
+	if err != nil || len(seasons) != 1 {
+		t.Fatalf("expected the fixture's season, got %v, %v", seasons, err)
+	}
+
+	err = inner.DeleteSeason(ctx, servicetest.Year)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seasons, err = db.GetAllSeasons(ctx)
+	if err != nil || len(seasons) != 1 {
+		t.Fatalf("expected the cached season until the new version is read, got %v, %v", seasons, err)
+	}
+	if _, err := db.GetDatasetVersion(ctx); err != nil {
+		t.Fatal(err)
+	}
+	seasons, err = db.GetAllSeasons(ctx)
+	if err != nil || len(seasons) != 0 {
+		t.Errorf("expected no seasons in the new version, got %v, %v", seasons, err)
+	}
+}
+
+// versionDB answers GetDatasetVersion with version, whatever the data is
+type versionDB struct {
+	database.Service
+	version int64
+}
+
+func (db *versionDB) GetDatasetVersion(ctx context.Context) (*types.DatasetVersion, error) {
+	return &types.DatasetVersion{Version: db.version}, nil
+}
+
+func TestReadThroughRequestVersion(t *testing.T) {
+	ctx := context.Background()
+	inner, err := memory.NewWithBatches(servicetest.Fixture())
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions := &versionDB{Service: inner, version: 5}
+	counting := &countingDB{Service: versions, calls: map[string]int{}}
+	cfg := config.Default().Cache
+	db := New(counting, NewLRU(cfg.MaxEntries, cfg.MaxBytes), cfg)
+
+	// a call reading an older version after a newer one doesn't move the keys back
+	if _, err := db.GetDatasetVersion(ctx); err != nil {
+		t.Fatal(err)
+	}
+	versions.version = 4
+	if _, err := db.GetDatasetVersion(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if db.version.Load() != 5 {
+		t.Errorf("expected the newest version to stay, got %d", db.version.Load())
+	}
+
+	// the version in the request's context picks the keys, not the newest one seen
+	for _, version := range []int64{4, 5, 4} {
+		if _, err := db.GetAllSeasons(database.WithDatasetVersion(ctx, version)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.GetAllSeasons(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if counting.calls["seasons"] != 2 {
+		t.Errorf("expected one read per version, got %d", counting.calls["seasons"])
+	}
+}
+
+func TestReadThroughSkipsLargeResults(t *testing.T) {
+	ctx := context.Background()
+	inner, err := memory.NewWithBatches(servicetest.Fixture())
+	if err != nil {
+		t.Fatal(err)
+	}
+	counting := &countingDB{Service: inner, calls: map[string]int{}}
+	cfg := config.Default().Cache
+	cfg.MaxEntryBytes = 64
+	lru := NewLRU(cfg.MaxEntries, cfg.MaxBytes)
+	db := New(counting, lru, cfg)
+
+	args := servicetest.Args()
+	for range 2 {
+		if _, err := db.GetShots(ctx, &args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if counting.calls["shots"] != 2 || lru.Len() != 0 {
+		t.Errorf("expected every shot to be read from the database twice, got %d queries and %d cached", counting.calls["shots"], lru.Len())
+	}
+}
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2016, 6, 19, 0, 0, 0, 0, time.UTC)
+	lru := NewLRU(3, 20)
+	lru.now = func() time.Time { return now }
+
+	for _, key := range []string{"a", "b", "c"} {
+		lru.Set(ctx, key, []byte("1234"), time.Minute)
+	}
+	lru.Get(ctx, "a")
+	// d is one entry too many, b is the least recently used
+	lru.Set(ctx, "d", []byte("1234"), time.Minute)
+	if _, ok, _ := lru.Get(ctx, "b"); ok {
+		t.Error("expected b to be evicted")
+	}
+
+	// e takes 10 bytes, c has to go for it to fit in 20
+	lru.Set(ctx, "e", []byte("123456789"), time.Minute)
+	for key, want := range map[string]bool{"a": true, "c": false, "d": true, "e": true} {
+		if _, ok, _ := lru.Get(ctx, key); ok != want {
+			t.Errorf("expected %s cached to be %t", key, want)
+		}
+	}
+
+	// larger than the whole cache
+	lru.Set(ctx, "f", []byte(strings.Repeat("x", 20)), time.Minute)
+	if _, ok, _ := lru.Get(ctx, "f"); ok || lru.Len() != 3 {
+		t.Errorf("expected f to be skipped, got %d entries", lru.Len())
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok, _ := lru.Get(ctx, "a"); ok {
+		t.Error("expected a to expire")
+	}
+
+	lru.Invalidate(ctx)
+	if lru.Len() != 0 {
+		t.Errorf("expected an empty cache, got %d entries", lru.Len())
+	}
+}
+
+func TestRedis(t *testing.T) {
+	ctx := context.Background()
+	addr := startRedisStub(t)
+	r := NewRedis(addr, "hunter2")
+	r.generationTTL = 50 * time.Millisecond
+	t.Cleanup(func() { r.Close() })
+
+	if _, ok, err := r.Get(ctx, "teams"); ok || err != nil {
+		t.Fatalf("expected a miss, got %t, %v", ok, err)
+	}
+	if err := r.Set(ctx, "teams", []byte("[\r\n]"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	value, ok, err := r.Get(ctx, "teams")
+	if err != nil || !ok || string(value) != "[\r\n]" {
+		t.Fatalf("expected the value back, got %q, %t, %v", value, ok, err)
+	}
+
+	// another instance on the same server sees the invalidation once its generation is read again
+	other := NewRedis(addr, "hunter2")
+	t.Cleanup(func() { other.Close() })
+	if err := other.Invalidate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := r.Get(ctx, "teams"); !ok || err != nil {
+		t.Errorf("expected the generation to be cached, got %t, %v", ok, err)
+	}
+	time.Sleep(r.generationTTL)
+	if _, ok, err := r.Get(ctx, "teams"); ok || err != nil {
+		t.Errorf("expected a miss after the invalidation, got %t, %v", ok, err)
+	}
+
+	// its own invalidation right away
+	if err := r.Set(ctx, "teams", []byte("[]"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Invalidate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := r.Get(ctx, "teams"); ok || err != nil {
+		t.Errorf("expected a miss right after its own invalidation, got %t, %v", ok, err)
+	}
+
+	// the requests share a pool of connections instead of waiting for one
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key := fmt.Sprintf("player:%d", i)
+			if err := r.Set(ctx, key, []byte(key), time.Minute); err != nil {
+				t.Error(err)
+				return
+			}
+			if value, ok, err := r.Get(ctx, key); !ok || err != nil || string(value) != key {
+				t.Errorf("expected %s back, got %q, %t, %v", key, value, ok, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	wrong := NewRedis(addr, "password")
+	t.Cleanup(func() { wrong.Close() })
+	if _, _, err := wrong.Get(ctx, "teams"); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("expected the wrong password to be refused, got %v", err)
+	}
+}
+
+// startRedisStub serves GET, SET with PX, INCR and AUTH from a map, a stand in for the real
+// server. AUTH is only enforced once it was called with the first password it got.
+func startRedisStub(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var (
+		mu       sync.Mutex
+		values   = map[string]string{}
+		expires  = map[string]time.Time{}
+		password string
+	)
+
+	handle := func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch strings.ToUpper(args[0]) {
+		case "AUTH":
+			if password == "" {
+				password = args[1]
+			}
+			if args[1] != password {
+				return "-WRONGPASS invalid password\r\n"
+			}
+			return "+OK\r\n"
+		case "GET":
+			value, ok := values[args[1]]
+			if exp, set := expires[args[1]]; !ok || (set && time.Now().After(exp)) {
+				return "$-1\r\n"
+			}
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+		case "SET":
+			values[args[1]] = args[2]
+			if len(args) == 5 && strings.ToUpper(args[3]) == "PX" {
+				ms, _ := strconv.Atoi(args[4])
+				expires[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+			}
+			return "+OK\r\n"
+		case "INCR":
+			n, _ := strconv.Atoi(values[args[1]])
+			values[args[1]] = strconv.Itoa(n + 1)
+			return fmt.Sprintf(":%d\r\n", n+1)
+		}
+		return "-ERR unknown command\r\n"
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				rd := bufio.NewReader(conn)
+				for {
+					args, err := readCommand(rd)
+					if err != nil {
+						return
+					}
+					io.WriteString(conn, handle(args))
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	var n int
+	if _, err := fmt.Fscanf(rd, "*%d\r\n", &n); err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		var size int
+		if _, err := fmt.Fscanf(rd, "$%d\r\n", &size); err != nil {
+			return nil, err
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
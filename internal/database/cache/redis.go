@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a Backend on a redis compatible server, shared by the api instances and ingest.
+// Invalidate bumps a generation stored next to the values instead of deleting them, values of
+// older generations are never read again and expire on their own.
+type Redis struct {
+	client *redis.Client
+	prefix string
+
+	// the generation is read again at most every generationTTL rather than on every Get, an
+	// Invalidate from another instance is seen that much later. The keys of cache.Service have
+	// the dataset version in them already, an ingest doesn't wait for it.
+	generationTTL time.Duration
+	mu            sync.Mutex
+	generation    int64
+	generationAt  time.Time
+}
+
+var _ Backend = (*Redis)(nil)
+
+// NewRedis connects lazily to addr with a pool of connections, a broken one is replaced by the
+// client
+func NewRedis(addr, password string) *Redis {
+	return &Redis{
+		client: redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: password,
+			// every command waits at most this long, the cache is skipped rather than slowing a request
+			DialTimeout:     time.Second,
+			ReadTimeout:     time.Second,
+			WriteTimeout:    time.Second,
+			DisableIdentity: true,
+		}),
+		prefix:        "nba-shots:cache:",
+		generationTTL: time.Second,
+	}
+}
+
+func (r *Redis) generationKey() string {
+	return r.prefix + "generation"
+}
+
+func (r *Redis) valueKey(gen int64, key string) string {
+	return r.prefix + strconv.FormatInt(gen, 10) + ":" + key
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	gen, err := r.currentGeneration(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	value, err := r.client.Get(ctx, r.valueKey(gen, key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	gen, err := r.currentGeneration(ctx)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, r.valueKey(gen, key), value, ttl).Err()
+}
+
+func (r *Redis) Invalidate(ctx context.Context) error {
+	gen, err := r.client.Incr(ctx, r.generationKey()).Result()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generation, r.generationAt = gen, time.Now()
+	return nil
+}
+
+// currentGeneration is the generation of the values, 0 before the first Invalidate
+func (r *Redis) currentGeneration(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	gen, fresh := r.generation, time.Since(r.generationAt) < r.generationTTL
+	r.mu.Unlock()
+	if fresh {
+		return gen, nil
+	}
+
+	started := time.Now()
+	gen, err := r.client.Get(ctx, r.generationKey()).Int64()
+	if errors.Is(err, redis.Nil) {
+		gen, err = 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// an Invalidate of this instance while the read was on its way has the newer generation
+	if r.generationAt.Before(started) {
+		r.generation, r.generationAt = gen, time.Now()
+	}
+	return r.generation, nil
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
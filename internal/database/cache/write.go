@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"context"
+	"log"
+
+	"nba-shots/internal/types"
+)
+
+// invalidateAfter drops the cache once a write went through, a write that failed may still have
+// changed some rows so it's dropped either way
+func (s *Service) invalidateAfter(ctx context.Context, err error) error {
+	// the write's ctx may be the one that got cancelled
+	if ierr := s.backend.Invalidate(context.WithoutCancel(ctx)); ierr != nil {
+		log.Printf("could not invalidate the cache: %v", ierr)
+	}
+	return err
+}
+
+func (s *Service) InsertSeasonBatch(ctx context.Context, batch *types.SeasonBatch, chunkSize int) error {
+	return s.invalidateAfter(ctx, s.Service.InsertSeasonBatch(ctx, batch, chunkSize))
+}
+
+func (s *Service) InsertPlayers(ctx context.Context, players []types.Player) error {
+	return s.invalidateAfter(ctx, s.Service.InsertPlayers(ctx, players))
+}
+
+func (s *Service) InsertTeams(ctx context.Context, teams []types.Team) error {
+	return s.invalidateAfter(ctx, s.Service.InsertTeams(ctx, teams))
+}
+
+func (s *Service) InsertSeasons(ctx context.Context, seasons []types.Season) error {
+	return s.invalidateAfter(ctx, s.Service.InsertSeasons(ctx, seasons))
+}
+
+func (s *Service) InsertGames(ctx context.Context, games []types.Game) error {
+	return s.invalidateAfter(ctx, s.Service.InsertGames(ctx, games))
+}
+
+func (s *Service) InsertShots(ctx context.Context, shots []types.Shot) error {
+	return s.invalidateAfter(ctx, s.Service.InsertShots(ctx, shots))
+}
+
+func (s *Service) InsertPlayerTeams(ctx context.Context, rows []types.PlayerTeam) error {
+	return s.invalidateAfter(ctx, s.Service.InsertPlayerTeams(ctx, rows))
+}
+
+func (s *Service) InsertPlayerSeasons(ctx context.Context, rows []types.PlayerSeason) error {
+	return s.invalidateAfter(ctx, s.Service.InsertPlayerSeasons(ctx, rows))
+}
+
+func (s *Service) InsertPlayerGames(ctx context.Context, rows []types.PlayerGame) error {
+	return s.invalidateAfter(ctx, s.Service.InsertPlayerGames(ctx, rows))
+}
+
+func (s *Service) InsertTeamSeasons(ctx context.Context, rows []types.TeamSeason) error {
+	return s.invalidateAfter(ctx, s.Service.InsertTeamSeasons(ctx, rows))
+}
+
+func (s *Service) InsertTeamGames(ctx context.Context, rows []types.TeamGame) error {
+	return s.invalidateAfter(ctx, s.Service.InsertTeamGames(ctx, rows))
+}
+
+func (s *Service) InsertGameSeasons(ctx context.Context, rows []types.GameSeason) error {
+	return s.invalidateAfter(ctx, s.Service.InsertGameSeasons(ctx, rows))
+}
+
+func (s *Service) RebuildTeamIdentities(ctx context.Context, teamIDs []int) error {
+	return s.invalidateAfter(ctx, s.Service.RebuildTeamIdentities(ctx, teamIDs))
+}
+
+func (s *Service) DeleteSeason(ctx context.Context, year int) error {
+	return s.invalidateAfter(ctx, s.Service.DeleteSeason(ctx, year))
+}
+
+func (s *Service) ReplaceSeason(ctx context.Context, year int, batch *types.SeasonBatch, chunkSize int) error {
+	return s.invalidateAfter(ctx, s.Service.ReplaceSeason(ctx, year, batch, chunkSize))
+}
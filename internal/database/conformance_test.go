@@ -0,0 +1,11 @@
+package database_test
+
+import (
+	"nba-shots/internal/database"
+	"nba-shots/internal/database/servicetest"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	servicetest.Run(t, database.SharedTestService())
+}
@@ -4,99 +4,216 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
+	"net"
+	"net/url"
+	"strconv"
 	"time"
 
+	"nba-shots/internal/config"
 	"nba-shots/internal/types"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/jackc/pgx/v5/stdlib"
-	_ "github.com/joho/godotenv/autoload"
 )
 
-// Service represents a service that interacts with a database.
-type Service interface {
-	InsertPlayers([]types.Player) error
-	InsertTeams([]types.Team) error
-	InsertSeasons([]types.Season) error
-	InsertGames([]types.Game) error
-	InsertShots([]types.Shot) error
-	InsertPlayerTeams([]types.PlayerTeam) error
-	InsertPlayerSeasons([]types.PlayerSeason) error
-	InsertPlayerGames([]types.PlayerGame) error
-	InsertTeamSeasons([]types.TeamSeason) error
-	InsertTeamGames([]types.TeamGame) error
-	InsertGameSeasons([]types.GameSeason) error
+// ShotReader answers the shot queries of the api
+type ShotReader interface {
+	GetShots(context.Context, *types.RequestShotParams) ([]types.ReturnShot, error)
+	GetShotSummary(context.Context, *types.RequestShotParams) (*types.ShotSummary, error)
+	// GetShotsBy and GetShotSummariesBy answer the query for every id of the group at once,
+	// keyed by the id
+	GetShotsBy(context.Context, *types.RequestShotParams, types.ShotGroup) (map[int][]types.ReturnShot, error)
+	GetShotSummariesBy(context.Context, *types.RequestShotParams, types.ShotGroup) (map[int]*types.ShotSummary, error)
+}
+
+// CatalogReader looks up the players, teams, seasons and games the shots belong to
+type CatalogReader interface {
+	GetPlayerByID(context.Context, int) (*types.Player, error)
+	GetPlayersByIDs(context.Context, []int) ([]types.Player, error)
+	GetPlayersByName(context.Context, string) ([]types.Player, error)
+	GetTeamByID(context.Context, int) (*types.Team, error)
+	GetTeamByIDForSeason(context.Context, int, int) (*types.Team, error)
+	GetTeamIdentities(context.Context, []int, []int) ([]types.TeamIdentity, error)
+	GetAllTeams(context.Context) ([]types.Team, error)
+	GetSeasonByYear(context.Context, int) (*types.Season, error)
+	GetAllSeasons(context.Context) ([]types.Season, error)
+	GetGameByID(context.Context, int) (*types.Game, error)
+	GetLastXGames(context.Context, int) ([]types.Game, error)
+	GetDatasetVersion(context.Context) (*types.DatasetVersion, error)
+}
+
+// RelationReader follows the link tables between players, teams, seasons and games. Every
+// method takes many ids so the relations of a whole list can be loaded at once, the link tables
+// are filtered by both of their ids and an empty list matches any id.
+type RelationReader interface {
+	GetTeamsByIDs(context.Context, []int) ([]types.Team, error)
+	GetSeasonsByYears(context.Context, []int) ([]types.Season, error)
+	GetGamesByIDs(context.Context, []int) ([]types.Game, error)
+	GetPlayerTeams(context.Context, []int, []int) ([]types.PlayerTeam, error)
+	GetPlayerSeasons(context.Context, []int, []int) ([]types.PlayerSeason, error)
+	GetPlayerGames(context.Context, []int, []int) ([]types.PlayerGame, error)
+	GetTeamSeasons(context.Context, []int, []int) ([]types.TeamSeason, error)
+	GetTeamGames(context.Context, []int, []int) ([]types.TeamGame, error)
+	GetGameSeasons(context.Context, []int, []int) ([]types.GameSeason, error)
+}
+
+// IngestWriter loads and removes seasons, and reads back what ingest needs to check a load
+type IngestWriter interface {
+	InsertSeasonBatch(context.Context, *types.SeasonBatch, int) error
+	InsertPlayers(context.Context, []types.Player) error
+	InsertTeams(context.Context, []types.Team) error
+	InsertSeasons(context.Context, []types.Season) error
+	InsertGames(context.Context, []types.Game) error
+	InsertShots(context.Context, []types.Shot) error
+	InsertPlayerTeams(context.Context, []types.PlayerTeam) error
+	InsertPlayerSeasons(context.Context, []types.PlayerSeason) error
+	InsertPlayerGames(context.Context, []types.PlayerGame) error
+	InsertTeamSeasons(context.Context, []types.TeamSeason) error
+	InsertTeamGames(context.Context, []types.TeamGame) error
+	InsertGameSeasons(context.Context, []types.GameSeason) error
+	RebuildTeamIdentities(context.Context, []int) error
+	DeleteSeason(context.Context, int) error
+	ReplaceSeason(context.Context, int, *types.SeasonBatch, int) error
+
+	GetSeasonTableCounts(context.Context, int) ([]types.TableCount, error)
+	GetSeasonQuality(context.Context, int) (*types.SeasonQuality, error)
+	GetGameIDsForSeasons(context.Context, []int) ([]int, error)
+}
+
+// QueryLog is the history of the shot queries the api answered
+type QueryLog interface {
 	InsertQueryHistory(context.Context, *types.QueryHistoryRecord) error
-	QueryShots(string, []interface{}, int) ([]types.ReturnShot, error)
-
-	GetShots(*types.RequestShotParams) ([]types.ReturnShot, error)
-	GetPlayerByID(int) (*types.Player, error)
-	GetPlayersByIDs([]int) ([]types.Player, error)
-	GetPlayersByName(string) ([]types.Player, error)
-	GetTeamByID(int) (*types.Team, error)
-	GetAllTeams() ([]types.Team, error)
-	GetSeasonByYear(int) (*types.Season, error)
-	GetAllSeasons() ([]types.Season, error)
-	GetGameByID(int) (*types.Game, error)
-	GetLastXGames(int) ([]types.Game, error)
-
-	IsEmptyDatabase() (bool, error)
-	Health() map[string]string
+	GetQueryFilterUsage(context.Context, int) ([]types.QueryFilterUsage, error)
+}
+
+// RefreshLog is the record of the watcher's refresh runs
+type RefreshLog interface {
+	StartRefreshRun(context.Context) (int, error)
+	FinishRefreshRun(context.Context, *types.RefreshRun) error
+	GetRefreshRuns(context.Context, int) ([]types.RefreshRun, error)
+}
+
+// Migrator applies and reverts the embedded migrations
+type Migrator interface {
+	MigrateUp(context.Context) ([]string, error)
+	MigrateDown(context.Context, int) ([]string, error)
+	MigrationStatus(context.Context) ([]types.MigrationStatus, error)
+}
+
+type HealthChecker interface {
+	Health(context.Context) map[string]string
+}
+
+// Service is everything the database does, the binaries hand out the parts of it they need.
+type Service interface {
+	ShotReader
+	CatalogReader
+	RelationReader
+	IngestWriter
+	QueryLog
+	RefreshLog
+	Migrator
+	HealthChecker
+
+	IsEmptyDatabase(context.Context) (bool, error)
 	Close()
 }
 
 type service struct {
-	db *pgxpool.Pool
+	db     *pgxpool.Pool
+	name   string
+	schema string
 }
 
-var (
-	database   = os.Getenv("DB_DATABASE")
-	password   = os.Getenv("DB_PASSWORD")
-	username   = os.Getenv("DB_USERNAME")
-	port       = os.Getenv("DB_PORT")
-	host       = os.Getenv("DB_HOST")
-	schema     = os.Getenv("DB_SCHEMA")
-	dbInstance *service
-)
+// connString is the postgres url of cfg, the pool settings are applied to the parsed config
+func connString(cfg config.Database) string {
+	query := url.Values{}
+	query.Set("sslmode", cfg.SSLMode)
+	query.Set("search_path", cfg.Schema)
+	if cfg.SSLRootCert != "" {
+		query.Set("sslrootcert", cfg.SSLRootCert)
+	}
+	if cfg.SSLCert != "" {
+		query.Set("sslcert", cfg.SSLCert)
+		query.Set("sslkey", cfg.SSLKey)
+	}
 
-func New() Service {
-	// Reuse Connection
-	if dbInstance != nil {
-		return dbInstance
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		Path:     "/" + cfg.Name,
+		RawQuery: query.Encode(),
 	}
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable&search_path=%s", username, password, host, port, database, schema)
-	config, err := pgxpool.ParseConfig(connStr)
+	return u.String()
+}
+
+// New connects to the database in cfg, every call makes its own pool so the caller closes it
+func New(ctx context.Context, cfg config.Database) (Service, error) {
+	return newService(ctx, cfg)
+}
 
+func newService(ctx context.Context, cfg config.Database) (*service, error) {
+	poolConfig, err := pgxpool.ParseConfig(connString(cfg))
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("parsing the connection string: %w", err)
 	}
 
-	db, err := pgxpool.NewWithConfig(context.Background(), config)
+	if cfg.StatementTimeout > 0 {
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+	if cfg.MaxConns > 0 {
+		poolConfig.MaxConns = cfg.MaxConns
+	}
+	if cfg.MinConns > 0 {
+		poolConfig.MinConns = cfg.MinConns
+	}
+	if cfg.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	}
+	if cfg.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
+	}
 
+	db, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("connecting to %s: %w", cfg.Name, err)
+	}
+
+	s := &service{
+		db:     db,
+		name:   cfg.Name,
+		schema: cfg.Schema,
 	}
 
-	dbInstance = &service{
-		db: db,
+	if cfg.AutoMigrate {
+		applied, err := s.MigrateUp(ctx)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		log.Printf("Applied %d migrations on startup\n", len(applied))
 	}
-	return dbInstance
+	return s, nil
 }
 
 // Health checks the health of the database connection by pinging the database.
 // It returns a map with keys indicating various health statistics.
-func (s *service) Health() map[string]string {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+func (s *service) Health(ctx context.Context) map[string]string {
+	pingCtx, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
 
 	stats := make(map[string]string)
 
 	// Ping the database
-	err := s.db.Ping(ctx)
+	err := s.db.Ping(pingCtx)
 	if err != nil {
 		stats["status"] = "down"
 		stats["error"] = fmt.Sprintf("db down: %v", err)
+		// a caller that went away doesn't mean the database did
+		if ctx.Err() != nil {
+			return stats
+		}
 		log.Fatalf("db down: %v", err) // Log the error and terminate the program
 		return stats
 	}
@@ -113,6 +230,6 @@ func (s *service) Health() map[string]string {
 // If the connection is successfully closed, it returns nil.
 // If an error occurs while closing the connection, it returns the error.
 func (s *service) Close() {
-	log.Printf("Disconnected from database: %s", database)
+	log.Printf("Disconnected from database: %s", s.name)
 	s.db.Close()
 }
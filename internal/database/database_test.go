@@ -3,6 +3,10 @@ package database
 import (
 	"context"
 	"log"
+	"nba-shots/internal/config"
+	"nba-shots/internal/types"
+	"nba-shots/migrations"
+	"strings"
 	"testing"
 	"time"
 
@@ -33,9 +37,7 @@ func mustStartPostgresContainer() (func(context.Context) error, error) {
 		return nil, err
 	}
 
-	database = dbName
-	password = dbPwd
-	username = dbUser
+	testConfig = config.Database{Name: dbName, Password: dbPwd, User: dbUser, Schema: "public", SSLMode: "disable"}
 
 	dbHost, err := dbContainer.Host(context.Background())
 	if err != nil {
@@ -47,19 +49,37 @@ func mustStartPostgresContainer() (func(context.Context) error, error) {
 		return dbContainer.Terminate, err
 	}
 
-	host = dbHost
-	port = dbPort.Port()
+	testConfig.Host = dbHost
+	testConfig.Port = dbPort.Int()
 
 	return dbContainer.Terminate, err
 }
 
+// testConfig reaches the postgres container, testDB is connected to it and shared by the tests
+var (
+	testConfig config.Database
+	testDB     *service
+)
+
 func TestMain(m *testing.M) {
 	teardown, err := mustStartPostgresContainer()
 	if err != nil {
 		log.Fatalf("could not start postgres container: %v", err)
 	}
 
+	testDB, err = newService(context.Background(), testConfig)
+	if err != nil {
+		log.Fatalf("could not connect to the test database: %v", err)
+	}
+
+	// the tests run against the same schema the binaries migrate to
+	_, err = testDB.MigrateUp(context.Background())
+	if err != nil {
+		log.Fatalf("could not migrate the test database: %v", err)
+	}
+
 	m.Run()
+	testDB.Close()
 
 	if teardown != nil && teardown(context.Background()) != nil {
 		log.Fatalf("could not teardown postgres container: %v", err)
@@ -67,16 +87,27 @@ func TestMain(m *testing.M) {
 }
 
 func TestNew(t *testing.T) {
-	srv := New()
-	if srv == nil {
-		t.Fatal("New() returned nil")
+	srv, err := New(context.Background(), testConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer srv.Close()
+
+	other, err := New(context.Background(), testConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer other.Close()
+	if srv == other {
+		t.Error("expected every New to make its own pool")
 	}
 }
 
 func TestHealth(t *testing.T) {
-	srv := New()
+	ctx := context.Background()
+	srv := testDB
 
-	stats := srv.Health()
+	stats := srv.Health(ctx)
 
 	if stats["status"] != "up" {
 		t.Fatalf("expected status to be up, got %s", stats["status"])
@@ -91,7 +122,178 @@ func TestHealth(t *testing.T) {
 	}
 }
 
+func TestMigrations(t *testing.T) {
+	ctx := context.Background()
+	srv := testDB
+
+	statuses, err := srv.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	all, err := loadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(statuses) != len(all) {
+		t.Fatalf("expected %d migrations, got %+v", len(all), statuses)
+	}
+	for _, status := range statuses {
+		if status.Status != types.MigrationApplied {
+			t.Errorf("expected %s to be applied, got %s", status.Version, status.Status)
+		}
+	}
+
+	// every down has to undo its up for the schema to come back cleanly
+	reverted, err := srv.MigrateDown(ctx, len(all))
+	if err != nil {
+		t.Fatalf("could not revert migrations: %v", err)
+	}
+	if len(reverted) != len(all) || reverted[0] != all[len(all)-1].Version {
+		t.Errorf("expected every migration reverted newest first, got %v", reverted)
+	}
+
+	empty, err := srv.IsEmptyDatabase(ctx)
+	if err == nil && !empty {
+		t.Error("expected the tables to be gone")
+	}
+
+	applied, err := srv.MigrateUp(ctx)
+	if err != nil {
+		t.Fatalf("could not reapply migrations: %v", err)
+	}
+	if len(applied) != len(all) {
+		t.Errorf("expected %d migrations reapplied, got %v", len(all), applied)
+	}
+
+	applied, err = srv.MigrateUp(ctx)
+	if err != nil || len(applied) != 0 {
+		t.Errorf("expected nothing left to apply, got %v, %v", applied, err)
+	}
+}
+
+func TestReplaceSeason(t *testing.T) {
+	ctx := context.Background()
+	srv := testDB
+	gameDate := time.Date(2024, 4, 14, 0, 0, 0, 0, time.UTC)
+
+	batch := func(shots int) *types.SeasonBatch {
+		b := &types.SeasonBatch{
+			Players: []types.Player{{ID: 977, Name: "Kobe Bryant"}},
+			Teams: []types.Team{
+				{ID: 1610612747, Name: "Los Angeles Lakers", Abbreviation: "LAL"},
+				{ID: 1610612757, Name: "Portland Trail Blazers", Abbreviation: "POR"},
+			},
+			Seasons:       []types.Season{{Year: 2004, SeasonYears: "2003-04"}},
+			Games:         []types.Game{{ID: 20301187, HomeTeamID: 1610612757, AwayTeamID: 1610612747, SeasonYear: 2004, GameDate: gameDate}},
+			PlayerTeams:   []types.PlayerTeam{{PlayerID: 977, TeamID: 1610612747, TeamName: "Los Angeles Lakers"}},
+			PlayerSeasons: []types.PlayerSeason{{PlayerID: 977, SeasonYear: 2004}},
+			PlayerGames:   []types.PlayerGame{{PlayerID: 977, GameID: 20301187, GameDate: gameDate}},
+			TeamSeasons: []types.TeamSeason{
+				{TeamID: 1610612747, SeasonYear: 2004, TeamName: "Los Angeles Lakers", Abbreviation: "LAL"},
+				{TeamID: 1610612757, SeasonYear: 2004, TeamName: "Portland Trail Blazers", Abbreviation: "POR"},
+			},
+			TeamGames:   []types.TeamGame{{TeamID: 1610612747, GameID: 20301187, GameDate: gameDate}},
+			GameSeasons: []types.GameSeason{{GameID: 20301187, SeasonYear: 2004}},
+		}
+		for i := 0; i < shots; i++ {
+			b.Shots = append(b.Shots, types.Shot{
+				PlayerID: 977, GameID: 20301187, TeamID: 1610612747,
+				HomeTeamID: 1610612757, AwayTeamID: 1610612747, SeasonYear: 2004,
+				EventType: "Made Shot", ShotMade: true, ActionType: "Jump Shot", ShotType: "3PT Field Goal",
+				BasicZone: "Above the Break 3", ZoneName: "Left Side Center", ZoneABB: "LC", ZoneRange: "24+ ft.",
+				LocX: 20, LocY: 21.35, ShotDistance: 25, Quarter: 4, SecsLeft: 10, TotalTimeLeftSecs: 10,
+				Position: "SG", PositionGroup: "G", GameDate: gameDate,
+			})
+		}
+		return b
+	}
+
+	shotCount := func() int {
+		counts, err := srv.GetSeasonTableCounts(ctx, 2004)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, count := range counts {
+			if count.Table == "shot" {
+				return count.Rows
+			}
+		}
+		return -1
+	}
+
+	err := srv.InsertSeasonBatch(ctx, batch(3), 2)
+	if err != nil {
+		t.Fatalf("could not insert the season: %v", err)
+	}
+	if n := shotCount(); n != 3 {
+		t.Fatalf("expected 3 shots, got %d", n)
+	}
+
+	err = srv.ReplaceSeason(ctx, 2004, batch(5), 2)
+	if err != nil {
+		t.Fatalf("could not replace the season: %v", err)
+	}
+	if n := shotCount(); n != 5 {
+		t.Errorf("expected the 5 replacement shots, got %d", n)
+	}
+
+	err = srv.DeleteSeason(ctx, 2004)
+	if err != nil {
+		t.Fatalf("could not delete the season: %v", err)
+	}
+	if n := shotCount(); n != 0 {
+		t.Errorf("expected no shots left, got %d", n)
+	}
+
+	var partition *string
+	err = srv.db.QueryRow(ctx, `SELECT to_regclass('shot_2004')::text`).Scan(&partition)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if partition != nil {
+		t.Errorf("expected the shot_2004 partition to be dropped with the season")
+	}
+}
+
+func TestQueryFilterUsage(t *testing.T) {
+	ctx := context.Background()
+	srv := testDB
+	records := []*types.QueryHistoryRecord{
+		{RequestShotParams: types.RequestShotParams{PlayerIDs: []int{977}, SeasonYears: []int{2004}, StartTimeLeftSecs: -1, EndTimeLeftSecs: -1}, ReturnedShots: 10},
+		{RequestShotParams: types.RequestShotParams{PlayerIDs: []int{977}, SeasonYears: []int{2005}, StartTimeLeftSecs: -1, EndTimeLeftSecs: -1}, ReturnedShots: 20},
+		{RequestShotParams: types.RequestShotParams{TeamIDs: []int{1610612747}, Quarters: []int{4}, StartTimeLeftSecs: 120, EndTimeLeftSecs: -1}, ReturnedShots: 5},
+	}
+	for _, record := range records {
+		err := srv.InsertQueryHistory(ctx, record)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	usage, err := srv.GetQueryFilterUsage(ctx, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(usage) != 2 {
+		t.Fatalf("expected 2 filter combinations, got %+v", usage)
+	}
+	if strings.Join(usage[0].Filters, ",") != "player,season" || usage[0].Queries != 2 || usage[0].AvgShots != 15 {
+		t.Errorf("expected the player season combination first, got %+v", usage[0])
+	}
+	if strings.Join(usage[1].Filters, ",") != "team,quarter,time_left" {
+		t.Errorf("expected the team quarter time_left combination, got %+v", usage[1])
+	}
+}
+
 func TestClose(t *testing.T) {
-	srv := New()
+	srv, err := New(context.Background(), testConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	srv.Close()
+
+	// closing a pool leaves the others alone
+	if stats := testDB.Health(context.Background()); stats["status"] != "up" {
+		t.Errorf("expected the shared pool to still be up, got %v", stats)
+	}
 }
@@ -0,0 +1,6 @@
+package database
+
+// SharedTestService is the service connected to the test container, for the tests outside the package
+func SharedTestService() Service {
+	return testDB
+}
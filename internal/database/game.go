@@ -6,24 +6,24 @@ import (
 	"nba-shots/internal/types"
 )
 
-func (s *service) GetGameByID(gameID int) (*types.Game, error) {
+func (s *service) GetGameByID(ctx context.Context, gameID int) (*types.Game, error) {
 	log.Println("Querying database for gameID", gameID)
 	game := &types.Game{}
 	query := `SELECT id, home_team_id, away_team_id, season_year, game_date FROM game WHERE id = $1`
-	err := s.db.QueryRow(context.Background(), query, gameID).Scan(&game.ID, &game.HomeTeamID, &game.AwayTeamID, &game.SeasonYear, &game.GameDate)
+	err := s.db.QueryRow(ctx, query, gameID).Scan(&game.ID, &game.HomeTeamID, &game.AwayTeamID, &game.SeasonYear, &game.GameDate)
 	if err != nil {
 		return nil, err
 	}
 	return game, nil
 }
 
-func (s *service) GetLastXGames(amount int) ([]types.Game, error) {
+func (s *service) GetLastXGames(ctx context.Context, amount int) ([]types.Game, error) {
 	log.Println("Querying database for playerName", amount)
 
 	games := []types.Game{}
 	query := `SELECT id, home_team_id, away_team_id, season_year, game_date FROM game ORDER BY game_date DESC LIMIT $1`
 
-	rows, err := s.db.Query(context.Background(), query, amount)
+	rows, err := s.db.Query(ctx, query, amount)
 
 	if err != nil {
 		return nil, err
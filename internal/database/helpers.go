@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
@@ -9,30 +10,85 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
-func (s *service) beginTransaction() (pgx.Tx, error) {
-	return s.db.Begin(context.Background())
+// postgres' query_canceled, raised when a statement goes over statement_timeout
+const queryCanceledCode = "57014"
+
+// IsTimeout reports whether a query failed for running out of time, either the deadline of its
+// context passed or postgres cancelled it for going over statement_timeout
+func IsTimeout(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &pgErr) && pgErr.Code == queryCanceledCode)
+}
+
+type datasetVersionKey struct{}
+
+// WithDatasetVersion records the dataset version a request is answered for, a cache in front of
+// the database keys what it reads for the request with it
+func WithDatasetVersion(ctx context.Context, version int64) context.Context {
+	return context.WithValue(ctx, datasetVersionKey{}, version)
+}
+
+// DatasetVersionFrom is the version WithDatasetVersion recorded, ok is false without one
+func DatasetVersionFrom(ctx context.Context) (version int64, ok bool) {
+	version, ok = ctx.Value(datasetVersionKey{}).(int64)
+	return version, ok
+}
+
+// IsNotFound reports whether a lookup of a single row found nothing, the in-memory service fails
+// the same way
+func IsNotFound(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows)
+}
+
+func (s *service) beginTransaction(ctx context.Context) (pgx.Tx, error) {
+	return s.db.Begin(ctx)
+}
+
+func (s *service) commitTransaction(ctx context.Context, tx pgx.Tx) error {
+	return tx.Commit(ctx)
 }
 
-func (s *service) commitTransaction(tx pgx.Tx) error {
-	return tx.Commit(context.Background())
+func (s *service) rollbackTransaction(ctx context.Context, tx pgx.Tx) error {
+	return tx.Rollback(ctx)
 }
 
-func (s *service) rollbackTransaction(tx pgx.Tx) error {
-	return tx.Rollback(context.Background())
+// inTransaction runs fn in a transaction, committing if it returns nil and rolling back otherwise
+func (s *service) inTransaction(ctx context.Context, fn func(pgx.Tx) error) error {
+	tx, err := s.beginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+
+	err = fn(tx)
+	if err != nil {
+		// still roll back when fn failed because ctx was cancelled
+		err2 := s.rollbackTransaction(context.WithoutCancel(ctx), tx)
+		if err2 != nil {
+			return fmt.Errorf("%w, rolling back also failed: %v", err, err2)
+		}
+		return fmt.Errorf("%w, transaction rolled back", err)
+	}
+
+	err = s.commitTransaction(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
 }
 
-func (s *service) bulkLoadData(tx pgx.Tx, tableName string, columns []string, data [][]interface{}) error {
+func bulkLoadData(ctx context.Context, tx pgx.Tx, tableName string, columns []string, data [][]interface{}) error {
 	copyCount, err := tx.CopyFrom(
-		context.Background(),
+		ctx,
 		pgx.Identifier{tableName},
 		columns,
 		pgx.CopyFromRows(data),
 	)
 
 	if err != nil {
-		return fmt.Errorf("error copying data: %v", err)
+		return fmt.Errorf("error copying data: %w", err)
 	}
 
 	log.Printf("Copied %v rows\n", copyCount)
@@ -41,12 +97,12 @@ func (s *service) bulkLoadData(tx pgx.Tx, tableName string, columns []string, da
 
 // Checks if all the tables in the db are empty
 // rough but i think its ok for now
-func (s *service) IsEmptyDatabase() (bool, error) {
+func (s *service) IsEmptyDatabase(ctx context.Context) (bool, error) {
 	var tables = [5]string{"player", "team", "season", "game", "shot"}
 	var count int
 	for _, table := range tables {
 		query := fmt.Sprintf(`SELECT 1 FROM %s LIMIT 1`, table)
-		err := s.db.QueryRow(context.Background(), query).Scan(&count)
+		err := s.db.QueryRow(ctx, query).Scan(&count)
 		if err != pgx.ErrNoRows {
 			return false, err
 		}
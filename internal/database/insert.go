@@ -9,24 +9,80 @@ import (
 	"github.com/jackc/pgx/v5"
 )
 
-// InsertPlayers - inserts multiple players into the database.
-func (s *service) InsertPlayers(players []types.Player) error {
-	tx, err := s.beginTransaction()
-	if err != nil {
+// InsertSeasonBatch - inserts every row derived from a season file in a single transaction.
+// If any table fails the whole file is rolled back, so a bad file never leaves partial rows.
+// Shots are copied in chunks of shotChunkSize rows.
+func (s *service) InsertSeasonBatch(ctx context.Context, batch *types.SeasonBatch, shotChunkSize int) error {
+	log.Printf("Transaction Started for seasons %v\n", batch.Seasons)
+
+	return s.inDatasetTransaction(ctx, func(tx pgx.Tx) error {
+		err := insertSeasonBatch(ctx, tx, batch, shotChunkSize)
+		if err != nil {
+			return err
+		}
+
+		// the eras of every team in the file are rebuilt now that its team_season rows exist
+		return rebuildTeamIdentities(ctx, tx, batchTeamIDs(batch))
+	})
+}
+
+func insertSeasonBatch(ctx context.Context, tx pgx.Tx, batch *types.SeasonBatch, shotChunkSize int) error {
+	if err := insertPlayers(ctx, tx, batch.Players); err != nil {
 		return err
 	}
-	log.Printf("Transaction Started with %v players\n", len(players))
+	if err := insertTeams(ctx, tx, batch.Teams); err != nil {
+		return err
+	}
+	if err := insertSeasons(ctx, tx, batch.Seasons); err != nil {
+		return err
+	}
+	if err := insertGames(ctx, tx, batch.Games); err != nil {
+		return err
+	}
+	for start := 0; start < len(batch.Shots); start += shotChunkSize {
+		end := min(start+shotChunkSize, len(batch.Shots))
+		if err := insertShots(ctx, tx, batch.Shots[start:end]); err != nil {
+			return fmt.Errorf("shots %d to %d: %w", start, end, err)
+		}
+		if err := addShotSummaries(ctx, tx, batch.Shots[start:end]); err != nil {
+			return fmt.Errorf("shots %d to %d: %w", start, end, err)
+		}
+	}
+	if err := insertPlayerTeams(ctx, tx, batch.PlayerTeams); err != nil {
+		return err
+	}
+	if err := insertPlayerSeasons(ctx, tx, batch.PlayerSeasons); err != nil {
+		return err
+	}
+	if err := insertPlayerGames(ctx, tx, batch.PlayerGames); err != nil {
+		return err
+	}
+	if err := insertTeamSeasons(ctx, tx, batch.TeamSeasons); err != nil {
+		return err
+	}
+	if err := insertTeamGames(ctx, tx, batch.TeamGames); err != nil {
+		return err
+	}
+	return insertGameSeasons(ctx, tx, batch.GameSeasons)
+}
+
+func batchTeamIDs(batch *types.SeasonBatch) []int {
+	teamIDs := make([]int, len(batch.Teams))
+	for i, team := range batch.Teams {
+		teamIDs[i] = team.ID
+	}
+	return teamIDs
+}
 
-	// // bulkUpload version
-	// columns := types.GetTypeDBColumnNames(types.Player{})
-	// log.Printf("Columns: %v\n", columns)
-	// data := make([][]any, len(players))
-	// for i, player := range players {
-	// 	data[i] = []any{player.ID, player.Name}
-	// }
-	// // log.Printf("data interface: %v\n", data)
-	// err = s.bulkLoadData(tx, "player", columns, data)
+// InsertPlayers - inserts multiple players into the database.
+func (s *service) InsertPlayers(ctx context.Context, players []types.Player) error {
+	log.Printf("Transaction Started with %v players\n", len(players))
+	return s.inDatasetTransaction(ctx, func(tx pgx.Tx) error {
+		return insertPlayers(ctx, tx, players)
+	})
+}
 
+func insertPlayers(ctx context.Context, tx pgx.Tx, players []types.Player) error {
 	query := `
 	INSERT INTO player (id, name)
 	VALUES ($1, $2)
@@ -37,34 +93,22 @@ func (s *service) InsertPlayers(players []types.Player) error {
 		batch.Queue(query, p.ID, p.Name)
 	}
 
-	br := tx.SendBatch(context.Background(), batch)
-	defer br.Close()
-
-	_, err = br.Exec()
-
+	err := tx.SendBatch(ctx, batch).Close()
 	if err != nil {
-		log.Fatalf("bulk loading error: %v", err)
-		err2 := s.rollbackTransaction(tx)
-		if err2 != nil {
-			return fmt.Errorf("error inserting players and rolling back: %v, %v", err, err2)
-		}
-		return err
+		return fmt.Errorf("inserting %d players: %w", len(players), err)
 	}
-
-	br.Close()
-
-	return s.commitTransaction(tx)
-
+	return nil
 }
 
 // InsertTeams - inserts multiple teams into the database.
-func (s *service) InsertTeams(teams []types.Team) error {
-	tx, err := s.beginTransaction()
-	if err != nil {
-		return err
-	}
+func (s *service) InsertTeams(ctx context.Context, teams []types.Team) error {
 	log.Printf("Transaction Started with %v teams\n", len(teams))
+	return s.inDatasetTransaction(ctx, func(tx pgx.Tx) error {
+		return insertTeams(ctx, tx, teams)
+	})
+}
 
+func insertTeams(ctx context.Context, tx pgx.Tx, teams []types.Team) error {
 	query := `
 	INSERT INTO team (id, name, abbreviation)
 	VALUES ($1, $2, $3)
@@ -77,32 +121,22 @@ func (s *service) InsertTeams(teams []types.Team) error {
 		batch.Queue(query, team.ID, team.Name, team.Abbreviation)
 	}
 
-	br := tx.SendBatch(context.Background(), batch)
-	defer br.Close()
-
-	_, err = br.Exec()
-
+	err := tx.SendBatch(ctx, batch).Close()
 	if err != nil {
-		err2 := s.rollbackTransaction(tx)
-		if err2 != nil {
-			return fmt.Errorf("error inserting teams and rolling back: %v, %v", err, err2)
-		}
-		return fmt.Errorf("failed to insert teams: %v, transaction rolled back", err)
+		return fmt.Errorf("inserting %d teams: %w", len(teams), err)
 	}
-
-	br.Close()
-
-	return s.commitTransaction(tx)
+	return nil
 }
 
-// InsertSeasons - inserts multiple teams into the database.
-func (s *service) InsertSeasons(seasons []types.Season) error {
-	tx, err := s.beginTransaction()
-	if err != nil {
-		return err
-	}
+// InsertSeasons - inserts multiple seasons into the database.
+func (s *service) InsertSeasons(ctx context.Context, seasons []types.Season) error {
 	log.Printf("Transaction Started with %v seasons\n", len(seasons))
+	return s.inDatasetTransaction(ctx, func(tx pgx.Tx) error {
+		return insertSeasons(ctx, tx, seasons)
+	})
+}
 
+func insertSeasons(ctx context.Context, tx pgx.Tx, seasons []types.Season) error {
 	query := `
 	INSERT INTO season (year, season_years)
 	VALUES ($1, $2)
@@ -115,69 +149,56 @@ func (s *service) InsertSeasons(seasons []types.Season) error {
 		batch.Queue(query, season.Year, season.SeasonYears)
 	}
 
-	br := tx.SendBatch(context.Background(), batch)
-	defer br.Close()
-
-	_, err = br.Exec()
-
+	err := tx.SendBatch(ctx, batch).Close()
 	if err != nil {
-		err2 := s.rollbackTransaction(tx)
-		if err2 != nil {
-			log.Printf("error inserting seasons and rolling back: %v, %v", err, err2)
-			return fmt.Errorf("error inserting seasons and rolling back: %v, %v", err, err2)
-		}
-		log.Printf("failed to insert seasons: %v, transaction rolled back", err)
-		return fmt.Errorf("failed to insert seasons: %v, transaction rolled back", err)
+		return fmt.Errorf("inserting %d seasons: %w", len(seasons), err)
 	}
-	br.Close()
-	return s.commitTransaction(tx)
+	return nil
 }
 
 // InsertGames - inserts multiple games into the database.
 // shouldnt need to worry about conflicts if we're loading in the season csv
-func (s *service) InsertGames(games []types.Game) error {
-	tx, err := s.beginTransaction()
-	if err != nil {
-		return err
-	}
+func (s *service) InsertGames(ctx context.Context, games []types.Game) error {
 	log.Printf("Transaction Started with %v games\n", len(games))
+	return s.inDatasetTransaction(ctx, func(tx pgx.Tx) error {
+		return insertGames(ctx, tx, games)
+	})
+}
 
+func insertGames(ctx context.Context, tx pgx.Tx, games []types.Game) error {
 	columns := types.GetTypeDBColumnNames(types.Game{})
 	data := make([][]any, len(games))
 	for i, game := range games {
 		data[i] = []any{game.ID, game.HomeTeamID, game.AwayTeamID, game.SeasonYear, game.GameDate}
 	}
-	err = s.bulkLoadData(tx, "game", columns, data)
 
+	err := bulkLoadData(ctx, tx, "game", columns, data)
 	if err != nil {
-		log.Fatalf("bulk loading error: %v", err)
-		err2 := s.rollbackTransaction(tx)
-		if err2 != nil {
-			return fmt.Errorf("error inserting games and rolling back: %v, %v", err, err2)
-		}
-		return err
+		return fmt.Errorf("inserting %d games: %w", len(games), err)
 	}
-
-	return s.commitTransaction(tx)
-
+	return nil
 }
 
 // InsertShots - inserts multiple shots into the database.
-// TODO: chunkedBulkUpload (50k rows at a time) - fine for now since only on ingest
-func (s *service) InsertShots(shots []types.Shot) error {
-	tx, err := s.beginTransaction()
-	if err != nil {
-		return err
-	}
+func (s *service) InsertShots(ctx context.Context, shots []types.Shot) error {
 	log.Printf("Transaction Started with %v shots\n", len(shots))
+	return s.inDatasetTransaction(ctx, func(tx pgx.Tx) error {
+		err := insertShots(ctx, tx, shots)
+		if err != nil {
+			return err
+		}
+		return addShotSummaries(ctx, tx, shots)
+	})
+}
 
+func insertShots(ctx context.Context, tx pgx.Tx, shots []types.Shot) error {
 	columns := types.GetTypeDBColumnNames(types.Shot{})
 
-	// log.Printf("Columns: %v\n", columns)
-
 	data := make([][]any, len(shots))
+	seasons := make(map[int]bool)
 
 	for i, shot := range shots {
+		seasons[shot.SeasonYear] = true
 		data[i] = []any{
 			shot.PlayerID,
 			shot.GameID,
@@ -206,41 +227,32 @@ func (s *service) InsertShots(shots []types.Shot) error {
 		}
 	}
 
-	// log.Printf("data interface: %v\n", data)
-
-	err = s.bulkLoadData(tx, "shot", columns, data)
-
+	years := make([]int, 0, len(seasons))
+	for year := range seasons {
+		years = append(years, year)
+	}
+	err := ensureShotPartitions(ctx, tx, years)
 	if err != nil {
-		log.Fatalf("bulk loading error: %v", err)
-		err2 := s.rollbackTransaction(tx)
-		if err2 != nil {
-			return fmt.Errorf("error inserting shots and rolling back: %v, %v", err, err2)
-		}
 		return err
 	}
 
-	return s.commitTransaction(tx)
-
+	// the copy goes through the partitioned parent, postgres routes every row to its season
+	err = bulkLoadData(ctx, tx, "shot", columns, data)
+	if err != nil {
+		return fmt.Errorf("inserting %d shots: %w", len(shots), err)
+	}
+	return nil
 }
 
 // InsertPlayerTeams - inserts multiple player teams into the database.
-func (s *service) InsertPlayerTeams(playerTeams []types.PlayerTeam) error {
-	tx, err := s.beginTransaction()
-	if err != nil {
-		return err
-	}
+func (s *service) InsertPlayerTeams(ctx context.Context, playerTeams []types.PlayerTeam) error {
 	log.Printf("Transaction Started with %v players teams\n", len(playerTeams))
+	return s.inDatasetTransaction(ctx, func(tx pgx.Tx) error {
+		return insertPlayerTeams(ctx, tx, playerTeams)
+	})
+}
 
-	// // old method using bulkUpload
-	// columns := types.GetTypeDBColumnNames(types.PlayerTeam{})
-	// // log.Printf("Columns: %v\n", columns)
-	// data := make([][]any, len(playerTeams))
-	// for i, playerTeam := range playerTeams {
-	// 	data[i] = []any{playerTeam.PlayerID, playerTeam.TeamID, playerTeam.TeamName}
-	// }
-	// // log.Printf("data interface: %v\n", data)
-	// err = s.bulkLoadData(tx, "player_team", columns, data)
-
+func insertPlayerTeams(ctx context.Context, tx pgx.Tx, playerTeams []types.PlayerTeam) error {
 	query := `
 	INSERT INTO player_team (player_id, team_id, team_name)
 	VALUES ($1, $2, $3)
@@ -253,43 +265,21 @@ func (s *service) InsertPlayerTeams(playerTeams []types.PlayerTeam) error {
 		batch.Queue(query, pt.PlayerID, pt.TeamID, pt.TeamName)
 	}
 
-	br := tx.SendBatch(context.Background(), batch)
-	defer br.Close()
-
-	_, err = br.Exec()
-
+	err := tx.SendBatch(ctx, batch).Close()
 	if err != nil {
-		log.Fatalf("bulk loading error: %v", err)
-		err2 := s.rollbackTransaction(tx)
-		if err2 != nil {
-			return fmt.Errorf("error inserting player teams and rolling back: %v, %v", err, err2)
-		}
-		return err
+		return fmt.Errorf("inserting %d player teams: %w", len(playerTeams), err)
 	}
-
-	br.Close()
-
-	return s.commitTransaction(tx)
-
+	return nil
 }
 
-func (s *service) InsertPlayerSeasons(playerSeasons []types.PlayerSeason) error {
-	tx, err := s.beginTransaction()
-	if err != nil {
-		return err
-	}
+func (s *service) InsertPlayerSeasons(ctx context.Context, playerSeasons []types.PlayerSeason) error {
 	log.Printf("Transaction Started with %v players seasons\n", len(playerSeasons))
+	return s.inDatasetTransaction(ctx, func(tx pgx.Tx) error {
+		return insertPlayerSeasons(ctx, tx, playerSeasons)
+	})
+}
 
-	// // old bulkLoad logic
-	// columns := types.GetTypeDBColumnNames(types.PlayerSeason{})
-	// // log.Printf("Columns: %v\n", columns)
-	// data := make([][]any, len(playerSeasons))
-	// for i, playerTeam := range playerSeasons {
-	// 	data[i] = []any{playerTeam.PlayerID, playerTeam.SeasonYear}
-	// }
-	// // log.Printf("data interface: %v\n", data)
-	// err = s.bulkLoadData(tx, "player_season", columns, data)
-
+func insertPlayerSeasons(ctx context.Context, tx pgx.Tx, playerSeasons []types.PlayerSeason) error {
 	query := `
 	INSERT INTO player_season (player_id, season_year)
 	VALUES ($1, $2)
@@ -302,43 +292,21 @@ func (s *service) InsertPlayerSeasons(playerSeasons []types.PlayerSeason) error
 		batch.Queue(query, ps.PlayerID, ps.SeasonYear)
 	}
 
-	br := tx.SendBatch(context.Background(), batch)
-	defer br.Close()
-
-	_, err = br.Exec()
-
+	err := tx.SendBatch(ctx, batch).Close()
 	if err != nil {
-		log.Fatalf("bulk loading error: %v", err)
-		err2 := s.rollbackTransaction(tx)
-		if err2 != nil {
-			return fmt.Errorf("error inserting player seasons and rolling back: %v, %v", err, err2)
-		}
-		return err
+		return fmt.Errorf("inserting %d player seasons: %w", len(playerSeasons), err)
 	}
-
-	br.Close()
-
-	return s.commitTransaction(tx)
-
+	return nil
 }
 
-func (s *service) InsertPlayerGames(playerGames []types.PlayerGame) error {
-	tx, err := s.beginTransaction()
-	if err != nil {
-		return err
-	}
+func (s *service) InsertPlayerGames(ctx context.Context, playerGames []types.PlayerGame) error {
 	log.Printf("Transaction Started with %v players games\n", len(playerGames))
+	return s.inDatasetTransaction(ctx, func(tx pgx.Tx) error {
+		return insertPlayerGames(ctx, tx, playerGames)
+	})
+}
 
-	// // old bulkUpload logic
-	// columns := types.GetTypeDBColumnNames(types.PlayerGame{})
-	// // log.Printf("Columns: %v\n", columns)
-	// data := make([][]any, len(playerGames))
-	// for i, playerGame := range playerGames {
-	// 	data[i] = []any{playerGame.PlayerID, playerGame.GameID, playerGame.GameDate}
-	// }
-	// // log.Printf("data interface: %v\n", data)
-	// err = s.bulkLoadData(tx, "player_game", columns, data)
-
+func insertPlayerGames(ctx context.Context, tx pgx.Tx, playerGames []types.PlayerGame) error {
 	query := `
 	INSERT INTO player_game (player_id, game_id, game_date)
 	VALUES ($1, $2, $3)
@@ -351,92 +319,48 @@ func (s *service) InsertPlayerGames(playerGames []types.PlayerGame) error {
 		batch.Queue(query, pg.PlayerID, pg.GameID, pg.GameDate)
 	}
 
-	br := tx.SendBatch(context.Background(), batch)
-	defer br.Close()
-
-	_, err = br.Exec()
-
+	err := tx.SendBatch(ctx, batch).Close()
 	if err != nil {
-		log.Fatalf("bulk loading error: %v", err)
-		err2 := s.rollbackTransaction(tx)
-		if err2 != nil {
-			return fmt.Errorf("error inserting players and rolling back: %v, %v", err, err2)
-		}
-		return err
+		return fmt.Errorf("inserting %d player games: %w", len(playerGames), err)
 	}
-
-	br.Close()
-
-	return s.commitTransaction(tx)
-
+	return nil
 }
 
-func (s *service) InsertTeamSeasons(teamSeasons []types.TeamSeason) error {
-	tx, err := s.beginTransaction()
-	if err != nil {
-		return err
-	}
+func (s *service) InsertTeamSeasons(ctx context.Context, teamSeasons []types.TeamSeason) error {
 	log.Printf("Transaction Started with %v team seasons\n", len(teamSeasons))
+	return s.inDatasetTransaction(ctx, func(tx pgx.Tx) error {
+		return insertTeamSeasons(ctx, tx, teamSeasons)
+	})
+}
 
-	// // old bulkUpload logic
-	// columns := types.GetTypeDBColumnNames(types.TeamSeason{})
-	// // log.Printf("Columns: %v\n", columns)
-	// data := make([][]any, len(teamSeasons))
-	// for i, teamSeason := range teamSeasons {
-	// 	data[i] = []any{teamSeason.TeamID, teamSeason.SeasonYear, teamSeason.TeamName}
-	// }
-	// // log.Printf("data interface: %v\n", data)
-	// err = s.bulkLoadData(tx, "team_season", columns, data)
-
+func insertTeamSeasons(ctx context.Context, tx pgx.Tx, teamSeasons []types.TeamSeason) error {
 	query := `
-	INSERT INTO team_season (team_id, season_year, team_name)
-	VALUES ($1, $2, $3)
+	INSERT INTO team_season (team_id, season_year, team_name, abbreviation)
+	VALUES ($1, $2, $3, $4)
 	ON CONFLICT (team_id, season_year) DO NOTHING
 	`
 
 	batch := &pgx.Batch{}
 
 	for _, ts := range teamSeasons {
-		batch.Queue(query, ts.TeamID, ts.SeasonYear, ts.TeamName)
+		batch.Queue(query, ts.TeamID, ts.SeasonYear, ts.TeamName, ts.Abbreviation)
 	}
 
-	br := tx.SendBatch(context.Background(), batch)
-	defer br.Close()
-
-	_, err = br.Exec()
-
+	err := tx.SendBatch(ctx, batch).Close()
 	if err != nil {
-		log.Fatalf("bulk loading error: %v", err)
-		err2 := s.rollbackTransaction(tx)
-		if err2 != nil {
-			return fmt.Errorf("error inserting team seasons and rolling back: %v, %v", err, err2)
-		}
-		return err
+		return fmt.Errorf("inserting %d team seasons: %w", len(teamSeasons), err)
 	}
-
-	br.Close()
-
-	return s.commitTransaction(tx)
-
+	return nil
 }
 
-func (s *service) InsertTeamGames(teamGames []types.TeamGame) error {
-	tx, err := s.beginTransaction()
-	if err != nil {
-		return err
-	}
+func (s *service) InsertTeamGames(ctx context.Context, teamGames []types.TeamGame) error {
 	log.Printf("Transaction Started with %v team games\n", len(teamGames))
+	return s.inDatasetTransaction(ctx, func(tx pgx.Tx) error {
+		return insertTeamGames(ctx, tx, teamGames)
+	})
+}
 
-	// // old bulkUpload logic
-	// columns := types.GetTypeDBColumnNames(types.TeamGame{})
-	// // log.Printf("Columns: %v\n", columns)
-	// data := make([][]any, len(teamGames))
-	// for i, teamGame := range teamGames {
-	// 	data[i] = []any{teamGame.TeamID, teamGame.GameID, teamGame.GameDate}
-	// }
-	// // log.Printf("data interface: %v\n", data)
-	// err = s.bulkLoadData(tx, "team_game", columns, data)
-
+func insertTeamGames(ctx context.Context, tx pgx.Tx, teamGames []types.TeamGame) error {
 	query := `
 	INSERT INTO team_game (team_id, game_id, game_date)
 	VALUES ($1, $2, $3)
@@ -449,43 +373,21 @@ func (s *service) InsertTeamGames(teamGames []types.TeamGame) error {
 		batch.Queue(query, tg.TeamID, tg.GameID, tg.GameDate)
 	}
 
-	br := tx.SendBatch(context.Background(), batch)
-	defer br.Close()
-
-	_, err = br.Exec()
-
+	err := tx.SendBatch(ctx, batch).Close()
 	if err != nil {
-		log.Fatalf("bulk loading error: %v", err)
-		err2 := s.rollbackTransaction(tx)
-		if err2 != nil {
-			return fmt.Errorf("error inserting team games and rolling back: %v, %v", err, err2)
-		}
-		return err
+		return fmt.Errorf("inserting %d team games: %w", len(teamGames), err)
 	}
-
-	br.Close()
-
-	return s.commitTransaction(tx)
-
+	return nil
 }
 
-func (s *service) InsertGameSeasons(gameSeasons []types.GameSeason) error {
-	tx, err := s.beginTransaction()
-	if err != nil {
-		return err
-	}
+func (s *service) InsertGameSeasons(ctx context.Context, gameSeasons []types.GameSeason) error {
 	log.Printf("Transaction Started with %v game seasons\n", len(gameSeasons))
+	return s.inDatasetTransaction(ctx, func(tx pgx.Tx) error {
+		return insertGameSeasons(ctx, tx, gameSeasons)
+	})
+}
 
-	// // old bulkUpload logic
-	// columns := types.GetTypeDBColumnNames(types.GameSeason{})
-	// // log.Printf("Columns: %v\n", columns)
-	// data := make([][]any, len(gameSeasons))
-	// for i, teamGame := range gameSeasons {
-	// 	data[i] = []any{teamGame.GameID, teamGame.SeasonYear}
-	// }
-	// // log.Printf("data interface: %v\n", data)
-	// err = s.bulkLoadData(tx, "game_season", columns, data)
-
+func insertGameSeasons(ctx context.Context, tx pgx.Tx, gameSeasons []types.GameSeason) error {
 	query := `
 	INSERT INTO game_season (game_id, season_year)
 	VALUES ($1, $2)
@@ -498,31 +400,60 @@ func (s *service) InsertGameSeasons(gameSeasons []types.GameSeason) error {
 		batch.Queue(query, gs.GameID, gs.SeasonYear)
 	}
 
-	br := tx.SendBatch(context.Background(), batch)
-	defer br.Close()
-
-	_, err = br.Exec()
-
+	err := tx.SendBatch(ctx, batch).Close()
 	if err != nil {
-		log.Fatalf("bulk loading error: %v", err)
-		err2 := s.rollbackTransaction(tx)
-		if err2 != nil {
-			return fmt.Errorf("error inserting game seasons and rolling back: %v, %v", err, err2)
-		}
-		return err
+		return fmt.Errorf("inserting %d game seasons: %w", len(gameSeasons), err)
 	}
+	return nil
+}
 
-	br.Close()
+// consecutive seasons with the same name and abbreviation collapse into one era
+const rebuildTeamIdentitiesQuery = `
+INSERT INTO team_identity (team_id, start_season, end_season, name, abbreviation)
+SELECT team_id, MIN(season_year), MAX(season_year), team_name, abbreviation
+FROM (
+	SELECT team_id, season_year, team_name, abbreviation,
+		season_year - ROW_NUMBER() OVER (PARTITION BY team_id, team_name, abbreviation ORDER BY season_year) AS era
+	FROM team_season
+	WHERE team_id = ANY($1)
+) eras
+GROUP BY team_id, team_name, abbreviation, era
+`
+
+const syncLatestTeamIdentityQuery = `
+UPDATE team t
+SET name = ti.name, abbreviation = ti.abbreviation, updated_at = CURRENT_TIMESTAMP
+FROM team_identity ti
+WHERE ti.team_id = t.id
+	AND t.id = ANY($1)
+	AND ti.end_season = (SELECT MAX(end_season) FROM team_identity WHERE team_id = t.id)
+`
+
+// RebuildTeamIdentities - recomputes the team_identity eras of the given teams from team_season
+// and points each team row at its latest name and abbreviation.
+func (s *service) RebuildTeamIdentities(ctx context.Context, teamIDs []int) error {
+	log.Printf("Transaction Started to rebuild identities for %v teams\n", len(teamIDs))
+	return s.inDatasetTransaction(ctx, func(tx pgx.Tx) error {
+		return rebuildTeamIdentities(ctx, tx, teamIDs)
+	})
+}
 
-	return s.commitTransaction(tx)
+func rebuildTeamIdentities(ctx context.Context, tx pgx.Tx, teamIDs []int) error {
+	batch := &pgx.Batch{}
+	batch.Queue(`DELETE FROM team_identity WHERE team_id = ANY($1)`, teamIDs)
+	batch.Queue(rebuildTeamIdentitiesQuery, teamIDs)
+	batch.Queue(syncLatestTeamIdentityQuery, teamIDs)
 
+	err := tx.SendBatch(ctx, batch).Close()
+	if err != nil {
+		return fmt.Errorf("rebuilding identities for %d teams: %w", len(teamIDs), err)
+	}
+	return nil
 }
 
+// InsertQueryHistory - logs the params of a shot query, this runs outside of any request
+// so it is a single statement with its own context
 func (s *service) InsertQueryHistory(ctx context.Context, qh *types.QueryHistoryRecord) error {
-	tx, err := s.beginTransaction()
-	if err != nil {
-		return err
-	}
 	log.Printf("Attempting to insert the queryHistory record for %v shots\n", qh.ReturnedShots)
 
 	query := `
@@ -543,7 +474,7 @@ func (s *service) InsertQueryHistory(ctx context.Context, qh *types.QueryHistory
 	`
 
 	// format the array values
-	_, err = s.db.Exec(ctx, query,
+	_, err := s.db.Exec(ctx, query,
 		formatPGIntArray(&qh.PlayerIDs),
 		formatPGIntArray(&qh.TeamIDs),
 		formatPGIntArray(&qh.SeasonYears),
@@ -558,12 +489,7 @@ func (s *service) InsertQueryHistory(ctx context.Context, qh *types.QueryHistory
 	)
 
 	if err != nil {
-		log.Printf("query log exec: %v\n", err)
-		err2 := s.rollbackTransaction(tx)
-		if err2 != nil {
-			return fmt.Errorf("error inserting game seasons and rolling back: %v, %v", err, err2)
-		}
-		return err
+		return fmt.Errorf("inserting query history: %w", err)
 	}
-	return s.commitTransaction(tx)
+	return nil
 }
@@ -0,0 +1,193 @@
+package memory
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"nba-shots/internal/database"
+	"nba-shots/internal/types"
+)
+
+type demoPlayer struct {
+	types.Player
+	TeamID        int
+	Position      string
+	PositionGroup string
+	// share of the player's shots taken from three
+	ThreeRate float64
+}
+
+var (
+	demoTeams = []types.Team{
+		{ID: 1610612738, Name: "Boston Celtics", Abbreviation: "BOS"},
+		{ID: 1610612743, Name: "Denver Nuggets", Abbreviation: "DEN"},
+		{ID: 1610612744, Name: "Golden State Warriors", Abbreviation: "GSW"},
+		{ID: 1610612747, Name: "Los Angeles Lakers", Abbreviation: "LAL"},
+	}
+
+	demoPlayers = []demoPlayer{
+		{types.Player{ID: 1628369, Name: "Jayson Tatum"}, 1610612738, "SF", "F", 0.45},
+		{types.Player{ID: 1627759, Name: "Jaylen Brown"}, 1610612738, "SG", "G", 0.35},
+		{types.Player{ID: 203999, Name: "Nikola Jokic"}, 1610612743, "C", "C", 0.2},
+		{types.Player{ID: 1627750, Name: "Jamal Murray"}, 1610612743, "PG", "G", 0.4},
+		{types.Player{ID: 201939, Name: "Stephen Curry"}, 1610612744, "PG", "G", 0.6},
+		{types.Player{ID: 1641764, Name: "Brandin Podziemski"}, 1610612744, "SG", "G", 0.4},
+		{types.Player{ID: 2544, Name: "LeBron James"}, 1610612747, "SF", "F", 0.3},
+		{types.Player{ID: 203076, Name: "Anthony Davis"}, 1610612747, "PF", "F", 0.1},
+	}
+
+	demoSeasons = []int{2023, 2024}
+)
+
+const demoShotsPerGame = 16
+
+// demoZone is where a shot can come from, distances are in feet from the hoop and angles
+// in degrees from the baseline
+type demoZone struct {
+	BasicZone, ZoneName, ZoneABB, ZoneRange string
+	ShotType                                string
+	MinDistance, MaxDistance                float64
+	MinAngle, MaxAngle                      float64
+	MakeRate                                float64
+}
+
+var (
+	demoTwos = []demoZone{
+		{"Restricted Area", "Center", "C", "Less Than 8 ft.", types.TwoPtShot, 0, 3.5, 0, 180, 0.64},
+		{"In The Paint (Non-RA)", "Center", "C", "8-16 ft.", types.TwoPtShot, 5, 12, 30, 150, 0.44},
+		{"Mid-Range", "Left Side", "L", "8-16 ft.", types.TwoPtShot, 9, 15, 0, 45, 0.42},
+		{"Mid-Range", "Right Side", "R", "8-16 ft.", types.TwoPtShot, 9, 15, 135, 180, 0.42},
+		{"Mid-Range", "Center", "C", "16-24 ft.", types.TwoPtShot, 16, 21, 60, 120, 0.4},
+	}
+	demoThrees = []demoZone{
+		{"Left Corner 3", "Left Side", "L", "24+ ft.", "3PT Field Goal", 22, 22.5, 0, 12, 0.39},
+		{"Right Corner 3", "Right Side", "R", "24+ ft.", "3PT Field Goal", 22, 22.5, 168, 180, 0.39},
+		{"Above the Break 3", "Left Side Center", "LC", "24+ ft.", "3PT Field Goal", 24, 27, 25, 70, 0.36},
+		{"Above the Break 3", "Center", "C", "24+ ft.", "3PT Field Goal", 24, 28, 70, 110, 0.36},
+		{"Above the Break 3", "Right Side Center", "RC", "24+ ft.", "3PT Field Goal", 24, 27, 110, 155, 0.36},
+	}
+)
+
+// the hoop is 5.25ft in from the baseline
+const hoopY = 5.25
+
+// NewDemo returns an in-memory database with DemoBatches loaded
+func NewDemo() (database.Service, error) {
+	return NewWithBatches(DemoBatches()...)
+}
+
+// DemoBatches are made up seasons of a few real teams and players, every team plays the others
+// home and away each season. The shots are random but the same on every call.
+func DemoBatches() []*types.SeasonBatch {
+	rng := rand.New(rand.NewPCG(2016, 73))
+	batches := make([]*types.SeasonBatch, len(demoSeasons))
+	for i, year := range demoSeasons {
+		batches[i] = demoSeason(rng, year)
+	}
+	return batches
+}
+
+func demoSeason(rng *rand.Rand, year int) *types.SeasonBatch {
+	batch := &types.SeasonBatch{
+		Teams:   demoTeams,
+		Seasons: []types.Season{{Year: year, SeasonYears: fmt.Sprintf("%d-%02d", year-1, year%100)}},
+	}
+	for _, team := range demoTeams {
+		batch.TeamSeasons = append(batch.TeamSeasons, types.TeamSeason{TeamID: team.ID, SeasonYear: year, TeamName: team.Name, Abbreviation: team.Abbreviation})
+	}
+	for _, p := range demoPlayers {
+		team := demoTeam(p.TeamID)
+		batch.Players = append(batch.Players, p.Player)
+		batch.PlayerTeams = append(batch.PlayerTeams, types.PlayerTeam{PlayerID: p.ID, TeamID: p.TeamID, TeamName: team.Name})
+		batch.PlayerSeasons = append(batch.PlayerSeasons, types.PlayerSeason{PlayerID: p.ID, SeasonYear: year})
+	}
+
+	gameDate := time.Date(year-1, 10, 24, 0, 0, 0, 0, time.UTC)
+	gameID := (year-1)%100*100000 + 20000000
+	for _, home := range demoTeams {
+		for _, away := range demoTeams {
+			if home.ID == away.ID {
+				continue
+			}
+			gameID++
+			gameDate = gameDate.AddDate(0, 0, 9)
+			game := types.Game{ID: gameID, HomeTeamID: home.ID, AwayTeamID: away.ID, SeasonYear: year, GameDate: gameDate}
+			batch.Games = append(batch.Games, game)
+			batch.GameSeasons = append(batch.GameSeasons, types.GameSeason{GameID: gameID, SeasonYear: year})
+			batch.TeamGames = append(batch.TeamGames,
+				types.TeamGame{TeamID: home.ID, GameID: gameID, GameDate: gameDate},
+				types.TeamGame{TeamID: away.ID, GameID: gameID, GameDate: gameDate},
+			)
+
+			for _, p := range demoPlayers {
+				if p.TeamID != home.ID && p.TeamID != away.ID {
+					continue
+				}
+				batch.PlayerGames = append(batch.PlayerGames, types.PlayerGame{PlayerID: p.ID, GameID: gameID, GameDate: gameDate})
+				for range demoShotsPerGame {
+					batch.Shots = append(batch.Shots, demoShot(rng, p, game))
+				}
+			}
+		}
+	}
+	return batch
+}
+
+func demoTeam(id int) types.Team {
+	for _, team := range demoTeams {
+		if team.ID == id {
+			return team
+		}
+	}
+	return types.Team{}
+}
+
+func demoShot(rng *rand.Rand, p demoPlayer, game types.Game) types.Shot {
+	zones := demoTwos
+	if rng.Float64() < p.ThreeRate {
+		zones = demoThrees
+	}
+	zone := zones[rng.IntN(len(zones))]
+
+	distance := zone.MinDistance + rng.Float64()*(zone.MaxDistance-zone.MinDistance)
+	angle := (zone.MinAngle + rng.Float64()*(zone.MaxAngle-zone.MinAngle)) * math.Pi / 180
+	made := rng.Float64() < zone.MakeRate
+	minsLeft, secsLeft := rng.IntN(12), rng.IntN(60)
+
+	eventType, actionType := "Missed Shot", "Jump Shot"
+	if made {
+		eventType = "Made Shot"
+	}
+	if zone.BasicZone == "Restricted Area" {
+		actionType = "Layup Shot"
+	}
+
+	return types.Shot{
+		PlayerID:          p.ID,
+		GameID:            game.ID,
+		TeamID:            p.TeamID,
+		HomeTeamID:        game.HomeTeamID,
+		AwayTeamID:        game.AwayTeamID,
+		SeasonYear:        game.SeasonYear,
+		EventType:         eventType,
+		ShotMade:          made,
+		ActionType:        actionType,
+		ShotType:          zone.ShotType,
+		BasicZone:         zone.BasicZone,
+		ZoneName:          zone.ZoneName,
+		ZoneABB:           zone.ZoneABB,
+		ZoneRange:         zone.ZoneRange,
+		LocX:              math.Round(-distance*math.Cos(angle)*10) / 10,
+		LocY:              math.Round((hoopY+distance*math.Sin(angle))*10) / 10,
+		ShotDistance:      int(distance),
+		Quarter:           rng.IntN(4) + 1,
+		MinsLeft:          minsLeft,
+		SecsLeft:          secsLeft,
+		TotalTimeLeftSecs: minsLeft*60 + secsLeft,
+		Position:          p.Position,
+		PositionGroup:     p.PositionGroup,
+		GameDate:          game.GameDate,
+	}
+}
@@ -0,0 +1,389 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"nba-shots/internal/types"
+)
+
+// InsertSeasonBatch - inserts every row of the batch or none of them
+func (s *service) InsertSeasonBatch(ctx context.Context, batch *types.SeasonBatch, shotChunkSize int) error {
+	log.Printf("Inserting seasons %v into memory\n", batch.Seasons)
+	return s.updateDataset(ctx, func(d *data) error {
+		err := d.insertSeasonBatch(batch)
+		if err != nil {
+			return err
+		}
+		d.rebuildTeamIdentities(batchTeamIDs(batch))
+		return nil
+	})
+}
+
+func (d *data) insertSeasonBatch(batch *types.SeasonBatch) error {
+	d.insertPlayers(batch.Players)
+	d.insertTeams(batch.Teams)
+	d.insertSeasons(batch.Seasons)
+	err := d.insertGames(batch.Games)
+	if err != nil {
+		return err
+	}
+	d.insertShots(batch.Shots)
+	d.insertPlayerTeams(batch.PlayerTeams)
+	d.insertPlayerSeasons(batch.PlayerSeasons)
+	d.insertPlayerGames(batch.PlayerGames)
+	d.insertTeamSeasons(batch.TeamSeasons)
+	d.insertTeamGames(batch.TeamGames)
+	d.insertGameSeasons(batch.GameSeasons)
+	return nil
+}
+
+func batchTeamIDs(batch *types.SeasonBatch) []int {
+	teamIDs := make([]int, len(batch.Teams))
+	for i, team := range batch.Teams {
+		teamIDs[i] = team.ID
+	}
+	return teamIDs
+}
+
+// the rows keyed like the tables with ON CONFLICT DO NOTHING keep the first copy
+
+func (d *data) insertPlayers(players []types.Player) {
+	for _, p := range players {
+		if _, ok := d.players[p.ID]; !ok {
+			d.players[p.ID] = p
+		}
+	}
+}
+
+func (d *data) insertTeams(teams []types.Team) {
+	for _, t := range teams {
+		if _, ok := d.teams[t.ID]; !ok {
+			d.teams[t.ID] = t
+		}
+	}
+}
+
+func (d *data) insertSeasons(seasons []types.Season) {
+	for _, season := range seasons {
+		if _, ok := d.seasons[season.Year]; !ok {
+			d.seasons[season.Year] = season
+		}
+	}
+}
+
+// games are copied in postgres, a game that is already loaded fails the whole insert
+func (d *data) insertGames(games []types.Game) error {
+	for _, g := range games {
+		if _, ok := d.games[g.ID]; ok {
+			return fmt.Errorf("inserting %d games: duplicate game %d", len(games), g.ID)
+		}
+		d.games[g.ID] = g
+	}
+	return nil
+}
+
+func (d *data) insertShots(shots []types.Shot) {
+	for _, s := range shots {
+		d.shots = append(d.shots, shot{ID: d.nextShotID, Shot: s})
+		d.nextShotID++
+	}
+}
+
+func (d *data) insertPlayerTeams(playerTeams []types.PlayerTeam) {
+	for _, pt := range playerTeams {
+		key := pair{pt.PlayerID, pt.TeamID}
+		if _, ok := d.playerTeams[key]; !ok {
+			d.playerTeams[key] = pt
+		}
+	}
+}
+
+func (d *data) insertPlayerSeasons(playerSeasons []types.PlayerSeason) {
+	for _, ps := range playerSeasons {
+		key := pair{ps.PlayerID, ps.SeasonYear}
+		if _, ok := d.playerSeasons[key]; !ok {
+			d.playerSeasons[key] = ps
+		}
+	}
+}
+
+func (d *data) insertPlayerGames(playerGames []types.PlayerGame) {
+	for _, pg := range playerGames {
+		key := pair{pg.PlayerID, pg.GameID}
+		if _, ok := d.playerGames[key]; !ok {
+			d.playerGames[key] = pg
+		}
+	}
+}
+
+func (d *data) insertTeamSeasons(teamSeasons []types.TeamSeason) {
+	for _, ts := range teamSeasons {
+		key := pair{ts.TeamID, ts.SeasonYear}
+		if _, ok := d.teamSeasons[key]; !ok {
+			d.teamSeasons[key] = ts
+		}
+	}
+}
+
+func (d *data) insertTeamGames(teamGames []types.TeamGame) {
+	for _, tg := range teamGames {
+		key := pair{tg.TeamID, tg.GameID}
+		if _, ok := d.teamGames[key]; !ok {
+			d.teamGames[key] = tg
+		}
+	}
+}
+
+func (d *data) insertGameSeasons(gameSeasons []types.GameSeason) {
+	for _, gs := range gameSeasons {
+		key := pair{gs.GameID, gs.SeasonYear}
+		if _, ok := d.gameSeasons[key]; !ok {
+			d.gameSeasons[key] = gs
+		}
+	}
+}
+
+func (s *service) InsertPlayers(ctx context.Context, players []types.Player) error {
+	return s.updateDataset(ctx, func(d *data) error {
+		d.insertPlayers(players)
+		return nil
+	})
+}
+
+func (s *service) InsertTeams(ctx context.Context, teams []types.Team) error {
+	return s.updateDataset(ctx, func(d *data) error {
+		d.insertTeams(teams)
+		return nil
+	})
+}
+
+func (s *service) InsertSeasons(ctx context.Context, seasons []types.Season) error {
+	return s.updateDataset(ctx, func(d *data) error {
+		d.insertSeasons(seasons)
+		return nil
+	})
+}
+
+func (s *service) InsertGames(ctx context.Context, games []types.Game) error {
+	return s.updateDataset(ctx, func(d *data) error {
+		return d.insertGames(games)
+	})
+}
+
+func (s *service) InsertShots(ctx context.Context, shots []types.Shot) error {
+	return s.updateDataset(ctx, func(d *data) error {
+		d.insertShots(shots)
+		return nil
+	})
+}
+
+func (s *service) InsertPlayerTeams(ctx context.Context, playerTeams []types.PlayerTeam) error {
+	return s.updateDataset(ctx, func(d *data) error {
+		d.insertPlayerTeams(playerTeams)
+		return nil
+	})
+}
+
+func (s *service) InsertPlayerSeasons(ctx context.Context, playerSeasons []types.PlayerSeason) error {
+	return s.updateDataset(ctx, func(d *data) error {
+		d.insertPlayerSeasons(playerSeasons)
+		return nil
+	})
+}
+
+func (s *service) InsertPlayerGames(ctx context.Context, playerGames []types.PlayerGame) error {
+	return s.updateDataset(ctx, func(d *data) error {
+		d.insertPlayerGames(playerGames)
+		return nil
+	})
+}
+
+func (s *service) InsertTeamSeasons(ctx context.Context, teamSeasons []types.TeamSeason) error {
+	return s.updateDataset(ctx, func(d *data) error {
+		d.insertTeamSeasons(teamSeasons)
+		return nil
+	})
+}
+
+func (s *service) InsertTeamGames(ctx context.Context, teamGames []types.TeamGame) error {
+	return s.updateDataset(ctx, func(d *data) error {
+		d.insertTeamGames(teamGames)
+		return nil
+	})
+}
+
+func (s *service) InsertGameSeasons(ctx context.Context, gameSeasons []types.GameSeason) error {
+	return s.updateDataset(ctx, func(d *data) error {
+		d.insertGameSeasons(gameSeasons)
+		return nil
+	})
+}
+
+func (s *service) RebuildTeamIdentities(ctx context.Context, teamIDs []int) error {
+	return s.updateDataset(ctx, func(d *data) error {
+		d.rebuildTeamIdentities(teamIDs)
+		return nil
+	})
+}
+
+// rebuildTeamIdentities collapses consecutive seasons with the same name and abbreviation into one era
+// and points each team at its latest one, the same as rebuildTeamIdentitiesQuery
+func (d *data) rebuildTeamIdentities(teamIDs []int) {
+	for _, teamID := range teamIDs {
+		var seasons []types.TeamSeason
+		for _, ts := range d.teamSeasons {
+			if ts.TeamID == teamID {
+				seasons = append(seasons, ts)
+			}
+		}
+		slices.SortFunc(seasons, func(a, b types.TeamSeason) int {
+			return cmp.Compare(a.SeasonYear, b.SeasonYear)
+		})
+
+		open := make(map[[2]string]*types.TeamIdentity)
+		var eras []*types.TeamIdentity
+		for _, ts := range seasons {
+			key := [2]string{ts.TeamName, ts.Abbreviation}
+			era := open[key]
+			if era == nil || era.EndSeason != ts.SeasonYear-1 {
+				era = &types.TeamIdentity{TeamID: teamID, StartSeason: ts.SeasonYear, Name: ts.TeamName, Abbreviation: ts.Abbreviation}
+				open[key] = era
+				eras = append(eras, era)
+			}
+			era.EndSeason = ts.SeasonYear
+		}
+
+		if len(eras) == 0 {
+			delete(d.identities, teamID)
+			continue
+		}
+		identities := make([]types.TeamIdentity, len(eras))
+		for i, era := range eras {
+			identities[i] = *era
+		}
+		slices.SortFunc(identities, func(a, b types.TeamIdentity) int {
+			return cmp.Compare(a.StartSeason, b.StartSeason)
+		})
+		d.identities[teamID] = identities
+
+		latest := slices.MaxFunc(identities, func(a, b types.TeamIdentity) int {
+			return cmp.Compare(a.EndSeason, b.EndSeason)
+		})
+		if team, ok := d.teams[teamID]; ok {
+			team.Name, team.Abbreviation = latest.Name, latest.Abbreviation
+			d.teams[teamID] = team
+		}
+	}
+}
+
+func (s *service) DeleteSeason(ctx context.Context, year int) error {
+	log.Printf("Deleting season %v from memory\n", year)
+	return s.updateDataset(ctx, func(d *data) error {
+		d.rebuildTeamIdentities(d.deleteSeason(year))
+		return nil
+	})
+}
+
+func (s *service) ReplaceSeason(ctx context.Context, year int, batch *types.SeasonBatch, shotChunkSize int) error {
+	for _, season := range batch.Seasons {
+		if season.Year != year {
+			return fmt.Errorf("replacing season %d with a batch that has season %d", year, season.Year)
+		}
+	}
+	log.Printf("Replacing season %v in memory\n", year)
+	return s.updateDataset(ctx, func(d *data) error {
+		teamIDs := d.deleteSeason(year)
+		err := d.insertSeasonBatch(batch)
+		if err != nil {
+			return err
+		}
+		d.rebuildTeamIdentities(append(teamIDs, batchTeamIDs(batch)...))
+		return nil
+	})
+}
+
+// deleteSeason removes the season scoped rows and the rows pointing at the season's games,
+// it returns the teams that played in the season
+func (d *data) deleteSeason(year int) []int {
+	var teamIDs []int
+	for key, ts := range d.teamSeasons {
+		if ts.SeasonYear == year {
+			teamIDs = append(teamIDs, ts.TeamID)
+			delete(d.teamSeasons, key)
+		}
+	}
+
+	inSeason := func(gameID int) bool {
+		g, ok := d.games[gameID]
+		return ok && g.SeasonYear == year
+	}
+	d.shots = slices.DeleteFunc(d.shots, func(s shot) bool {
+		return s.SeasonYear == year || inSeason(s.GameID)
+	})
+	for key, pg := range d.playerGames {
+		if inSeason(pg.GameID) {
+			delete(d.playerGames, key)
+		}
+	}
+	for key, tg := range d.teamGames {
+		if inSeason(tg.GameID) {
+			delete(d.teamGames, key)
+		}
+	}
+	for key, gs := range d.gameSeasons {
+		if gs.SeasonYear == year || inSeason(gs.GameID) {
+			delete(d.gameSeasons, key)
+		}
+	}
+	for id, g := range d.games {
+		if g.SeasonYear == year {
+			delete(d.games, id)
+		}
+	}
+	for key, ps := range d.playerSeasons {
+		if ps.SeasonYear == year {
+			delete(d.playerSeasons, key)
+		}
+	}
+	delete(d.seasons, year)
+
+	slices.Sort(teamIDs)
+	return teamIDs
+}
+
+func (s *service) InsertQueryHistory(ctx context.Context, qh *types.QueryHistoryRecord) error {
+	return s.update(ctx, func(d *data) error {
+		d.queryHistory = append(d.queryHistory, queryHistory{QueryHistoryRecord: *qh, CreatedAt: time.Now()})
+		return nil
+	})
+}
+
+func (s *service) StartRefreshRun(ctx context.Context) (int, error) {
+	var id int
+	err := s.update(ctx, func(d *data) error {
+		id = len(d.refreshRuns) + 1
+		d.refreshRuns = append(d.refreshRuns, types.RefreshRun{ID: id, Status: types.RefreshRunning, StartedAt: time.Now()})
+		return nil
+	})
+	return id, err
+}
+
+func (s *service) FinishRefreshRun(ctx context.Context, run *types.RefreshRun) error {
+	return s.update(ctx, func(d *data) error {
+		for i := range d.refreshRuns {
+			if d.refreshRuns[i].ID != run.ID {
+				continue
+			}
+			finished := time.Now()
+			r := &d.refreshRuns[i]
+			r.Status, r.Sources, r.GamesAdded, r.ShotsAdded, r.Error = run.Status, run.Sources, run.GamesAdded, run.ShotsAdded, run.Error
+			r.FinishedAt = &finished
+		}
+		return nil
+	})
+}
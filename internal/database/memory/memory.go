@@ -0,0 +1,189 @@
+// Package memory is a database.Service that keeps everything in memory, for handler tests and
+// running the api without postgres. It answers shot queries with the same filters as
+// database.ShotQuery but doesn't check foreign keys.
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"nba-shots/internal/database"
+	"nba-shots/internal/types"
+)
+
+type pair [2]int
+
+type shot struct {
+	ID int
+	types.Shot
+}
+
+type queryHistory struct {
+	types.QueryHistoryRecord
+	CreatedAt time.Time
+}
+
+// data is one version of every table, writes change a copy of it and swap it in once they succeed
+// so a failed write leaves nothing behind, like a rolled back transaction
+type data struct {
+	players       map[int]types.Player
+	teams         map[int]types.Team
+	seasons       map[int]types.Season
+	games         map[int]types.Game
+	shots         []shot
+	nextShotID    int
+	playerTeams   map[pair]types.PlayerTeam
+	playerSeasons map[pair]types.PlayerSeason
+	playerGames   map[pair]types.PlayerGame
+	teamSeasons   map[pair]types.TeamSeason
+	teamGames     map[pair]types.TeamGame
+	gameSeasons   map[pair]types.GameSeason
+	identities    map[int][]types.TeamIdentity
+	queryHistory  []queryHistory
+	refreshRuns   []types.RefreshRun
+	version       types.DatasetVersion
+}
+
+func newData() *data {
+	return &data{
+		players:       make(map[int]types.Player),
+		teams:         make(map[int]types.Team),
+		seasons:       make(map[int]types.Season),
+		games:         make(map[int]types.Game),
+		nextShotID:    1,
+		playerTeams:   make(map[pair]types.PlayerTeam),
+		playerSeasons: make(map[pair]types.PlayerSeason),
+		playerGames:   make(map[pair]types.PlayerGame),
+		teamSeasons:   make(map[pair]types.TeamSeason),
+		teamGames:     make(map[pair]types.TeamGame),
+		gameSeasons:   make(map[pair]types.GameSeason),
+		identities:    make(map[int][]types.TeamIdentity),
+		version:       types.DatasetVersion{Version: 1, UpdatedAt: time.Now()},
+	}
+}
+
+func (d *data) clone() *data {
+	return &data{
+		players:       maps.Clone(d.players),
+		teams:         maps.Clone(d.teams),
+		seasons:       maps.Clone(d.seasons),
+		games:         maps.Clone(d.games),
+		shots:         slices.Clone(d.shots),
+		nextShotID:    d.nextShotID,
+		playerTeams:   maps.Clone(d.playerTeams),
+		playerSeasons: maps.Clone(d.playerSeasons),
+		playerGames:   maps.Clone(d.playerGames),
+		teamSeasons:   maps.Clone(d.teamSeasons),
+		teamGames:     maps.Clone(d.teamGames),
+		gameSeasons:   maps.Clone(d.gameSeasons),
+		identities:    maps.Clone(d.identities),
+		queryHistory:  slices.Clone(d.queryHistory),
+		refreshRuns:   slices.Clone(d.refreshRuns),
+		version:       d.version,
+	}
+}
+
+type service struct {
+	mu   sync.RWMutex
+	data *data
+}
+
+var _ database.Service = (*service)(nil)
+
+// ErrUnsupported is returned for the parts of database.Service that only make sense against postgres
+var ErrUnsupported = errors.New("not supported by the in-memory database")
+
+// New returns an empty in-memory database
+func New() database.Service {
+	return &service{data: newData()}
+}
+
+// NewWithBatches returns an in-memory database with the batches inserted
+func NewWithBatches(batches ...*types.SeasonBatch) (database.Service, error) {
+	s := New()
+	for _, batch := range batches {
+		err := s.InsertSeasonBatch(context.Background(), batch, len(batch.Shots))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// read returns the current version of the tables, it must not be changed
+func (s *service) read(ctx context.Context) (*data, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data, nil
+}
+
+// update runs fn on a copy of the tables and keeps it if fn succeeds, writes are serialized
+func (s *service) update(ctx context.Context, fn func(*data) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.data.clone()
+	err := fn(d)
+	if err != nil {
+		return err
+	}
+	s.data = d
+	return nil
+}
+
+// updateDataset is update for writes to the data, they bump its version
+func (s *service) updateDataset(ctx context.Context, fn func(*data) error) error {
+	return s.update(ctx, func(d *data) error {
+		err := fn(d)
+		if err != nil {
+			return err
+		}
+		d.version = types.DatasetVersion{Version: d.version.Version + 1, UpdatedAt: time.Now()}
+		return nil
+	})
+}
+
+func (s *service) MigrateUp(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (s *service) MigrateDown(ctx context.Context, steps int) ([]string, error) {
+	return nil, fmt.Errorf("migrating down: %w", ErrUnsupported)
+}
+
+// MigrationStatus lists no migrations, there is no schema to migrate
+func (s *service) MigrationStatus(ctx context.Context) ([]types.MigrationStatus, error) {
+	return []types.MigrationStatus{}, nil
+}
+
+func (s *service) IsEmptyDatabase(ctx context.Context) (bool, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return false, err
+	}
+	empty := len(d.players) == 0 && len(d.teams) == 0 && len(d.seasons) == 0 && len(d.games) == 0 && len(d.shots) == 0
+	return empty, nil
+}
+
+func (s *service) Health(ctx context.Context) map[string]string {
+	return map[string]string{
+		"status":  "up",
+		"message": "It's healthy",
+	}
+}
+
+func (s *service) Close() {
+	log.Println("Closed the in-memory database")
+}
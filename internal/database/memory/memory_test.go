@@ -0,0 +1,46 @@
+package memory
+
+import (
+	"context"
+	"nba-shots/internal/database/servicetest"
+	"nba-shots/internal/types"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	servicetest.Run(t, New())
+}
+
+func TestDemo(t *testing.T) {
+	ctx := context.Background()
+	db, err := NewDemo()
+	if err != nil {
+		t.Fatalf("could not load the demo: %v", err)
+	}
+
+	for _, year := range demoSeasons {
+		q, err := db.GetSeasonQuality(ctx, year)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		games := len(demoTeams) * (len(demoTeams) - 1)
+		if len(q.ShotsPerGame) != games || q.Shots != games*4*demoShotsPerGame {
+			t.Errorf("expected %d games of %d shots in %d, got %+v", games, 4*demoShotsPerGame, year, q)
+		}
+		if q.ShotTypeMismatches != 0 || q.OutOfBoundsShots != 0 || len(q.PlayersWithoutShots) != 0 {
+			t.Errorf("expected the demo season %d to pass the quality checks, got %+v", year, q)
+		}
+	}
+
+	// the same shots every time
+	again, err := NewDemo()
+	if err != nil {
+		t.Fatalf("could not load the demo: %v", err)
+	}
+	args := &types.RequestShotParams{PlayerIDs: []int{201939}, StartTimeLeftSecs: -1, EndTimeLeftSecs: -1}
+	first, _ := db.GetShotSummary(ctx, args)
+	second, _ := again.GetShotSummary(ctx, args)
+	if first.ShotAggregates != second.ShotAggregates || first.TotalMadeShots == 0 {
+		t.Errorf("expected the demo to be the same on every load, got %+v and %+v", first.ShotAggregates, second.ShotAggregates)
+	}
+}
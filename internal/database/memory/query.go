@@ -0,0 +1,378 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+
+	"nba-shots/internal/types"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// the lookups of a single row fail with pgx.ErrNoRows like the postgres ones do
+
+func (s *service) GetPlayerByID(ctx context.Context, playerID int) (*types.Player, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	player, ok := d.players[playerID]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return &player, nil
+}
+
+func (s *service) GetPlayersByIDs(ctx context.Context, playerIDs []int) ([]types.Player, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	players := []types.Player{}
+	for _, id := range playerIDs {
+		if player, ok := d.players[id]; ok && !slices.Contains(players, player) {
+			players = append(players, player)
+		}
+	}
+	return players, nil
+}
+
+// GetPlayersByName matches the name with ILIKE semantics, % is any run of characters and _ is one
+func (s *service) GetPlayersByName(ctx context.Context, playerName string) ([]types.Player, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	like := likePattern(playerName)
+	players := []types.Player{}
+	for _, player := range sortedValues(d.players) {
+		if like.MatchString(player.Name) {
+			players = append(players, player)
+		}
+	}
+	return players, nil
+}
+
+func likePattern(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString(`(?is)^`)
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(`.*`)
+		case '_':
+			b.WriteString(`.`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString(`$`)
+	return regexp.MustCompile(b.String())
+}
+
+func (s *service) GetTeamByID(ctx context.Context, teamID int) (*types.Team, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	team, ok := d.teams[teamID]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return &team, nil
+}
+
+func (s *service) GetTeamByIDForSeason(ctx context.Context, teamID int, seasonYear int) (*types.Team, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, identity := range d.identities[teamID] {
+		if seasonYear >= identity.StartSeason && seasonYear <= identity.EndSeason {
+			return &types.Team{ID: teamID, Name: identity.Name, Abbreviation: identity.Abbreviation}, nil
+		}
+	}
+	return nil, pgx.ErrNoRows
+}
+
+func (s *service) GetTeamIdentities(ctx context.Context, teamIDs []int, seasonYears []int) ([]types.TeamIdentity, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	identities := []types.TeamIdentity{}
+	for _, teamID := range sortedKeys(d.identities) {
+		if !slices.Contains(teamIDs, teamID) {
+			continue
+		}
+		for _, identity := range d.identities[teamID] {
+			overlaps := len(seasonYears) == 0 || slices.ContainsFunc(seasonYears, func(year int) bool {
+				return year >= identity.StartSeason && year <= identity.EndSeason
+			})
+			if overlaps {
+				identities = append(identities, identity)
+			}
+		}
+	}
+	return identities, nil
+}
+
+func (s *service) GetAllTeams(ctx context.Context) ([]types.Team, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sortedValues(d.teams), nil
+}
+
+func (s *service) GetSeasonByYear(ctx context.Context, year int) (*types.Season, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	season, ok := d.seasons[year]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return &season, nil
+}
+
+func (s *service) GetAllSeasons(ctx context.Context) ([]types.Season, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sortedValues(d.seasons), nil
+}
+
+func (s *service) GetSeasonTableCounts(ctx context.Context, year int) ([]types.TableCount, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inSeason := func(gameID int) bool {
+		return d.games[gameID].SeasonYear == year
+	}
+
+	counts := []types.TableCount{
+		{Table: "season"},
+		{Table: "game", Rows: countFunc(d.games, func(g types.Game) bool { return g.SeasonYear == year })},
+		{Table: "shot", Rows: len(filterShots(d.shots, &types.RequestShotParams{SeasonYears: []int{year}, StartTimeLeftSecs: -1, EndTimeLeftSecs: -1}))},
+		{Table: "player_season", Rows: countFunc(d.playerSeasons, func(ps types.PlayerSeason) bool { return ps.SeasonYear == year })},
+		{Table: "player_game", Rows: countFunc(d.playerGames, func(pg types.PlayerGame) bool { return inSeason(pg.GameID) })},
+		{Table: "team_season", Rows: countFunc(d.teamSeasons, func(ts types.TeamSeason) bool { return ts.SeasonYear == year })},
+		{Table: "team_game", Rows: countFunc(d.teamGames, func(tg types.TeamGame) bool { return inSeason(tg.GameID) })},
+		{Table: "game_season", Rows: countFunc(d.gameSeasons, func(gs types.GameSeason) bool { return gs.SeasonYear == year })},
+	}
+	if _, ok := d.seasons[year]; ok {
+		counts[0].Rows = 1
+	}
+	return counts, nil
+}
+
+// GetSeasonQuality computes the same numbers as the postgres quality queries
+func (s *service) GetSeasonQuality(ctx context.Context, year int) (*types.SeasonQuality, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	q := &types.SeasonQuality{
+		Year:                year,
+		ShotsPerGame:        make(map[int]int),
+		GamesPerTeam:        make(map[int]int),
+		PlayersWithoutShots: []int{},
+		GamesWithoutTeams:   []int{},
+	}
+
+	for _, g := range sortedValues(d.games) {
+		if g.SeasonYear != year {
+			continue
+		}
+		q.ShotsPerGame[g.ID] = 0
+		q.GamesPerTeam[g.HomeTeamID]++
+		q.GamesPerTeam[g.AwayTeamID]++
+
+		_, home := d.teams[g.HomeTeamID]
+		_, away := d.teams[g.AwayTeamID]
+		if g.HomeTeamID == 0 || g.AwayTeamID == 0 || g.HomeTeamID == g.AwayTeamID || !home || !away {
+			q.GamesWithoutTeams = append(q.GamesWithoutTeams, g.ID)
+		}
+	}
+
+	shooters := make(map[int]bool)
+	for _, s := range d.shots {
+		if _, ok := q.ShotsPerGame[s.GameID]; ok {
+			q.ShotsPerGame[s.GameID]++
+		}
+		if s.SeasonYear != year {
+			continue
+		}
+		shooters[s.PlayerID] = true
+		q.Shots++
+		if shotTypeMismatch(s.Shot) {
+			q.ShotTypeMismatches++
+		}
+		if s.BasicZone != "Backcourt" && (s.LocX < -25 || s.LocX > 25 || s.LocY < 0 || s.LocY > 47) {
+			q.OutOfBoundsShots++
+		}
+	}
+
+	for _, ps := range d.playerSeasons {
+		if ps.SeasonYear == year && !shooters[ps.PlayerID] {
+			q.PlayersWithoutShots = append(q.PlayersWithoutShots, ps.PlayerID)
+		}
+	}
+	slices.Sort(q.PlayersWithoutShots)
+	return q, nil
+}
+
+func shotTypeMismatch(s types.Shot) bool {
+	switch s.ShotType {
+	case "3PT Field Goal":
+		return slices.Contains([]string{"Restricted Area", "In The Paint (Non-RA)", "Mid-Range"}, s.BasicZone) || s.ShotDistance < 21
+	case types.TwoPtShot:
+		return slices.Contains([]string{"Left Corner 3", "Right Corner 3", "Above the Break 3", "Backcourt"}, s.BasicZone) || s.ShotDistance > 25
+	}
+	return false
+}
+
+func (s *service) GetGameByID(ctx context.Context, gameID int) (*types.Game, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	game, ok := d.games[gameID]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return &game, nil
+}
+
+func (s *service) GetLastXGames(ctx context.Context, amount int) ([]types.Game, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	games := sortedValues(d.games)
+	slices.SortStableFunc(games, func(a, b types.Game) int {
+		return b.GameDate.Compare(a.GameDate)
+	})
+	return games[:min(max(amount, 0), len(games))], nil
+}
+
+func (s *service) GetGameIDsForSeasons(ctx context.Context, seasonYears []int) ([]int, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := []int{}
+	for _, g := range sortedValues(d.games) {
+		if slices.Contains(seasonYears, g.SeasonYear) {
+			ids = append(ids, g.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (s *service) GetDatasetVersion(ctx context.Context) (*types.DatasetVersion, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v := d.version
+	return &v, nil
+}
+
+func (s *service) GetRefreshRuns(ctx context.Context, limit int) ([]types.RefreshRun, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	runs := slices.Clone(d.refreshRuns)
+	slices.Reverse(runs)
+	return runs[:min(max(limit, 0), len(runs))], nil
+}
+
+// GetQueryFilterUsage groups query_history by its filters like queryFilterUsageQuery does
+func (s *service) GetQueryFilterUsage(ctx context.Context, limit int) ([]types.QueryFilterUsage, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byFilters := make(map[string]*types.QueryFilterUsage)
+	var shots = make(map[string]int)
+	for _, qh := range d.queryHistory {
+		filters := queryFilters(&qh.RequestShotParams)
+		key := strings.Join(filters, ",")
+		u := byFilters[key]
+		if u == nil {
+			u = &types.QueryFilterUsage{Filters: filters}
+			byFilters[key] = u
+		}
+		u.Queries++
+		shots[key] += qh.ReturnedShots
+		if qh.CreatedAt.After(u.LastQueried) {
+			u.LastQueried = qh.CreatedAt
+		}
+	}
+
+	usage := []types.QueryFilterUsage{}
+	for key, u := range byFilters {
+		u.AvgShots = float64(shots[key]) / float64(u.Queries)
+		usage = append(usage, *u)
+	}
+	slices.SortFunc(usage, func(a, b types.QueryFilterUsage) int {
+		return cmp.Or(cmp.Compare(b.Queries, a.Queries), slices.Compare(a.Filters, b.Filters))
+	})
+	return usage[:min(max(limit, 0), len(usage))], nil
+}
+
+// queryFilters are the filters a query was made with, in the order ShotQuery applies them
+func queryFilters(args *types.RequestShotParams) []string {
+	filters := []string{}
+	add := func(used bool, filter string) {
+		if used {
+			filters = append(filters, filter)
+		}
+	}
+	add(len(args.PlayerIDs) > 0, types.FilterPlayer)
+	add(len(args.TeamIDs) > 0, types.FilterTeam)
+	add(len(args.SeasonYears) > 0, types.FilterSeason)
+	add(len(args.OpposingTeamIds) > 0, types.FilterOpponent)
+	add(!args.StartGameDate.IsZero() || !args.EndGameDate.IsZero(), types.FilterGameDate)
+	add(args.GameLocation != "", types.FilterLocation)
+	add(len(args.Quarters) > 0, types.FilterQuarter)
+	add(args.StartTimeLeftSecs != -1 || args.EndTimeLeftSecs != -1, types.FilterTimeLeft)
+	return filters
+}
+
+func countFunc[K comparable, V any](rows map[K]V, match func(V) bool) int {
+	n := 0
+	for _, v := range rows {
+		if match(v) {
+			n++
+		}
+	}
+	return n
+}
+
+func sortedKeys[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func sortedValues[V any](m map[int]V) []V {
+	values := make([]V, 0, len(m))
+	for _, k := range sortedKeys(m) {
+		values = append(values, m[k])
+	}
+	return values
+}
@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+
+	"nba-shots/internal/types"
+)
+
+func (s *service) GetTeamsByIDs(ctx context.Context, teamIDs []int) ([]types.Team, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return byIDs(d.teams, teamIDs), nil
+}
+
+func (s *service) GetSeasonsByYears(ctx context.Context, seasonYears []int) ([]types.Season, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return byIDs(d.seasons, seasonYears), nil
+}
+
+func (s *service) GetGamesByIDs(ctx context.Context, gameIDs []int) ([]types.Game, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return byIDs(d.games, gameIDs), nil
+}
+
+// byIDs is the rows of the ids that exist, ordered by id like the postgres queries
+func byIDs[V any](rows map[int]V, ids []int) []V {
+	found := []V{}
+	for _, id := range sortedKeys(rows) {
+		if slices.Contains(ids, id) {
+			found = append(found, rows[id])
+		}
+	}
+	return found
+}
+
+func (s *service) GetPlayerTeams(ctx context.Context, playerIDs []int, teamIDs []int) ([]types.PlayerTeam, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return links(d.playerTeams, playerIDs, teamIDs), nil
+}
+
+func (s *service) GetPlayerSeasons(ctx context.Context, playerIDs []int, seasonYears []int) ([]types.PlayerSeason, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return links(d.playerSeasons, playerIDs, seasonYears), nil
+}
+
+func (s *service) GetPlayerGames(ctx context.Context, playerIDs []int, gameIDs []int) ([]types.PlayerGame, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return links(d.playerGames, playerIDs, gameIDs), nil
+}
+
+func (s *service) GetTeamSeasons(ctx context.Context, teamIDs []int, seasonYears []int) ([]types.TeamSeason, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return links(d.teamSeasons, teamIDs, seasonYears), nil
+}
+
+func (s *service) GetTeamGames(ctx context.Context, teamIDs []int, gameIDs []int) ([]types.TeamGame, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return links(d.teamGames, teamIDs, gameIDs), nil
+}
+
+func (s *service) GetGameSeasons(ctx context.Context, gameIDs []int, seasonYears []int) ([]types.GameSeason, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return links(d.gameSeasons, gameIDs, seasonYears), nil
+}
+
+// links is the rows of a link table keyed by its two ids that match first and second, an empty
+// list matches any id. They're ordered by both ids like the postgres queries.
+func links[V any](rows map[pair]V, first, second []int) []V {
+	keys := slices.SortedFunc(maps.Keys(rows), func(a, b pair) int {
+		return cmp.Or(cmp.Compare(a[0], b[0]), cmp.Compare(a[1], b[1]))
+	})
+	found := []V{}
+	for _, key := range keys {
+		if (len(first) == 0 || slices.Contains(first, key[0])) && (len(second) == 0 || slices.Contains(second, key[1])) {
+			found = append(found, rows[key])
+		}
+	}
+	return found
+}
@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"nba-shots/internal/types"
+)
+
+func (s *service) GetShots(ctx context.Context, args *types.RequestShotParams) ([]types.ReturnShot, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shots := filterShots(d.shots, args)
+	returned := make([]types.ReturnShot, len(shots))
+	for i, s := range shots {
+		returned[i] = types.ReturnShot{ID: s.ID, LocX: s.LocX, LocY: s.LocY, ShotMade: s.ShotMade, ShotType: s.ShotType}
+	}
+	return returned, nil
+}
+
+// GetShotSummary counts the matching shots by zone, the postgres summary tables hold the same counts
+func (s *service) GetShotSummary(ctx context.Context, args *types.RequestShotParams) (*types.ShotSummary, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return summarize(filterShots(d.shots, args)), nil
+}
+
+func (s *service) GetShotsBy(ctx context.Context, args *types.RequestShotParams, group types.ShotGroup) (map[int][]types.ReturnShot, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shots := make(map[int][]types.ReturnShot)
+	for _, s := range filterShots(d.shots, args) {
+		id := group.Of(&s.Shot)
+		shots[id] = append(shots[id], types.ReturnShot{ID: s.ID, LocX: s.LocX, LocY: s.LocY, ShotMade: s.ShotMade, ShotType: s.ShotType})
+	}
+	return shots, nil
+}
+
+// GetShotSummariesBy has a summary for every id of the group, empty for the ones without shots
+func (s *service) GetShotSummariesBy(ctx context.Context, args *types.RequestShotParams, group types.ShotGroup) (map[int]*types.ShotSummary, error) {
+	d, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shots := make(map[int][]shot)
+	for _, s := range filterShots(d.shots, args) {
+		id := group.Of(&s.Shot)
+		shots[id] = append(shots[id], s)
+	}
+	summaries := make(map[int]*types.ShotSummary)
+	for _, id := range *group.IDs(args) {
+		summaries[id] = summarize(shots[id])
+	}
+	return summaries, nil
+}
+
+// summarize counts shots by zone, ordered by zone like the postgres queries
+func summarize(shots []shot) *types.ShotSummary {
+	zones := make(map[[2]string]*types.ZoneAggregates)
+	for _, s := range shots {
+		key := [2]string{s.BasicZone, s.ZoneABB}
+		if zones[key] == nil {
+			zones[key] = &types.ZoneAggregates{BasicZone: s.BasicZone, ZoneABB: s.ZoneABB}
+		}
+		zones[key].AddShot(s.ShotMade, s.ShotType)
+	}
+
+	summary := &types.ShotSummary{Zones: []types.ZoneAggregates{}}
+	for _, zone := range zones {
+		summary.Zones = append(summary.Zones, *zone)
+		summary.Add(zone.ShotAggregates)
+	}
+	slices.SortFunc(summary.Zones, func(a, b types.ZoneAggregates) int {
+		return cmp.Or(cmp.Compare(a.BasicZone, b.BasicZone), cmp.Compare(a.ZoneABB, b.ZoneABB))
+	})
+	return summary
+}
+
+func filterShots(shots []shot, args *types.RequestShotParams) []shot {
+	var matched []shot
+	for _, s := range shots {
+		if matchShot(&s.Shot, args) {
+			matched = append(matched, s)
+		}
+	}
+	return matched
+}
+
+// matchShot is the where clause database.ShotQuery builds for the args
+func matchShot(s *types.Shot, args *types.RequestShotParams) bool {
+	if len(args.PlayerIDs) > 0 && !slices.Contains(args.PlayerIDs, s.PlayerID) {
+		return false
+	}
+	if len(args.TeamIDs) > 0 && !slices.Contains(args.TeamIDs, s.TeamID) {
+		return false
+	}
+	if len(args.SeasonYears) > 0 && !slices.Contains(args.SeasonYears, s.SeasonYear) {
+		return false
+	}
+	if len(args.OpposingTeamIds) > 0 {
+		home := s.HomeTeamID == s.TeamID && slices.Contains(args.OpposingTeamIds, s.AwayTeamID)
+		away := s.AwayTeamID == s.TeamID && slices.Contains(args.OpposingTeamIds, s.HomeTeamID)
+		if !home && !away {
+			return false
+		}
+	}
+	if !args.StartGameDate.IsZero() && s.GameDate.Before(args.StartGameDate) {
+		return false
+	}
+	if !args.EndGameDate.IsZero() && s.GameDate.After(args.EndGameDate) {
+		return false
+	}
+	if args.GameLocation == "home" && s.TeamID != s.HomeTeamID {
+		return false
+	}
+	if args.GameLocation != "" && args.GameLocation != "home" && s.TeamID != s.AwayTeamID {
+		return false
+	}
+	if len(args.Quarters) > 0 && !slices.Contains(args.Quarters, s.Quarter) {
+		return false
+	}
+	if args.StartTimeLeftSecs >= 0 && args.StartTimeLeftSecs <= 720 && s.TotalTimeLeftSecs > args.StartTimeLeftSecs {
+		return false
+	}
+	if args.EndTimeLeftSecs >= 0 && args.EndTimeLeftSecs <= 720 && s.TotalTimeLeftSecs < args.EndTimeLeftSecs {
+		return false
+	}
+	return true
+}
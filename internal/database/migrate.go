@@ -0,0 +1,217 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"nba-shots/internal/types"
+	"nba-shots/migrations"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// key of the advisory lock held while migrating, so an api and an ingest starting
+// at the same time don't both apply the same migration
+const migrationLockKey = 7_340_118_001
+
+// migration is a NNN_name.sql file and the NNN_name.down.sql that reverts it,
+// the version is the file name without the extension like init-db.sh used to store it
+type migration struct {
+	Version string
+	Up      string
+	Down    string
+}
+
+// loadMigrations reads the migrations in fsys sorted by version
+func loadMigrations(fsys fs.FS) ([]migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[string]*migration)
+	for _, file := range files {
+		contents, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("reading migration %s: %w", file, err)
+		}
+
+		version, down := strings.CutSuffix(file, ".down.sql")
+		if !down {
+			version = strings.TrimSuffix(file, ".sql")
+		}
+		if byVersion[version] == nil {
+			byVersion[version] = &migration{Version: version}
+		}
+		if down {
+			byVersion[version].Down = string(contents)
+		} else {
+			byVersion[version].Up = string(contents)
+		}
+	}
+
+	all := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %s has a down but no up", m.Version)
+		}
+		all = append(all, *m)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Version < all[j].Version
+	})
+	return all, nil
+}
+
+// MigrateUp applies every embedded migration that hasn't been applied yet and returns their versions
+func (s *service) MigrateUp(ctx context.Context) ([]string, error) {
+	return s.migrate(ctx, func(ctx context.Context, conn *pgxpool.Conn, all []migration, applied map[string]time.Time) ([]string, error) {
+		var done []string
+		for _, m := range all {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+
+			log.Println("Applying migration:", m.Version)
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, m.Up)
+				if err != nil {
+					return err
+				}
+				_, err = tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, m.Version)
+				return err
+			})
+			if err != nil {
+				return done, fmt.Errorf("applying migration %s: %w", m.Version, err)
+			}
+			done = append(done, m.Version)
+		}
+		return done, nil
+	})
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first, and returns their versions
+func (s *service) MigrateDown(ctx context.Context, steps int) ([]string, error) {
+	return s.migrate(ctx, func(ctx context.Context, conn *pgxpool.Conn, all []migration, applied map[string]time.Time) ([]string, error) {
+		var done []string
+		for i := len(all) - 1; i >= 0 && len(done) < steps; i-- {
+			m := all[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return done, fmt.Errorf("migration %s has no down migration", m.Version)
+			}
+
+			log.Println("Reverting migration:", m.Version)
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, m.Down)
+				if err != nil {
+					return err
+				}
+				_, err = tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+				return err
+			})
+			if err != nil {
+				return done, fmt.Errorf("reverting migration %s: %w", m.Version, err)
+			}
+			done = append(done, m.Version)
+		}
+		return done, nil
+	})
+}
+
+// MigrationStatus lists every embedded migration and when it was applied, versions that are
+// applied in the database but no longer embedded are listed as missing
+func (s *service) MigrationStatus(ctx context.Context) ([]types.MigrationStatus, error) {
+	var statuses []types.MigrationStatus
+	_, err := s.migrate(ctx, func(ctx context.Context, conn *pgxpool.Conn, all []migration, applied map[string]time.Time) ([]string, error) {
+		embedded := make(map[string]bool)
+		for _, m := range all {
+			embedded[m.Version] = true
+			status := types.MigrationStatus{Version: m.Version, Status: types.MigrationPending}
+			if at, ok := applied[m.Version]; ok {
+				status.Status, status.AppliedAt = types.MigrationApplied, &at
+			}
+			statuses = append(statuses, status)
+		}
+
+		for version, at := range applied {
+			if !embedded[version] {
+				statuses = append(statuses, types.MigrationStatus{Version: version, Status: types.MigrationMissing, AppliedAt: &at})
+			}
+		}
+		sort.Slice(statuses, func(i, j int) bool {
+			return statuses[i].Version < statuses[j].Version
+		})
+		return nil, nil
+	})
+	return statuses, err
+}
+
+type migrateFunc func(ctx context.Context, conn *pgxpool.Conn, all []migration, applied map[string]time.Time) ([]string, error)
+
+// migrate runs fn on a single connection holding the migration lock, with the versions table created
+// and the applied versions read
+func (s *service) migrate(ctx context.Context, fn migrateFunc) ([]string, error) {
+	all, err := loadMigrations(migrations.FS)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := s.db.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquiring connection: %w", err)
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey)
+	if err != nil {
+		return nil, fmt.Errorf("taking the migration lock: %w", err)
+	}
+	defer func() {
+		_, err := conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+		if err != nil {
+			log.Println("could not release the migration lock:", err)
+		}
+	}()
+
+	if s.schema != "" {
+		_, err = conn.Exec(ctx, fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS %s`, pgx.Identifier{s.schema}.Sanitize()))
+		if err != nil {
+			return nil, fmt.Errorf("creating schema %s: %w", s.schema, err)
+		}
+	}
+
+	_, err = conn.Exec(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version VARCHAR(255) PRIMARY KEY,
+		applied_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	rows, err := conn.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("reading schema_migrations: %w", err)
+	}
+	applied := make(map[string]time.Time)
+	var version string
+	var appliedAt time.Time
+	_, err = pgx.ForEachRow(rows, []any{&version, &appliedAt}, func() error {
+		applied[version] = appliedAt
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading schema_migrations: %w", err)
+	}
+
+	return fn(ctx, conn, all, applied)
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// shot is list partitioned by season_year, every season has its own shot_<year> partition
+func shotPartition(year int) string {
+	return fmt.Sprintf("shot_%d", year)
+}
+
+// ensureShotPartitions creates the partitions the shots are about to be copied into,
+// a COPY into shot fails for a season_year that has no partition
+func ensureShotPartitions(ctx context.Context, tx pgx.Tx, years []int) error {
+	sort.Ints(years)
+	batch := &pgx.Batch{}
+	for _, year := range years {
+		batch.Queue(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF shot FOR VALUES IN (%d)`,
+			pgx.Identifier{shotPartition(year)}.Sanitize(), year))
+	}
+
+	err := tx.SendBatch(ctx, batch).Close()
+	if err != nil {
+		return fmt.Errorf("creating shot partitions for seasons %v: %w", years, err)
+	}
+	return nil
+}
+
+// dropShotPartition detaches the season's partition and drops it, which is much cheaper than
+// deleting its rows one by one and leaves no dead tuples behind
+func dropShotPartition(ctx context.Context, tx pgx.Tx, year int) error {
+	name := shotPartition(year)
+
+	var exists bool
+	err := tx.QueryRow(ctx, `
+	SELECT EXISTS (
+		SELECT 1 FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'shot'::regclass AND c.relname = $1
+	)
+	`, name).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("finding shot partition %s: %w", name, err)
+	}
+	if !exists {
+		return nil
+	}
+
+	identifier := pgx.Identifier{name}.Sanitize()
+	batch := &pgx.Batch{}
+	batch.Queue(fmt.Sprintf(`ALTER TABLE shot DETACH PARTITION %s`, identifier))
+	batch.Queue(fmt.Sprintf(`DROP TABLE %s`, identifier))
+
+	err = tx.SendBatch(ctx, batch).Close()
+	if err != nil {
+		return fmt.Errorf("dropping shot partition %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"nba-shots/internal/types"
+	"nba-shots/migrations"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	benchSeasons      = 5
+	benchGames        = 40
+	benchShotsPerGame = 170
+	benchFirstSeason  = 2001
+	benchSeason       = 2003
+)
+
+// newBenchService migrates its own schema and loads benchSeasons seasons into it.
+// Unpartitioned schemas have migration 5 reverted after the load.
+func newBenchService(b *testing.B, name string, partitioned bool) *service {
+	b.Helper()
+	ctx := context.Background()
+
+	_, err := testDB.db.Exec(ctx, fmt.Sprintf(`DROP SCHEMA IF EXISTS %s CASCADE`, pgx.Identifier{name}.Sanitize()))
+	if err != nil {
+		b.Fatalf("could not drop schema %s: %v", name, err)
+	}
+
+	cfg := testConfig
+	cfg.Schema = name
+	s, err := newService(ctx, cfg)
+	if err != nil {
+		b.Fatalf("could not connect: %v", err)
+	}
+	b.Cleanup(s.Close)
+	pool := s.db
+
+	_, err = s.MigrateUp(ctx)
+	if err != nil {
+		b.Fatalf("could not migrate %s: %v", name, err)
+	}
+	for year := benchFirstSeason; year < benchFirstSeason+benchSeasons; year++ {
+		err = s.InsertSeasonBatch(ctx, benchSeasonBatch(year), 10_000)
+		if err != nil {
+			b.Fatalf("could not load season %d: %v", year, err)
+		}
+	}
+
+	if !partitioned {
+		all, err := loadMigrations(migrations.FS)
+		if err != nil {
+			b.Fatal(err)
+		}
+		steps := 0
+		for _, m := range all {
+			if m.Version >= "005_partition_shot" {
+				steps++
+			}
+		}
+		_, err = s.MigrateDown(ctx, steps)
+		if err != nil {
+			b.Fatalf("could not unpartition %s: %v", name, err)
+		}
+	}
+
+	_, err = pool.Exec(ctx, `ANALYZE`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return s
+}
+
+func benchSeasonBatch(year int) *types.SeasonBatch {
+	home, away := 1610612747, 1610612757
+	batch := &types.SeasonBatch{
+		Players: []types.Player{{ID: 977, Name: "Kobe Bryant"}, {ID: 1717, Name: "Dirk Nowitzki"}},
+		Teams: []types.Team{
+			{ID: home, Name: "Los Angeles Lakers", Abbreviation: "LAL"},
+			{ID: away, Name: "Portland Trail Blazers", Abbreviation: "POR"},
+		},
+		Seasons: []types.Season{{Year: year, SeasonYears: fmt.Sprintf("%d-%02d", year-1, year%100)}},
+		TeamSeasons: []types.TeamSeason{
+			{TeamID: home, SeasonYear: year, TeamName: "Los Angeles Lakers", Abbreviation: "LAL"},
+			{TeamID: away, SeasonYear: year, TeamName: "Portland Trail Blazers", Abbreviation: "POR"},
+		},
+	}
+
+	for g := 0; g < benchGames; g++ {
+		gameID := year*1000 + g
+		gameDate := time.Date(year-1, 11, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, g*2)
+		batch.Games = append(batch.Games, types.Game{ID: gameID, HomeTeamID: home, AwayTeamID: away, SeasonYear: year, GameDate: gameDate})
+		batch.GameSeasons = append(batch.GameSeasons, types.GameSeason{GameID: gameID, SeasonYear: year})
+
+		for i := 0; i < benchShotsPerGame; i++ {
+			playerID, teamID := 977, home
+			if i%2 == 1 {
+				playerID, teamID = 1717, away
+			}
+			batch.Shots = append(batch.Shots, types.Shot{
+				PlayerID: playerID, GameID: gameID, TeamID: teamID,
+				HomeTeamID: home, AwayTeamID: away, SeasonYear: year,
+				EventType: "Made Shot", ShotMade: i%3 == 0, ActionType: "Jump Shot", ShotType: "2PT Field Goal",
+				BasicZone: "Mid-Range", ZoneName: "Center", ZoneABB: "C", ZoneRange: "8-16 ft.",
+				LocX: float64(i%50 - 25), LocY: float64(i % 30), ShotDistance: i % 30, Quarter: i%4 + 1,
+				MinsLeft: i % 12, SecsLeft: i % 60, TotalTimeLeftSecs: i % 720,
+				Position: "SG", PositionGroup: "G", GameDate: gameDate,
+			})
+		}
+	}
+	return batch
+}
+
+// BenchmarkSeasonShots compares a season query on the partitioned shot table, which only scans
+// the season's partition, with the same query on a single table going through idx_shot_season_id
+func BenchmarkSeasonShots(b *testing.B) {
+	ctx := context.Background()
+	for _, partitioned := range []bool{true, false} {
+		name := "unpartitioned"
+		if partitioned {
+			name = "partitioned"
+		}
+		b.Run(name, func(b *testing.B) {
+			s := newBenchService(b, "bench_"+name, partitioned)
+			params := &types.RequestShotParams{SeasonYears: []int{benchSeason}, PlayerIDs: []int{977}}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				shots, err := s.GetShots(ctx, params)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(shots) != benchGames*benchShotsPerGame/2 {
+					b.Fatalf("expected %d shots, got %d", benchGames*benchShotsPerGame/2, len(shots))
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkReloadSeasonShots compares replacing a season's shots by dropping its partition with
+// deleting its rows from a single table, both copy the season back in the same transaction
+func BenchmarkReloadSeasonShots(b *testing.B) {
+	ctx := context.Background()
+	shots := benchSeasonBatch(benchSeason).Shots
+
+	b.Run("partitioned", func(b *testing.B) {
+		s := newBenchService(b, "bench_partitioned", true)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			err := s.inTransaction(ctx, func(tx pgx.Tx) error {
+				err := dropShotPartition(ctx, tx, benchSeason)
+				if err != nil {
+					return err
+				}
+				return insertShots(ctx, tx, shots)
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("unpartitioned", func(b *testing.B) {
+		s := newBenchService(b, "bench_unpartitioned", false)
+		columns := types.GetTypeDBColumnNames(types.Shot{})
+		data := make([][]any, len(shots))
+		for i, shot := range shots {
+			data[i] = []any{
+				shot.PlayerID, shot.GameID, shot.TeamID, shot.HomeTeamID, shot.AwayTeamID, shot.SeasonYear,
+				shot.EventType, shot.ShotMade, shot.ActionType, shot.ShotType, shot.BasicZone, shot.ZoneName,
+				shot.ZoneABB, shot.ZoneRange, shot.LocX, shot.LocY, shot.ShotDistance, shot.Quarter,
+				shot.MinsLeft, shot.SecsLeft, shot.TotalTimeLeftSecs, shot.Position, shot.PositionGroup, shot.GameDate,
+			}
+		}
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			// how deleteSeason and insertShots reloaded a season before shot was partitioned
+			err := s.inTransaction(ctx, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, `DELETE FROM shot WHERE season_year = $1`, benchSeason)
+				if err != nil {
+					return err
+				}
+				return bulkLoadData(ctx, tx, "shot", columns, data)
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
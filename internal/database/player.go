@@ -8,24 +8,24 @@ import (
 	"strings"
 )
 
-func (s *service) GetPlayerByID(playerID int) (*types.Player, error) {
+func (s *service) GetPlayerByID(ctx context.Context, playerID int) (*types.Player, error) {
 	log.Println("Querying database for playerId", playerID)
 	player := &types.Player{}
 	query := `SELECT id, name FROM player WHERE id = $1`
-	err := s.db.QueryRow(context.Background(), query, playerID).Scan(&player.ID, &player.Name)
+	err := s.db.QueryRow(ctx, query, playerID).Scan(&player.ID, &player.Name)
 	if err != nil {
 		return nil, err
 	}
 	return player, nil
 }
 
-func (s *service) GetPlayersByName(playerName string) ([]types.Player, error) {
+func (s *service) GetPlayersByName(ctx context.Context, playerName string) ([]types.Player, error) {
 	log.Println("Querying database for playerName", playerName)
 
 	players := []types.Player{}
 	query := `SELECT id, name FROM player WHERE name ilike $1`
 
-	rows, err := s.db.Query(context.Background(), query, playerName)
+	rows, err := s.db.Query(ctx, query, playerName)
 
 	if err != nil {
 		return nil, err
@@ -52,7 +52,7 @@ func (s *service) GetPlayersByName(playerName string) ([]types.Player, error) {
 	return players, nil
 }
 
-func (s *service) GetPlayersByIDs(playerIDs []int) ([]types.Player, error) {
+func (s *service) GetPlayersByIDs(ctx context.Context, playerIDs []int) ([]types.Player, error) {
 	log.Println("Querying database for playerIDs", playerIDs)
 
 	args := make([]any, len(playerIDs))
@@ -73,7 +73,7 @@ func (s *service) GetPlayersByIDs(playerIDs []int) ([]types.Player, error) {
 	players := []types.Player{}
 	query := `SELECT id, name FROM player ` + whereClause
 	log.Println("query", query)
-	rows, err := s.db.Query(context.Background(), query, args...)
+	rows, err := s.db.Query(ctx, query, args...)
 
 	if err != nil {
 		return nil, err
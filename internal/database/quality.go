@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"nba-shots/internal/types"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// GetSeasonQuality gathers the numbers the post-ingest quality checks need for a season
+func (s *service) GetSeasonQuality(ctx context.Context, year int) (*types.SeasonQuality, error) {
+	log.Println("Querying database for the data quality of season", year)
+	q := &types.SeasonQuality{
+		Year:         year,
+		ShotsPerGame: make(map[int]int),
+		GamesPerTeam: make(map[int]int),
+	}
+
+	err := collectCounts(ctx, s, q.ShotsPerGame, `
+	SELECT g.id, COUNT(s.id)
+	FROM game g
+	LEFT JOIN shot s ON s.game_id = g.id
+	WHERE g.season_year = $1
+	GROUP BY g.id
+	`, year)
+	if err != nil {
+		return nil, fmt.Errorf("counting shots per game: %w", err)
+	}
+
+	err = collectCounts(ctx, s, q.GamesPerTeam, `
+	SELECT team_id, COUNT(*)
+	FROM (
+		SELECT home_team_id AS team_id FROM game WHERE season_year = $1
+		UNION ALL
+		SELECT away_team_id FROM game WHERE season_year = $1
+	) t
+	GROUP BY team_id
+	`, year)
+	if err != nil {
+		return nil, fmt.Errorf("counting games per team: %w", err)
+	}
+
+	q.PlayersWithoutShots, err = collectIDs(ctx, s, `
+	SELECT ps.player_id
+	FROM player_season ps
+	WHERE ps.season_year = $1
+		AND NOT EXISTS (SELECT 1 FROM shot s WHERE s.player_id = ps.player_id AND s.season_year = $1)
+	ORDER BY ps.player_id
+	`, year)
+	if err != nil {
+		return nil, fmt.Errorf("finding players without shots: %w", err)
+	}
+
+	q.GamesWithoutTeams, err = collectIDs(ctx, s, `
+	SELECT g.id
+	FROM game g
+	WHERE g.season_year = $1
+		AND (
+			g.home_team_id = 0 OR g.away_team_id = 0 OR g.home_team_id = g.away_team_id
+			OR NOT EXISTS (SELECT 1 FROM team t WHERE t.id = g.home_team_id)
+			OR NOT EXISTS (SELECT 1 FROM team t WHERE t.id = g.away_team_id)
+		)
+	ORDER BY g.id
+	`, year)
+	if err != nil {
+		return nil, fmt.Errorf("finding games without teams: %w", err)
+	}
+
+	// the court is 50ft wide with x centered on the hoop, and a half court is 47ft deep from the baseline
+	query := `
+	SELECT
+		COUNT(*),
+		COUNT(*) FILTER (WHERE
+			(shot_type = '3PT Field Goal' AND (basic_zone IN ('Restricted Area', 'In The Paint (Non-RA)', 'Mid-Range') OR shot_distance < 21))
+			OR (shot_type = '2PT Field Goal' AND (basic_zone IN ('Left Corner 3', 'Right Corner 3', 'Above the Break 3', 'Backcourt') OR shot_distance > 25))
+		),
+		COUNT(*) FILTER (WHERE
+			basic_zone <> 'Backcourt' AND (loc_x < -25 OR loc_x > 25 OR loc_y < 0 OR loc_y > 47)
+		)
+	FROM shot
+	WHERE season_year = $1
+	`
+	err = s.db.QueryRow(ctx, query, year).Scan(&q.Shots, &q.ShotTypeMismatches, &q.OutOfBoundsShots)
+	if err != nil {
+		return nil, fmt.Errorf("checking shots: %w", err)
+	}
+
+	return q, nil
+}
+
+// collectCounts reads (id, count) rows into counts
+func collectCounts(ctx context.Context, s *service, counts map[int]int, query string, args ...any) error {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, count int
+		err := rows.Scan(&id, &count)
+		if err != nil {
+			return err
+		}
+		counts[id] = count
+	}
+	return rows.Err()
+}
+
+func collectIDs(ctx context.Context, s *service, query string, args ...any) ([]int, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[int])
+}
@@ -4,15 +4,17 @@ import (
 	"context"
 	"log"
 	"nba-shots/internal/types"
+
+	"github.com/jackc/pgx/v5"
 )
 
 // Gets shots from the database given a query string and arguments
-func (s *service) QueryShots(queryString string, args []interface{}, argCount int) ([]types.ReturnShot, error) {
+func (s *service) QueryShots(ctx context.Context, queryString string, args []interface{}, argCount int) ([]types.ReturnShot, error) {
 	var shots []types.ReturnShot
 
 	log.Println("Initiating shots query with query string and args: ", queryString, args)
 
-	rows, err := s.db.Query(context.Background(), queryString, args...)
+	rows, err := s.db.Query(ctx, queryString, args...)
 
 	if err != nil {
 		return nil, err
@@ -44,3 +46,42 @@ func (s *service) QueryShots(queryString string, args []interface{}, argCount in
 
 	return shots, nil
 }
+
+// the filters every query_history row was made with, in the order ShotQuery applies them
+const queryFilterUsageQuery = `
+SELECT filters, COUNT(*) AS queries, COALESCE(AVG(returned_shots), 0)::float8 AS avg_shots, MAX(created_at) AS last_queried
+FROM (
+	SELECT array_remove(ARRAY[
+		CASE WHEN cardinality(player_id) > 0 THEN 'player' END,
+		CASE WHEN cardinality(team_id) > 0 THEN 'team' END,
+		CASE WHEN cardinality(season_year) > 0 THEN 'season' END,
+		CASE WHEN cardinality(opp_team_id) > 0 THEN 'opponent' END,
+		CASE WHEN start_game_date IS NOT NULL OR end_game_date IS NOT NULL THEN 'game_date' END,
+		CASE WHEN game_location IS NOT NULL THEN 'location' END,
+		CASE WHEN cardinality(quarter) > 0 THEN 'quarter' END,
+		CASE WHEN start_time_left IS NOT NULL OR end_time_left IS NOT NULL THEN 'time_left' END
+	], NULL)::text[] AS filters, returned_shots, created_at
+	FROM query_history
+) used
+GROUP BY filters
+ORDER BY queries DESC, filters
+LIMIT $1
+`
+
+// GetQueryFilterUsage returns the most common combinations of filters in query_history
+func (s *service) GetQueryFilterUsage(ctx context.Context, limit int) ([]types.QueryFilterUsage, error) {
+	log.Println("Querying database for the most common query filters", limit)
+
+	rows, err := s.db.Query(ctx, queryFilterUsageQuery, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.QueryFilterUsage])
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Query successful, returning %d filter combinations: \n", len(usage))
+	return usage, nil
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"nba-shots/internal/types"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// StartRefreshRun records the start of a refresh and returns its id
+func (s *service) StartRefreshRun(ctx context.Context) (int, error) {
+	var id int
+	query := `INSERT INTO refresh_run (status) VALUES ($1) RETURNING id`
+	err := s.db.QueryRow(ctx, query, types.RefreshRunning).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("starting refresh run: %w", err)
+	}
+	return id, nil
+}
+
+// FinishRefreshRun stores the outcome of a refresh started with StartRefreshRun
+func (s *service) FinishRefreshRun(ctx context.Context, run *types.RefreshRun) error {
+	query := `
+	UPDATE refresh_run
+	SET status = $2, sources = $3, games_added = $4, shots_added = $5, error = $6, finished_at = CURRENT_TIMESTAMP
+	WHERE id = $1
+	`
+	_, err := s.db.Exec(ctx, query,
+		run.ID,
+		run.Status,
+		run.Sources,
+		run.GamesAdded,
+		run.ShotsAdded,
+		run.Error,
+	)
+	if err != nil {
+		return fmt.Errorf("finishing refresh run %d: %w", run.ID, err)
+	}
+	return nil
+}
+
+// GetRefreshRuns returns the latest refresh runs, newest first
+func (s *service) GetRefreshRuns(ctx context.Context, limit int) ([]types.RefreshRun, error) {
+	log.Println("Querying database for the last refresh runs", limit)
+	query := `
+	SELECT id, status, sources, games_added, shots_added, error, started_at, finished_at
+	FROM refresh_run
+	ORDER BY started_at DESC, id DESC
+	LIMIT $1
+	`
+
+	rows, err := s.db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	runs, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.RefreshRun])
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Query successful, returning %d refresh runs: \n", len(runs))
+	return runs, nil
+}
+
+// GetGameIDsForSeasons returns the ids of every game already loaded for the seasons
+func (s *service) GetGameIDsForSeasons(ctx context.Context, seasonYears []int) ([]int, error) {
+	log.Println("Querying database for the games of seasons", seasonYears)
+	query := `SELECT id FROM game WHERE season_year = ANY($1)`
+
+	rows, err := s.db.Query(ctx, query, seasonYears)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[int])
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"nba-shots/internal/types"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *service) GetTeamsByIDs(ctx context.Context, teamIDs []int) ([]types.Team, error) {
+	log.Println("Querying database for teamIDs", teamIDs)
+	rows, err := s.db.Query(ctx, `SELECT id, name, abbreviation FROM team WHERE id = ANY($1) ORDER BY id`, teamIDs)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowToStructByName[types.Team])
+}
+
+func (s *service) GetSeasonsByYears(ctx context.Context, seasonYears []int) ([]types.Season, error) {
+	log.Println("Querying database for seasons", seasonYears)
+	rows, err := s.db.Query(ctx, `SELECT year, season_years FROM season WHERE year = ANY($1) ORDER BY year`, seasonYears)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowToStructByName[types.Season])
+}
+
+func (s *service) GetGamesByIDs(ctx context.Context, gameIDs []int) ([]types.Game, error) {
+	log.Println("Querying database for gameIDs", gameIDs)
+	query := `SELECT id, home_team_id, away_team_id, season_year, game_date FROM game WHERE id = ANY($1) ORDER BY id`
+	rows, err := s.db.Query(ctx, query, gameIDs)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowToStructByName[types.Game])
+}
+
+func (s *service) GetPlayerTeams(ctx context.Context, playerIDs []int, teamIDs []int) ([]types.PlayerTeam, error) {
+	return queryLinks[types.PlayerTeam](ctx, s, "player_team", playerIDs, teamIDs)
+}
+
+func (s *service) GetPlayerSeasons(ctx context.Context, playerIDs []int, seasonYears []int) ([]types.PlayerSeason, error) {
+	return queryLinks[types.PlayerSeason](ctx, s, "player_season", playerIDs, seasonYears)
+}
+
+func (s *service) GetPlayerGames(ctx context.Context, playerIDs []int, gameIDs []int) ([]types.PlayerGame, error) {
+	return queryLinks[types.PlayerGame](ctx, s, "player_game", playerIDs, gameIDs)
+}
+
+func (s *service) GetTeamSeasons(ctx context.Context, teamIDs []int, seasonYears []int) ([]types.TeamSeason, error) {
+	return queryLinks[types.TeamSeason](ctx, s, "team_season", teamIDs, seasonYears)
+}
+
+func (s *service) GetTeamGames(ctx context.Context, teamIDs []int, gameIDs []int) ([]types.TeamGame, error) {
+	return queryLinks[types.TeamGame](ctx, s, "team_game", teamIDs, gameIDs)
+}
+
+func (s *service) GetGameSeasons(ctx context.Context, gameIDs []int, seasonYears []int) ([]types.GameSeason, error) {
+	return queryLinks[types.GameSeason](ctx, s, "game_season", gameIDs, seasonYears)
+}
+
+// queryLinks selects the rows of a link table whose first two columns, in the order of T's
+// fields, are in first and second. An empty list matches any id.
+func queryLinks[T any](ctx context.Context, s *service, table string, first, second []int) ([]T, error) {
+	log.Println("Querying database for the", table, "rows of", first, second)
+	var row T
+	columns := types.GetTypeDBColumnNames(row)
+	query := fmt.Sprintf(`
+	SELECT %[1]s
+	FROM %[2]s
+	WHERE (cardinality($1::int[]) = 0 OR %[3]s = ANY($1))
+		AND (cardinality($2::int[]) = 0 OR %[4]s = ANY($2))
+	ORDER BY %[3]s, %[4]s
+	`, strings.Join(columns, ", "), table, columns[0], columns[1])
+
+	if first == nil {
+		first = []int{}
+	}
+	if second == nil {
+		second = []int{}
+	}
+
+	rows, err := s.db.Query(ctx, query, first, second)
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := pgx.CollectRows(rows, pgx.RowToStructByName[T])
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Query successful, returning %d %s rows: \n", len(links), table)
+	return links, nil
+}
@@ -2,27 +2,30 @@ package database
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"nba-shots/internal/types"
+
+	"github.com/jackc/pgx/v5"
 )
 
-func (s *service) GetSeasonByYear(year int) (*types.Season, error) {
+func (s *service) GetSeasonByYear(ctx context.Context, year int) (*types.Season, error) {
 	log.Println("Querying database for year", year)
 	season := &types.Season{}
 	query := `SELECT year, season_years FROM season WHERE year = $1`
-	err := s.db.QueryRow(context.Background(), query, year).Scan(&season.Year, &season.SeasonYears)
+	err := s.db.QueryRow(ctx, query, year).Scan(&season.Year, &season.SeasonYears)
 	if err != nil {
 		return nil, err
 	}
 	return season, nil
 }
 
-func (s *service) GetAllSeasons() ([]types.Season, error) {
+func (s *service) GetAllSeasons(ctx context.Context) ([]types.Season, error) {
 	log.Println("Querying database for all seasons")
 	seasons := []types.Season{}
 	query := `SELECT year, season_years FROM season`
 
-	rows, err := s.db.Query(context.Background(), query)
+	rows, err := s.db.Query(ctx, query)
 
 	if err != nil {
 		return nil, err
@@ -48,3 +51,139 @@ func (s *service) GetAllSeasons() ([]types.Season, error) {
 
 	return seasons, nil
 }
+
+// GetSeasonTableCounts returns the number of rows each season scoped table has for the season
+func (s *service) GetSeasonTableCounts(ctx context.Context, year int) ([]types.TableCount, error) {
+	log.Println("Querying database for row counts of season", year)
+	tables := []string{"season", "game", "shot", "player_season", "player_game", "team_season", "team_game", "game_season"}
+	query := `
+	SELECT
+		(SELECT COUNT(*) FROM season WHERE year = $1),
+		(SELECT COUNT(*) FROM game WHERE season_year = $1),
+		(SELECT COUNT(*) FROM shot WHERE season_year = $1),
+		(SELECT COUNT(*) FROM player_season WHERE season_year = $1),
+		(SELECT COUNT(*) FROM player_game pg JOIN game g ON g.id = pg.game_id WHERE g.season_year = $1),
+		(SELECT COUNT(*) FROM team_season WHERE season_year = $1),
+		(SELECT COUNT(*) FROM team_game tg JOIN game g ON g.id = tg.game_id WHERE g.season_year = $1),
+		(SELECT COUNT(*) FROM game_season WHERE season_year = $1)
+	`
+
+	rows := make([]int, len(tables))
+	dest := make([]any, len(tables))
+	for i := range rows {
+		dest[i] = &rows[i]
+	}
+
+	err := s.db.QueryRow(ctx, query, year).Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]types.TableCount, len(tables))
+	for i, table := range tables {
+		counts[i] = types.TableCount{Table: table, Rows: rows[i]}
+	}
+	return counts, nil
+}
+
+// DeleteSeason - deletes every row belonging to the season in a single transaction.
+// Players and teams are kept since they span seasons, but the team eras are rebuilt without it.
+func (s *service) DeleteSeason(ctx context.Context, year int) error {
+	log.Printf("Transaction Started to delete season %v\n", year)
+
+	return s.inDatasetTransaction(ctx, func(tx pgx.Tx) error {
+		teamIDs, err := deleteSeason(ctx, tx, year)
+		if err != nil {
+			return err
+		}
+		return rebuildTeamIdentities(ctx, tx, teamIDs)
+	})
+}
+
+// ReplaceSeason - deletes a season and inserts the batch in its place in a single transaction,
+// readers see either the old season or the new one. The batch can only contain that season.
+func (s *service) ReplaceSeason(ctx context.Context, year int, batch *types.SeasonBatch, shotChunkSize int) error {
+	for _, season := range batch.Seasons {
+		if season.Year != year {
+			return fmt.Errorf("replacing season %d with a batch that has season %d", year, season.Year)
+		}
+	}
+
+	log.Printf("Transaction Started to replace season %v\n", year)
+
+	return s.inDatasetTransaction(ctx, func(tx pgx.Tx) error {
+		teamIDs, err := deleteSeason(ctx, tx, year)
+		if err != nil {
+			return err
+		}
+
+		err = insertSeasonBatch(ctx, tx, batch, shotChunkSize)
+		if err != nil {
+			return err
+		}
+
+		// teams that are only in the old data lose their era for the season, new ones gain one
+		return rebuildTeamIdentities(ctx, tx, append(teamIDs, batchTeamIDs(batch)...))
+	})
+}
+
+// deleteSeason removes every season scoped row and returns the teams that played in the season,
+// their identities have to be rebuilt once the transaction is done changing team_season
+func deleteSeason(ctx context.Context, tx pgx.Tx, year int) ([]int, error) {
+	rows, err := tx.Query(ctx, `SELECT team_id FROM team_season WHERE season_year = $1`, year)
+	if err != nil {
+		return nil, fmt.Errorf("finding the teams of season %d: %w", year, err)
+	}
+	teamIDs, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, fmt.Errorf("finding the teams of season %d: %w", year, err)
+	}
+
+	// the season's shots go with their partition
+	err = dropShotPartition(ctx, tx, year)
+	if err != nil {
+		return nil, err
+	}
+
+	// children first so no foreign keys are left dangling, rows pointing at the season's games
+	// are removed even when they were tagged with another season
+	rows, err = tx.Query(ctx, `
+	WITH deleted AS (
+		DELETE FROM shot WHERE game_id IN (SELECT id FROM game WHERE season_year = $1) RETURNING season_year
+	)
+	SELECT DISTINCT season_year FROM deleted WHERE season_year <> $1
+	`, year)
+	if err != nil {
+		return nil, fmt.Errorf("deleting the shots of season %d: %w", year, err)
+	}
+	otherSeasons, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, fmt.Errorf("deleting the shots of season %d: %w", year, err)
+	}
+
+	batch := &pgx.Batch{}
+	for _, table := range shotSummaryTables {
+		batch.Queue(fmt.Sprintf(`DELETE FROM %s WHERE season_year = $1`, table.Name), year)
+	}
+	batch.Queue(`DELETE FROM player_game WHERE game_id IN (SELECT id FROM game WHERE season_year = $1)`, year)
+	batch.Queue(`DELETE FROM team_game WHERE game_id IN (SELECT id FROM game WHERE season_year = $1)`, year)
+	batch.Queue(`DELETE FROM game_season WHERE season_year = $1 OR game_id IN (SELECT id FROM game WHERE season_year = $1)`, year)
+	batch.Queue(`DELETE FROM game WHERE season_year = $1`, year)
+	batch.Queue(`DELETE FROM player_season WHERE season_year = $1`, year)
+	batch.Queue(`DELETE FROM team_season WHERE season_year = $1`, year)
+	batch.Queue(`DELETE FROM season WHERE year = $1`, year)
+
+	err = tx.SendBatch(ctx, batch).Close()
+	if err != nil {
+		return nil, fmt.Errorf("deleting season %d: %w", year, err)
+	}
+
+	// the seasons that lost shots with the season's games are counted again
+	if len(otherSeasons) > 0 {
+		err = rebuildShotSummaries(ctx, tx, otherSeasons)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return teamIDs, nil
+}
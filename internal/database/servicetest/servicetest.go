@@ -0,0 +1,626 @@
+// Package servicetest is the conformance suite every database.Service has to pass, the postgres
+// service and the in-memory one have to give the same answers for the same rows
+package servicetest
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"nba-shots/internal/database"
+	"nba-shots/internal/types"
+)
+
+const (
+	Year = 2016
+
+	Warriors  = 1610612744
+	Cavaliers = 1610612739
+	Spurs     = 1610612759
+
+	Curry  = 201939
+	LeBron = 2544
+	Kawhi  = 202695
+
+	// Warriors at home to the Cavaliers, Warriors at the Spurs, Spurs at the Cavaliers
+	Game1 = 21500001
+	Game2 = 21500002
+	Game3 = 21500003
+)
+
+var (
+	game1Date = time.Date(2015, 11, 1, 0, 0, 0, 0, time.UTC)
+	game2Date = time.Date(2015, 12, 1, 0, 0, 0, 0, time.UTC)
+	game3Date = time.Date(2016, 1, 15, 0, 0, 0, 0, time.UTC)
+)
+
+type fixtureShot struct {
+	PlayerID, TeamID, GameID int
+	Made                     bool
+	ShotType, BasicZone      string
+	Quarter, TimeLeft        int
+}
+
+// every fixture shot has its position in the list as its loc_x, that's how the suite tells which
+// shots came back without relying on ids
+var fixtureShots = []fixtureShot{
+	1:  {Curry, Warriors, Game1, true, "3PT Field Goal", "Above the Break 3", 1, 600},
+	2:  {Curry, Warriors, Game1, false, "3PT Field Goal", "Left Corner 3", 4, 30},
+	3:  {Curry, Warriors, Game1, true, types.TwoPtShot, "Restricted Area", 2, 400},
+	4:  {LeBron, Cavaliers, Game1, true, types.TwoPtShot, "Restricted Area", 1, 700},
+	5:  {LeBron, Cavaliers, Game1, false, types.TwoPtShot, "Mid-Range", 3, 200},
+	6:  {Curry, Warriors, Game2, true, "3PT Field Goal", "Above the Break 3", 4, 100},
+	7:  {Curry, Warriors, Game2, false, types.TwoPtShot, "Mid-Range", 2, 500},
+	8:  {Kawhi, Spurs, Game2, true, types.TwoPtShot, "Mid-Range", 1, 300},
+	9:  {Kawhi, Spurs, Game2, false, "3PT Field Goal", "Right Corner 3", 4, 10},
+	10: {LeBron, Cavaliers, Game3, true, "3PT Field Goal", "Above the Break 3", 2, 650},
+	11: {Kawhi, Spurs, Game3, true, types.TwoPtShot, "In The Paint (Non-RA)", 3, 350},
+	12: {Kawhi, Spurs, Game3, false, types.TwoPtShot, "Restricted Area", 4, 5},
+}
+
+var zoneDistances = map[string]int{
+	"Restricted Area":       2,
+	"In The Paint (Non-RA)": 9,
+	"Mid-Range":             17,
+	"Left Corner 3":         22,
+	"Right Corner 3":        22,
+	"Above the Break 3":     25,
+}
+
+// Fixture is a season of three games and twelve shots
+func Fixture() *types.SeasonBatch {
+	games := []types.Game{
+		{ID: Game1, HomeTeamID: Warriors, AwayTeamID: Cavaliers, SeasonYear: Year, GameDate: game1Date},
+		{ID: Game2, HomeTeamID: Spurs, AwayTeamID: Warriors, SeasonYear: Year, GameDate: game2Date},
+		{ID: Game3, HomeTeamID: Cavaliers, AwayTeamID: Spurs, SeasonYear: Year, GameDate: game3Date},
+	}
+	batch := &types.SeasonBatch{
+		Players: []types.Player{{ID: Curry, Name: "Stephen Curry"}, {ID: LeBron, Name: "LeBron James"}, {ID: Kawhi, Name: "Kawhi Leonard"}},
+		Teams: []types.Team{
+			{ID: Warriors, Name: "Golden State Warriors", Abbreviation: "GSW"},
+			{ID: Cavaliers, Name: "Cleveland Cavaliers", Abbreviation: "CLE"},
+			{ID: Spurs, Name: "San Antonio Spurs", Abbreviation: "SAS"},
+		},
+		Seasons: []types.Season{{Year: Year, SeasonYears: "2015-16"}},
+		Games:   games,
+		PlayerTeams: []types.PlayerTeam{
+			{PlayerID: Curry, TeamID: Warriors, TeamName: "Golden State Warriors"},
+			{PlayerID: LeBron, TeamID: Cavaliers, TeamName: "Cleveland Cavaliers"},
+			{PlayerID: Kawhi, TeamID: Spurs, TeamName: "San Antonio Spurs"},
+		},
+		PlayerSeasons: []types.PlayerSeason{{PlayerID: Curry, SeasonYear: Year}, {PlayerID: LeBron, SeasonYear: Year}, {PlayerID: Kawhi, SeasonYear: Year}},
+		TeamSeasons: []types.TeamSeason{
+			{TeamID: Warriors, SeasonYear: Year, TeamName: "Golden State Warriors", Abbreviation: "GSW"},
+			{TeamID: Cavaliers, SeasonYear: Year, TeamName: "Cleveland Cavaliers", Abbreviation: "CLE"},
+			{TeamID: Spurs, SeasonYear: Year, TeamName: "San Antonio Spurs", Abbreviation: "SAS"},
+		},
+	}
+
+	playerGames := make(map[[2]int]bool)
+	for _, g := range games {
+		batch.GameSeasons = append(batch.GameSeasons, types.GameSeason{GameID: g.ID, SeasonYear: Year})
+		batch.TeamGames = append(batch.TeamGames,
+			types.TeamGame{TeamID: g.HomeTeamID, GameID: g.ID, GameDate: g.GameDate},
+			types.TeamGame{TeamID: g.AwayTeamID, GameID: g.ID, GameDate: g.GameDate},
+		)
+	}
+	for i, s := range fixtureShots {
+		if i == 0 {
+			continue
+		}
+		g := games[slices.IndexFunc(games, func(g types.Game) bool { return g.ID == s.GameID })]
+		if !playerGames[[2]int{s.PlayerID, s.GameID}] {
+			playerGames[[2]int{s.PlayerID, s.GameID}] = true
+			batch.PlayerGames = append(batch.PlayerGames, types.PlayerGame{PlayerID: s.PlayerID, GameID: s.GameID, GameDate: g.GameDate})
+		}
+
+		eventType := "Missed Shot"
+		if s.Made {
+			eventType = "Made Shot"
+		}
+		batch.Shots = append(batch.Shots, types.Shot{
+			PlayerID: s.PlayerID, GameID: s.GameID, TeamID: s.TeamID,
+			HomeTeamID: g.HomeTeamID, AwayTeamID: g.AwayTeamID, SeasonYear: Year,
+			EventType: eventType, ShotMade: s.Made, ActionType: "Jump Shot", ShotType: s.ShotType,
+			BasicZone: s.BasicZone, ZoneName: "Center", ZoneABB: "C", ZoneRange: "24+ ft.",
+			LocX: float64(i), LocY: 10, ShotDistance: zoneDistances[s.BasicZone], Quarter: s.Quarter,
+			MinsLeft: s.TimeLeft / 60, SecsLeft: s.TimeLeft % 60, TotalTimeLeftSecs: s.TimeLeft,
+			Position: "G", PositionGroup: "G", GameDate: g.GameDate,
+		})
+	}
+	return batch
+}
+
+// Args are shot params without any filters, the clock filters are off at -1
+func Args() types.RequestShotParams {
+	return types.RequestShotParams{StartTimeLeftSecs: -1, EndTimeLeftSecs: -1}
+}
+
+// Run loads the fixture into db, checks every read against it and deletes the season again.
+// db can have other seasons loaded as long as they don't use the fixture's year, games or players.
+func Run(t *testing.T, db database.Service) {
+	ctx := context.Background()
+	version := datasetVersion(t, db)
+
+	err := db.InsertSeasonBatch(ctx, Fixture(), 5)
+	if err != nil {
+		t.Fatalf("could not load the fixture: %v", err)
+	}
+	version = expectNewVersion(t, db, version)
+
+	t.Run("Shots", func(t *testing.T) { testShots(t, db) })
+	t.Run("ShotSummary", func(t *testing.T) { testShotSummary(t, db) })
+	t.Run("GroupedShots", func(t *testing.T) { testGroupedShots(t, db) })
+	t.Run("Catalog", func(t *testing.T) { testCatalog(t, db) })
+	t.Run("Relations", func(t *testing.T) { testRelations(t, db) })
+	t.Run("SeasonCounts", func(t *testing.T) { testSeasonCounts(t, db) })
+	t.Run("QueryLog", func(t *testing.T) { testQueryLog(t, db) })
+	t.Run("RefreshRuns", func(t *testing.T) { testRefreshRuns(t, db) })
+
+	err = db.InsertGames(ctx, Fixture().Games[:1])
+	if err == nil {
+		t.Errorf("expected inserting a loaded game again to fail")
+	}
+	// neither the reads, the logs nor the failed insert changed the data
+	if v := datasetVersion(t, db); v != version {
+		t.Errorf("expected the dataset version to stay at %d, got %d", version, v)
+	}
+
+	err = db.ReplaceSeason(ctx, Year, Fixture(), 5)
+	if err != nil {
+		t.Fatalf("could not replace the fixture: %v", err)
+	}
+	expectCount(t, db, "shot", len(fixtureShots)-1)
+	version = expectNewVersion(t, db, version)
+
+	err = db.DeleteSeason(ctx, Year)
+	if err != nil {
+		t.Fatalf("could not delete the fixture: %v", err)
+	}
+	expectNewVersion(t, db, version)
+	for _, table := range []string{"season", "game", "shot", "player_game", "team_game"} {
+		expectCount(t, db, table, 0)
+	}
+	_, err = db.GetSeasonByYear(ctx, Year)
+	if err == nil {
+		t.Errorf("expected season %d to be gone", Year)
+	}
+	identities, err := db.GetTeamIdentities(ctx, []int{Warriors}, []int{Year})
+	if err != nil || len(identities) != 0 {
+		t.Errorf("expected the season's team identities to be gone, got %+v, %v", identities, err)
+	}
+}
+
+func datasetVersion(t *testing.T, db database.Service) int64 {
+	t.Helper()
+	v, err := db.GetDatasetVersion(context.Background())
+	if err != nil {
+		t.Fatalf("could not get the dataset version: %v", err)
+	}
+	return v.Version
+}
+
+// expectNewVersion checks a write moved the dataset version past previous and returns the new one
+func expectNewVersion(t *testing.T, db database.Service, previous int64) int64 {
+	t.Helper()
+	v := datasetVersion(t, db)
+	if v <= previous {
+		t.Errorf("expected the dataset version to move past %d, got %d", previous, v)
+	}
+	return v
+}
+
+func testShots(t *testing.T, db database.Service) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		args   func(*types.RequestShotParams)
+		expect []int
+	}{
+		{"no filters", func(a *types.RequestShotParams) { a.SeasonYears = []int{Year} }, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}},
+		{"player", func(a *types.RequestShotParams) { a.PlayerIDs = []int{Curry} }, []int{1, 2, 3, 6, 7}},
+		{"player season", func(a *types.RequestShotParams) { a.PlayerIDs, a.SeasonYears = []int{Curry}, []int{Year} }, []int{1, 2, 3, 6, 7}},
+		{"team", func(a *types.RequestShotParams) { a.TeamIDs = []int{Spurs} }, []int{8, 9, 11, 12}},
+		{"opponent", func(a *types.RequestShotParams) { a.PlayerIDs, a.OpposingTeamIds = []int{Curry}, []int{Cavaliers} }, []int{1, 2, 3}},
+		{"opponents", func(a *types.RequestShotParams) {
+			a.TeamIDs, a.OpposingTeamIds = []int{Spurs}, []int{Warriors, Cavaliers}
+		}, []int{8, 9, 11, 12}},
+		{"home", func(a *types.RequestShotParams) { a.TeamIDs, a.GameLocation = []int{Warriors}, "home" }, []int{1, 2, 3}},
+		{"away", func(a *types.RequestShotParams) { a.TeamIDs, a.GameLocation = []int{Warriors}, "away" }, []int{6, 7}},
+		{"quarter", func(a *types.RequestShotParams) { a.SeasonYears, a.Quarters = []int{Year}, []int{4} }, []int{2, 6, 9, 12}},
+		{"players quarters", func(a *types.RequestShotParams) { a.PlayerIDs, a.Quarters = []int{Curry, LeBron}, []int{1, 2} }, []int{1, 3, 4, 7, 10}},
+		{"time left", func(a *types.RequestShotParams) { a.SeasonYears, a.StartTimeLeftSecs = []int{Year}, 120 }, []int{2, 6, 9, 12}},
+		{"time range", func(a *types.RequestShotParams) {
+			a.SeasonYears, a.StartTimeLeftSecs, a.EndTimeLeftSecs = []int{Year}, 120, 20
+		}, []int{2, 6}},
+		{"date range", func(a *types.RequestShotParams) {
+			a.SeasonYears = []int{Year}
+			a.StartGameDate, a.EndGameDate = time.Date(2015, 11, 15, 0, 0, 0, 0, time.UTC), time.Date(2015, 12, 31, 0, 0, 0, 0, time.UTC)
+		}, []int{6, 7, 8, 9}},
+		{"start date", func(a *types.RequestShotParams) {
+			a.SeasonYears, a.StartGameDate = []int{Year}, game3Date
+		}, []int{10, 11, 12}},
+		{"end date", func(a *types.RequestShotParams) {
+			a.SeasonYears, a.EndGameDate = []int{Year}, game1Date
+		}, []int{1, 2, 3, 4, 5}},
+		{"nothing", func(a *types.RequestShotParams) { a.PlayerIDs, a.TeamIDs = []int{Curry}, []int{Spurs} }, []int{}},
+	}
+
+	for _, tt := range tests {
+		args := Args()
+		tt.args(&args)
+		shots, err := db.GetShots(ctx, &args)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+
+		got := make([]int, len(shots))
+		for i, shot := range shots {
+			got[i] = int(shot.LocX)
+			f := fixtureShots[got[i]]
+			if shot.ShotMade != f.Made || shot.ShotType != f.ShotType || shot.LocY != 10 {
+				t.Errorf("%s: shot %d came back as %+v", tt.name, got[i], shot)
+			}
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.expect) {
+			t.Errorf("%s: expected shots %v, got %v", tt.name, tt.expect, got)
+		}
+	}
+}
+
+func testShotSummary(t *testing.T, db database.Service) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		args   func(*types.RequestShotParams)
+		expect types.ShotAggregates
+		zones  int
+	}{
+		{"player", func(a *types.RequestShotParams) { a.PlayerIDs, a.SeasonYears = []int{Curry}, []int{Year} },
+			types.ShotAggregates{TotalMadeShots: 3, TotalMissedShots: 2, Made2PtShots: 1, Missed2PtShots: 1, Made3PtShots: 2, Missed3PtShots: 1}, 4},
+		{"team", func(a *types.RequestShotParams) { a.TeamIDs = []int{Spurs} },
+			types.ShotAggregates{TotalMadeShots: 2, TotalMissedShots: 2, Made2PtShots: 2, Missed2PtShots: 1, Missed3PtShots: 1}, 4},
+		{"league", func(a *types.RequestShotParams) { a.SeasonYears = []int{Year} },
+			types.ShotAggregates{TotalMadeShots: 7, TotalMissedShots: 5, Made2PtShots: 4, Missed2PtShots: 3, Made3PtShots: 3, Missed3PtShots: 2}, 6},
+		{"player quarter", func(a *types.RequestShotParams) { a.PlayerIDs, a.Quarters = []int{Curry}, []int{4} },
+			types.ShotAggregates{TotalMadeShots: 1, TotalMissedShots: 1, Made3PtShots: 1, Missed3PtShots: 1}, 2},
+		{"nothing", func(a *types.RequestShotParams) { a.PlayerIDs, a.TeamIDs = []int{Curry}, []int{Spurs} },
+			types.ShotAggregates{}, 0},
+	}
+
+	for _, tt := range tests {
+		args := Args()
+		tt.args(&args)
+		summary, err := db.GetShotSummary(ctx, &args)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if summary.ShotAggregates != tt.expect || len(summary.Zones) != tt.zones {
+			t.Errorf("%s: expected %+v in %d zones, got %+v in %d zones", tt.name, tt.expect, tt.zones, summary.ShotAggregates, len(summary.Zones))
+		}
+
+		var zones types.ShotAggregates
+		for i, zone := range summary.Zones {
+			zones.Add(zone.ShotAggregates)
+			if i > 0 && summary.Zones[i-1].BasicZone > zone.BasicZone {
+				t.Errorf("%s: expected the zones in order, got %+v", tt.name, summary.Zones)
+			}
+		}
+		if zones != summary.ShotAggregates {
+			t.Errorf("%s: expected the zones to add up to the totals, got %+v", tt.name, summary)
+		}
+	}
+}
+
+// testGroupedShots checks a grouped query answers every id of the group like the query of that
+// id alone does
+func testGroupedShots(t *testing.T, db database.Service) {
+	ctx := context.Background()
+	tests := []struct {
+		name  string
+		group types.ShotGroup
+		args  func(*types.RequestShotParams)
+	}{
+		{"players", types.GroupByPlayer, func(a *types.RequestShotParams) { a.PlayerIDs = []int{Curry, LeBron, Kawhi, 1} }},
+		{"players in a season", types.GroupByPlayer, func(a *types.RequestShotParams) { a.PlayerIDs, a.SeasonYears = []int{Curry, Kawhi}, []int{Year} }},
+		{"teams by quarter", types.GroupByTeam, func(a *types.RequestShotParams) { a.TeamIDs, a.Quarters = []int{Warriors, Spurs}, []int{4} }},
+		{"seasons", types.GroupBySeason, func(a *types.RequestShotParams) { a.SeasonYears = []int{Year, Year - 1} }},
+	}
+
+	for _, tt := range tests {
+		args := Args()
+		tt.args(&args)
+		shots, err := db.GetShotsBy(ctx, &args, tt.group)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		summaries, err := db.GetShotSummariesBy(ctx, &args, tt.group)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if len(summaries) != len(*tt.group.IDs(&args)) {
+			t.Errorf("%s: expected a summary for every id, got %v", tt.name, summaries)
+		}
+
+		for _, id := range *tt.group.IDs(&args) {
+			one := args
+			*tt.group.IDs(&one) = []int{id}
+			expectShots, err := db.GetShots(ctx, &one)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := shotIDs(shots[id]); !slices.Equal(got, shotIDs(expectShots)) {
+				t.Errorf("%s: expected the shots of %d to be %v, got %v", tt.name, id, shotIDs(expectShots), got)
+			}
+			expectSummary, err := db.GetShotSummary(ctx, &one)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary := summaries[id]; summary == nil || summary.ShotAggregates != expectSummary.ShotAggregates || !slices.Equal(summary.Zones, expectSummary.Zones) {
+				t.Errorf("%s: expected the summary of %d to be %+v, got %+v", tt.name, id, expectSummary, summary)
+			}
+		}
+	}
+}
+
+// shotIDs is the sorted ids of shots, the queries don't order them
+func shotIDs(shots []types.ReturnShot) []int {
+	ids := []int{}
+	for _, shot := range shots {
+		ids = append(ids, shot.ID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func testCatalog(t *testing.T, db database.Service) {
+	ctx := context.Background()
+
+	player, err := db.GetPlayerByID(ctx, Curry)
+	if err != nil || player.Name != "Stephen Curry" {
+		t.Errorf("expected Stephen Curry, got %+v, %v", player, err)
+	}
+	_, err = db.GetPlayerByID(ctx, 1)
+	if err == nil {
+		t.Errorf("expected an error for a missing player")
+	}
+
+	players, err := db.GetPlayersByName(ctx, "%cUrR%")
+	if err != nil || len(players) != 1 || players[0].ID != Curry {
+		t.Errorf("expected the name search to find Curry, got %+v, %v", players, err)
+	}
+
+	players, err = db.GetPlayersByIDs(ctx, []int{Kawhi, LeBron})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ids := make([]int, len(players))
+	for i, p := range players {
+		ids[i] = p.ID
+	}
+	slices.Sort(ids)
+	if !slices.Equal(ids, []int{LeBron, Kawhi}) {
+		t.Errorf("expected LeBron and Kawhi, got %+v", players)
+	}
+
+	team, err := db.GetTeamByID(ctx, Spurs)
+	if err != nil || team.Abbreviation != "SAS" {
+		t.Errorf("expected the Spurs, got %+v, %v", team, err)
+	}
+	team, err = db.GetTeamByIDForSeason(ctx, Warriors, Year)
+	if err != nil || team.Name != "Golden State Warriors" {
+		t.Errorf("expected the Warriors in %d, got %+v, %v", Year, team, err)
+	}
+	_, err = db.GetTeamByIDForSeason(ctx, Warriors, Year+1)
+	if err == nil {
+		t.Errorf("expected no Warriors identity in %d", Year+1)
+	}
+
+	teams, err := db.GetAllTeams(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, id := range []int{Warriors, Cavaliers, Spurs} {
+		if !slices.ContainsFunc(teams, func(t types.Team) bool { return t.ID == id }) {
+			t.Errorf("expected team %d in %+v", id, teams)
+		}
+	}
+
+	identities, err := db.GetTeamIdentities(ctx, []int{Spurs, Warriors}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []types.TeamIdentity{
+		{TeamID: Warriors, StartSeason: Year, EndSeason: Year, Name: "Golden State Warriors", Abbreviation: "GSW"},
+		{TeamID: Spurs, StartSeason: Year, EndSeason: Year, Name: "San Antonio Spurs", Abbreviation: "SAS"},
+	}
+	if !slices.Equal(identities, expected) {
+		t.Errorf("expected %+v, got %+v", expected, identities)
+	}
+
+	season, err := db.GetSeasonByYear(ctx, Year)
+	if err != nil || season.SeasonYears != "2015-16" {
+		t.Errorf("expected the 2015-16 season, got %+v, %v", season, err)
+	}
+	seasons, err := db.GetAllSeasons(ctx)
+	if err != nil || !slices.Contains(seasons, types.Season{Year: Year, SeasonYears: "2015-16"}) {
+		t.Errorf("expected the 2015-16 season in %+v, %v", seasons, err)
+	}
+
+	game, err := db.GetGameByID(ctx, Game2)
+	if err != nil || game.HomeTeamID != Spurs || game.AwayTeamID != Warriors || !game.GameDate.Equal(game2Date) {
+		t.Errorf("expected the Warriors at the Spurs, got %+v, %v", game, err)
+	}
+	games, err := db.GetLastXGames(ctx, 2)
+	if err != nil || len(games) != 2 || games[0].ID != Game3 || games[1].ID != Game2 {
+		t.Errorf("expected the last two games newest first, got %+v, %v", games, err)
+	}
+
+	gameIDs, err := db.GetGameIDsForSeasons(ctx, []int{Year})
+	slices.Sort(gameIDs)
+	if err != nil || !slices.Equal(gameIDs, []int{Game1, Game2, Game3}) {
+		t.Errorf("expected the three games, got %v, %v", gameIDs, err)
+	}
+
+	empty, err := db.IsEmptyDatabase(ctx)
+	if err != nil || empty {
+		t.Errorf("expected a loaded database not to be empty, got %v, %v", empty, err)
+	}
+	if health := db.Health(ctx); health["status"] != "up" {
+		t.Errorf("expected the database to be up, got %v", health)
+	}
+}
+
+func testRelations(t *testing.T, db database.Service) {
+	ctx := context.Background()
+
+	teams, err := db.GetTeamsByIDs(ctx, []int{Spurs, Warriors, 1})
+	if err != nil || len(teams) != 2 || teams[0].ID != Warriors || teams[1].Abbreviation != "SAS" {
+		t.Errorf("expected the Warriors and the Spurs, got %+v, %v", teams, err)
+	}
+	seasons, err := db.GetSeasonsByYears(ctx, []int{Year, Year + 1})
+	if err != nil || !slices.Equal(seasons, []types.Season{{Year: Year, SeasonYears: "2015-16"}}) {
+		t.Errorf("expected the 2015-16 season, got %+v, %v", seasons, err)
+	}
+	games, err := db.GetGamesByIDs(ctx, []int{Game3, Game1})
+	if err != nil || len(games) != 2 || games[0].ID != Game1 || games[1].HomeTeamID != Cavaliers || !games[1].GameDate.Equal(game3Date) {
+		t.Errorf("expected the first and third games, got %+v, %v", games, err)
+	}
+
+	playerTeams, err := db.GetPlayerTeams(ctx, []int{Curry, Kawhi}, nil)
+	expectedPlayerTeams := []types.PlayerTeam{
+		{PlayerID: Curry, TeamID: Warriors, TeamName: "Golden State Warriors"},
+		{PlayerID: Kawhi, TeamID: Spurs, TeamName: "San Antonio Spurs"},
+	}
+	if err != nil || !slices.Equal(playerTeams, expectedPlayerTeams) {
+		t.Errorf("expected %+v, got %+v, %v", expectedPlayerTeams, playerTeams, err)
+	}
+	playerTeams, err = db.GetPlayerTeams(ctx, nil, []int{Cavaliers})
+	if err != nil || len(playerTeams) != 1 || playerTeams[0].PlayerID != LeBron {
+		t.Errorf("expected LeBron on the Cavaliers, got %+v, %v", playerTeams, err)
+	}
+	playerTeams, err = db.GetPlayerTeams(ctx, []int{Curry}, []int{Spurs})
+	if err != nil || len(playerTeams) != 0 {
+		t.Errorf("expected Curry not to be on the Spurs, got %+v, %v", playerTeams, err)
+	}
+
+	playerSeasons, err := db.GetPlayerSeasons(ctx, nil, []int{Year})
+	if err != nil || len(playerSeasons) != 3 || playerSeasons[0] != (types.PlayerSeason{PlayerID: LeBron, SeasonYear: Year}) {
+		t.Errorf("expected the three players in %d ordered by id, got %+v, %v", Year, playerSeasons, err)
+	}
+
+	playerGames, err := db.GetPlayerGames(ctx, []int{Kawhi}, nil)
+	if err != nil || len(playerGames) != 2 || playerGames[0].GameID != Game2 || playerGames[1].GameID != Game3 || !playerGames[0].GameDate.Equal(game2Date) {
+		t.Errorf("expected Kawhi's two games, got %+v, %v", playerGames, err)
+	}
+	playerGames, err = db.GetPlayerGames(ctx, nil, []int{Game1})
+	if err != nil || len(playerGames) != 2 || playerGames[0].PlayerID != LeBron || playerGames[1].PlayerID != Curry {
+		t.Errorf("expected LeBron and Curry in the first game, got %+v, %v", playerGames, err)
+	}
+
+	teamSeasons, err := db.GetTeamSeasons(ctx, []int{Spurs}, []int{Year})
+	expectedTeamSeasons := []types.TeamSeason{{TeamID: Spurs, SeasonYear: Year, TeamName: "San Antonio Spurs", Abbreviation: "SAS"}}
+	if err != nil || !slices.Equal(teamSeasons, expectedTeamSeasons) {
+		t.Errorf("expected %+v, got %+v, %v", expectedTeamSeasons, teamSeasons, err)
+	}
+
+	teamGames, err := db.GetTeamGames(ctx, []int{Warriors}, nil)
+	if err != nil || len(teamGames) != 2 || teamGames[0].GameID != Game1 || teamGames[1].GameID != Game2 {
+		t.Errorf("expected the Warriors' two games, got %+v, %v", teamGames, err)
+	}
+	teamGames, err = db.GetTeamGames(ctx, nil, []int{Game3})
+	if err != nil || len(teamGames) != 2 || teamGames[0].TeamID != Cavaliers || teamGames[1].TeamID != Spurs {
+		t.Errorf("expected the Cavaliers and the Spurs in the third game, got %+v, %v", teamGames, err)
+	}
+
+	gameSeasons, err := db.GetGameSeasons(ctx, nil, []int{Year})
+	if err != nil || len(gameSeasons) != 3 || gameSeasons[2] != (types.GameSeason{GameID: Game3, SeasonYear: Year}) {
+		t.Errorf("expected the three games of %d, got %+v, %v", Year, gameSeasons, err)
+	}
+}
+
+func testSeasonCounts(t *testing.T, db database.Service) {
+	ctx := context.Background()
+	expected := map[string]int{
+		"season": 1, "game": 3, "shot": 12, "player_season": 3,
+		"player_game": 6, "team_season": 3, "team_game": 6, "game_season": 3,
+	}
+	for table, rows := range expected {
+		expectCount(t, db, table, rows)
+	}
+
+	q, err := db.GetSeasonQuality(ctx, Year)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if q.Shots != 12 || q.ShotTypeMismatches != 0 || q.OutOfBoundsShots != 0 ||
+		len(q.PlayersWithoutShots) != 0 || len(q.GamesWithoutTeams) != 0 {
+		t.Errorf("expected a clean season, got %+v", q)
+	}
+	if q.ShotsPerGame[Game1] != 5 || q.ShotsPerGame[Game2] != 4 || q.ShotsPerGame[Game3] != 3 {
+		t.Errorf("expected 5, 4 and 3 shots per game, got %v", q.ShotsPerGame)
+	}
+	for _, team := range []int{Warriors, Cavaliers, Spurs} {
+		if q.GamesPerTeam[team] != 2 {
+			t.Errorf("expected team %d to play 2 games, got %v", team, q.GamesPerTeam)
+		}
+	}
+}
+
+func testQueryLog(t *testing.T, db database.Service) {
+	ctx := context.Background()
+	args := Args()
+	args.PlayerIDs, args.OpposingTeamIds, args.GameLocation = []int{Curry}, []int{Cavaliers}, "home"
+
+	err := db.InsertQueryHistory(ctx, &types.QueryHistoryRecord{RequestShotParams: args, ReturnedShots: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	usage, err := db.GetQueryFilterUsage(ctx, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	i := slices.IndexFunc(usage, func(u types.QueryFilterUsage) bool {
+		return strings.Join(u.Filters, ",") == "player,opponent,location"
+	})
+	if i < 0 || usage[i].Queries < 1 || usage[i].LastQueried.IsZero() {
+		t.Errorf("expected the player, opponent and location query to be logged, got %+v", usage)
+	}
+}
+
+func testRefreshRuns(t *testing.T, db database.Service) {
+	ctx := context.Background()
+	id, err := db.StartRefreshRun(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = db.FinishRefreshRun(ctx, &types.RefreshRun{ID: id, Status: types.RefreshSucceeded, Sources: 1, GamesAdded: 3, ShotsAdded: 12})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	runs, err := db.GetRefreshRuns(ctx, 1)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("expected the last refresh run, got %+v, %v", runs, err)
+	}
+	if runs[0].ID != id || runs[0].Status != types.RefreshSucceeded || runs[0].ShotsAdded != 12 || runs[0].FinishedAt == nil {
+		t.Errorf("expected run %d to have finished, got %+v", id, runs[0])
+	}
+}
+
+func expectCount(t *testing.T, db database.Service, table string, rows int) {
+	t.Helper()
+	counts, err := db.GetSeasonTableCounts(context.Background(), Year)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, count := range counts {
+		if count.Table == table && count.Rows != rows {
+			t.Errorf("expected %d %s rows, got %d", rows, table, count.Rows)
+		}
+	}
+}
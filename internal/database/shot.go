@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"nba-shots/internal/types"
@@ -22,7 +23,7 @@ func NewShotQuery(args *types.RequestShotParams) *ShotQuery {
 	}
 }
 
-func (s *service) GetShots(args *types.RequestShotParams) ([]types.ReturnShot, error) {
+func (s *service) GetShots(ctx context.Context, args *types.RequestShotParams) ([]types.ReturnShot, error) {
 	shotQuery := NewShotQuery(args)
 
 	query, err := shotQuery.buildQueryString()
@@ -31,7 +32,7 @@ func (s *service) GetShots(args *types.RequestShotParams) ([]types.ReturnShot, e
 		return nil, err
 	}
 
-	shots, err := s.QueryShots(query, shotQuery.Args, shotQuery.ArgCount)
+	shots, err := s.QueryShots(ctx, query, shotQuery.Args, shotQuery.ArgCount)
 
 	if err != nil {
 		return nil, err
@@ -40,6 +41,35 @@ func (s *service) GetShots(args *types.RequestShotParams) ([]types.ReturnShot, e
 	return shots, nil
 }
 
+// GetShotsBy is GetShots for every id of group in one query
+func (s *service) GetShotsBy(ctx context.Context, args *types.RequestShotParams, group types.ShotGroup) (map[int][]types.ReturnShot, error) {
+	shotQuery := NewShotQuery(args)
+	shotQuery.buildWhereClause()
+	query := `SELECT ` + string(group) + `, id, loc_x, loc_y, shot_made, shot_type FROM shot `
+	if len(shotQuery.WhereConditions) > 0 {
+		query += "WHERE " + strings.Join(shotQuery.WhereConditions, " AND ")
+	}
+	log.Println("Initiating grouped shots query with query string and args: ", query, shotQuery.Args)
+
+	rows, err := s.db.Query(ctx, query, shotQuery.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shots := make(map[int][]types.ReturnShot)
+	for rows.Next() {
+		var id int
+		var shot types.ReturnShot
+		err := rows.Scan(&id, &shot.ID, &shot.LocX, &shot.LocY, &shot.ShotMade, &shot.ShotType)
+		if err != nil {
+			return nil, err
+		}
+		shots[id] = append(shots[id], shot)
+	}
+	return shots, rows.Err()
+}
+
 func (q *ShotQuery) buildQueryString() (string, error) {
 	queryString := `SELECT id, loc_x, loc_y, shot_made, shot_type FROM shot `
 
@@ -92,10 +122,10 @@ func (q *ShotQuery) buildWhereClause() {
 		q.WhereConditions = append(q.WhereConditions, startGameCond)
 	}
 
-	if !q.RequestArgs.StartGameDate.IsZero() {
-		startGameCond := fmt.Sprintf("game_date <= $%d", q.nextArgNum())
+	if !q.RequestArgs.EndGameDate.IsZero() {
+		endGameCond := fmt.Sprintf("game_date <= $%d", q.nextArgNum())
 		q.Args = append(q.Args, q.RequestArgs.EndGameDate)
-		q.WhereConditions = append(q.WhereConditions, startGameCond)
+		q.WhereConditions = append(q.WhereConditions, endGameCond)
 	}
 
 	if q.RequestArgs.GameLocation != "" {
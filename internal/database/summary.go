@@ -0,0 +1,285 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"nba-shots/internal/types"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// shotSummaryTable counts the shots of every season and zone by the player or team that took them,
+// the league summary has no id column
+type shotSummaryTable struct {
+	Name   string
+	Column string
+	ID     func(types.Shot) int
+}
+
+var (
+	playerShotSummary = shotSummaryTable{"player_season_zone_summary", "player_id", func(s types.Shot) int { return s.PlayerID }}
+	teamShotSummary   = shotSummaryTable{"team_season_zone_summary", "team_id", func(s types.Shot) int { return s.TeamID }}
+	leagueShotSummary = shotSummaryTable{"league_season_zone_summary", "", nil}
+
+	shotSummaryTables = []shotSummaryTable{playerShotSummary, teamShotSummary, leagueShotSummary}
+)
+
+// the counts of types.ShotAggregates, aggregateShotsColumns computes them from the shot table
+const aggregateColumns = `total_made_shots, total_missed_shots, made_2pt_shots, missed_2pt_shots, made_3pt_shots, missed_3pt_shots`
+
+const aggregateShotsColumns = `
+	COUNT(*) FILTER (WHERE shot_made) AS total_made_shots,
+	COUNT(*) FILTER (WHERE NOT shot_made) AS total_missed_shots,
+	COUNT(*) FILTER (WHERE shot_made AND shot_type = '2PT Field Goal') AS made_2pt_shots,
+	COUNT(*) FILTER (WHERE NOT shot_made AND shot_type = '2PT Field Goal') AS missed_2pt_shots,
+	COUNT(*) FILTER (WHERE shot_made AND shot_type <> '2PT Field Goal') AS made_3pt_shots,
+	COUNT(*) FILTER (WHERE NOT shot_made AND shot_type <> '2PT Field Goal') AS missed_3pt_shots
+`
+
+const sumAggregateColumns = `
+	SUM(total_made_shots)::bigint AS total_made_shots,
+	SUM(total_missed_shots)::bigint AS total_missed_shots,
+	SUM(made_2pt_shots)::bigint AS made_2pt_shots,
+	SUM(missed_2pt_shots)::bigint AS missed_2pt_shots,
+	SUM(made_3pt_shots)::bigint AS made_3pt_shots,
+	SUM(missed_3pt_shots)::bigint AS missed_3pt_shots
+`
+
+func (t shotSummaryTable) keyColumns() string {
+	if t.Column == "" {
+		return "season_year, basic_zone, zone_abb"
+	}
+	return t.Column + ", season_year, basic_zone, zone_abb"
+}
+
+// upsertQuery adds a row of counts to the counts already in the summary
+func (t shotSummaryTable) upsertQuery() string {
+	columns := strings.Split(aggregateColumns, ", ")
+	keys := strings.Split(t.keyColumns(), ", ")
+
+	placeholders := make([]string, len(keys)+len(columns))
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	updates := make([]string, len(columns))
+	for i, column := range columns {
+		updates[i] = fmt.Sprintf("%[2]s = %[1]s.%[2]s + EXCLUDED.%[2]s", t.Name, column)
+	}
+
+	return fmt.Sprintf(`
+	INSERT INTO %s (%s, %s)
+	VALUES (%s)
+	ON CONFLICT (%s) DO UPDATE SET %s
+	`, t.Name, t.keyColumns(), aggregateColumns, strings.Join(placeholders, ", "), t.keyColumns(), strings.Join(updates, ", "))
+}
+
+type shotSummaryKey struct {
+	ID         int
+	SeasonYear int
+	BasicZone  string
+	ZoneABB    string
+}
+
+func compareShotSummaryKeys(a, b shotSummaryKey) int {
+	return cmp.Or(
+		cmp.Compare(a.ID, b.ID),
+		cmp.Compare(a.SeasonYear, b.SeasonYear),
+		cmp.Compare(a.BasicZone, b.BasicZone),
+		cmp.Compare(a.ZoneABB, b.ZoneABB),
+	)
+}
+
+// addShotSummaries adds the shots to the summaries in the same transaction that copies them, so the
+// summaries never count a shot that isn't loaded
+func addShotSummaries(ctx context.Context, tx pgx.Tx, shots []types.Shot) error {
+	batch := &pgx.Batch{}
+	for _, table := range shotSummaryTables {
+		counts := make(map[shotSummaryKey]*types.ShotAggregates)
+		for _, shot := range shots {
+			key := shotSummaryKey{SeasonYear: shot.SeasonYear, BasicZone: shot.BasicZone, ZoneABB: shot.ZoneABB}
+			if table.ID != nil {
+				key.ID = table.ID(shot)
+			}
+			if counts[key] == nil {
+				counts[key] = &types.ShotAggregates{}
+			}
+			counts[key].AddShot(shot.ShotMade, shot.ShotType)
+		}
+
+		// the rows are always locked in the same order, so two loads can't deadlock on them
+		keys := make([]shotSummaryKey, 0, len(counts))
+		for key := range counts {
+			keys = append(keys, key)
+		}
+		slices.SortFunc(keys, compareShotSummaryKeys)
+
+		query := table.upsertQuery()
+		for _, key := range keys {
+			aggs := counts[key]
+			values := []any{key.SeasonYear, key.BasicZone, key.ZoneABB}
+			if table.ID != nil {
+				values = append([]any{key.ID}, values...)
+			}
+			values = append(values, aggs.TotalMadeShots, aggs.TotalMissedShots, aggs.Made2PtShots,
+				aggs.Missed2PtShots, aggs.Made3PtShots, aggs.Missed3PtShots)
+			batch.Queue(query, values...)
+		}
+	}
+
+	err := tx.SendBatch(ctx, batch).Close()
+	if err != nil {
+		return fmt.Errorf("adding %d shots to the summaries: %w", len(shots), err)
+	}
+	return nil
+}
+
+// rebuildShotSummaries counts the summaries of the seasons again from the shots
+func rebuildShotSummaries(ctx context.Context, tx pgx.Tx, seasons []int) error {
+	batch := &pgx.Batch{}
+	for _, table := range shotSummaryTables {
+		where := "season_year = ANY($1)"
+		if table.Column != "" {
+			where += fmt.Sprintf(" AND %s IS NOT NULL", table.Column)
+		}
+		batch.Queue(fmt.Sprintf(`DELETE FROM %s WHERE season_year = ANY($1)`, table.Name), seasons)
+		batch.Queue(fmt.Sprintf(`
+		INSERT INTO %[1]s (%[2]s, %[3]s)
+		SELECT %[2]s, %[4]s
+		FROM shot
+		WHERE %[5]s
+		GROUP BY %[2]s
+		`, table.Name, table.keyColumns(), aggregateColumns, aggregateShotsColumns, where), seasons)
+	}
+
+	err := tx.SendBatch(ctx, batch).Close()
+	if err != nil {
+		return fmt.Errorf("rebuilding the summaries of seasons %v: %w", seasons, err)
+	}
+	return nil
+}
+
+// shotSummaryFor picks the summary that can answer a query, only player or team and season filters
+// are kept in them. Anything else has to be counted from the shots.
+func shotSummaryFor(args *types.RequestShotParams) (shotSummaryTable, bool) {
+	filtered := len(args.OpposingTeamIds) > 0 ||
+		!args.StartGameDate.IsZero() ||
+		!args.EndGameDate.IsZero() ||
+		args.GameLocation != "" ||
+		len(args.Quarters) > 0 ||
+		(args.StartTimeLeftSecs >= 0 && args.StartTimeLeftSecs <= 720) ||
+		(args.EndTimeLeftSecs >= 0 && args.EndTimeLeftSecs <= 720)
+
+	switch {
+	case filtered, len(args.PlayerIDs) > 0 && len(args.TeamIDs) > 0:
+		return shotSummaryTable{}, false
+	case len(args.PlayerIDs) > 0:
+		return playerShotSummary, true
+	case len(args.TeamIDs) > 0:
+		return teamShotSummary, true
+	default:
+		return leagueShotSummary, true
+	}
+}
+
+// buildSummaryQueryString counts the shots of every zone, from a summary table when there's one
+// for the filters and from the shots otherwise. A group splits the counts by its column too, the
+// table picked for a query always has the column of the filter the group's ids are in.
+func (q *ShotQuery) buildSummaryQueryString(group types.ShotGroup) (string, bool) {
+	columns, groupBy := `basic_zone, zone_abb`, `basic_zone, zone_abb`
+	if group != "" {
+		columns, groupBy = string(group)+` AS group_id, `+columns, string(group)+`, `+groupBy
+	}
+
+	table, ok := shotSummaryFor(q.RequestArgs)
+	if !ok {
+		q.buildWhereClause()
+		query := `SELECT ` + columns + `, ` + aggregateShotsColumns + ` FROM shot `
+		if len(q.WhereConditions) > 0 {
+			query += "WHERE " + strings.Join(q.WhereConditions, " AND ")
+		}
+		return query + ` GROUP BY ` + groupBy + ` ORDER BY ` + groupBy, false
+	}
+
+	switch table.Column {
+	case "player_id":
+		q.WhereConditions = append(q.WhereConditions, q.getWhereLogicforInts(q.RequestArgs.PlayerIDs, "player_id"))
+	case "team_id":
+		q.WhereConditions = append(q.WhereConditions, q.getWhereLogicforInts(q.RequestArgs.TeamIDs, "team_id"))
+	}
+	if len(q.RequestArgs.SeasonYears) > 0 {
+		q.WhereConditions = append(q.WhereConditions, q.getWhereLogicforInts(q.RequestArgs.SeasonYears, "season_year"))
+	}
+
+	query := `SELECT ` + columns + `, ` + sumAggregateColumns + ` FROM ` + table.Name + ` `
+	if len(q.WhereConditions) > 0 {
+		query += "WHERE " + strings.Join(q.WhereConditions, " AND ")
+	}
+	return query + ` GROUP BY ` + groupBy + ` ORDER BY ` + groupBy, true
+}
+
+// GetShotSummary returns the aggregates of a shot query by zone without reading the shots when
+// the summaries can answer it
+func (s *service) GetShotSummary(ctx context.Context, args *types.RequestShotParams) (*types.ShotSummary, error) {
+	shotQuery := NewShotQuery(args)
+	query, fromSummary := shotQuery.buildSummaryQueryString("")
+	log.Println("Initiating shot summary query, from the summaries:", fromSummary, query, shotQuery.Args)
+
+	rows, err := s.db.Query(ctx, query, shotQuery.Args...)
+	if err != nil {
+		return nil, err
+	}
+
+	zones, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.ZoneAggregates])
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &types.ShotSummary{Zones: zones}
+	for _, zone := range zones {
+		summary.Add(zone.ShotAggregates)
+	}
+
+	log.Printf("Query successful, returning the summary of %d zones: \n", len(zones))
+	return summary, nil
+}
+
+// groupedZoneAggregates is a row of a summary query split by a group
+type groupedZoneAggregates struct {
+	GroupID int `db:"group_id"`
+	types.ZoneAggregates
+}
+
+// GetShotSummariesBy is GetShotSummary for every id of group in one query, the ids without
+// shots have an empty summary
+func (s *service) GetShotSummariesBy(ctx context.Context, args *types.RequestShotParams, group types.ShotGroup) (map[int]*types.ShotSummary, error) {
+	shotQuery := NewShotQuery(args)
+	query, fromSummary := shotQuery.buildSummaryQueryString(group)
+	log.Println("Initiating grouped shot summary query, from the summaries:", fromSummary, query, shotQuery.Args)
+
+	rows, err := s.db.Query(ctx, query, shotQuery.Args...)
+	if err != nil {
+		return nil, err
+	}
+	zones, err := pgx.CollectRows(rows, pgx.RowToStructByName[groupedZoneAggregates])
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make(map[int]*types.ShotSummary, len(*group.IDs(args)))
+	for _, id := range *group.IDs(args) {
+		summaries[id] = &types.ShotSummary{Zones: []types.ZoneAggregates{}}
+	}
+	for _, zone := range zones {
+		summary, ok := summaries[zone.GroupID]
+		if !ok {
+			continue
+		}
+		summary.Zones = append(summary.Zones, zone.ZoneAggregates)
+		summary.Add(zone.ShotAggregates)
+	}
+	return summaries, nil
+}
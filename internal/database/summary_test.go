@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"nba-shots/internal/types"
+	"reflect"
+	"testing"
+)
+
+func TestShotSummaryFor(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  types.RequestShotParams
+		table string
+		ok    bool
+	}{
+		{"player season", types.RequestShotParams{PlayerIDs: []int{977}, SeasonYears: []int{2004}, StartTimeLeftSecs: -1, EndTimeLeftSecs: -1}, playerShotSummary.Name, true},
+		{"team", types.RequestShotParams{TeamIDs: []int{1610612747}, StartTimeLeftSecs: -1, EndTimeLeftSecs: -1}, teamShotSummary.Name, true},
+		{"league season", types.RequestShotParams{SeasonYears: []int{2004}, StartTimeLeftSecs: -1, EndTimeLeftSecs: -1}, leagueShotSummary.Name, true},
+		{"player and team", types.RequestShotParams{PlayerIDs: []int{977}, TeamIDs: []int{1610612747}, StartTimeLeftSecs: -1, EndTimeLeftSecs: -1}, "", false},
+		{"player quarter", types.RequestShotParams{PlayerIDs: []int{977}, Quarters: []int{4}, StartTimeLeftSecs: -1, EndTimeLeftSecs: -1}, "", false},
+		{"player clock", types.RequestShotParams{PlayerIDs: []int{977}, StartTimeLeftSecs: 120, EndTimeLeftSecs: -1}, "", false},
+	}
+
+	for _, tt := range tests {
+		table, ok := shotSummaryFor(&tt.args)
+		if ok != tt.ok || table.Name != tt.table {
+			t.Errorf("%s: expected %q %v, got %q %v", tt.name, tt.table, tt.ok, table.Name, ok)
+		}
+	}
+}
+
+func TestGetShotSummary(t *testing.T) {
+	ctx := context.Background()
+	srv := testDB
+	const year = 2010
+
+	err := srv.InsertSeasonBatch(ctx, benchSeasonBatch(year), 1000)
+	if err != nil {
+		t.Fatalf("could not insert the season: %v", err)
+	}
+
+	for _, args := range []types.RequestShotParams{
+		{PlayerIDs: []int{977}, SeasonYears: []int{year}},
+		{TeamIDs: []int{1610612757}, SeasonYears: []int{year}},
+		{SeasonYears: []int{year}},
+	} {
+		args.StartTimeLeftSecs, args.EndTimeLeftSecs = -1, -1
+		summary, err := srv.GetShotSummary(ctx, &args)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// every shot is in one of the quarters, so this counts the same shots from the shot table
+		scanned := args
+		scanned.Quarters = []int{1, 2, 3, 4}
+		expected, err := srv.GetShotSummary(ctx, &scanned)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(summary, expected) {
+			t.Errorf("expected the summary of %+v to match the shots, got %+v and %+v", args, summary, expected)
+		}
+		if summary.TotalMadeShots+summary.TotalMissedShots == 0 {
+			t.Errorf("expected shots in the summary of %+v", args)
+		}
+	}
+
+	err = srv.DeleteSeason(ctx, year)
+	if err != nil {
+		t.Fatalf("could not delete the season: %v", err)
+	}
+	summary, err := srv.GetShotSummary(ctx, &types.RequestShotParams{SeasonYears: []int{year}, StartTimeLeftSecs: -1, EndTimeLeftSecs: -1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(summary.Zones) != 0 {
+		t.Errorf("expected the summary to be cleared with the season, got %+v", summary)
+	}
+}
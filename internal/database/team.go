@@ -6,23 +6,23 @@ import (
 	"nba-shots/internal/types"
 )
 
-func (s *service) GetTeamByID(teamID int) (*types.Team, error) {
+func (s *service) GetTeamByID(ctx context.Context, teamID int) (*types.Team, error) {
 	log.Println("Querying database for teamID", teamID)
 	team := &types.Team{}
 	query := `SELECT id, name, abbreviation FROM team WHERE id = $1`
-	err := s.db.QueryRow(context.Background(), query, teamID).Scan(&team.ID, &team.Name, &team.Abbreviation)
+	err := s.db.QueryRow(ctx, query, teamID).Scan(&team.ID, &team.Name, &team.Abbreviation)
 	if err != nil {
 		return nil, err
 	}
 	return team, nil
 }
 
-func (s *service) GetAllTeams() ([]types.Team, error) {
+func (s *service) GetAllTeams(ctx context.Context) ([]types.Team, error) {
 	log.Println("Querying database for all teams")
 	teams := []types.Team{}
 	query := `SELECT id, name, abbreviation FROM team`
 
-	rows, err := s.db.Query(context.Background(), query)
+	rows, err := s.db.Query(ctx, query)
 
 	if err != nil {
 		return nil, err
@@ -49,3 +49,70 @@ func (s *service) GetAllTeams() ([]types.Team, error) {
 
 	return teams, nil
 }
+
+// GetTeamByIDForSeason returns the team with the name and abbreviation it used in the given season
+func (s *service) GetTeamByIDForSeason(ctx context.Context, teamID int, seasonYear int) (*types.Team, error) {
+	log.Println("Querying database for teamID", teamID, "in season", seasonYear)
+	team := &types.Team{}
+	query := `
+	SELECT team_id, name, abbreviation
+	FROM team_identity
+	WHERE team_id = $1 AND $2 BETWEEN start_season AND end_season
+	`
+	err := s.db.QueryRow(ctx, query, teamID, seasonYear).Scan(&team.ID, &team.Name, &team.Abbreviation)
+	if err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+// GetTeamIdentities returns the eras of the given teams that overlap any of the given seasons.
+// If no seasons are passed in every era of the teams is returned.
+func (s *service) GetTeamIdentities(ctx context.Context, teamIDs []int, seasonYears []int) ([]types.TeamIdentity, error) {
+	log.Println("Querying database for team identities", teamIDs, seasonYears)
+	identities := []types.TeamIdentity{}
+
+	query := `
+	SELECT team_id, start_season, end_season, name, abbreviation
+	FROM team_identity
+	WHERE team_id = ANY($1)
+		AND (
+			cardinality($2::int[]) = 0
+			OR EXISTS (SELECT 1 FROM unnest($2::int[]) AS y WHERE y BETWEEN start_season AND end_season)
+		)
+	ORDER BY team_id, start_season
+	`
+
+	if seasonYears == nil {
+		seasonYears = []int{}
+	}
+
+	rows, err := s.db.Query(ctx, query, teamIDs, seasonYears)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var identity types.TeamIdentity
+		err := rows.Scan(
+			&identity.TeamID,
+			&identity.StartSeason,
+			&identity.EndSeason,
+			&identity.Name,
+			&identity.Abbreviation,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		identities = append(identities, identity)
+	}
+
+	log.Printf("Query successful, returning %d team identities: \n", len(identities))
+
+	return identities, nil
+}
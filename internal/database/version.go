@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"nba-shots/internal/types"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// GetDatasetVersion returns the version of the loaded data, it changes with every write to it
+func (s *service) GetDatasetVersion(ctx context.Context) (*types.DatasetVersion, error) {
+	var v types.DatasetVersion
+	err := s.db.QueryRow(ctx, `SELECT version, updated_at FROM dataset_version`).Scan(&v.Version, &v.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("getting the dataset version: %w", err)
+	}
+	return &v, nil
+}
+
+// inDatasetTransaction is inTransaction for writes to the data, the version is bumped in the
+// same transaction so it never moves without the data or the other way around
+func (s *service) inDatasetTransaction(ctx context.Context, fn func(pgx.Tx) error) error {
+	return s.inTransaction(ctx, func(tx pgx.Tx) error {
+		err := fn(tx)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `UPDATE dataset_version SET version = version + 1, updated_at = CURRENT_TIMESTAMP`)
+		if err != nil {
+			return fmt.Errorf("bumping the dataset version: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"nba-shots/internal/types"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/render"
+)
+
+const (
+	defaultRefreshRuns = 20
+	maxRefreshRuns     = 200
+)
+
+// AdminOnly lets requests through when they carry the ADMIN_TOKEN as a bearer token.
+// The admin routes don't exist when no token is configured.
+func (s *Server) AdminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.adminToken == "" {
+			renderError(w, r, ErrNotFound())
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			renderError(w, r, ErrUnauthorized())
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+type RefreshRunResponse struct {
+	*types.RefreshRun
+}
+
+func NewRefreshRunListResponse(runs *[]types.RefreshRun) []render.Renderer {
+	list := []render.Renderer{}
+	for i := range *runs {
+		list = append(list, &RefreshRunResponse{RefreshRun: &(*runs)[i]})
+	}
+	return list
+}
+
+func (rd *RefreshRunResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// getRefreshRunsHandler lists the latest runs of the scheduled refresh, ?limit= sets how many
+func (s *Server) getRefreshRunsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, err := refreshRunsLimit(r)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	runs, err := s.refreshLog.GetRefreshRuns(r.Context(), limit)
+	if err != nil {
+		render.Render(w, r, ErrQuery(r, err))
+		return
+	}
+
+	err = render.RenderList(w, r, NewRefreshRunListResponse(&runs))
+	if err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// refreshRunsLimit parses ?limit=, defaultRefreshRuns when it's not set
+func refreshRunsLimit(r *http.Request) (int, error) {
+	limitParam := r.URL.Query().Get("limit")
+	if limitParam == "" {
+		return defaultRefreshRuns, nil
+	}
+	limit, err := strconv.Atoi(limitParam)
+	if err != nil || limit <= 0 || limit > maxRefreshRuns {
+		return 0, errors.New("limit must be between 1 and 200")
+	}
+	return limit, nil
+}
+
+// invalidateCacheHandler drops the api's cached results, for an in-process cache ingest can't
+// reach. There's nothing to drop without a cache.
+func (s *Server) invalidateCacheHandler(w http.ResponseWriter, r *http.Request) {
+	if s.cache == nil {
+		renderError(w, r, ErrNotFound())
+		return
+	}
+
+	err := s.cache.Invalidate(r.Context())
+	if err != nil {
+		renderError(w, r, ErrQuery(r, err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
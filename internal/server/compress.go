@@ -0,0 +1,225 @@
+package server
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// responses smaller than this are sent as they are, compressing them saves less than it costs
+const compressMinSize = 1024
+
+// the encodings in the order they're preferred when the client accepts more than one equally
+var compressEncodings = []string{"br", "zstd", "gzip"}
+
+// compressTypes are the content types worth compressing, the protobuf shots still have repeated
+// varints in them
+var compressTypes = map[string]bool{
+	"application/json":       true,
+	"application/x-ndjson":   true,
+	"text/csv":               true,
+	"application/x-protobuf": true,
+}
+
+// encoder is what brotli, zstd and gzip writers have in common
+type encoder interface {
+	io.Writer
+	Flush() error
+	Close() error
+	Reset(io.Writer)
+}
+
+// the encoders are reused across responses, zstd and brotli allocate a lot up front
+var encoderPools = map[string]*sync.Pool{
+	"br": {New: func() any {
+		return brotli.NewWriterLevel(nil, 4)
+	}},
+	"zstd": {New: func() any {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+		return enc
+	}},
+	"gzip": {New: func() any {
+		enc, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return enc
+	}},
+}
+
+// Compress encodes the JSON, CSV and NDJSON responses with the best encoding the client accepts
+// once they're past compressMinSize. A handler that flushes gets what it wrote so far sent
+// compressed, so streamed responses keep streaming.
+func Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding picks the encoding with the highest q value in the Accept-Encoding header,
+// ties go to the order of compressEncodings. It returns "" when none is accepted.
+func negotiateEncoding(acceptEncoding string) string {
+	quality := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if name == "*" {
+			wildcard = q
+		} else if name != "" {
+			quality[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range compressEncodings {
+		q, ok := quality[encoding]
+		if !ok {
+			q = max(wildcard, 0)
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressWriter holds the start of the response until it knows whether to compress it: once
+// it's past compressMinSize, flushed or known to be the wrong type
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+
+	code    int
+	buf     []byte
+	decided bool
+	enc     encoder
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided || w.code != 0 {
+		return
+	}
+	w.code = code
+	// nothing to compress in these
+	if code == http.StatusNoContent || code == http.StatusNotModified || code < http.StatusOK {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		if !w.compressible() {
+			w.decide(false)
+		} else {
+			w.buf = append(w.buf, b...)
+			if len(w.buf) < compressMinSize {
+				return len(b), nil
+			}
+			err := w.decide(true)
+			return len(b), err
+		}
+	}
+
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// compressible reports whether the handler set a content type worth compressing and no encoding
+func (w *compressWriter) compressible() bool {
+	if w.Header().Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	return err == nil && compressTypes[mediaType]
+}
+
+// decide sends the headers and whatever was held back, compressed or not
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+
+	if compress {
+		w.Header().Set("Content-Encoding", w.encoding)
+		w.Header().Del("Content-Length")
+		w.enc = encoderPools[w.encoding].Get().(encoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+// Flush sends what was written so far, a response that's flushed before reaching the minimum
+// size is compressed anyway since more is coming
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(len(w.buf) > 0 && w.compressible())
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Close finishes the response once the handler returned
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		if w.code == 0 && len(w.buf) == 0 {
+			// the handler wrote nothing, net/http sends its own 200
+			return nil
+		}
+		w.decide(false)
+	}
+	if w.enc == nil {
+		return nil
+	}
+	err := w.enc.Close()
+	w.enc.Reset(nil)
+	encoderPools[w.encoding].Put(w.enc)
+	w.enc = nil
+	return err
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"math/rand/v2"
+	"nba-shots/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/go-chi/render"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+func decode(t testing.TB, encoding string, body []byte) []byte {
+	t.Helper()
+	var rd io.Reader
+	switch encoding {
+	case "br":
+		rd = brotli.NewReader(bytes.NewReader(body))
+	case "zstd":
+		dec, err := zstd.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer dec.Close()
+		rd = dec
+	case "gzip":
+		dec, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rd = dec
+	default:
+		return body
+	}
+	decoded, err := io.ReadAll(rd)
+	if err != nil {
+		t.Fatalf("could not decode the %s body: %v", encoding, err)
+	}
+	return decoded
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                          "",
+		"identity":                  "",
+		"gzip":                      "gzip",
+		"gzip, deflate, br, zstd":   "br",
+		"gzip;q=1, br;q=0.5":        "gzip",
+		"br;q=0, zstd":              "zstd",
+		"*":                         "br",
+		"*;q=0.1, gzip;q=0.5":       "gzip",
+		"*, br;q=0":                 "zstd",
+		"GZIP;q=0.8, zstd;q=bogus ": "gzip",
+	}
+	for header, want := range tests {
+		if got := negotiateEncoding(header); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	large := `{"shots":"` + strings.Repeat("made 3PT Field Goal ", 200) + `"}`
+	handler := func(contentType, body string) http.Handler {
+		return Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			io.WriteString(w, body)
+		}))
+	}
+
+	for _, encoding := range compressEncodings {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", encoding)
+		w := httptest.NewRecorder()
+		handler("application/json; charset=utf-8", large).ServeHTTP(w, req)
+
+		if w.Header().Get("Content-Encoding") != encoding || w.Body.Len() >= len(large) {
+			t.Errorf("expected a smaller %s body, got %v with %d bytes", encoding, w.Header(), w.Body.Len())
+		}
+		if got := decode(t, encoding, w.Body.Bytes()); string(got) != large {
+			t.Errorf("%s body doesn't decode back to the response", encoding)
+		}
+	}
+
+	for name, tt := range map[string]struct {
+		handler        http.Handler
+		acceptEncoding string
+	}{
+		"below the minimum size": {handler("application/json", `{"id":2544}`), "gzip"},
+		"binary content":         {handler("image/png", large), "gzip"},
+		"not accepted":           {handler("text/csv", large), "identity"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		w := httptest.NewRecorder()
+		tt.handler.ServeHTTP(w, req)
+		if w.Header().Get("Content-Encoding") != "" || w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s: expected an uncompressed body that varies by encoding, got %v", name, w.Header())
+		}
+	}
+}
+
+func TestCompressStreaming(t *testing.T) {
+	lines := make(chan string)
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for line := range lines {
+			io.WriteString(w, line)
+			http.NewResponseController(w).Flush()
+		}
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	// the transport only decodes gzip on its own when it set the header itself
+	req.Header.Set("Accept-Encoding", "zstd")
+	done := make(chan *http.Response)
+	go func() {
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- resp
+	}()
+
+	// the first line arrives before the handler is done writing
+	lines <- `{"id":1}` + "\n"
+	resp := <-done
+	defer resp.Body.Close()
+	dec, err := zstd.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+
+	first := make([]byte, len(`{"id":1}`+"\n"))
+	if _, err := io.ReadFull(dec, first); err != nil || string(first) != `{"id":1}`+"\n" {
+		t.Fatalf("expected the flushed line, got %q, %v", first, err)
+	}
+	lines <- `{"id":2}` + "\n"
+	close(lines)
+	rest, err := io.ReadAll(dec)
+	if err != nil || string(rest) != `{"id":2}`+"\n" {
+		t.Errorf("expected the second line, got %q, %v", rest, err)
+	}
+}
+
+// fullSeasonShots is a made up season the size of a real one, 1230 games of about 85 shots a team
+func fullSeasonShots() *ShotResponse {
+	rng := rand.New(rand.NewPCG(2016, 82))
+	shots := make([]types.ReturnShot, 1230*170)
+	aggs := types.ShotAggregates{}
+	for i := range shots {
+		distance := rng.Float64() * 28
+		angle := rng.Float64() * math.Pi
+		shotType := TWO_PT_SHOT
+		if distance > 22 {
+			shotType = THREE_PT_SHOT
+		}
+		shots[i] = types.ReturnShot{
+			ID:       i + 1,
+			LocX:     math.Round(-distance*math.Cos(angle)*10) / 10,
+			LocY:     math.Round((5.25+distance*math.Sin(angle))*10) / 10,
+			ShotMade: rng.Float64() < 0.47,
+			ShotType: shotType,
+		}
+		aggs.AddShot(shots[i].ShotMade, shotType)
+	}
+	return NewShotResponse(&aggs, &shots)
+}
+
+// BenchmarkCompressFullSeason reports how much of a full season's /shots payload each encoding
+// sends, as compressed/raw
+func BenchmarkCompressFullSeason(b *testing.B) {
+	resp := fullSeasonShots()
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		render.Render(w, r, resp)
+	}))
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, encoding := range append([]string{"identity"}, compressEncodings...) {
+		b.Run(encoding, func(b *testing.B) {
+			var size int
+			for range b.N {
+				req := httptest.NewRequest(http.MethodGet, "/shots?season=2016", nil)
+				req.Header.Set("Accept-Encoding", encoding)
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+				size = w.Body.Len()
+			}
+			b.StopTimer()
+
+			req := httptest.NewRequest(http.MethodGet, "/shots?season=2016", nil)
+			req.Header.Set("Accept-Encoding", encoding)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if got := decode(b, w.Header().Get("Content-Encoding"), w.Body.Bytes()); !bytes.Equal(bytes.TrimSpace(got), raw) {
+				b.Fatalf("%s body doesn't decode back to the shots", encoding)
+			}
+
+			b.ReportMetric(float64(size), "bytes")
+			b.ReportMetric(float64(size)/float64(len(raw)), "ratio")
+			b.Logf("%s: %d of %d bytes", encoding, size, len(raw))
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"nba-shots/internal/database"
 	"net/http"
 
 	"github.com/go-chi/render"
@@ -20,6 +23,15 @@ func (rd *ErrResponse) Render(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// renderError sends rd the way the request's api version sends errors, for the middlewares
+// shared between versions
+func renderError(w http.ResponseWriter, r *http.Request, rd render.Renderer) {
+	if e, ok := rd.(*ErrResponse); ok && apiVersion(r) >= 2 {
+		rd = NewErrorEnvelope(e)
+	}
+	render.Render(w, r, rd)
+}
+
 func ErrRender(err error) render.Renderer {
 	return &ErrResponse{
 		Err:            err,
@@ -47,6 +59,38 @@ func ErrInternalServer(err error) render.Renderer {
 	}
 }
 
+// the status nginx logs for a request the client closed before getting the response
+const StatusClientClosedRequest = 499
+
+// ErrQuery maps a failed database call to a response, a query cut short by the client going away
+// is a 499 and one that ran out of time is a 504
+func ErrQuery(r *http.Request, err error) render.Renderer {
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(r.Context().Err(), context.Canceled):
+		return &ErrResponse{
+			Err:            err,
+			HTTPStatusCode: StatusClientClosedRequest,
+			StatusText:     "Client closed request",
+		}
+	case database.IsTimeout(err):
+		return &ErrResponse{
+			Err:            err,
+			HTTPStatusCode: http.StatusGatewayTimeout,
+			StatusText:     "Query timed out",
+			ErrorText:      err.Error(),
+		}
+	default:
+		return ErrInternalServer(err)
+	}
+}
+
+func ErrUnauthorized() render.Renderer {
+	return &ErrResponse{
+		HTTPStatusCode: 401,
+		StatusText:     "Unauthorized",
+	}
+}
+
 func ErrNotFound() render.Renderer {
 	return &ErrResponse{
 		HTTPStatusCode: 404,
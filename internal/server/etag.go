@@ -0,0 +1,141 @@
+package server
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"nba-shots/internal/database"
+	"nba-shots/internal/types"
+	"net/http"
+	"strings"
+)
+
+// Cache-Control of the responses, once they're stale clients revalidate them with the ETag and
+// get a 304 while the data hasn't changed
+const (
+	// players, teams, seasons and games only change when ingest runs
+	cacheCatalog = "public, max-age=3600"
+	// the seasons before the latest one are over, they only change when one is reloaded
+	cacheHistorical = "public, max-age=86400"
+	// revalidated on every use
+	cacheRevalidate = "public, no-cache"
+)
+
+// cachePolicy picks the Cache-Control of a request
+type cachePolicy func(r *http.Request) string
+
+func staticPolicy(cacheControl string) cachePolicy {
+	return func(*http.Request) string { return cacheControl }
+}
+
+// Validated sets an ETag on the response made of the dataset version and the normalized query,
+// a request whose If-None-Match still matches gets a 304 without the handler running. The
+// version goes in the request's context for the cache to read the same one. Only successful
+// responses keep the ETag and Cache-Control.
+func (s *Server) Validated(policy cachePolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			version, err := s.catalog.GetDatasetVersion(r.Context())
+			if err != nil {
+				log.Printf("could not get the dataset version, responding without an ETag: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// the cache answers the request with the results of the same version
+			r = r.WithContext(database.WithDatasetVersion(r.Context(), version.Version))
+			etag := datasetETag(version, r)
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Cache-Control", policy(r))
+			if etagMatches(r.Header.Get("If-None-Match"), etag) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			next.ServeHTTP(&validatedWriter{ResponseWriter: w}, r)
+		})
+	}
+}
+
+// datasetETag is weak, the same response can be sent compressed or not
+func datasetETag(version *types.DatasetVersion, r *http.Request) string {
+	query := r.URL.Query().Encode()
+	if args, ok := r.Context().Value(shotArgsKey).(*types.RequestShotParams); ok {
+		query = args.Key()
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{'?'})
+	h.Write([]byte(query))
+	// the protobuf shots are another representation of the same query
+	if acceptsProtobuf(r) {
+		h.Write([]byte{0})
+		h.Write([]byte(protobufContentType))
+	}
+	return fmt.Sprintf(`W/"%d-%x"`, version.Version, h.Sum64())
+}
+
+// etagMatches compares the If-None-Match list with etag the weak way, as GET requests are
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// validatedWriter drops the validators of error responses, they mustn't be cached
+type validatedWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *validatedWriter) WriteHeader(code int) {
+	if !w.wroteHeader && code != http.StatusOK {
+		w.Header().Del("ETag")
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *validatedWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *validatedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// shotsCachePolicy caches the shots of seasons that are over for longer, every other query is
+// revalidated since the latest season still gets new games
+func (s *Server) shotsCachePolicy(r *http.Request) string {
+	args, ok := r.Context().Value(shotArgsKey).(*types.RequestShotParams)
+	if !ok || len(args.SeasonYears) == 0 {
+		return cacheRevalidate
+	}
+
+	seasons, err := s.catalog.GetAllSeasons(r.Context())
+	if err != nil {
+		return cacheRevalidate
+	}
+	latest := 0
+	for _, season := range seasons {
+		latest = max(latest, season.Year)
+	}
+	for _, year := range args.SeasonYears {
+		if year >= latest {
+			return cacheRevalidate
+		}
+	}
+	return cacheHistorical
+}
@@ -44,9 +44,9 @@ func (s *Server) getGameByIDHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// 2 - send the gameID to the db service to get the game
-	game, err := s.db.GetGameByID(gameID)
+	game, err := s.catalog.GetGameByID(r.Context(), gameID)
 	if err != nil {
-		render.Render(w, r, ErrInternalServer(err))
+		render.Render(w, r, ErrQuery(r, err))
 		return
 	}
 
@@ -67,9 +67,9 @@ func (s *Server) getLastXGamesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// 2 - send the number of games to the db service to get the games
-	games, err := s.db.GetLastXGames(limit)
+	games, err := s.catalog.GetLastXGames(r.Context(), limit)
 	if err != nil {
-		render.Render(w, r, ErrInternalServer(err))
+		render.Render(w, r, ErrQuery(r, err))
 		return
 	}
 
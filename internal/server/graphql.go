@@ -0,0 +1,645 @@
+package server
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"nba-shots/internal/database"
+	"nba-shots/internal/types"
+
+	"github.com/go-chi/render"
+	"github.com/graph-gophers/graphql-go"
+)
+
+const graphQLPath = "/graphql"
+
+//go:embed schema.graphql
+var graphQLSchema string
+
+const (
+	// team { players { teams { players { name } } } } is 4 deep
+	graphQLMaxDepth = 8
+	// the elements of a list are resolved this many at a time, a list longer than it takes more
+	// than one batch per loader
+	graphQLParallelism = 100
+)
+
+// graphQLRequest is the body of a POST to /graphql
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// graphQLResponse documents the response of /graphql, the body is the graphql.Response
+type graphQLResponse struct {
+	Data   map[string]any `json:"data"`
+	Errors []graphQLError `json:"errors,omitempty"`
+}
+
+type graphQLError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func newGraphQLSchema(s *Server) *graphql.Schema {
+	return graphql.MustParseSchema(graphQLSchema, &queryResolver{s: s},
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(graphQLMaxDepth),
+		graphql.MaxParallelism(graphQLParallelism),
+	)
+}
+
+// graphQLHandler answers a query with the players, teams, seasons, games and shots it asks for,
+// the lookups its resolvers make are batched by the request's loaders
+func (s *Server) graphQLHandler(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		renderError(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if req.Query == "" {
+		renderError(w, r, ErrInvalidRequest(errors.New("missing query")))
+		return
+	}
+
+	ctx := r.Context()
+	// the cache reads a version another process loaded from here on, /graphql has no ETag
+	version, err := s.catalog.GetDatasetVersion(ctx)
+	if err != nil {
+		log.Printf("could not get the dataset version: %v", err)
+	} else {
+		ctx = database.WithDatasetVersion(ctx, version.Version)
+	}
+	ctx = withLoaders(ctx, newLoaders(s.catalog, s.relations, s.shots, s.logShotQuery))
+	resp := s.graphQL.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	render.JSON(w, r, resp)
+}
+
+// queryResolver answers the fields of Query, the rows its lists find are primed into the
+// request's loaders so the fields below them don't look them up again
+type queryResolver struct {
+	s *Server
+}
+
+// lookup is the row a loader found for id, nil when there isn't one
+func lookup[V any](row *V, err error) (*V, error) {
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+	return row, err
+}
+
+func (q *queryResolver) Player(ctx context.Context, args struct{ ID int32 }) (*playerResolver, error) {
+	player, err := lookup(loadersFrom(ctx).players.Load(ctx, int(args.ID))())
+	if player == nil {
+		return nil, err
+	}
+	return &playerResolver{q.s, player}, nil
+}
+
+func (q *queryResolver) Players(ctx context.Context, args struct {
+	IDs  *[]int32
+	Name *string
+}) ([]*playerResolver, error) {
+	var players []types.Player
+	var err error
+	switch {
+	case args.IDs != nil && args.Name == nil:
+		players, err = q.s.catalog.GetPlayersByIDs(ctx, ints(*args.IDs))
+	case args.Name != nil && args.IDs == nil:
+		players, err = q.s.catalog.GetPlayersByName(ctx, "%"+*args.Name+"%")
+	default:
+		return nil, errors.New("players takes either ids or a name")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	l := loadersFrom(ctx)
+	resolvers := make([]*playerResolver, len(players))
+	for i := range players {
+		l.players.Prime(ctx, players[i].ID, &players[i])
+		resolvers[i] = &playerResolver{q.s, &players[i]}
+	}
+	return resolvers, nil
+}
+
+func (q *queryResolver) Team(ctx context.Context, args struct{ ID int32 }) (*teamResolver, error) {
+	team, err := lookup(loadersFrom(ctx).teams.Load(ctx, int(args.ID))())
+	if team == nil {
+		return nil, err
+	}
+	return &teamResolver{q.s, team}, nil
+}
+
+func (q *queryResolver) Teams(ctx context.Context) ([]*teamResolver, error) {
+	teams, err := q.s.catalog.GetAllTeams(ctx)
+	if err != nil {
+		return nil, err
+	}
+	l := loadersFrom(ctx)
+	resolvers := make([]*teamResolver, len(teams))
+	for i := range teams {
+		l.teams.Prime(ctx, teams[i].ID, &teams[i])
+		resolvers[i] = &teamResolver{q.s, &teams[i]}
+	}
+	return resolvers, nil
+}
+
+func (q *queryResolver) Season(ctx context.Context, args struct{ Year int32 }) (*seasonResolver, error) {
+	season, err := lookup(loadersFrom(ctx).seasons.Load(ctx, int(args.Year))())
+	if season == nil {
+		return nil, err
+	}
+	return &seasonResolver{q.s, season}, nil
+}
+
+func (q *queryResolver) Seasons(ctx context.Context) ([]*seasonResolver, error) {
+	seasons, err := q.s.catalog.GetAllSeasons(ctx)
+	if err != nil {
+		return nil, err
+	}
+	l := loadersFrom(ctx)
+	resolvers := make([]*seasonResolver, len(seasons))
+	for i := range seasons {
+		l.seasons.Prime(ctx, seasons[i].Year, &seasons[i])
+		resolvers[i] = &seasonResolver{q.s, &seasons[i]}
+	}
+	return resolvers, nil
+}
+
+func (q *queryResolver) Game(ctx context.Context, args struct{ ID int32 }) (*gameResolver, error) {
+	game, err := lookup(loadersFrom(ctx).games.Load(ctx, int(args.ID))())
+	if game == nil {
+		return nil, err
+	}
+	return &gameResolver{q.s, game}, nil
+}
+
+func (q *queryResolver) LastGames(ctx context.Context, args struct{ Count int32 }) ([]*gameResolver, error) {
+	if args.Count < 1 {
+		return nil, fmt.Errorf("count must be at least 1, got %d", args.Count)
+	}
+	games, err := q.s.catalog.GetLastXGames(ctx, int(args.Count))
+	if err != nil {
+		return nil, err
+	}
+	l := loadersFrom(ctx)
+	resolvers := make([]*gameResolver, len(games))
+	for i := range games {
+		l.games.Prime(ctx, games[i].ID, &games[i])
+		resolvers[i] = &gameResolver{q.s, &games[i]}
+	}
+	return resolvers, nil
+}
+
+// Shots needs a player, team or season, the shots of everyone in every season are too many
+func (q *queryResolver) Shots(args struct{ Filter *shotFilter }) (*shotsResolver, error) {
+	shotArgs, err := args.Filter.shotArgs()
+	if err != nil {
+		return nil, err
+	}
+	if len(shotArgs.PlayerIDs) == 0 && len(shotArgs.TeamIDs) == 0 && len(shotArgs.SeasonYears) == 0 {
+		return nil, errors.New("shots takes at least one of playerIds, teamIds or seasons")
+	}
+	return &shotsResolver{s: q.s, shotArgs: shotArgs}, nil
+}
+
+type playerResolver struct {
+	s      *Server
+	player *types.Player
+}
+
+func (p *playerResolver) ID() int32 {
+	return int32(p.player.ID)
+}
+
+func (p *playerResolver) Name() string {
+	return p.player.Name
+}
+
+func (p *playerResolver) Teams(ctx context.Context) ([]*teamResolver, error) {
+	l := loadersFrom(ctx)
+	teams, err := loadAll(ctx, l.playerTeams, l.teams, p.player.ID)
+	return wrap(teams, err, func(t *types.Team) *teamResolver { return &teamResolver{p.s, t} })
+}
+
+func (p *playerResolver) Seasons(ctx context.Context) ([]*seasonResolver, error) {
+	l := loadersFrom(ctx)
+	seasons, err := loadAll(ctx, l.playerSeasons, l.seasons, p.player.ID)
+	return wrap(seasons, err, func(season *types.Season) *seasonResolver { return &seasonResolver{p.s, season} })
+}
+
+func (p *playerResolver) Games(ctx context.Context) ([]*gameResolver, error) {
+	l := loadersFrom(ctx)
+	games, err := loadAll(ctx, l.playerGames, l.games, p.player.ID)
+	return wrap(games, err, func(g *types.Game) *gameResolver { return &gameResolver{p.s, g} })
+}
+
+func (p *playerResolver) Shots(args struct{ Filter *shotFilter }) (*shotsResolver, error) {
+	return newShotsResolver(p.s, args.Filter, types.GroupByPlayer, p.player.ID)
+}
+
+type teamResolver struct {
+	s    *Server
+	team *types.Team
+}
+
+func (t *teamResolver) ID() int32 {
+	return int32(t.team.ID)
+}
+
+func (t *teamResolver) Name() string {
+	return t.team.Name
+}
+
+func (t *teamResolver) Abbreviation() string {
+	return t.team.Abbreviation
+}
+
+func (t *teamResolver) Players(ctx context.Context) ([]*playerResolver, error) {
+	l := loadersFrom(ctx)
+	players, err := loadAll(ctx, l.teamPlayers, l.players, t.team.ID)
+	return wrap(players, err, func(p *types.Player) *playerResolver { return &playerResolver{t.s, p} })
+}
+
+func (t *teamResolver) Seasons(ctx context.Context) ([]*teamSeasonResolver, error) {
+	teamSeasons, err := loadersFrom(ctx).teamSeasons.Load(ctx, t.team.ID)()
+	return wrapTeamSeasons(t.s, teamSeasons, err)
+}
+
+func (t *teamResolver) Games(ctx context.Context) ([]*gameResolver, error) {
+	l := loadersFrom(ctx)
+	games, err := loadAll(ctx, l.teamGames, l.games, t.team.ID)
+	return wrap(games, err, func(g *types.Game) *gameResolver { return &gameResolver{t.s, g} })
+}
+
+func (t *teamResolver) Shots(args struct{ Filter *shotFilter }) (*shotsResolver, error) {
+	return newShotsResolver(t.s, args.Filter, types.GroupByTeam, t.team.ID)
+}
+
+type seasonResolver struct {
+	s      *Server
+	season *types.Season
+}
+
+func (s *seasonResolver) Year() int32 {
+	return int32(s.season.Year)
+}
+
+func (s *seasonResolver) SeasonYears() string {
+	return s.season.SeasonYears
+}
+
+func (s *seasonResolver) Teams(ctx context.Context) ([]*teamSeasonResolver, error) {
+	teamSeasons, err := loadersFrom(ctx).seasonTeams.Load(ctx, s.season.Year)()
+	return wrapTeamSeasons(s.s, teamSeasons, err)
+}
+
+func (s *seasonResolver) Players(ctx context.Context) ([]*playerResolver, error) {
+	l := loadersFrom(ctx)
+	players, err := loadAll(ctx, l.seasonPlayers, l.players, s.season.Year)
+	return wrap(players, err, func(p *types.Player) *playerResolver { return &playerResolver{s.s, p} })
+}
+
+func (s *seasonResolver) Games(ctx context.Context) ([]*gameResolver, error) {
+	l := loadersFrom(ctx)
+	games, err := loadAll(ctx, l.seasonGames, l.games, s.season.Year)
+	return wrap(games, err, func(g *types.Game) *gameResolver { return &gameResolver{s.s, g} })
+}
+
+func (s *seasonResolver) Shots(args struct{ Filter *shotFilter }) (*shotsResolver, error) {
+	return newShotsResolver(s.s, args.Filter, types.GroupBySeason, s.season.Year)
+}
+
+type teamSeasonResolver struct {
+	s          *Server
+	teamSeason types.TeamSeason
+}
+
+func wrapTeamSeasons(s *Server, teamSeasons []types.TeamSeason, err error) ([]*teamSeasonResolver, error) {
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]*teamSeasonResolver, len(teamSeasons))
+	for i, teamSeason := range teamSeasons {
+		resolvers[i] = &teamSeasonResolver{s, teamSeason}
+	}
+	return resolvers, nil
+}
+
+func (ts *teamSeasonResolver) Team(ctx context.Context) (*teamResolver, error) {
+	team, err := loadersFrom(ctx).teams.Load(ctx, ts.teamSeason.TeamID)()
+	if err != nil {
+		return nil, err
+	}
+	return &teamResolver{ts.s, team}, nil
+}
+
+func (ts *teamSeasonResolver) Season(ctx context.Context) (*seasonResolver, error) {
+	season, err := loadersFrom(ctx).seasons.Load(ctx, ts.teamSeason.SeasonYear)()
+	if err != nil {
+		return nil, err
+	}
+	return &seasonResolver{ts.s, season}, nil
+}
+
+func (ts *teamSeasonResolver) Name() string {
+	return ts.teamSeason.TeamName
+}
+
+func (ts *teamSeasonResolver) Abbreviation() string {
+	return ts.teamSeason.Abbreviation
+}
+
+type gameResolver struct {
+	s    *Server
+	game *types.Game
+}
+
+func (g *gameResolver) ID() int32 {
+	return int32(g.game.ID)
+}
+
+func (g *gameResolver) Date() string {
+	return g.game.GameDate.Format("2006-01-02")
+}
+
+func (g *gameResolver) Season(ctx context.Context) (*seasonResolver, error) {
+	season, err := loadersFrom(ctx).seasons.Load(ctx, g.game.SeasonYear)()
+	if err != nil {
+		return nil, err
+	}
+	return &seasonResolver{g.s, season}, nil
+}
+
+func (g *gameResolver) HomeTeam(ctx context.Context) (*teamResolver, error) {
+	return g.team(ctx, g.game.HomeTeamID)
+}
+
+func (g *gameResolver) AwayTeam(ctx context.Context) (*teamResolver, error) {
+	return g.team(ctx, g.game.AwayTeamID)
+}
+
+func (g *gameResolver) team(ctx context.Context, id int) (*teamResolver, error) {
+	team, err := loadersFrom(ctx).teams.Load(ctx, id)()
+	if err != nil {
+		return nil, err
+	}
+	return &teamResolver{g.s, team}, nil
+}
+
+func (g *gameResolver) Players(ctx context.Context) ([]*playerResolver, error) {
+	l := loadersFrom(ctx)
+	players, err := loadAll(ctx, l.gamePlayers, l.players, g.game.ID)
+	return wrap(players, err, func(p *types.Player) *playerResolver { return &playerResolver{g.s, p} })
+}
+
+// wrap turns the rows a loader found into their resolvers
+func wrap[V, R any](rows []*V, err error, resolver func(*V) R) ([]R, error) {
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]R, len(rows))
+	for i, row := range rows {
+		resolvers[i] = resolver(row)
+	}
+	return resolvers, nil
+}
+
+// shotFilter is the ShotFilter input, the filters of ShotCtx
+type shotFilter struct {
+	PlayerIDs       *[]int32
+	TeamIDs         *[]int32
+	Seasons         *[]int32
+	OpposingTeamIDs *[]int32
+	StartGameDate   *string
+	EndGameDate     *string
+	GameLocation    *string
+	Quarters        *[]int32
+	StartTimeLeft   *string
+	EndTimeLeft     *string
+}
+
+// shotArgs are the params of the filter, checked like ShotCtx checks the query parameters
+func (f *shotFilter) shotArgs() (*types.RequestShotParams, error) {
+	shotArgs := types.NewRequestShotParams()
+	shotArgs.StartTimeLeftSecs = -1
+	shotArgs.EndTimeLeftSecs = -1
+	if f == nil {
+		return shotArgs, nil
+	}
+
+	optionalInts := func(ids *[]int32) []int {
+		if ids == nil {
+			return nil
+		}
+		return ints(*ids)
+	}
+	shotArgs.PlayerIDs = optionalInts(f.PlayerIDs)
+	shotArgs.TeamIDs = optionalInts(f.TeamIDs)
+	shotArgs.SeasonYears = optionalInts(f.Seasons)
+	shotArgs.OpposingTeamIds = optionalInts(f.OpposingTeamIDs)
+	shotArgs.Quarters = optionalInts(f.Quarters)
+
+	var err error
+	if f.StartGameDate != nil {
+		shotArgs.StartGameDate, err = time.Parse("2006-01-02", *f.StartGameDate)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if f.EndGameDate != nil {
+		shotArgs.EndGameDate, err = time.Parse("2006-01-02", *f.EndGameDate)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if f.GameLocation != nil {
+		shotArgs.GameLocation = strings.ToLower(*f.GameLocation)
+	}
+	if f.StartTimeLeft != nil {
+		shotArgs.StartTimeLeftSecs, err = parseClockTimeLeftToSecs(*f.StartTimeLeft)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if f.EndTimeLeft != nil {
+		shotArgs.EndTimeLeftSecs, err = parseClockTimeLeftToSecs(*f.EndTimeLeft)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = validateShotArgs(shotArgs)
+	if err != nil {
+		return nil, err
+	}
+	return shotArgs, nil
+}
+
+// shotsResolver queries the shots and their summary only if they're asked for, and once. The
+// shots of a player, team or season come from the request's loaders, the ones of every player,
+// team or season of a list are one query.
+type shotsResolver struct {
+	s        *Server
+	shotArgs *types.RequestShotParams
+	// the player, team or season the shots were asked for, group is empty for Query.shots
+	group types.ShotGroup
+	id    int
+
+	summaryOnce sync.Once
+	summary     *types.ShotSummary
+	summaryErr  error
+}
+
+// newShotsResolver resolves the shots of id that match the filter, its ids of the group are
+// ignored
+func newShotsResolver(s *Server, filter *shotFilter, group types.ShotGroup, id int) (*shotsResolver, error) {
+	shotArgs, err := filter.shotArgs()
+	if err != nil {
+		return nil, err
+	}
+	*group.IDs(shotArgs) = nil
+	return &shotsResolver{s: s, shotArgs: shotArgs, group: group, id: id}, nil
+}
+
+func (r *shotsResolver) getSummary(ctx context.Context) (*types.ShotSummary, error) {
+	r.summaryOnce.Do(func() {
+		if r.group != "" {
+			r.summary, r.summaryErr = loadersFrom(ctx).summariesOf(r.group, r.shotArgs).Load(ctx, r.id)()
+			return
+		}
+		r.summary, r.summaryErr = r.s.shots.GetShotSummary(ctx, r.shotArgs)
+	})
+	return r.summary, r.summaryErr
+}
+
+func (r *shotsResolver) Aggregates(ctx context.Context) (*aggregatesResolver, error) {
+	summary, err := r.getSummary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &aggregatesResolver{summary.ShotAggregates}, nil
+}
+
+func (r *shotsResolver) Zones(ctx context.Context) ([]*zoneResolver, error) {
+	summary, err := r.getSummary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]*zoneResolver, len(summary.Zones))
+	for i, zone := range summary.Zones {
+		resolvers[i] = &zoneResolver{zone}
+	}
+	return resolvers, nil
+}
+
+func (r *shotsResolver) Shots(ctx context.Context) ([]*shotResolver, error) {
+	var shots []types.ReturnShot
+	var err error
+	if r.group != "" {
+		// the loader logs the query of the whole batch
+		shots, err = loadersFrom(ctx).shotsOf(r.group, r.shotArgs).Load(ctx, r.id)()
+	} else {
+		shots, err = r.s.shots.GetShots(ctx, r.shotArgs)
+		if err == nil {
+			r.s.logShotQuery(r.shotArgs, len(shots))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*shotResolver, len(shots))
+	for i, shot := range shots {
+		resolvers[i] = &shotResolver{shot}
+	}
+	return resolvers, nil
+}
+
+type aggregatesResolver struct {
+	aggregates types.ShotAggregates
+}
+
+func (a *aggregatesResolver) TotalMadeShots() int32 {
+	return int32(a.aggregates.TotalMadeShots)
+}
+
+func (a *aggregatesResolver) TotalMissedShots() int32 {
+	return int32(a.aggregates.TotalMissedShots)
+}
+
+func (a *aggregatesResolver) Made2PtShots() int32 {
+	return int32(a.aggregates.Made2PtShots)
+}
+
+func (a *aggregatesResolver) Missed2PtShots() int32 {
+	return int32(a.aggregates.Missed2PtShots)
+}
+
+func (a *aggregatesResolver) Made3PtShots() int32 {
+	return int32(a.aggregates.Made3PtShots)
+}
+
+func (a *aggregatesResolver) Missed3PtShots() int32 {
+	return int32(a.aggregates.Missed3PtShots)
+}
+
+type zoneResolver struct {
+	zone types.ZoneAggregates
+}
+
+func (z *zoneResolver) BasicZone() string {
+	return z.zone.BasicZone
+}
+
+func (z *zoneResolver) ZoneAbb() string {
+	return z.zone.ZoneABB
+}
+
+func (z *zoneResolver) Aggregates() *aggregatesResolver {
+	return &aggregatesResolver{z.zone.ShotAggregates}
+}
+
+type shotResolver struct {
+	shot types.ReturnShot
+}
+
+func (s *shotResolver) ID() int32 {
+	return int32(s.shot.ID)
+}
+
+func (s *shotResolver) LocX() float64 {
+	return s.shot.LocX
+}
+
+func (s *shotResolver) LocY() float64 {
+	return s.shot.LocY
+}
+
+func (s *shotResolver) Made() bool {
+	return s.shot.ShotMade
+}
+
+func (s *shotResolver) ShotType() string {
+	return s.shot.ShotType
+}
+
+func ints(ids []int32) []int {
+	out := make([]int, len(ids))
+	for i, id := range ids {
+		out[i] = int(id)
+	}
+	return out
+}
@@ -0,0 +1,331 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"nba-shots/internal/database"
+	"nba-shots/internal/database/memory"
+	"nba-shots/internal/database/servicetest"
+	"nba-shots/internal/types"
+)
+
+// graphQL posts query to /graphql and decodes the data into v
+func graphQL(t *testing.T, handler http.Handler, query string, v any) []graphQLError {
+	t.Helper()
+	body, _ := json.Marshal(graphQLRequest{Query: query})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, graphQLPath, bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", w.Code, w.Body)
+	}
+
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []graphQLError  `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("could not decode %s: %v", w.Body, err)
+	}
+	if v != nil && len(resp.Data) > 0 {
+		if err := json.Unmarshal(resp.Data, v); err != nil {
+			t.Fatalf("could not decode the data %s: %v", resp.Data, err)
+		}
+	}
+	return resp.Errors
+}
+
+func TestGraphQL(t *testing.T) {
+	handler := newTestServer(t)
+
+	var data struct {
+		Player struct {
+			Name  string
+			Teams []struct{ Abbreviation string }
+			Games []struct {
+				Date     string
+				HomeTeam struct{ Name string }
+				Season   struct{ SeasonYears string }
+			}
+			Shots struct {
+				Aggregates struct{ TotalMadeShots, Missed3PtShots int }
+				Shots      []struct{ Made bool }
+			}
+		}
+		Season struct {
+			Teams []struct {
+				Abbreviation string
+				Team         struct{ Players []struct{ Name string } }
+			}
+		}
+		Missing *struct{ Name string }
+	}
+	errs := graphQL(t, handler, fmt.Sprintf(`{
+		player(id: %d) {
+			name
+			teams { abbreviation }
+			games { date homeTeam { name } season { seasonYears } }
+			shots(filter: {opposingTeamIds: [%d], playerIds: [%d]}) {
+				aggregates { totalMadeShots missed3PtShots }
+				shots { made }
+			}
+		}
+		season(year: %d) { teams { abbreviation team { players { name } } } }
+		missing: player(id: 1) { name }
+	}`, servicetest.Curry, servicetest.Cavaliers, servicetest.Kawhi, servicetest.Year), &data)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %+v", errs)
+	}
+
+	p := data.Player
+	if p.Name != "Stephen Curry" || len(p.Teams) != 1 || p.Teams[0].Abbreviation != "GSW" {
+		t.Errorf("expected Curry on the Warriors, got %+v", p)
+	}
+	if len(p.Games) != 2 || p.Games[0].Date != "2015-11-01" || p.Games[0].HomeTeam.Name != "Golden State Warriors" || p.Games[1].Season.SeasonYears != "2015-16" {
+		t.Errorf("expected Curry's two games, got %+v", p.Games)
+	}
+	// the player's id replaces the filter's
+	if p.Shots.Aggregates.TotalMadeShots != 2 || p.Shots.Aggregates.Missed3PtShots != 1 || len(p.Shots.Shots) != 3 {
+		t.Errorf("expected Curry's 3 shots against Cleveland, got %+v", p.Shots)
+	}
+
+	teams := data.Season.Teams
+	if len(teams) != 3 || teams[0].Abbreviation != "CLE" || len(teams[0].Team.Players) != 1 || teams[0].Team.Players[0].Name != "LeBron James" {
+		t.Errorf("expected the three teams of the season with their players, got %+v", teams)
+	}
+	if data.Missing != nil {
+		t.Errorf("expected a missing player to be null, got %+v", data.Missing)
+	}
+
+	// a filter with only one end of the time range
+	var spurs struct {
+		Team struct {
+			Shots struct{ Shots []struct{ ID int } }
+		}
+	}
+	errs = graphQL(t, handler, fmt.Sprintf(`{ team(id: %d) { shots(filter: {endTimeLeft: "0:20"}) { shots { id } } } }`, servicetest.Spurs), &spurs)
+	if len(errs) > 0 || len(spurs.Team.Shots.Shots) != 2 {
+		t.Errorf("expected the Spurs' 2 shots with more than 20 seconds left, got %+v %+v", spurs, errs)
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	handler := newTestServer(t)
+
+	for query, message := range map[string]string{
+		`{ shots(filter: {startTimeLeft: "1:00", endTimeLeft: "2:00"}) { aggregates { totalMadeShots } } }`: "start_time_left is less than end_time_left: 1:00 < 2:00",
+		`{ shots(filter: {startGameDate: "2016-02-01", endGameDate: "2016-01-01"}) { shots { id } } }`:      "start_game_date: 2016-02-01 is after end_game_date: 2016-01-01",
+		`{ shots { shots { id } } }`: "shots takes at least one of playerIds, teamIds or seasons",
+		`{ shots(filter: {quarters: [4]}) { aggregates { totalMadeShots } } }`: "shots takes at least one of playerIds, teamIds or seasons",
+		`{ players { name } }`:           "players takes either ids or a name",
+		`{ lastGames(count: 0) { id } }`: "count must be at least 1",
+		`{ player(id: 1) { height } }`:   `Cannot query field "height"`,
+	} {
+		errs := graphQL(t, handler, query, nil)
+		if len(errs) == 0 || !strings.Contains(errs[0].Message, message) {
+			t.Errorf("expected %s to fail with %q, got %+v", query, message, errs)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, graphQLPath, strings.NewReader(`{}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected a request without a query to be a 400, got %d", w.Code)
+	}
+}
+
+// countingDB counts the reads that reach the database
+type countingDB struct {
+	database.Service
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (db *countingDB) count(name string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.calls[name]++
+}
+
+func (db *countingDB) GetPlayersByIDs(ctx context.Context, ids []int) ([]types.Player, error) {
+	db.count("players")
+	return db.Service.GetPlayersByIDs(ctx, ids)
+}
+
+func (db *countingDB) GetTeamsByIDs(ctx context.Context, ids []int) ([]types.Team, error) {
+	db.count("teams")
+	return db.Service.GetTeamsByIDs(ctx, ids)
+}
+
+func (db *countingDB) GetGamesByIDs(ctx context.Context, ids []int) ([]types.Game, error) {
+	db.count("games")
+	return db.Service.GetGamesByIDs(ctx, ids)
+}
+
+func (db *countingDB) GetPlayerTeams(ctx context.Context, playerIDs, teamIDs []int) ([]types.PlayerTeam, error) {
+	db.count("player_team")
+	return db.Service.GetPlayerTeams(ctx, playerIDs, teamIDs)
+}
+
+func (db *countingDB) GetPlayerGames(ctx context.Context, playerIDs, gameIDs []int) ([]types.PlayerGame, error) {
+	db.count("player_game")
+	return db.Service.GetPlayerGames(ctx, playerIDs, gameIDs)
+}
+
+func (db *countingDB) GetTeamGames(ctx context.Context, teamIDs, gameIDs []int) ([]types.TeamGame, error) {
+	db.count("team_game")
+	return db.Service.GetTeamGames(ctx, teamIDs, gameIDs)
+}
+
+func (db *countingDB) GetShots(ctx context.Context, args *types.RequestShotParams) ([]types.ReturnShot, error) {
+	db.count("shots")
+	return db.Service.GetShots(ctx, args)
+}
+
+func (db *countingDB) GetShotsBy(ctx context.Context, args *types.RequestShotParams, group types.ShotGroup) (map[int][]types.ReturnShot, error) {
+	db.count("shots_by")
+	return db.Service.GetShotsBy(ctx, args, group)
+}
+
+func (db *countingDB) GetShotSummary(ctx context.Context, args *types.RequestShotParams) (*types.ShotSummary, error) {
+	db.count("summary")
+	return db.Service.GetShotSummary(ctx, args)
+}
+
+func (db *countingDB) GetShotSummariesBy(ctx context.Context, args *types.RequestShotParams, group types.ShotGroup) (map[int]*types.ShotSummary, error) {
+	db.count("summaries_by")
+	return db.Service.GetShotSummariesBy(ctx, args, group)
+}
+
+// TestGraphQLBatching checks the relations of every element of a list are loaded with one query
+// per level of the query, not one per element
+func TestGraphQLBatching(t *testing.T) {
+	inner, err := memory.NewWithBatches(servicetest.Fixture())
+	if err != nil {
+		t.Fatalf("could not load the fixture: %v", err)
+	}
+	db := &countingDB{Service: inner, calls: map[string]int{}}
+	s := &Server{shots: db, catalog: db, relations: db, queryLog: db, refreshLog: db, health: db}
+	handler := s.RegisterRoutes()
+
+	var data struct {
+		Players []struct {
+			Teams []struct {
+				Games []struct {
+					HomeTeam struct{ Abbreviation string }
+					Players  []struct{ Name string }
+				}
+			}
+		}
+	}
+	errs := graphQL(t, handler, fmt.Sprintf(`{
+		players(ids: [%d, %d, %d]) {
+			teams { games { homeTeam { abbreviation } players { name } } }
+		}
+	}`, servicetest.Curry, servicetest.LeBron, servicetest.Kawhi), &data)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %+v", errs)
+	}
+	if len(data.Players) != 3 || len(data.Players[0].Teams) != 1 || len(data.Players[0].Teams[0].Games) != 2 {
+		t.Fatalf("expected every player with their team's games, got %+v", data)
+	}
+
+	expected := map[string]int{
+		// the players of the games are the ones in the list
+		"players":     1,
+		"player_team": 1,
+		// the home teams of the games are the players' teams, the loader has them already
+		"teams":       1,
+		"team_game":   1,
+		"games":       1,
+		"player_game": 1,
+	}
+	for name, calls := range expected {
+		if db.calls[name] != calls {
+			t.Errorf("expected %d %s queries, got %d", calls, name, db.calls[name])
+		}
+	}
+}
+
+// TestGraphQLShotsBatching checks the shots of every player and team of a list are one query,
+// and the same as asking for them one by one
+func TestGraphQLShotsBatching(t *testing.T) {
+	inner, err := memory.NewWithBatches(servicetest.Fixture())
+	if err != nil {
+		t.Fatalf("could not load the fixture: %v", err)
+	}
+	db := &countingDB{Service: inner, calls: map[string]int{}}
+	s := &Server{shots: db, catalog: db, relations: db, queryLog: db, refreshLog: db, health: db}
+	handler := s.RegisterRoutes()
+
+	type shots struct {
+		Aggregates struct{ TotalMadeShots, TotalMissedShots int64 }
+		Shots      []struct{ ID int }
+	}
+	var data struct {
+		Players []struct {
+			ID    int
+			Shots shots
+		}
+		Teams []struct {
+			ID    int
+			Shots shots
+		}
+	}
+	errs := graphQL(t, handler, fmt.Sprintf(`{
+		players(ids: [%d, %d, %d]) {
+			id
+			shots(filter: {quarters: [4]}) { aggregates { totalMadeShots totalMissedShots } shots { id } }
+		}
+		teams {
+			id
+			shots { aggregates { totalMadeShots totalMissedShots } shots { id } }
+		}
+	}`, servicetest.Curry, servicetest.LeBron, servicetest.Kawhi), &data)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %+v", errs)
+	}
+	if len(data.Players) != 3 || len(data.Teams) != 3 {
+		t.Fatalf("expected every player and team, got %+v", data)
+	}
+
+	expected := map[string]int{
+		// one for the players' shots in the 4th, one for the teams' shots
+		"shots_by":     2,
+		"summaries_by": 2,
+		"shots":        0,
+		"summary":      0,
+	}
+	for name, calls := range expected {
+		if db.calls[name] != calls {
+			t.Errorf("expected %d %s queries, got %d", calls, name, db.calls[name])
+		}
+	}
+
+	check := func(scope string, id int, got shots, args *types.RequestShotParams) {
+		summary, err := inner.GetShotSummary(context.Background(), args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		returned, err := inner.GetShots(context.Background(), args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Aggregates.TotalMadeShots != summary.TotalMadeShots || got.Aggregates.TotalMissedShots != summary.TotalMissedShots || len(got.Shots) != len(returned) {
+			t.Errorf("expected the shots of %s %d to be %+v and %d shots, got %+v", scope, id, summary.ShotAggregates, len(returned), got)
+		}
+	}
+	for _, p := range data.Players {
+		check("player", p.ID, p.Shots, &types.RequestShotParams{PlayerIDs: []int{p.ID}, Quarters: []int{4}, StartTimeLeftSecs: -1, EndTimeLeftSecs: -1})
+	}
+	for _, team := range data.Teams {
+		check("team", team.ID, team.Shots, &types.RequestShotParams{TeamIDs: []int{team.ID}, StartTimeLeftSecs: -1, EndTimeLeftSecs: -1})
+	}
+}
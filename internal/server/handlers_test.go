@@ -0,0 +1,274 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"nba-shots/internal/config"
+	"nba-shots/internal/database/cache"
+	"nba-shots/internal/database/memory"
+	"nba-shots/internal/database/servicetest"
+	"nba-shots/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// newTestServer serves the servicetest fixture from memory
+func newTestServer(t *testing.T) http.Handler {
+	t.Helper()
+	db, err := memory.NewWithBatches(servicetest.Fixture())
+	if err != nil {
+		t.Fatalf("could not load the fixture: %v", err)
+	}
+	s := &Server{shots: db, catalog: db, relations: db, queryLog: db, refreshLog: db, health: db}
+	return s.RegisterRoutes()
+}
+
+func get(t *testing.T, handler http.Handler, url string, v any) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	if w.Code == http.StatusOK && v != nil {
+		err := json.Unmarshal(w.Body.Bytes(), v)
+		if err != nil {
+			t.Fatalf("could not decode %s: %v", url, err)
+		}
+	}
+	return w.Code
+}
+
+func TestShotsHandler(t *testing.T) {
+	handler := newTestServer(t)
+
+	var resp ShotResponse
+	code := get(t, handler, "/shots?player_id="+strconv.Itoa(servicetest.Curry)+"&opposing_team_id="+strconv.Itoa(servicetest.Cavaliers), &resp)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if len(resp.Shots) != 3 || resp.TotalMadeShots != 2 || resp.Missed3PtShots != 1 {
+		t.Errorf("expected Curry's 3 shots against Cleveland, got %+v", resp)
+	}
+	if len(resp.Teams) != 1 || resp.Teams[0].Abbreviation != "CLE" {
+		t.Errorf("expected the opponent's name with the shots, got %+v", resp.Teams)
+	}
+
+	code = get(t, handler, "/shots?team_id="+strconv.Itoa(servicetest.Spurs)+"&start_time_left=2:00", &resp)
+	if code != http.StatusOK || len(resp.Shots) != 2 {
+		t.Errorf("expected the Spurs' 2 shots in the last two minutes, got %d %+v", code, resp)
+	}
+
+	code = get(t, handler, "/shots?team_id="+strconv.Itoa(servicetest.Spurs)+"&end_time_left=0:20", &resp)
+	if code != http.StatusOK || len(resp.Shots) != 2 {
+		t.Errorf("expected the Spurs' 2 shots with more than 20 seconds left, got %d %+v", code, resp)
+	}
+}
+
+func TestShotAggregatesHandler(t *testing.T) {
+	handler := newTestServer(t)
+
+	var resp ShotSummaryResponse
+	code := get(t, handler, "/shots/aggregates?season="+strconv.Itoa(servicetest.Year), &resp)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if resp.TotalMadeShots != 7 || resp.TotalMissedShots != 5 || len(resp.Zones) != 6 {
+		t.Errorf("expected the league's 12 shots in 6 zones, got %+v", resp.ShotSummary)
+	}
+
+	code = get(t, handler, "/shots/aggregates?player_id=1", &resp)
+	if code != http.StatusOK || resp.Zones == nil || len(resp.Zones) != 0 {
+		t.Errorf("expected an empty list of zones for a player without shots, got %d %+v", code, resp.ShotSummary)
+	}
+}
+
+func TestCatalogHandlers(t *testing.T) {
+	handler := newTestServer(t)
+
+	var player types.Player
+	code := get(t, handler, "/player/"+strconv.Itoa(servicetest.Kawhi), &player)
+	if code != http.StatusOK || player.Name != "Kawhi Leonard" {
+		t.Errorf("expected Kawhi Leonard, got %d %+v", code, player)
+	}
+
+	var players []types.Player
+	code = get(t, handler, "/player?name=lebron", &players)
+	if code != http.StatusOK || len(players) != 1 || players[0].ID != servicetest.LeBron {
+		t.Errorf("expected the name search to find LeBron, got %d %+v", code, players)
+	}
+
+	var team types.Team
+	code = get(t, handler, "/team/"+strconv.Itoa(servicetest.Warriors)+"/?season="+strconv.Itoa(servicetest.Year), &team)
+	if code != http.StatusOK || team.Abbreviation != "GSW" {
+		t.Errorf("expected the Warriors in %d, got %d %+v", servicetest.Year, code, team)
+	}
+
+	code = get(t, handler, "/team/"+strconv.Itoa(servicetest.Warriors)+"/?season=1990", nil)
+	if code != http.StatusNotFound {
+		t.Errorf("expected a season the team didn't play to be a 404, got %d", code)
+	}
+
+	var seasons []types.Season
+	code = get(t, handler, "/season/all", &seasons)
+	if code != http.StatusOK || len(seasons) != 1 || seasons[0].SeasonYears != "2015-16" {
+		t.Errorf("expected the 2015-16 season, got %d %+v", code, seasons)
+	}
+
+	var health map[string]string
+	code = get(t, handler, "/health", &health)
+	if code != http.StatusOK || health["status"] != "up" {
+		t.Errorf("expected the database to be up, got %d %v", code, health)
+	}
+}
+
+func TestInvalidateCacheHandler(t *testing.T) {
+	inner, err := memory.NewWithBatches(servicetest.Fixture())
+	if err != nil {
+		t.Fatalf("could not load the fixture: %v", err)
+	}
+	cfg := config.Default()
+	cfg.API.AdminToken = "secret"
+	lru := cache.NewLRU(cfg.Cache.MaxEntries, cfg.Cache.MaxBytes)
+	handler := NewServer(context.Background(), cfg.API, cache.New(inner, lru, cfg.Cache)).Handler
+
+	var seasons []types.Season
+	get(t, handler, "/season/all", &seasons)
+	if lru.Len() != 1 {
+		t.Fatalf("expected the seasons to be cached, got %d entries", lru.Len())
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/cache/invalidate", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent || lru.Len() != 0 {
+		t.Errorf("expected the cache to be emptied, got %d with %d entries", w.Code, lru.Len())
+	}
+
+	// without a cache there's nothing to invalidate
+	handler = NewServer(context.Background(), cfg.API, inner).Handler
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without a cache, got %d", w.Code)
+	}
+}
+
+func TestValidatedResponses(t *testing.T) {
+	ctx := context.Background()
+	db, err := memory.NewWithBatches(servicetest.Fixture())
+	if err != nil {
+		t.Fatalf("could not load the fixture: %v", err)
+	}
+	s := &Server{shots: db, catalog: db, relations: db, queryLog: db, refreshLog: db, health: db}
+	handler := s.RegisterRoutes()
+
+	request := func(url, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/team/all", "")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Header().Get("Cache-Control") != cacheCatalog {
+		t.Fatalf("expected the teams with an ETag and %q, got %d %v", cacheCatalog, w.Code, w.Header())
+	}
+
+	w = request("/team/all", `"other", `+etag)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expected a 304 for a matching ETag, got %d %q", w.Code, w.Body.String())
+	}
+
+	// the same shot query with its ids reordered has the same ETag
+	first := request("/shots?player_id="+strconv.Itoa(servicetest.Curry)+","+strconv.Itoa(servicetest.LeBron), "")
+	second := request("/shots?player_id="+strconv.Itoa(servicetest.LeBron)+","+strconv.Itoa(servicetest.Curry), "")
+	if first.Header().Get("ETag") != second.Header().Get("ETag") || first.Header().Get("Cache-Control") != cacheRevalidate {
+		t.Errorf("expected the same revalidated ETag for both orders, got %v and %v", first.Header(), second.Header())
+	}
+
+	// the fixture's season is the latest one
+	w = request("/shots?season="+strconv.Itoa(servicetest.Year), "")
+	if w.Header().Get("Cache-Control") != cacheRevalidate {
+		t.Errorf("expected the latest season to be revalidated, got %q", w.Header().Get("Cache-Control"))
+	}
+	w = request("/shots?season="+strconv.Itoa(servicetest.Year-1), "")
+	if w.Header().Get("Cache-Control") != cacheHistorical {
+		t.Errorf("expected an earlier season to be cached for longer, got %q", w.Header().Get("Cache-Control"))
+	}
+
+	w = request("/player/1", "")
+	if w.Code == http.StatusOK || w.Header().Get("ETag") != "" || w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("expected the unknown player's error not to be cached, got %d %v", w.Code, w.Header())
+	}
+
+	// a new version of the data doesn't match the old ETag anymore
+	err = db.DeleteSeason(ctx, servicetest.Year)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = request("/team/all", etag)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("expected the teams again with a new ETag after the delete, got %d %v", w.Code, w.Header())
+	}
+}
+
+// TestValidatedCachedResponses checks the body and ETag agree when the data changes under the
+// cache, like it does when ingest runs in another process
+func TestValidatedCachedResponses(t *testing.T) {
+	ctx := context.Background()
+	inner, err := memory.NewWithBatches(servicetest.Fixture())
+	if err != nil {
+		t.Fatalf("could not load the fixture: %v", err)
+	}
+	cfg := config.Default().Cache
+	db := cache.New(inner, cache.NewLRU(cfg.MaxEntries, cfg.MaxBytes), cfg)
+	s := &Server{shots: db, catalog: db, relations: db, queryLog: db, refreshLog: db, health: db}
+	handler := s.RegisterRoutes()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/season/all", nil))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected the seasons with an ETag, got %d %v", w.Code, w.Header())
+	}
+
+	err = inner.DeleteSeason(ctx, servicetest.Year)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seasons []types.Season
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/season/all", nil))
+	if err := json.Unmarshal(w.Body.Bytes(), &seasons); err != nil {
+		t.Fatalf("could not decode the seasons: %v", err)
+	}
+	if w.Header().Get("ETag") == etag || len(seasons) != 0 {
+		t.Errorf("expected the new ETag with the new data, got %q and %+v", w.Header().Get("ETag"), seasons)
+	}
+
+	// /graphql has no ETag, it reads the version itself
+	inner, err = memory.NewWithBatches(servicetest.Fixture())
+	if err != nil {
+		t.Fatalf("could not load the fixture: %v", err)
+	}
+	db = cache.New(inner, cache.NewLRU(cfg.MaxEntries, cfg.MaxBytes), cfg)
+	s = &Server{shots: db, catalog: db, relations: db, queryLog: db, refreshLog: db, health: db}
+	handler = s.RegisterRoutes()
+	var data struct{ Seasons []struct{ Year int } }
+	if errs := graphQL(t, handler, `{ seasons { year } }`, &data); len(errs) > 0 || len(data.Seasons) != 1 {
+		t.Fatalf("expected the fixture's season, got %+v %+v", data, errs)
+	}
+	err = inner.DeleteSeason(ctx, servicetest.Year)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.Seasons = nil
+	if errs := graphQL(t, handler, `{ seasons { year } }`, &data); len(errs) > 0 || len(data.Seasons) != 0 {
+		t.Errorf("expected no seasons after the delete, got %+v %+v", data, errs)
+	}
+}
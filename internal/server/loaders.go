@@ -0,0 +1,232 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"nba-shots/internal/database"
+	"nba-shots/internal/types"
+
+	"github.com/graph-gophers/dataloader/v7"
+)
+
+// how long a loader waits for the other resolvers of a list to ask for their rows before querying
+const loaderWait = 2 * time.Millisecond
+
+var errNotFound = errors.New("not found")
+
+type loadersKey struct{}
+
+// loaders batch and cache the lookups the graphql resolvers of one request make, asking for the
+// teams of every player in a list is one query for the links and one for the teams
+type loaders struct {
+	players *dataloader.Loader[int, *types.Player]
+	teams   *dataloader.Loader[int, *types.Team]
+	seasons *dataloader.Loader[int, *types.Season]
+	games   *dataloader.Loader[int, *types.Game]
+
+	// the ids on the other side of a link table
+	playerTeams   *dataloader.Loader[int, []int]
+	teamPlayers   *dataloader.Loader[int, []int]
+	playerSeasons *dataloader.Loader[int, []int]
+	seasonPlayers *dataloader.Loader[int, []int]
+	playerGames   *dataloader.Loader[int, []int]
+	gamePlayers   *dataloader.Loader[int, []int]
+	teamGames     *dataloader.Loader[int, []int]
+	seasonGames   *dataloader.Loader[int, []int]
+	teamSeasons   *dataloader.Loader[int, []types.TeamSeason]
+	seasonTeams   *dataloader.Loader[int, []types.TeamSeason]
+
+	// the shots of the players, teams or seasons of a list, one loader per group and filter
+	shots         database.ShotReader
+	logShots      func(*types.RequestShotParams, int)
+	mu            sync.Mutex
+	shotLists     map[string]*dataloader.Loader[int, []types.ReturnShot]
+	shotSummaries map[string]*dataloader.Loader[int, *types.ShotSummary]
+}
+
+func newLoaders(catalog database.CatalogReader, relations database.RelationReader, shots database.ShotReader, logShots func(*types.RequestShotParams, int)) *loaders {
+	return &loaders{
+		shots:         shots,
+		logShots:      logShots,
+		shotLists:     map[string]*dataloader.Loader[int, []types.ReturnShot]{},
+		shotSummaries: map[string]*dataloader.Loader[int, *types.ShotSummary]{},
+
+		players: rowLoader("player", catalog.GetPlayersByIDs, func(p types.Player) int { return p.ID }),
+		teams:   rowLoader("team", relations.GetTeamsByIDs, func(t types.Team) int { return t.ID }),
+		seasons: rowLoader("season", relations.GetSeasonsByYears, func(s types.Season) int { return s.Year }),
+		games:   rowLoader("game", relations.GetGamesByIDs, func(g types.Game) int { return g.ID }),
+
+		playerTeams: linkLoader(first(relations.GetPlayerTeams), func(l types.PlayerTeam) (int, int) { return l.PlayerID, l.TeamID }),
+		teamPlayers: linkLoader(second(relations.GetPlayerTeams), func(l types.PlayerTeam) (int, int) { return l.TeamID, l.PlayerID }),
+		playerSeasons: linkLoader(first(relations.GetPlayerSeasons), func(l types.PlayerSeason) (int, int) {
+			return l.PlayerID, l.SeasonYear
+		}),
+		seasonPlayers: linkLoader(second(relations.GetPlayerSeasons), func(l types.PlayerSeason) (int, int) {
+			return l.SeasonYear, l.PlayerID
+		}),
+		playerGames: linkLoader(first(relations.GetPlayerGames), func(l types.PlayerGame) (int, int) { return l.PlayerID, l.GameID }),
+		gamePlayers: linkLoader(second(relations.GetPlayerGames), func(l types.PlayerGame) (int, int) { return l.GameID, l.PlayerID }),
+		teamGames:   linkLoader(first(relations.GetTeamGames), func(l types.TeamGame) (int, int) { return l.TeamID, l.GameID }),
+		seasonGames: linkLoader(second(relations.GetGameSeasons), func(l types.GameSeason) (int, int) { return l.SeasonYear, l.GameID }),
+		teamSeasons: linkLoader(first(relations.GetTeamSeasons), func(l types.TeamSeason) (int, types.TeamSeason) {
+			return l.TeamID, l
+		}),
+		seasonTeams: linkLoader(second(relations.GetTeamSeasons), func(l types.TeamSeason) (int, types.TeamSeason) {
+			return l.SeasonYear, l
+		}),
+	}
+}
+
+// shotLoaderKey is the same for every player, team or season asking for the shots of the same
+// filter, filter doesn't have the ids of the group
+func shotLoaderKey(group types.ShotGroup, filter *types.RequestShotParams) string {
+	return string(group) + ":" + filter.Key()
+}
+
+// shotsOf loads the shots matching filter of the ids of group, every id asked for at once is
+// one GetShotsBy
+func (l *loaders) shotsOf(group types.ShotGroup, filter *types.RequestShotParams) *dataloader.Loader[int, []types.ReturnShot] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := shotLoaderKey(group, filter)
+	if loader, ok := l.shotLists[key]; ok {
+		return loader
+	}
+	loader := dataloader.NewBatchedLoader(func(ctx context.Context, ids []int) []*dataloader.Result[[]types.ReturnShot] {
+		args := *filter
+		*group.IDs(&args) = ids
+		results := make([]*dataloader.Result[[]types.ReturnShot], len(ids))
+		shots, err := l.shots.GetShotsBy(ctx, &args, group)
+		returned := 0
+		for i, id := range ids {
+			results[i] = &dataloader.Result[[]types.ReturnShot]{Data: shots[id], Error: err}
+			returned += len(shots[id])
+		}
+		if err == nil {
+			l.logShots(&args, returned)
+		}
+		return results
+	}, dataloader.WithWait[int, []types.ReturnShot](loaderWait))
+	l.shotLists[key] = loader
+	return loader
+}
+
+// summariesOf is shotsOf for the summaries, one GetShotSummariesBy per batch
+func (l *loaders) summariesOf(group types.ShotGroup, filter *types.RequestShotParams) *dataloader.Loader[int, *types.ShotSummary] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := shotLoaderKey(group, filter)
+	if loader, ok := l.shotSummaries[key]; ok {
+		return loader
+	}
+	loader := dataloader.NewBatchedLoader(func(ctx context.Context, ids []int) []*dataloader.Result[*types.ShotSummary] {
+		args := *filter
+		*group.IDs(&args) = ids
+		results := make([]*dataloader.Result[*types.ShotSummary], len(ids))
+		summaries, err := l.shots.GetShotSummariesBy(ctx, &args, group)
+		for i, id := range ids {
+			summary := summaries[id]
+			if summary == nil {
+				summary = &types.ShotSummary{Zones: []types.ZoneAggregates{}}
+			}
+			results[i] = &dataloader.Result[*types.ShotSummary]{Data: summary, Error: err}
+		}
+		return results
+	}, dataloader.WithWait[int, *types.ShotSummary](loaderWait))
+	l.shotSummaries[key] = loader
+	return loader
+}
+
+// withLoaders gives the request its own loaders, nothing is cached between requests
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+// rowLoader loads rows by id, an id without a row fails with errNotFound
+func rowLoader[V any](name string, load func(context.Context, []int) ([]V, error), id func(V) int) *dataloader.Loader[int, *V] {
+	return dataloader.NewBatchedLoader(func(ctx context.Context, ids []int) []*dataloader.Result[*V] {
+		results := make([]*dataloader.Result[*V], len(ids))
+		rows, err := load(ctx, ids)
+		if err != nil {
+			for i := range results {
+				results[i] = &dataloader.Result[*V]{Error: err}
+			}
+			return results
+		}
+
+		byID := make(map[int]*V, len(rows))
+		for i := range rows {
+			byID[id(rows[i])] = &rows[i]
+		}
+		for i, key := range ids {
+			row, ok := byID[key]
+			if !ok {
+				results[i] = &dataloader.Result[*V]{Error: fmt.Errorf("%s %d: %w", name, key, errNotFound)}
+				continue
+			}
+			results[i] = &dataloader.Result[*V]{Data: row}
+		}
+		return results
+	}, dataloader.WithWait[int, *V](loaderWait))
+}
+
+// linkLoader loads the rows of a link table by one of its ids, link splits a row into that id
+// and what the loader returns for it
+func linkLoader[R, V any](load func(context.Context, []int) ([]R, error), link func(R) (int, V)) *dataloader.Loader[int, []V] {
+	return dataloader.NewBatchedLoader(func(ctx context.Context, ids []int) []*dataloader.Result[[]V] {
+		results := make([]*dataloader.Result[[]V], len(ids))
+		rows, err := load(ctx, ids)
+		if err != nil {
+			for i := range results {
+				results[i] = &dataloader.Result[[]V]{Error: err}
+			}
+			return results
+		}
+
+		byID := make(map[int][]V, len(ids))
+		for _, row := range rows {
+			id, v := link(row)
+			byID[id] = append(byID[id], v)
+		}
+		for i, key := range ids {
+			results[i] = &dataloader.Result[[]V]{Data: byID[key]}
+		}
+		return results
+	}, dataloader.WithWait[int, []V](loaderWait))
+}
+
+// first and second look up the rows of a link table by its first or second id
+func first[R any](load func(context.Context, []int, []int) ([]R, error)) func(context.Context, []int) ([]R, error) {
+	return func(ctx context.Context, ids []int) ([]R, error) {
+		return load(ctx, ids, nil)
+	}
+}
+
+func second[R any](load func(context.Context, []int, []int) ([]R, error)) func(context.Context, []int) ([]R, error) {
+	return func(ctx context.Context, ids []int) ([]R, error) {
+		return load(ctx, nil, ids)
+	}
+}
+
+// loadAll loads the rows of the ids a link loader found for id
+func loadAll[V any](ctx context.Context, links *dataloader.Loader[int, []int], rows *dataloader.Loader[int, *V], id int) ([]*V, error) {
+	ids, err := links.Load(ctx, id)()
+	if err != nil {
+		return nil, err
+	}
+	found, errs := rows.LoadMany(ctx, ids)()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,525 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"nba-shots/internal/types"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// the spec is served at openAPIPath and browsed with the swagger ui at docsPath
+const (
+	openAPIPath = "/openapi.json"
+	docsPath    = "/docs/"
+)
+
+// apiParam is a path or query parameter of an operation
+type apiParam struct {
+	name        string
+	in          string
+	description string
+	schema      map[string]any
+	required    bool
+}
+
+// apiOperation documents a route, the schemas of its responses are read from the types
+type apiOperation struct {
+	summary string
+	params  []apiParam
+	// a value of the request body's type, nil when there's no body
+	request any
+	// a value of the response body's type, nil when there's no body
+	response any
+	// the status of a successful response, 200 when unset
+	status int
+	// the response has an ETag and answers If-None-Match with a 304
+	validated bool
+	// the response is also sent as shots.proto to clients that accept it
+	protobuf bool
+	admin    bool
+	// the media type of a body that isn't json
+	contentType string
+	// the body is wrapped in an Envelope and errors in an ErrorEnvelope, as /v2 does
+	envelope bool
+	// an unversioned alias of /v1, see Deprecated
+	deprecated bool
+}
+
+var (
+	integerSchema = map[string]any{"type": "integer"}
+	stringSchema  = map[string]any{"type": "string"}
+	// the comma separated lists of ids ShotCtx splits
+	idsSchema  = map[string]any{"type": "array", "items": integerSchema}
+	dateSchema = map[string]any{"type": "string", "format": "date"}
+	// M:SS left in the quarter
+	clockSchema = map[string]any{"type": "string", "pattern": `^(0?[0-9]|1[0-2]):[0-5][0-9]$`, "example": "2:00"}
+)
+
+func pathParam(name, description string) apiParam {
+	return apiParam{name: name, in: "path", description: description, schema: integerSchema, required: true}
+}
+
+// shotParams are the filters ShotCtx parses, every one is optional
+var shotParams = []apiParam{
+	{name: "player_id", in: "query", description: "players who took the shots", schema: idsSchema},
+	{name: "team_id", in: "query", description: "teams that took the shots", schema: idsSchema},
+	{name: "season", in: "query", description: "seasons by the year they ended in, 2016 for 2015-16", schema: idsSchema},
+	{name: "opposing_team_id", in: "query", description: "teams the shots were taken against", schema: idsSchema},
+	{name: "start_game_date", in: "query", description: "first game date, inclusive", schema: dateSchema},
+	{name: "end_game_date", in: "query", description: "last game date, inclusive", schema: dateSchema},
+	{name: "game_location", in: "query", description: "where the shooting team played", schema: map[string]any{"type": "string", "enum": []string{"home", "away"}}},
+	{name: "quarter", in: "query", description: "quarters, 5 and up are overtimes", schema: idsSchema},
+	{name: "start_time_left", in: "query", description: "time left in the quarter the shots start at", schema: clockSchema},
+	{name: "end_time_left", in: "query", description: "time left in the quarter the shots end at", schema: clockSchema},
+}
+
+// the operations are keyed by method and path, the versioned ones by their path in the version.
+// TestOpenAPI fails when a route is missing from them or reads parameters they don't list.
+var (
+	// apiOperations documents the routes outside the versions
+	apiOperations = map[string]apiOperation{
+		"GET /": {
+			summary:     "These docs as a list of routes",
+			contentType: "text/html",
+		},
+		"GET " + openAPIPath: {
+			summary:  "This OpenAPI document",
+			response: map[string]any{},
+		},
+		"GET /health": {
+			summary:  "Database connection pool stats",
+			response: map[string]string{},
+		},
+		"POST " + graphQLPath: {
+			summary:  "A GraphQL query over the players, teams, seasons, games and shots, see internal/server/schema.graphql",
+			request:  graphQLRequest{},
+			response: graphQLResponse{},
+		},
+	}
+	// v1Operations documents /v1, the unversioned routes are its deprecated aliases
+	v1Operations = map[string]apiOperation{
+		"GET /player/{playerID}/": {
+			summary:   "A player by id",
+			params:    []apiParam{pathParam("playerID", "")},
+			response:  PlayerResponse{},
+			validated: true,
+		},
+		"GET /player/": {
+			summary:   "Players whose name contains the search",
+			params:    []apiParam{{name: "name", in: "query", schema: stringSchema, required: true}},
+			response:  []PlayerResponse{},
+			validated: true,
+		},
+		"GET /player/multi": {
+			summary:   "Players by id",
+			params:    []apiParam{{name: "player_id", in: "query", schema: idsSchema, required: true}},
+			response:  []PlayerResponse{},
+			validated: true,
+		},
+		"GET /team/{teamID}/": {
+			summary: "A team by id",
+			params: []apiParam{
+				pathParam("teamID", ""),
+				{name: "season", in: "query", description: "send the name and abbreviation the team used that season", schema: integerSchema},
+			},
+			response:  TeamResponse{},
+			validated: true,
+		},
+		"GET /team/all": {
+			summary:   "Every team",
+			response:  []TeamResponse{},
+			validated: true,
+		},
+		"GET /season/{year}/": {
+			summary:   "A season by the year it ended in, 2016 for 2015-16",
+			params:    []apiParam{pathParam("year", "")},
+			response:  SeasonResponse{},
+			validated: true,
+		},
+		"GET /season/all": {
+			summary:   "Every season",
+			response:  []SeasonResponse{},
+			validated: true,
+		},
+		"GET /game/{gameID}/": {
+			summary:   "A game by id",
+			params:    []apiParam{pathParam("gameID", "")},
+			response:  GameResponse{},
+			validated: true,
+		},
+		"GET /game/last/{numGames}": {
+			summary:   "The latest games",
+			params:    []apiParam{pathParam("numGames", "how many games")},
+			response:  []GameResponse{},
+			validated: true,
+		},
+		"GET /shots/": {
+			summary:   "The shots that match the filters and their aggregates",
+			params:    shotParams,
+			response:  ShotResponse{},
+			validated: true,
+			protobuf:  true,
+		},
+		"GET /shots/aggregates": {
+			summary:   "The aggregates by zone of the shots that match the filters, without the shots",
+			params:    shotParams,
+			response:  ShotSummaryResponse{},
+			validated: true,
+		},
+		"GET /admin/refresh": {
+			summary:  "The latest runs of ingest watch",
+			params:   []apiParam{{name: "limit", in: "query", description: "how many runs, up to 200", schema: map[string]any{"type": "integer", "minimum": 1, "maximum": maxRefreshRuns, "default": defaultRefreshRuns}}},
+			response: []RefreshRunResponse{},
+			admin:    true,
+		},
+		"POST /admin/cache/invalidate": {
+			summary: "Drop the cached results",
+			status:  http.StatusNoContent,
+			admin:   true,
+		},
+	}
+	// v2Operations documents /v2, its responses are wrapped in an Envelope
+	v2Operations = map[string]apiOperation{
+		"GET /player/{playerID}/": {
+			summary:   "A player by id",
+			params:    []apiParam{pathParam("playerID", "")},
+			response:  types.Player{},
+			validated: true,
+		},
+		"GET /player/": {
+			summary:   "Players whose name contains the search",
+			params:    []apiParam{{name: "name", in: "query", schema: stringSchema, required: true}},
+			response:  []types.Player{},
+			validated: true,
+		},
+		"GET /player/multi": {
+			summary:   "Players by id",
+			params:    []apiParam{{name: "player_id", in: "query", schema: idsSchema, required: true}},
+			response:  []types.Player{},
+			validated: true,
+		},
+		"GET /team/{teamID}/": {
+			summary: "A team by id",
+			params: []apiParam{
+				pathParam("teamID", ""),
+				{name: "season", in: "query", description: "send the name and abbreviation the team used that season", schema: integerSchema},
+			},
+			response:  types.Team{},
+			validated: true,
+		},
+		"GET /team/all": {
+			summary:   "Every team",
+			response:  []types.Team{},
+			validated: true,
+		},
+		"GET /season/{year}/": {
+			summary:   "A season by the year it ended in, 2016 for 2015-16",
+			params:    []apiParam{pathParam("year", "")},
+			response:  SeasonV2{},
+			validated: true,
+		},
+		"GET /season/all": {
+			summary:   "Every season",
+			response:  []SeasonV2{},
+			validated: true,
+		},
+		"GET /game/{gameID}/": {
+			summary:   "A game by id",
+			params:    []apiParam{pathParam("gameID", "")},
+			response:  GameV2{},
+			validated: true,
+		},
+		"GET /game/last/{numGames}": {
+			summary:   "The latest games",
+			params:    []apiParam{pathParam("numGames", "how many games")},
+			response:  []GameV2{},
+			validated: true,
+		},
+		"GET /shots/": {
+			summary:   "The shots that match the filters and their aggregates",
+			params:    shotParams,
+			response:  ShotsV2{},
+			validated: true,
+			protobuf:  true,
+		},
+		"GET /shots/aggregates": {
+			summary:   "The aggregates by zone of the shots that match the filters, without the shots",
+			params:    shotParams,
+			response:  ShotSummaryV2{},
+			validated: true,
+		},
+		"GET /admin/refresh": {
+			summary:  "The latest runs of ingest watch",
+			params:   []apiParam{{name: "limit", in: "query", description: "how many runs, up to 200", schema: map[string]any{"type": "integer", "minimum": 1, "maximum": maxRefreshRuns, "default": defaultRefreshRuns}}},
+			response: []types.RefreshRun{},
+			admin:    true,
+		},
+		"POST /admin/cache/invalidate": {
+			summary: "Drop the cached results",
+			status:  http.StatusNoContent,
+			admin:   true,
+		},
+	}
+)
+
+// operationFor finds the docs of a route, the unversioned aliases of /v1 are deprecated
+func operationFor(method, route string) (apiOperation, bool) {
+	if path, ok := strings.CutPrefix(route, "/v1/"); ok {
+		op, ok := v1Operations[method+" /"+path]
+		return op, ok
+	}
+	if path, ok := strings.CutPrefix(route, "/v2/"); ok {
+		op, ok := v2Operations[method+" /"+path]
+		op.envelope = true
+		return op, ok
+	}
+	if op, ok := apiOperations[method+" "+route]; ok {
+		return op, true
+	}
+	op, ok := v1Operations[method+" "+route]
+	op.deprecated = true
+	return op, ok
+}
+
+// specPath is the path of a route in the spec, middleware.URLFormat strips the extension of
+// the requests so /openapi.json is routed as /openapi
+func specPath(route string) string {
+	if route == strings.TrimSuffix(openAPIPath, ".json") {
+		return openAPIPath
+	}
+	return route
+}
+
+// openAPISpec documents the routes of r with apiOperations, routes it doesn't have are left out
+func openAPISpec(r chi.Routes) ([]byte, error) {
+	schemas := map[string]any{}
+	paths := map[string]map[string]any{}
+	var missing []string
+
+	err := chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		// the swagger ui
+		if strings.HasPrefix(route, strings.TrimSuffix(docsPath, "/")) {
+			return nil
+		}
+		route = specPath(route)
+		op, ok := operationFor(method, route)
+		if !ok {
+			missing = append(missing, method+" "+route)
+			return nil
+		}
+		if paths[route] == nil {
+			paths[route] = map[string]any{}
+		}
+		paths[route][strings.ToLower(method)] = op.spec(schemas)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		log.Printf("routes missing from the OpenAPI spec: %s", strings.Join(missing, ", "))
+	}
+
+	// every operation can fail with one
+	schemaOf(reflect.TypeOf(ErrResponse{}), schemas)
+	schemaOf(reflect.TypeOf(ErrorEnvelope{}), schemas)
+	return json.MarshalIndent(map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "nba-shots",
+			"description": "Query the shots of every NBA game since 2004 by player, team, season, opponent, date, location and game time.",
+			"version":     "2",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"adminToken": map[string]any{"type": "http", "scheme": "bearer", "description": "the ADMIN_TOKEN, the admin routes are 404s without one"},
+			},
+		},
+	}, "", "  ")
+}
+
+func (op apiOperation) spec(schemas map[string]any) map[string]any {
+	status := op.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	success := map[string]any{"description": http.StatusText(status)}
+	switch {
+	case op.contentType != "":
+		success["content"] = map[string]any{op.contentType: map[string]any{"schema": stringSchema}}
+	case op.response != nil:
+		schema := schemaOf(reflect.TypeOf(op.response), schemas)
+		if op.envelope {
+			schema = map[string]any{"type": "object", "properties": map[string]any{"data": schema}, "required": []string{"data"}}
+		}
+		content := map[string]any{"application/json": map[string]any{"schema": schema}}
+		if op.protobuf {
+			content[protobufContentType] = map[string]any{
+				"schema": map[string]any{
+					"type":        "string",
+					"format":      "binary",
+					"description": "a " + shotsMessageType + " message, see proto/shots/v1/shots.proto",
+				},
+			}
+		}
+		success["content"] = content
+	}
+
+	errSchema := ref("ErrResponse")
+	if op.envelope {
+		errSchema = ref("ErrorEnvelope")
+	}
+	responses := map[string]any{
+		fmt.Sprint(status): success,
+		"default": map[string]any{
+			"description": "Error",
+			"content":     map[string]any{"application/json": map[string]any{"schema": errSchema}},
+		},
+	}
+	if op.validated {
+		success["headers"] = map[string]any{
+			"ETag":          map[string]any{"schema": stringSchema, "description": "send it back as If-None-Match to revalidate"},
+			"Cache-Control": map[string]any{"schema": stringSchema},
+		}
+		responses[fmt.Sprint(http.StatusNotModified)] = map[string]any{"description": "The If-None-Match ETag is still current"}
+	}
+
+	spec := map[string]any{"summary": op.summary, "responses": responses}
+	if len(op.params) > 0 {
+		params := make([]map[string]any, 0, len(op.params))
+		for _, p := range op.params {
+			param := map[string]any{"name": p.name, "in": p.in, "required": p.required, "schema": p.schema}
+			if p.description != "" {
+				param["description"] = p.description
+			}
+			// lists are sent comma separated, player_id=1,2
+			if p.schema["type"] == "array" {
+				param["style"] = "form"
+				param["explode"] = false
+			}
+			params = append(params, param)
+		}
+		spec["parameters"] = params
+	}
+	if op.request != nil {
+		spec["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": schemaOf(reflect.TypeOf(op.request), schemas)}},
+		}
+	}
+	if op.admin {
+		spec["security"] = []map[string][]string{{"adminToken": {}}}
+	}
+	if op.deprecated {
+		spec["deprecated"] = true
+		spec["description"] = fmt.Sprintf("Served until %s, use the same route under /v1. The responses have Deprecation, Sunset and successor-version Link headers.", unversionedSunset.Format(time.DateOnly))
+	}
+	return spec
+}
+
+func ref(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaOf describes how encoding/json writes a value of type t, structs are added to schemas
+// and referenced by their name
+func schemaOf(t reflect.Type, schemas map[string]any) map[string]any {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem(), schemas)
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return integerSchema
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return stringSchema
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		if t.Elem().Kind() == reflect.Interface {
+			return map[string]any{"type": "object"}
+		}
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		if _, ok := schemas[t.Name()]; !ok {
+			// a placeholder first, for the types that refer to themselves
+			schemas[t.Name()] = nil
+			properties, required := map[string]any{}, []string{}
+			addFields(t, properties, &required, schemas)
+			schema := map[string]any{"type": "object", "properties": properties}
+			if len(required) > 0 {
+				slices.Sort(required)
+				schema["required"] = required
+			}
+			schemas[t.Name()] = schema
+		}
+		return ref(t.Name())
+	}
+	return map[string]any{}
+}
+
+// addFields adds the json fields of struct t, the ones of embedded structs are promoted like
+// encoding/json does
+func addFields(t reflect.Type, properties map[string]any, required *[]string, schemas map[string]any) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			addFields(fieldType, properties, required, schemas)
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		schema := schemaOf(field.Type, schemas)
+		// a nil pointer is written as null
+		if field.Type.Kind() == reflect.Pointer {
+			if _, isRef := schema["$ref"]; isRef {
+				schema = map[string]any{"allOf": []any{schema}, "nullable": true}
+			} else {
+				schema = maps.Clone(schema)
+				schema["nullable"] = true
+			}
+		}
+		properties[name] = schema
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func (s *Server) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(s.OpenAPI)
+}
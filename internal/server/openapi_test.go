@@ -0,0 +1,235 @@
+package server
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"os"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// readParams finds the query parameters read with r.URL.Query().Get and the path ones read with
+// chi.URLParam by every function of the package, closures count towards the function they're in
+// and so do the functions of the package they call
+func readParams(t *testing.T) map[string][]string {
+	t.Helper()
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reads := map[string][]string{}
+	calls := map[string][]string{}
+	for _, file := range pkgs["server"].Files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			ast.Inspect(fn, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				switch fun := call.Fun.(type) {
+				case *ast.Ident:
+					calls[fn.Name.Name] = append(calls[fn.Name.Name], fun.Name)
+				case *ast.SelectorExpr:
+					calls[fn.Name.Name] = append(calls[fn.Name.Name], fun.Sel.Name)
+				}
+				if len(call.Args) == 0 {
+					return true
+				}
+				name, err := strconv.Unquote(literal(call.Args[len(call.Args)-1]))
+				if err != nil {
+					return true
+				}
+				sel, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				switch {
+				case sel.Sel.Name == "Get" && isCall(sel.X, "Query"):
+					reads[fn.Name.Name] = append(reads[fn.Name.Name], "query "+name)
+				case sel.Sel.Name == "URLParam" && isIdent(sel.X, "chi"):
+					reads[fn.Name.Name] = append(reads[fn.Name.Name], "path "+name)
+				}
+				return true
+			})
+		}
+	}
+
+	var resolve func(name string, seen map[string]bool) []string
+	resolve = func(name string, seen map[string]bool) []string {
+		if seen[name] {
+			return nil
+		}
+		seen[name] = true
+		read := reads[name]
+		for _, callee := range calls[name] {
+			read = append(read, resolve(callee, seen)...)
+		}
+		return read
+	}
+	resolved := map[string][]string{}
+	for name := range calls {
+		resolved[name] = resolve(name, map[string]bool{})
+	}
+	return resolved
+}
+
+func literal(e ast.Expr) string {
+	if lit, ok := e.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+		return lit.Value
+	}
+	return ""
+}
+
+func isCall(e ast.Expr, method string) bool {
+	call, ok := e.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == method
+}
+
+func isIdent(e ast.Expr, name string) bool {
+	ident, ok := e.(*ast.Ident)
+	return ok && ident.Name == name
+}
+
+// funcName is the name of the function declared in this package that f is, or is a closure
+// of, "" for functions of other packages
+func funcName(f any) string {
+	name := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+	name, ok := strings.CutPrefix(name, "nba-shots/internal/server.")
+	if !ok {
+		return ""
+	}
+	name = strings.TrimPrefix(name, "(*Server).")
+	name, _, _ = strings.Cut(name, ".")
+	return strings.TrimSuffix(name, "-fm")
+}
+
+// TestOpenAPI fails when a route isn't in the spec, or when the parameters its handler and
+// middlewares read aren't the ones the spec lists
+func TestOpenAPI(t *testing.T) {
+	s := &Server{}
+	r := s.RegisterRoutes().(chi.Routes)
+	reads := readParams(t)
+
+	documented := map[string]bool{}
+	err := chi.Walk(r, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, strings.TrimSuffix(docsPath, "/")) {
+			return nil
+		}
+		key := method + " " + specPath(route)
+		op, ok := operationFor(method, specPath(route))
+		if !ok {
+			t.Errorf("%s isn't documented", key)
+			return nil
+		}
+		documented[key] = true
+
+		var read []string
+		for _, f := range append([]any{handler}, anySlice(middlewares)...) {
+			read = append(read, reads[funcName(f)]...)
+		}
+		var listed []string
+		for _, p := range op.params {
+			listed = append(listed, p.in+" "+p.name)
+		}
+		slices.Sort(read)
+		slices.Sort(listed)
+		if !slices.Equal(slices.Compact(read), listed) {
+			t.Errorf("%s reads %v, the spec lists %v", key, read, listed)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for tables, prefixes := range map[*map[string]apiOperation][]string{
+		&apiOperations: {""},
+		// the unversioned aliases too
+		&v1Operations: {"/v1", ""},
+		&v2Operations: {"/v2"},
+	} {
+		for key := range *tables {
+			method, path, _ := strings.Cut(key, " ")
+			for _, prefix := range prefixes {
+				if !documented[method+" "+prefix+path] {
+					t.Errorf("%s %s%s is documented but isn't a route", method, prefix, path)
+				}
+			}
+		}
+	}
+
+	var spec struct {
+		OpenAPI string                               `json:"openapi"`
+		Paths   map[string]map[string]map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(s.OpenAPI, &spec); err != nil {
+		t.Fatalf("could not decode the spec: %v", err)
+	}
+	if spec.OpenAPI != "3.0.3" || spec.Paths["/v1/shots/"]["get"]["parameters"] == nil {
+		t.Errorf("expected the shot parameters in the spec, got %+v", spec.Paths["/v1/shots/"])
+	}
+	if spec.Paths["/shots/"]["get"]["deprecated"] != true || spec.Paths["/v1/shots/"]["get"]["deprecated"] != nil {
+		t.Errorf("expected only the unversioned alias to be deprecated, got %+v and %+v", spec.Paths["/shots/"], spec.Paths["/v1/shots/"])
+	}
+}
+
+func anySlice[T any](values []T) []any {
+	out := make([]any, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	handler := newTestServer(t)
+
+	var spec map[string]any
+	if code := get(t, handler, openAPIPath, &spec); code != http.StatusOK || spec["paths"] == nil {
+		t.Errorf("expected the spec at %s, got %d", openAPIPath, code)
+	}
+	if code := get(t, handler, docsPath, nil); code != http.StatusOK {
+		t.Errorf("expected the swagger ui at %s, got %d", docsPath, code)
+	}
+}
+
+func TestSchemaOf(t *testing.T) {
+	schemas := map[string]any{}
+	schemaOf(reflect.TypeOf(RefreshRunResponse{}), schemas)
+	schemaOf(reflect.TypeOf(ShotResponse{}), schemas)
+
+	got, _ := json.Marshal(schemas)
+	for _, want := range []string{
+		// the embedded *types.RefreshRun's fields, finished_at is a pointer
+		`"finished_at":{"format":"date-time","nullable":true,"type":"string"}`,
+		// omitempty
+		`"required":["finished_at","games_added","id","shots_added","sources","started_at","status"]`,
+		// promoted from types.ShotAggregates
+		`"total_made_shots":{"format":"int64","type":"integer"}`,
+		`"teams":{"items":{"$ref":"#/components/schemas/TeamIdentity"},"type":"array"}`,
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("expected the schemas to contain %s, got %s", want, got)
+		}
+	}
+}
@@ -49,10 +49,10 @@ func (s *Server) getPlayerByIDHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 2 - send the playerID to the db service to get the player
-	player, err := s.db.GetPlayerByID(playerId)
+	player, err := s.catalog.GetPlayerByID(r.Context(), playerId)
 
 	if err != nil {
-		render.Render(w, r, ErrInternalServer(err))
+		render.Render(w, r, ErrQuery(r, err))
 		return
 	}
 	log.Println("Player received from db: ", player.Name)
@@ -74,10 +74,10 @@ func (s *Server) getPlayerByNameHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	players, err := s.db.GetPlayersByName(playerName)
+	players, err := s.catalog.GetPlayersByName(r.Context(), playerName)
 
 	if err != nil {
-		render.Render(w, r, ErrInternalServer(err))
+		render.Render(w, r, ErrQuery(r, err))
 		return
 	}
 
@@ -106,10 +106,10 @@ func (s *Server) GetPlayersByIDsHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// 2 - send the playerID to the db service to get the player
-	players, err := s.db.GetPlayersByIDs(playerIds)
+	players, err := s.catalog.GetPlayersByIDs(r.Context(), playerIds)
 
 	if err != nil {
-		render.Render(w, r, ErrInternalServer(err))
+		render.Render(w, r, ErrQuery(r, err))
 		return
 	}
 	log.Println("Players received from db: ", players)
@@ -5,12 +5,14 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/go-chi/docgen"
 	"github.com/go-chi/render"
+	"github.com/swaggest/swgui/v5emb"
 	"github.com/yuin/goldmark"
 )
 
@@ -20,8 +22,10 @@ func (s *Server) RegisterRoutes() http.Handler {
 	r.Use(middleware.RequestID)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
+	r.Use(Compress)
 	r.Use(middleware.URLFormat)
 	r.Use(render.SetContentType(render.ContentTypeJSON))
+	r.Use(s.QueryTimeout)
 
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
@@ -33,7 +37,57 @@ func (s *Server) RegisterRoutes() http.Handler {
 
 	r.Get("/health", s.healthHandler)
 
+	s.graphQL = newGraphQLSchema(s)
+	r.Post(graphQLPath, s.graphQLHandler)
+
+	r.Route("/v1", func(r chi.Router) {
+		r.Use(APIVersion(1))
+		s.v1Routes(r)
+	})
+	r.Route("/v2", func(r chi.Router) {
+		r.Use(APIVersion(2))
+		s.v2Routes(r)
+	})
+	// the routes from before the versions, kept until unversionedSunset
+	r.Group(func(r chi.Router) {
+		r.Use(APIVersion(1))
+		r.Use(Deprecated(unversionedDeprecation, unversionedSunset, "/v1"))
+		s.v1Routes(r)
+	})
+
+	markdownDoc := docgen.MarkdownRoutesDoc(r, docgen.MarkdownOpts{
+		ProjectPath: "github.com/lukamircetic/nba-shots",
+		Intro:       "Welcome to the nba-shots GO api docs",
+	})
+
+	var htmlBuffer bytes.Buffer
+	err := goldmark.Convert([]byte(markdownDoc), &htmlBuffer)
+
+	if err != nil {
+		log.Println("Unable to convert docs into hmtl", err)
+	}
+
+	s.APIDocs = htmlBuffer.Bytes()
+
+	r.Get("/", s.rootHandler)
+	// middleware.URLFormat routes /openapi.json as /openapi
+	r.Get(strings.TrimSuffix(openAPIPath, ".json"), s.openAPIHandler)
+	r.Handle(docsPath+"*", v5emb.New("nba-shots api", openAPIPath, docsPath))
+	r.Get(strings.TrimSuffix(docsPath, "/"), http.RedirectHandler(docsPath, http.StatusMovedPermanently).ServeHTTP)
+
+	s.OpenAPI, err = openAPISpec(r)
+	if err != nil {
+		log.Println("Unable to generate the OpenAPI spec", err)
+	}
+
+	return r
+}
+
+// v1Routes are the routes of the first version, the responses keep the shapes the frontend was
+// written against
+func (s *Server) v1Routes(r chi.Router) {
 	r.Route("/player", func(r chi.Router) {
+		r.Use(s.Validated(staticPolicy(cacheCatalog)))
 		r.Route("/{playerID}", func(r chi.Router) {
 			r.Get("/", s.getPlayerByIDHandler)
 		})
@@ -42,6 +96,7 @@ func (s *Server) RegisterRoutes() http.Handler {
 	})
 
 	r.Route("/team", func(r chi.Router) {
+		r.Use(s.Validated(staticPolicy(cacheCatalog)))
 		r.Route("/{teamID}", func(r chi.Router) {
 			r.Get("/", s.getTeamByIDHandler)
 		})
@@ -49,6 +104,7 @@ func (s *Server) RegisterRoutes() http.Handler {
 	})
 
 	r.Route("/season", func(r chi.Router) {
+		r.Use(s.Validated(staticPolicy(cacheCatalog)))
 		r.Route("/{year}", func(r chi.Router) {
 			r.Get("/", s.getSeasonByYearHandler)
 		})
@@ -57,33 +113,25 @@ func (s *Server) RegisterRoutes() http.Handler {
 
 	r.Route("/game", func(r chi.Router) {
 		r.Route("/{gameID}", func(r chi.Router) {
+			r.Use(s.Validated(staticPolicy(cacheCatalog)))
 			r.Get("/", s.getGameByIDHandler)
 		})
-		r.Get("/last/{numGames}", s.getLastXGamesHandler)
+		// the latest games change with every refresh
+		r.With(s.Validated(staticPolicy(cacheRevalidate))).Get("/last/{numGames}", s.getLastXGamesHandler)
 	})
 
 	r.Route("/shots", func(r chi.Router) {
 		r.Use(ShotCtx)
+		r.Use(s.Validated(s.shotsCachePolicy))
 		r.Get("/", s.getShotsHandler)
+		r.Get("/aggregates", s.getShotAggregatesHandler)
 	})
 
-	markdownDoc := docgen.MarkdownRoutesDoc(r, docgen.MarkdownOpts{
-		ProjectPath: "github.com/lukamircetic/nba-shots",
-		Intro:       "Welcome to the nba-shots GO api docs",
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(s.AdminOnly)
+		r.Get("/refresh", s.getRefreshRunsHandler)
+		r.Post("/cache/invalidate", s.invalidateCacheHandler)
 	})
-
-	var htmlBuffer bytes.Buffer
-	err := goldmark.Convert([]byte(markdownDoc), &htmlBuffer)
-
-	if err != nil {
-		log.Println("Unable to convert docs into hmtl", err)
-	}
-
-	s.APIDocs = htmlBuffer.Bytes()
-
-	r.Get("/", s.rootHandler)
-
-	return r
 }
 
 func (s *Server) rootHandler(w http.ResponseWriter, r *http.Request) {
@@ -93,6 +141,6 @@ func (s *Server) rootHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
-	jsonResp, _ := json.Marshal(s.db.Health())
+	jsonResp, _ := json.Marshal(s.health.Health(r.Context()))
 	_, _ = w.Write(jsonResp)
 }
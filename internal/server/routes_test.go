@@ -1,9 +1,13 @@
 package server
 
 import (
+	"context"
+	"nba-shots/internal/database"
+	"nba-shots/internal/types"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestHandler(t *testing.T) {
@@ -20,3 +24,33 @@ func TestHandler(t *testing.T) {
 		t.Errorf("expected status OK; got %v", resp.Status)
 	}
 }
+
+// slowDB never answers, its queries only end when their context does
+type slowDB struct {
+	database.CatalogReader
+}
+
+func (db *slowDB) GetAllSeasons(ctx context.Context) ([]types.Season, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestQueryCancellation(t *testing.T) {
+	s := &Server{catalog: &slowDB{}, queryTimeout: 10 * time.Millisecond}
+	handler := s.QueryTimeout(http.HandlerFunc(s.getAllSeasonsHandler))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/season/all", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected a query past the timeout to be a 504, got %d", w.Code)
+	}
+
+	// the client goes away before the query finishes
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/season/all", nil).WithContext(ctx))
+	if w.Code != StatusClientClosedRequest {
+		t.Errorf("expected a cancelled request to be a 499, got %d", w.Code)
+	}
+}
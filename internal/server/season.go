@@ -46,10 +46,10 @@ func (s *Server) getSeasonByYearHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// 2 - send the seasonID to the db service to get the season
-	season, err := s.db.GetSeasonByYear(year)
+	season, err := s.catalog.GetSeasonByYear(r.Context(), year)
 
 	if err != nil {
-		render.Render(w, r, ErrInternalServer(err))
+		render.Render(w, r, ErrQuery(r, err))
 		return
 	}
 	log.Println("Season received from db: ", season.SeasonYears)
@@ -63,10 +63,10 @@ func (s *Server) getSeasonByYearHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func (s *Server) getAllSeasonsHandler(w http.ResponseWriter, r *http.Request) {
-	seasons, err := s.db.GetAllSeasons()
+	seasons, err := s.catalog.GetAllSeasons(r.Context())
 
 	if err != nil {
-		render.Render(w, r, ErrInternalServer(err))
+		render.Render(w, r, ErrQuery(r, err))
 		return
 	}
 
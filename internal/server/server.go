@@ -1,30 +1,68 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"net/http"
-	"os"
-	"strconv"
 	"time"
 
-	_ "github.com/joho/godotenv/autoload"
-
+	"nba-shots/internal/config"
 	"nba-shots/internal/database"
+
+	"github.com/graph-gophers/graphql-go"
 )
 
 type Server struct {
 	port int
 
-	db      database.Service
-	APIDocs []byte
+	shots        database.ShotReader
+	catalog      database.CatalogReader
+	relations    database.RelationReader
+	queryLog     database.QueryLog
+	refreshLog   database.RefreshLog
+	health       database.HealthChecker
+	cache        Invalidator
+	adminToken   string
+	queryTimeout time.Duration
+	APIDocs      []byte
+	OpenAPI      []byte
+	graphQL      *graphql.Schema
+}
+
+// Store is every part of the database the api uses, database.Service and the in-memory
+// demo database both are one
+type Store interface {
+	database.ShotReader
+	database.CatalogReader
+	database.RelationReader
+	database.QueryLog
+	database.RefreshLog
+	database.HealthChecker
+}
+
+// Invalidator is a cache in front of the database, see cache.Service
+type Invalidator interface {
+	Invalidate(context.Context) error
 }
 
-func NewServer() *http.Server {
-	port, _ := strconv.Atoi(os.Getenv("PORT"))
+// NewServer serves db with the settings in cfg, which the caller has validated. The contexts of
+// the requests derive from ctx, cancelling it cancels the database calls they're making.
+func NewServer(ctx context.Context, cfg config.API, db Store) *http.Server {
 	NewServer := &Server{
-		port: port,
+		port: cfg.Port,
 
-		db: database.New(),
+		shots:        db,
+		catalog:      db,
+		relations:    db,
+		queryLog:     db,
+		refreshLog:   db,
+		health:       db,
+		adminToken:   cfg.AdminToken,
+		queryTimeout: cfg.QueryTimeout,
+	}
+	if cache, ok := db.(Invalidator); ok {
+		NewServer.cache = cache
 	}
 
 	// Declare Server config
@@ -34,7 +72,31 @@ func NewServer() *http.Server {
 		IdleTimeout:  time.Minute,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
+		BaseContext:  func(net.Listener) context.Context { return ctx },
 	}
 
 	return server
 }
+
+// Shutdown stops srv and waits for its requests until ctx is done. The ones still running after
+// grace are cancelled with cancelRequests, the cancel of the context NewServer was given, so a
+// long shot query doesn't hold the shutdown up until its timeout.
+func Shutdown(ctx context.Context, srv *http.Server, cancelRequests context.CancelFunc, grace time.Duration) error {
+	timer := time.AfterFunc(grace, cancelRequests)
+	defer timer.Stop()
+	return srv.Shutdown(ctx)
+}
+
+// QueryTimeout puts a deadline on the request's context, the database calls made with it are
+// cancelled once it passes. A timeout of 0 leaves the request without one.
+func (s *Server) QueryTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.queryTimeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), s.queryTimeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"nba-shots/internal/config"
+	"nba-shots/internal/database"
+	"nba-shots/internal/database/memory"
+	"nba-shots/internal/database/servicetest"
+	"nba-shots/internal/types"
+)
+
+// blockingDB is a shot query that runs until its context is cancelled
+type blockingDB struct {
+	database.Service
+	started  chan struct{}
+	returned chan error
+}
+
+func (db *blockingDB) GetShots(ctx context.Context, args *types.RequestShotParams) ([]types.ReturnShot, error) {
+	close(db.started)
+	<-ctx.Done()
+	db.returned <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+func TestShutdownCancelsRequests(t *testing.T) {
+	inner, err := memory.NewWithBatches(servicetest.Fixture())
+	if err != nil {
+		t.Fatalf("could not load the fixture: %v", err)
+	}
+	db := &blockingDB{Service: inner, started: make(chan struct{}), returned: make(chan error, 1)}
+
+	requests, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	srv := NewServer(requests, config.API{}, db)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(listener)
+
+	go http.Get("http://" + listener.Addr().String() + "/shots?player_id=" + strconv.Itoa(servicetest.Curry))
+	select {
+	case <-db.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the shot query never started")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	err = Shutdown(ctx, srv, cancelRequests, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("expected the shutdown to finish once the query was cancelled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the shutdown to take about the grace period, took %v", elapsed)
+	}
+	select {
+	case err := <-db.returned:
+		if err != context.Canceled {
+			t.Errorf("expected the query to be cancelled, got %v", err)
+		}
+	default:
+		t.Error("expected the query to have returned")
+	}
+}
@@ -16,47 +16,25 @@ import (
 
 type shotsContextKey string
 
-type shotAggregates struct {
-	TotalMadeShots   int64 `json:"total_made_shots"`
-	TotalMissedShots int64 `json:"total_missed_shots"`
-	Made2PtShots     int64 `json:"made_2pt_shots"`
-	Missed2PtShots   int64 `json:"missed_2pt_shots"`
-	Made3PtShots     int64 `json:"made_3pt_shots"`
-	Missed3PtShots   int64 `json:"missed_3pt_shots"`
-}
-
 type ReturnShots []types.ReturnShot
 
 type ShotResponse struct {
-	shotAggregates
-	Shots []types.ReturnShot `json:"shots"`
+	types.ShotAggregates
+	Teams []types.TeamIdentity `json:"teams,omitempty"`
+	Shots []types.ReturnShot   `json:"shots"`
 }
 
 const shotArgsKey shotsContextKey = "shotArgs"
 const (
 	MINS_IN_A_QUARTER int    = 12
-	TWO_PT_SHOT       string = "2PT Field Goal"
+	TWO_PT_SHOT       string = types.TwoPtShot
 	THREE_PT_SHOT     string = "3PT Field Goal"
 )
 
-func (s *Server) getShotAggregates(shots *[]types.ReturnShot) shotAggregates {
-	aggs := shotAggregates{}
+func (s *Server) getShotAggregates(shots *[]types.ReturnShot) types.ShotAggregates {
+	aggs := types.ShotAggregates{}
 	for _, s := range *shots {
-		if s.ShotMade {
-			aggs.TotalMadeShots++
-			if s.ShotType == TWO_PT_SHOT {
-				aggs.Made2PtShots++
-			} else {
-				aggs.Made3PtShots++
-			}
-		} else {
-			aggs.TotalMissedShots++
-			if s.ShotType == TWO_PT_SHOT {
-				aggs.Missed2PtShots++
-			} else {
-				aggs.Missed3PtShots++
-			}
-		}
+		aggs.AddShot(s.ShotMade, s.ShotType)
 	}
 	return aggs
 }
@@ -67,45 +45,109 @@ func (s *Server) getShotsHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 1.5 - TODO: validate query args
 
-	// 2 - send the parsed arguments to the db service to get the shots
-	shots, err := s.db.GetShots(queryArgs)
+	// 2 - get the shots and their aggregates
+	resp, err := s.queryShots(r, queryArgs)
+	if err != nil {
+		render.Render(w, r, ErrQuery(r, err))
+		return
+	}
+
+	// 3 - send the shots back to the client, as columns when it asked for protobuf
+	if acceptsProtobuf(r) {
+		if err := renderShotsProto(w, resp); err != nil {
+			log.Printf("could not write the protobuf shots: %v", err)
+		}
+		return
+	}
+	err = render.Render(w, r, resp)
 	if err != nil {
-		render.Render(w, r, ErrInternalServer(err))
+		render.Render(w, r, ErrRender(err))
 		return
 	}
+}
+
+// queryShots gets the shots that match queryArgs with their aggregates, and logs the query
+func (s *Server) queryShots(r *http.Request, queryArgs *types.RequestShotParams) (*ShotResponse, error) {
+	// 2 - send the parsed arguments to the db service to get the shots
+	shots, err := s.shots.GetShots(r.Context(), queryArgs)
+	if err != nil {
+		return nil, err
+	}
 
 	// 2.5 - insert the current query params into the shot history table
-	// essentially i have a table called query_history that i would like to keep a history of requests people have made
-	// each record would contain all of the arguments from queryArgs and the len(shots) of returned shots from above
-	// can i make this run as a go routine so it doesnt slow down the response (whether this fails or not shouldnt affect the user)
+	s.logShotQuery(queryArgs, len(shots))
+
+	// 3 - calc shot stat aggregates
+	shotAggs := s.getShotAggregates(&shots)
+	resp := NewShotResponse(&shotAggs, &shots)
+
+	// 3.5 - include the names the requested teams went by in the requested seasons
+	teamIDs := append(append([]int{}, queryArgs.TeamIDs...), queryArgs.OpposingTeamIds...)
+	if len(teamIDs) > 0 {
+		teams, err := s.catalog.GetTeamIdentities(r.Context(), teamIDs, queryArgs.SeasonYears)
+		if err != nil {
+			return nil, err
+		}
+		resp.Teams = teams
+	}
+	return resp, nil
+}
+
+// logShotQuery keeps a history of the shot queries people have made in query_history, each record
+// has the arguments and the number of shots returned. It runs in a goroutine so it doesn't slow
+// down the response, whether it fails or not shouldn't affect the user.
+func (s *Server) logShotQuery(queryArgs *types.RequestShotParams, returnedShots int) {
 	go func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
 		queryHistoryRecord := &types.QueryHistoryRecord{
 			RequestShotParams: *queryArgs,
-			ReturnedShots:     len(shots),
+			ReturnedShots:     returnedShots,
 		}
 
-		err := s.db.InsertQueryHistory(ctx, queryHistoryRecord)
+		err := s.queryLog.InsertQueryHistory(ctx, queryHistoryRecord)
 
 		if err != nil {
 			log.Printf("Error logging the query history: %v\n", err)
 		}
 	}()
+}
 
-	// 3 - calc shot stat aggregates
-	shotAggs := s.getShotAggregates(&shots)
+// getShotAggregatesHandler returns the aggregates of a shot query by zone without the shots,
+// the player, team and season ones come from the summary tables
+func (s *Server) getShotAggregatesHandler(w http.ResponseWriter, r *http.Request) {
+	queryArgs := r.Context().Value(shotArgsKey).(*types.RequestShotParams)
+
+	summary, err := s.shots.GetShotSummary(r.Context(), queryArgs)
+	if err != nil {
+		render.Render(w, r, ErrQuery(r, err))
+		return
+	}
 
-	// 4 - send the shots back to the client
-	err = render.Render(w, r, NewShotResponse(&shotAggs, &shots))
+	err = render.Render(w, r, NewShotSummaryResponse(summary))
 	if err != nil {
 		render.Render(w, r, ErrRender(err))
 		return
 	}
 }
 
-func NewShotResponse(shotAggs *shotAggregates, shots *[]types.ReturnShot) *ShotResponse {
+type ShotSummaryResponse struct {
+	*types.ShotSummary
+}
+
+func NewShotSummaryResponse(summary *types.ShotSummary) *ShotSummaryResponse {
+	if summary.Zones == nil {
+		summary.Zones = make([]types.ZoneAggregates, 0)
+	}
+	return &ShotSummaryResponse{ShotSummary: summary}
+}
+
+func (rd *ShotSummaryResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func NewShotResponse(shotAggs *types.ShotAggregates, shots *[]types.ReturnShot) *ShotResponse {
 	var shotsList []types.ReturnShot
 	if len(*shots) == 0 {
 		shotsList = make(ReturnShots, 0)
@@ -113,7 +155,7 @@ func NewShotResponse(shotAggs *shotAggregates, shots *[]types.ReturnShot) *ShotR
 		shotsList = *shots
 	}
 	resp := &ShotResponse{
-		shotAggregates: *shotAggs,
+		ShotAggregates: *shotAggs,
 		Shots:          shotsList,
 	}
 	return resp
@@ -127,6 +169,8 @@ func (rd *ShotResponse) Render(w http.ResponseWriter, r *http.Request) error {
 func ShotCtx(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var shotArgs = types.NewRequestShotParams()
+		// the shots are sent as json or protobuf depending on the Accept header
+		w.Header().Add("Vary", "Accept")
 
 		playerQueryParams := r.URL.Query().Get("player_id")
 
@@ -136,7 +180,8 @@ func ShotCtx(next http.Handler) http.Handler {
 			playerIds, err := ConvertStringSlicetoIntSlice(playerStringIds)
 
 			if err != nil {
-				render.Render(w, r, ErrInvalidRequest(err))
+				renderError(w, r, ErrInvalidRequest(err))
+				return
 			}
 
 			shotArgs.PlayerIDs = playerIds
@@ -150,7 +195,8 @@ func ShotCtx(next http.Handler) http.Handler {
 			teamIds, err := ConvertStringSlicetoIntSlice(teamStringIds)
 
 			if err != nil {
-				render.Render(w, r, ErrInvalidRequest(err))
+				renderError(w, r, ErrInvalidRequest(err))
+				return
 			}
 
 			shotArgs.TeamIDs = teamIds
@@ -163,7 +209,8 @@ func ShotCtx(next http.Handler) http.Handler {
 			seasonIds, err := ConvertStringSlicetoIntSlice(seasonStringIds)
 
 			if err != nil {
-				render.Render(w, r, ErrInvalidRequest(err))
+				renderError(w, r, ErrInvalidRequest(err))
+				return
 			}
 
 			shotArgs.SeasonYears = seasonIds
@@ -176,7 +223,8 @@ func ShotCtx(next http.Handler) http.Handler {
 			opposingTeamIDs, err := ConvertStringSlicetoIntSlice(opposingTeamStringIDs)
 
 			if err != nil {
-				render.Render(w, r, ErrInvalidRequest(err))
+				renderError(w, r, ErrInvalidRequest(err))
+				return
 			}
 
 			shotArgs.OpposingTeamIds = opposingTeamIDs
@@ -188,7 +236,8 @@ func ShotCtx(next http.Handler) http.Handler {
 			startGameDate, err := time.Parse("2006-01-02", startGameDateParam)
 
 			if err != nil {
-				render.Render(w, r, ErrInvalidRequest(err))
+				renderError(w, r, ErrInvalidRequest(err))
+				return
 			}
 
 			shotArgs.StartGameDate = startGameDate
@@ -200,24 +249,13 @@ func ShotCtx(next http.Handler) http.Handler {
 			endGameDate, err := time.Parse("2006-01-02", endGameDateParam)
 
 			if err != nil {
-				render.Render(w, r, ErrInvalidRequest(err))
+				renderError(w, r, ErrInvalidRequest(err))
+				return
 			}
 
 			shotArgs.EndGameDate = endGameDate
 		}
 
-		if !shotArgs.StartGameDate.IsZero() &&
-			!shotArgs.EndGameDate.IsZero() &&
-			!shotArgs.StartGameDate.Before(shotArgs.EndGameDate) &&
-			!shotArgs.StartGameDate.Equal(shotArgs.EndGameDate) {
-			render.Render(w, r, ErrInvalidRequest(
-				fmt.Errorf("start_game_date: %s is after end_game_date: %s",
-					startGameDateParam,
-					endGameDateParam,
-				),
-			))
-		}
-
 		gameLocationParam := r.URL.Query().Get("game_location")
 		gameLocationParam = strings.ToLower(gameLocationParam)
 		if gameLocationParam != "" && (gameLocationParam == "home" || gameLocationParam == "away") {
@@ -231,7 +269,8 @@ func ShotCtx(next http.Handler) http.Handler {
 			quarterString := strings.Split(quartersParam, ",")
 			quarters, err := ConvertStringSlicetoIntSlice(quarterString)
 			if err != nil {
-				render.Render(w, r, ErrInvalidRequest(err))
+				renderError(w, r, ErrInvalidRequest(err))
+				return
 			}
 			shotArgs.Quarters = quarters
 		}
@@ -242,7 +281,8 @@ func ShotCtx(next http.Handler) http.Handler {
 			log.Println("start time left passed in:", startTimeLeftParams)
 			startTimeLeft, err := parseClockTimeLeftToSecs(startTimeLeftParams)
 			if err != nil {
-				render.Render(w, r, ErrInvalidRequest(err))
+				renderError(w, r, ErrInvalidRequest(err))
+				return
 			}
 			shotArgs.StartTimeLeftSecs = startTimeLeft
 		}
@@ -253,19 +293,15 @@ func ShotCtx(next http.Handler) http.Handler {
 			log.Println("end time left passed in:", endTimeLeftParams)
 			endTimeLeft, err := parseClockTimeLeftToSecs(endTimeLeftParams)
 			if err != nil {
-				render.Render(w, r, ErrInvalidRequest(err))
+				renderError(w, r, ErrInvalidRequest(err))
+				return
 			}
 			shotArgs.EndTimeLeftSecs = endTimeLeft
 		}
 
-		// validate that the start time is greater than the end time
-		if shotArgs.EndTimeLeftSecs > shotArgs.StartTimeLeftSecs {
-			render.Render(w, r, ErrInvalidRequest(
-				fmt.Errorf("start_time_left is less than end_time_left: %s < %s",
-					startTimeLeftParams,
-					endTimeLeftParams,
-				),
-			))
+		if err := validateShotArgs(shotArgs); err != nil {
+			renderError(w, r, ErrInvalidRequest(err))
+			return
 		}
 
 		log.Println("shotArgs", shotArgs)
@@ -274,6 +310,37 @@ func ShotCtx(next http.Handler) http.Handler {
 	})
 }
 
+// validateShotArgs checks the filters against each other, the dates and the times left have to
+// be in order
+func validateShotArgs(args *types.RequestShotParams) error {
+	if !args.StartGameDate.IsZero() &&
+		!args.EndGameDate.IsZero() &&
+		args.StartGameDate.After(args.EndGameDate) {
+		return fmt.Errorf("start_game_date: %s is after end_game_date: %s",
+			args.StartGameDate.Format("2006-01-02"),
+			args.EndGameDate.Format("2006-01-02"),
+		)
+	}
+
+	// the start time has to be greater than the end time, either can be unset (-1)
+	if args.StartTimeLeftSecs >= 0 && args.EndTimeLeftSecs >= 0 &&
+		args.EndTimeLeftSecs > args.StartTimeLeftSecs {
+		return fmt.Errorf("start_time_left is less than end_time_left: %s < %s",
+			formatClockTimeLeft(args.StartTimeLeftSecs),
+			formatClockTimeLeft(args.EndTimeLeftSecs),
+		)
+	}
+	return nil
+}
+
+// formatClockTimeLeft is the M:SS parseClockTimeLeftToSecs parses, "" for an unset time
+func formatClockTimeLeft(secs int) string {
+	if secs < 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d:%02d", secs/60, secs%60)
+}
+
 func parseClockTimeLeftToSecs(t string) (int, error) {
 	// Expecting format: M:S
 	re := regexp.MustCompile("^(0?[0-9]|1[0-2]):([0-5][0-9])$")
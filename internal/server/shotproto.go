@@ -0,0 +1,175 @@
+package server
+
+import (
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// the shots are sent as proto/shots/v1/shots.proto to the clients that accept it
+const (
+	protobufContentType = "application/x-protobuf"
+	shotsMessageType    = "nbashots.shots.v1.Shots"
+	shotsSchemaVersion  = 1
+)
+
+// the field numbers of the messages in shots.proto
+const (
+	shotsFieldSchemaVersion protowire.Number = 1
+	shotsFieldAggregates    protowire.Number = 2
+	shotsFieldTeams         protowire.Number = 3
+	shotsFieldCount         protowire.Number = 4
+	shotsFieldIDDelta       protowire.Number = 5
+	shotsFieldLocX          protowire.Number = 6
+	shotsFieldLocY          protowire.Number = 7
+	shotsFieldMade          protowire.Number = 8
+	shotsFieldShotType      protowire.Number = 9
+
+	aggregatesFieldTotalMade   protowire.Number = 1
+	aggregatesFieldTotalMissed protowire.Number = 2
+	aggregatesFieldMade2Pt     protowire.Number = 3
+	aggregatesFieldMissed2Pt   protowire.Number = 4
+	aggregatesFieldMade3Pt     protowire.Number = 5
+	aggregatesFieldMissed3Pt   protowire.Number = 6
+	teamFieldTeamID            protowire.Number = 1
+	teamFieldStartSeason       protowire.Number = 2
+	teamFieldEndSeason         protowire.Number = 3
+	teamFieldName              protowire.Number = 4
+	teamFieldAbbreviation      protowire.Number = 5
+)
+
+// the values of the ShotType enum
+const (
+	shotTypeUnspecified uint64 = 0
+	shotType2Pt         uint64 = 1
+	shotType3Pt         uint64 = 2
+)
+
+// acceptsProtobuf reports whether the Accept header asks for protobuf, json stays the default
+// for anything else including */*
+func acceptsProtobuf(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mediaType != protobufContentType {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// renderShotsProto writes the response as a shots.proto Shots message
+func renderShotsProto(w http.ResponseWriter, resp *ShotResponse) error {
+	w.Header().Set("Content-Type", protobufContentType+"; messageType="+shotsMessageType)
+	_, err := w.Write(encodeShotsProto(resp))
+	return err
+}
+
+func encodeShotsProto(resp *ShotResponse) []byte {
+	var b []byte
+	b = appendVarintField(b, shotsFieldSchemaVersion, shotsSchemaVersion)
+
+	var aggs []byte
+	aggs = appendVarintField(aggs, aggregatesFieldTotalMade, uint64(resp.TotalMadeShots))
+	aggs = appendVarintField(aggs, aggregatesFieldTotalMissed, uint64(resp.TotalMissedShots))
+	aggs = appendVarintField(aggs, aggregatesFieldMade2Pt, uint64(resp.Made2PtShots))
+	aggs = appendVarintField(aggs, aggregatesFieldMissed2Pt, uint64(resp.Missed2PtShots))
+	aggs = appendVarintField(aggs, aggregatesFieldMade3Pt, uint64(resp.Made3PtShots))
+	aggs = appendVarintField(aggs, aggregatesFieldMissed3Pt, uint64(resp.Missed3PtShots))
+	b = protowire.AppendTag(b, shotsFieldAggregates, protowire.BytesType)
+	b = protowire.AppendBytes(b, aggs)
+
+	for _, team := range resp.Teams {
+		var t []byte
+		t = appendVarintField(t, teamFieldTeamID, uint64(team.TeamID))
+		t = appendVarintField(t, teamFieldStartSeason, uint64(team.StartSeason))
+		t = appendVarintField(t, teamFieldEndSeason, uint64(team.EndSeason))
+		t = appendStringField(t, teamFieldName, team.Name)
+		t = appendStringField(t, teamFieldAbbreviation, team.Abbreviation)
+		b = protowire.AppendTag(b, shotsFieldTeams, protowire.BytesType)
+		b = protowire.AppendBytes(b, t)
+	}
+
+	shots := resp.Shots
+	b = appendVarintField(b, shotsFieldCount, uint64(len(shots)))
+	if len(shots) == 0 {
+		return b
+	}
+
+	ids := make([]uint64, len(shots))
+	locX := make([]uint64, len(shots))
+	locY := make([]uint64, len(shots))
+	shotTypes := make([]uint64, len(shots))
+	made := make([]byte, (len(shots)+7)/8)
+	previousID := 0
+	for i, shot := range shots {
+		ids[i] = protowire.EncodeZigZag(int64(shot.ID - previousID))
+		previousID = shot.ID
+		locX[i] = protowire.EncodeZigZag(hundredths(shot.LocX))
+		locY[i] = protowire.EncodeZigZag(hundredths(shot.LocY))
+		shotTypes[i] = encodeShotType(shot.ShotType)
+		if shot.ShotMade {
+			made[i/8] |= 1 << (i % 8)
+		}
+	}
+	b = appendPackedField(b, shotsFieldIDDelta, ids)
+	b = appendPackedField(b, shotsFieldLocX, locX)
+	b = appendPackedField(b, shotsFieldLocY, locY)
+	b = protowire.AppendTag(b, shotsFieldMade, protowire.BytesType)
+	b = protowire.AppendBytes(b, made)
+	b = appendPackedField(b, shotsFieldShotType, shotTypes)
+	return b
+}
+
+// hundredths keeps the two decimals the dataset has, the stats source is in tenths and shifted
+// by 5.25
+func hundredths(feet float64) int64 {
+	return int64(math.Round(feet * 100))
+}
+
+func encodeShotType(shotType string) uint64 {
+	switch shotType {
+	case TWO_PT_SHOT:
+		return shotType2Pt
+	case THREE_PT_SHOT:
+		return shotType3Pt
+	}
+	return shotTypeUnspecified
+}
+
+// proto3 leaves out the fields that are zero
+func appendVarintField(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendStringField(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendPackedField(b []byte, num protowire.Number, values []uint64) []byte {
+	size := 0
+	for _, v := range values {
+		size += protowire.SizeVarint(v)
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	b = protowire.AppendVarint(b, uint64(size))
+	for _, v := range values {
+		b = protowire.AppendVarint(b, v)
+	}
+	return b
+}
@@ -0,0 +1,275 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"nba-shots/internal/database/servicetest"
+	"nba-shots/internal/types"
+
+	"github.com/go-chi/render"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodeShotsProto reads a Shots message back into the json response, the way a client would
+func decodeShotsProto(t testing.TB, b []byte) *ShotResponse {
+	t.Helper()
+	resp := &ShotResponse{Shots: []types.ReturnShot{}}
+	var ids, locX, locY, shotTypes []uint64
+	var made []byte
+	count := 0
+
+	fields(t, b, func(num protowire.Number, v uint64, bytes []byte) {
+		switch num {
+		case shotsFieldSchemaVersion:
+			if v != shotsSchemaVersion {
+				t.Fatalf("unknown schema version %d", v)
+			}
+		case shotsFieldAggregates:
+			fields(t, bytes, func(num protowire.Number, v uint64, _ []byte) {
+				*map[protowire.Number]*int64{
+					aggregatesFieldTotalMade:   &resp.TotalMadeShots,
+					aggregatesFieldTotalMissed: &resp.TotalMissedShots,
+					aggregatesFieldMade2Pt:     &resp.Made2PtShots,
+					aggregatesFieldMissed2Pt:   &resp.Missed2PtShots,
+					aggregatesFieldMade3Pt:     &resp.Made3PtShots,
+					aggregatesFieldMissed3Pt:   &resp.Missed3PtShots,
+				}[num] = int64(v)
+			})
+		case shotsFieldTeams:
+			var team types.TeamIdentity
+			fields(t, bytes, func(num protowire.Number, v uint64, bytes []byte) {
+				switch num {
+				case teamFieldTeamID:
+					team.TeamID = int(v)
+				case teamFieldStartSeason:
+					team.StartSeason = int(v)
+				case teamFieldEndSeason:
+					team.EndSeason = int(v)
+				case teamFieldName:
+					team.Name = string(bytes)
+				case teamFieldAbbreviation:
+					team.Abbreviation = string(bytes)
+				}
+			})
+			resp.Teams = append(resp.Teams, team)
+		case shotsFieldCount:
+			count = int(v)
+		case shotsFieldIDDelta:
+			ids = packed(t, bytes)
+		case shotsFieldLocX:
+			locX = packed(t, bytes)
+		case shotsFieldLocY:
+			locY = packed(t, bytes)
+		case shotsFieldMade:
+			made = bytes
+		case shotsFieldShotType:
+			shotTypes = packed(t, bytes)
+		}
+	})
+
+	if len(ids) != count || len(locX) != count || len(locY) != count || len(shotTypes) != count || len(made) != (count+7)/8 {
+		t.Fatalf("expected %d shots in every column, got %d, %d, %d, %d and %d bytes of made", count, len(ids), len(locX), len(locY), len(shotTypes), len(made))
+	}
+	id := 0
+	for i := range count {
+		id += int(protowire.DecodeZigZag(ids[i]))
+		shot := types.ReturnShot{
+			ID:       id,
+			LocX:     float64(protowire.DecodeZigZag(locX[i])) / 100,
+			LocY:     float64(protowire.DecodeZigZag(locY[i])) / 100,
+			ShotMade: made[i/8]&(1<<(i%8)) != 0,
+		}
+		switch shotTypes[i] {
+		case shotType2Pt:
+			shot.ShotType = TWO_PT_SHOT
+		case shotType3Pt:
+			shot.ShotType = THREE_PT_SHOT
+		}
+		resp.Shots = append(resp.Shots, shot)
+	}
+	return resp
+}
+
+func fields(t testing.TB, b []byte, field func(num protowire.Number, v uint64, bytes []byte)) {
+	t.Helper()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		b = b[n:]
+		var v uint64
+		var bytes []byte
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			bytes, n = protowire.ConsumeBytes(b)
+		default:
+			t.Fatalf("unexpected wire type %d for field %d", typ, num)
+		}
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		b = b[n:]
+		field(num, v, bytes)
+	}
+}
+
+func packed(t testing.TB, b []byte) []uint64 {
+	t.Helper()
+	var values []uint64
+	for len(b) > 0 {
+		v, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		values = append(values, v)
+		b = b[n:]
+	}
+	return values
+}
+
+func TestShotsProto(t *testing.T) {
+	handler := newTestServer(t)
+	url := "/shots?season=" + strconv.Itoa(servicetest.Year) + "&team_id=" + strconv.Itoa(servicetest.Warriors) + "," + strconv.Itoa(servicetest.Cavaliers)
+
+	var want ShotResponse
+	if code := get(t, handler, url, &want); code != http.StatusOK || len(want.Shots) == 0 {
+		t.Fatalf("expected shots, got %d %+v", code, want)
+	}
+	jsonReq := httptest.NewRequest(http.MethodGet, url, nil)
+	jsonW := httptest.NewRecorder()
+	handler.ServeHTTP(jsonW, jsonReq)
+
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Accept", "application/x-protobuf, application/json;q=0.9")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/x-protobuf; messageType=nbashots.shots.v1.Shots" {
+		t.Fatalf("expected protobuf, got %d %v", w.Code, w.Header())
+	}
+	if got := decodeShotsProto(t, w.Body.Bytes()); !reflect.DeepEqual(got, &want) {
+		t.Errorf("the protobuf shots differ from the json ones\n got %+v\nwant %+v", got, &want)
+	}
+	if w.Header().Get("ETag") == jsonW.Header().Get("ETag") || w.Header().Get("Vary") == "" {
+		t.Errorf("expected the representations to have their own ETag and vary by Accept, got %v", w.Header())
+	}
+
+	// an empty result still decodes
+	req = httptest.NewRequest(http.MethodGet, "/shots?season=1990", nil)
+	req.Header.Set("Accept", "application/x-protobuf")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if got := decodeShotsProto(t, w.Body.Bytes()); w.Code != http.StatusOK || len(got.Shots) != 0 {
+		t.Errorf("expected no shots, got %d %+v", w.Code, got)
+	}
+
+	for accept, want := range map[string]bool{
+		"":                           false,
+		"*/*":                        false,
+		"application/json":           false,
+		"application/x-protobuf;q=0": false,
+		"application/json, application/x-protobuf;q=0.5": true,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/shots", nil)
+		req.Header.Set("Accept", accept)
+		if got := acceptsProtobuf(req); got != want {
+			t.Errorf("acceptsProtobuf(%q) = %t, want %t", accept, got, want)
+		}
+	}
+}
+
+// TestShotsSchema fails when the field numbers the encoder uses drift from proto/shots/v1
+func TestShotsSchema(t *testing.T) {
+	schema, err := os.ReadFile("../../proto/shots/v1/shots.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	declared := map[string]int{}
+	message := ""
+	block := regexp.MustCompile(`^(message|enum) (\w+) \{$`)
+	field := regexp.MustCompile(`^(?:repeated )?\w+ (\w+) = (\d+);$`)
+	for _, line := range regexp.MustCompile(`\r?\n\s*`).Split(string(schema), -1) {
+		if m := block.FindStringSubmatch(line); m != nil {
+			message = m[2]
+		} else if m := field.FindStringSubmatch(line); m != nil {
+			declared[message+"."+m[1]], _ = strconv.Atoi(m[2])
+		} else if m := regexp.MustCompile(`^(\w+) = (\d+);$`).FindStringSubmatch(line); m != nil {
+			declared[message+"."+m[1]], _ = strconv.Atoi(m[2])
+		}
+	}
+
+	used := map[string]int{
+		"Shots.schema_version":           int(shotsFieldSchemaVersion),
+		"Shots.aggregates":               int(shotsFieldAggregates),
+		"Shots.teams":                    int(shotsFieldTeams),
+		"Shots.count":                    int(shotsFieldCount),
+		"Shots.id_delta":                 int(shotsFieldIDDelta),
+		"Shots.loc_x":                    int(shotsFieldLocX),
+		"Shots.loc_y":                    int(shotsFieldLocY),
+		"Shots.made":                     int(shotsFieldMade),
+		"Shots.shot_type":                int(shotsFieldShotType),
+		"Aggregates.total_made_shots":    int(aggregatesFieldTotalMade),
+		"Aggregates.total_missed_shots":  int(aggregatesFieldTotalMissed),
+		"Aggregates.made_2pt_shots":      int(aggregatesFieldMade2Pt),
+		"Aggregates.missed_2pt_shots":    int(aggregatesFieldMissed2Pt),
+		"Aggregates.made_3pt_shots":      int(aggregatesFieldMade3Pt),
+		"Aggregates.missed_3pt_shots":    int(aggregatesFieldMissed3Pt),
+		"TeamIdentity.team_id":           int(teamFieldTeamID),
+		"TeamIdentity.start_season":      int(teamFieldStartSeason),
+		"TeamIdentity.end_season":        int(teamFieldEndSeason),
+		"TeamIdentity.name":              int(teamFieldName),
+		"TeamIdentity.abbreviation":      int(teamFieldAbbreviation),
+		"ShotType.SHOT_TYPE_UNSPECIFIED": int(shotTypeUnspecified),
+		"ShotType.SHOT_TYPE_2PT":         int(shotType2Pt),
+		"ShotType.SHOT_TYPE_3PT":         int(shotType3Pt),
+	}
+	if !reflect.DeepEqual(declared, used) {
+		t.Errorf("shots.proto declares %v\nthe encoder uses %v", declared, used)
+	}
+}
+
+// BenchmarkShotsProtoFullSeason reports the size of a full season's shots as protobuf next to
+// json, both uncompressed and with each encoding
+func BenchmarkShotsProtoFullSeason(b *testing.B) {
+	resp := fullSeasonShots()
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		b.Fatal(err)
+	}
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if acceptsProtobuf(r) {
+			renderShotsProto(w, resp)
+			return
+		}
+		render.Render(w, r, resp)
+	}))
+
+	for _, accept := range []string{"application/json", protobufContentType} {
+		for _, encoding := range append([]string{"identity"}, compressEncodings...) {
+			b.Run(fmt.Sprintf("%s/%s", accept, encoding), func(b *testing.B) {
+				var size int
+				for range b.N {
+					req := httptest.NewRequest(http.MethodGet, "/shots?season=2016", nil)
+					req.Header.Set("Accept", accept)
+					req.Header.Set("Accept-Encoding", encoding)
+					w := httptest.NewRecorder()
+					handler.ServeHTTP(w, req)
+					size = w.Body.Len()
+				}
+				b.ReportMetric(float64(size), "bytes")
+				b.ReportMetric(float64(size)/float64(len(raw)), "ratio")
+			})
+		}
+	}
+}
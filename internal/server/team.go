@@ -2,6 +2,7 @@ package server
 
 import (
 	"log"
+	"nba-shots/internal/database"
 	"nba-shots/internal/types"
 	"net/http"
 	"strconv"
@@ -46,10 +47,26 @@ func (s *Server) getTeamByIDHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 2 - send the teamID to the db service to get the team
-	team, err := s.db.GetTeamByID(teamId)
+	// with a season, the team comes back with the name and abbreviation it used that season
+	var team *types.Team
+	seasonParam := r.URL.Query().Get("season")
+	if seasonParam != "" {
+		season, perr := strconv.Atoi(seasonParam)
+		if perr != nil {
+			render.Render(w, r, ErrInvalidRequest(perr))
+			return
+		}
+		team, err = s.catalog.GetTeamByIDForSeason(r.Context(), teamId, season)
+	} else {
+		team, err = s.catalog.GetTeamByID(r.Context(), teamId)
+	}
 
+	if database.IsNotFound(err) {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
 	if err != nil {
-		render.Render(w, r, ErrInternalServer(err))
+		render.Render(w, r, ErrQuery(r, err))
 		return
 	}
 	log.Println("Team received from db: ", team.Name)
@@ -63,10 +80,10 @@ func (s *Server) getTeamByIDHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) getAllTeamsHandler(w http.ResponseWriter, r *http.Request) {
-	teams, err := s.db.GetAllTeams()
+	teams, err := s.catalog.GetAllTeams(r.Context())
 
 	if err != nil {
-		render.Render(w, r, ErrInternalServer(err))
+		render.Render(w, r, ErrQuery(r, err))
 		return
 	}
 
@@ -0,0 +1,348 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"nba-shots/internal/database"
+	"nba-shots/internal/types"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// v2 answers the same routes as v1 with consistent bodies: every response is an Envelope and
+// every error an ErrorEnvelope, fields are snake_case, seasons are keyed by year and lookups
+// that find nothing are 404s
+
+// Envelope is the body of every successful v2 response
+type Envelope struct {
+	Data any `json:"data"`
+}
+
+func (rd *Envelope) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// ErrorEnvelope is the body of every failed v2 response
+type ErrorEnvelope struct {
+	Error ErrorV2 `json:"error"`
+}
+
+type ErrorV2 struct {
+	Code    int    `json:"code"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+func NewErrorEnvelope(e *ErrResponse) *ErrorEnvelope {
+	return &ErrorEnvelope{Error: ErrorV2{Code: e.HTTPStatusCode, Status: e.StatusText, Message: e.ErrorText}}
+}
+
+func (rd *ErrorEnvelope) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, rd.Error.Code)
+	return nil
+}
+
+type SeasonV2 struct {
+	Year        int    `json:"year"`
+	SeasonYears string `json:"season_years"`
+}
+
+func NewSeasonV2(season types.Season) SeasonV2 {
+	return SeasonV2{Year: season.Year, SeasonYears: season.SeasonYears}
+}
+
+type GameV2 struct {
+	ID         int    `json:"id"`
+	HomeTeamID int    `json:"home_team_id"`
+	AwayTeamID int    `json:"away_team_id"`
+	SeasonYear int    `json:"season_year"`
+	GameDate   string `json:"game_date"`
+}
+
+func NewGameV2(game types.Game) GameV2 {
+	return GameV2{
+		ID:         game.ID,
+		HomeTeamID: game.HomeTeamID,
+		AwayTeamID: game.AwayTeamID,
+		SeasonYear: game.SeasonYear,
+		GameDate:   game.GameDate.Format("2006-01-02"),
+	}
+}
+
+// ShotsV2 keeps the aggregates apart from the shots instead of next to them
+type ShotsV2 struct {
+	Aggregates types.ShotAggregates `json:"aggregates"`
+	Teams      []types.TeamIdentity `json:"teams"`
+	Shots      []types.ReturnShot   `json:"shots"`
+}
+
+type ShotSummaryV2 struct {
+	Aggregates types.ShotAggregates   `json:"aggregates"`
+	Zones      []types.ZoneAggregates `json:"zones"`
+}
+
+func (s *Server) v2Routes(r chi.Router) {
+	r.Route("/player", func(r chi.Router) {
+		r.Use(s.Validated(staticPolicy(cacheCatalog)))
+		r.Route("/{playerID}", func(r chi.Router) {
+			r.Get("/", s.getPlayerV2Handler)
+		})
+		r.Get("/", s.searchPlayersV2Handler)
+		r.Get("/multi", s.getPlayersByIDsV2Handler)
+	})
+
+	r.Route("/team", func(r chi.Router) {
+		r.Use(s.Validated(staticPolicy(cacheCatalog)))
+		r.Route("/{teamID}", func(r chi.Router) {
+			r.Get("/", s.getTeamV2Handler)
+		})
+		r.Get("/all", s.getAllTeamsV2Handler)
+	})
+
+	r.Route("/season", func(r chi.Router) {
+		r.Use(s.Validated(staticPolicy(cacheCatalog)))
+		r.Route("/{year}", func(r chi.Router) {
+			r.Get("/", s.getSeasonV2Handler)
+		})
+		r.Get("/all", s.getAllSeasonsV2Handler)
+	})
+
+	r.Route("/game", func(r chi.Router) {
+		r.Route("/{gameID}", func(r chi.Router) {
+			r.Use(s.Validated(staticPolicy(cacheCatalog)))
+			r.Get("/", s.getGameV2Handler)
+		})
+		r.With(s.Validated(staticPolicy(cacheRevalidate))).Get("/last/{numGames}", s.getLastGamesV2Handler)
+	})
+
+	r.Route("/shots", func(r chi.Router) {
+		r.Use(ShotCtx)
+		r.Use(s.Validated(s.shotsCachePolicy))
+		r.Get("/", s.getShotsV2Handler)
+		r.Get("/aggregates", s.getShotAggregatesV2Handler)
+	})
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(s.AdminOnly)
+		r.Get("/refresh", s.getRefreshRunsV2Handler)
+		r.Post("/cache/invalidate", s.invalidateCacheHandler)
+	})
+}
+
+// respond sends data in an Envelope
+func respond(w http.ResponseWriter, r *http.Request, data any) {
+	err := render.Render(w, r, &Envelope{Data: data})
+	if err != nil {
+		renderError(w, r, ErrRender(err))
+	}
+}
+
+// respondErr sends the error of a database call, a lookup that found nothing is a 404
+func respondErr(w http.ResponseWriter, r *http.Request, err error) {
+	if database.IsNotFound(err) {
+		renderError(w, r, ErrNotFound())
+		return
+	}
+	renderError(w, r, ErrQuery(r, err))
+}
+
+func (s *Server) getPlayerV2Handler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "playerID"))
+	if err != nil {
+		renderError(w, r, ErrInvalidRequest(err))
+		return
+	}
+	player, err := s.catalog.GetPlayerByID(r.Context(), id)
+	if err != nil {
+		respondErr(w, r, err)
+		return
+	}
+	respond(w, r, player)
+}
+
+func (s *Server) searchPlayersV2Handler(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		renderError(w, r, ErrInvalidRequest(errors.New("missing required name query parameter")))
+		return
+	}
+	players, err := s.catalog.GetPlayersByName(r.Context(), "%"+name+"%")
+	if err != nil {
+		respondErr(w, r, err)
+		return
+	}
+	respond(w, r, players)
+}
+
+func (s *Server) getPlayersByIDsV2Handler(w http.ResponseWriter, r *http.Request) {
+	param := r.URL.Query().Get("player_id")
+	if param == "" {
+		renderError(w, r, ErrInvalidRequest(errors.New("missing required player_id query parameter")))
+		return
+	}
+	ids, err := ConvertStringSlicetoIntSlice(strings.Split(param, ","))
+	if err != nil {
+		renderError(w, r, ErrInvalidRequest(err))
+		return
+	}
+	players, err := s.catalog.GetPlayersByIDs(r.Context(), ids)
+	if err != nil {
+		respondErr(w, r, err)
+		return
+	}
+	respond(w, r, players)
+}
+
+// getTeamV2Handler sends the name and abbreviation the team used in ?season= when it's set
+func (s *Server) getTeamV2Handler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "teamID"))
+	if err != nil {
+		renderError(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	var team *types.Team
+	if seasonParam := r.URL.Query().Get("season"); seasonParam != "" {
+		season, err := strconv.Atoi(seasonParam)
+		if err != nil {
+			renderError(w, r, ErrInvalidRequest(fmt.Errorf("season must be a year, got %q", seasonParam)))
+			return
+		}
+		team, err = s.catalog.GetTeamByIDForSeason(r.Context(), id, season)
+		if err != nil {
+			respondErr(w, r, err)
+			return
+		}
+	} else {
+		team, err = s.catalog.GetTeamByID(r.Context(), id)
+		if err != nil {
+			respondErr(w, r, err)
+			return
+		}
+	}
+	respond(w, r, team)
+}
+
+func (s *Server) getAllTeamsV2Handler(w http.ResponseWriter, r *http.Request) {
+	teams, err := s.catalog.GetAllTeams(r.Context())
+	if err != nil {
+		respondErr(w, r, err)
+		return
+	}
+	respond(w, r, teams)
+}
+
+func (s *Server) getSeasonV2Handler(w http.ResponseWriter, r *http.Request) {
+	year, err := strconv.Atoi(chi.URLParam(r, "year"))
+	if err != nil {
+		renderError(w, r, ErrInvalidRequest(err))
+		return
+	}
+	season, err := s.catalog.GetSeasonByYear(r.Context(), year)
+	if err != nil {
+		respondErr(w, r, err)
+		return
+	}
+	respond(w, r, NewSeasonV2(*season))
+}
+
+func (s *Server) getAllSeasonsV2Handler(w http.ResponseWriter, r *http.Request) {
+	seasons, err := s.catalog.GetAllSeasons(r.Context())
+	if err != nil {
+		respondErr(w, r, err)
+		return
+	}
+	list := make([]SeasonV2, len(seasons))
+	for i, season := range seasons {
+		list[i] = NewSeasonV2(season)
+	}
+	respond(w, r, list)
+}
+
+func (s *Server) getGameV2Handler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "gameID"))
+	if err != nil {
+		renderError(w, r, ErrInvalidRequest(err))
+		return
+	}
+	game, err := s.catalog.GetGameByID(r.Context(), id)
+	if err != nil {
+		respondErr(w, r, err)
+		return
+	}
+	respond(w, r, NewGameV2(*game))
+}
+
+func (s *Server) getLastGamesV2Handler(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.Atoi(chi.URLParam(r, "numGames"))
+	if err != nil {
+		renderError(w, r, ErrInvalidRequest(err))
+		return
+	}
+	games, err := s.catalog.GetLastXGames(r.Context(), limit)
+	if err != nil {
+		respondErr(w, r, err)
+		return
+	}
+	list := make([]GameV2, len(games))
+	for i, game := range games {
+		list[i] = NewGameV2(game)
+	}
+	respond(w, r, list)
+}
+
+// getShotsV2Handler sends the shots in an Envelope, or as shots.proto like v1 does
+func (s *Server) getShotsV2Handler(w http.ResponseWriter, r *http.Request) {
+	queryArgs := r.Context().Value(shotArgsKey).(*types.RequestShotParams)
+	resp, err := s.queryShots(r, queryArgs)
+	if err != nil {
+		respondErr(w, r, err)
+		return
+	}
+
+	if acceptsProtobuf(r) {
+		if err := renderShotsProto(w, resp); err != nil {
+			log.Printf("could not write the protobuf shots: %v", err)
+		}
+		return
+	}
+	teams := resp.Teams
+	if teams == nil {
+		teams = []types.TeamIdentity{}
+	}
+	respond(w, r, ShotsV2{Aggregates: resp.ShotAggregates, Teams: teams, Shots: resp.Shots})
+}
+
+func (s *Server) getShotAggregatesV2Handler(w http.ResponseWriter, r *http.Request) {
+	queryArgs := r.Context().Value(shotArgsKey).(*types.RequestShotParams)
+	summary, err := s.shots.GetShotSummary(r.Context(), queryArgs)
+	if err != nil {
+		respondErr(w, r, err)
+		return
+	}
+	zones := summary.Zones
+	if zones == nil {
+		zones = []types.ZoneAggregates{}
+	}
+	respond(w, r, ShotSummaryV2{Aggregates: summary.ShotAggregates, Zones: zones})
+}
+
+func (s *Server) getRefreshRunsV2Handler(w http.ResponseWriter, r *http.Request) {
+	limit, err := refreshRunsLimit(r)
+	if err != nil {
+		renderError(w, r, ErrInvalidRequest(err))
+		return
+	}
+	runs, err := s.refreshLog.GetRefreshRuns(r.Context(), limit)
+	if err != nil {
+		respondErr(w, r, err)
+		return
+	}
+	respond(w, r, runs)
+}
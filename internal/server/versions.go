@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// the routes from before /v1 are answered until unversionedSunset, with headers pointing to /v1
+var (
+	unversionedDeprecation = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	unversionedSunset      = time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC)
+)
+
+type apiVersionKey struct{}
+
+// APIVersion records the version of the api a request was routed to, the handlers and
+// middlewares shared between versions answer with that version's shapes
+func APIVersion(version int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), apiVersionKey{}, version)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// apiVersion is the version the request was routed to, 1 outside of the versioned routes
+func apiVersion(r *http.Request) int {
+	if version, ok := r.Context().Value(apiVersionKey{}).(int); ok {
+		return version
+	}
+	return 1
+}
+
+// Deprecated marks the responses of routes that are going away: Deprecation (RFC 9745) is when
+// they were deprecated, Sunset (RFC 8594) when they stop being served and the successor-version
+// link is the same request under prefix
+func Deprecated(deprecation, sunset time.Time, prefix string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			successor := prefix + r.URL.Path
+			if r.URL.RawQuery != "" {
+				successor += "?" + r.URL.RawQuery
+			}
+			w.Header().Set("Deprecation", fmt.Sprintf("@%d", deprecation.Unix()))
+			w.Header().Set("Sunset", sunset.Format(http.TimeFormat))
+			w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, strings.ReplaceAll(successor, ">", "%3E")))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"nba-shots/internal/database/servicetest"
+)
+
+// versionedURLs request every public route with parameters the fixture has, keyed by the
+// route in the spec
+var versionedURLs = map[string]string{
+	"/player/{playerID}/":     "/player/" + strconv.Itoa(servicetest.Kawhi) + "/",
+	"/player/":                "/player/?name=lebron",
+	"/player/multi":           fmt.Sprintf("/player/multi?player_id=%d,%d", servicetest.Curry, servicetest.LeBron),
+	"/team/{teamID}/":         "/team/" + strconv.Itoa(servicetest.Warriors) + "/?season=" + strconv.Itoa(servicetest.Year),
+	"/team/all":               "/team/all",
+	"/season/{year}/":         "/season/" + strconv.Itoa(servicetest.Year) + "/",
+	"/season/all":             "/season/all",
+	"/game/{gameID}/":         "/game/" + strconv.Itoa(servicetest.Game1) + "/",
+	"/game/last/{numGames}":   "/game/last/2",
+	"/shots/":                 "/shots/?player_id=" + strconv.Itoa(servicetest.Curry),
+	"/shots/aggregates":       "/shots/aggregates?player_id=" + strconv.Itoa(servicetest.Curry),
+	"/admin/refresh":          "",
+	"/admin/cache/invalidate": "",
+}
+
+func serve(handler http.Handler, url string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	return w
+}
+
+// TestVersionContracts checks every public route of both versions against the spec, and that
+// the unversioned aliases answer like /v1 with the deprecation headers
+func TestVersionContracts(t *testing.T) {
+	handler := newTestServer(t)
+	var spec map[string]any
+	if code := get(t, handler, openAPIPath, &spec); code != http.StatusOK {
+		t.Fatalf("expected the spec, got %d", code)
+	}
+	schemas := spec["components"].(map[string]any)["schemas"].(map[string]any)
+	paths := spec["paths"].(map[string]any)
+
+	for _, version := range []struct {
+		prefix     string
+		operations map[string]apiOperation
+	}{{"/v1", v1Operations}, {"/v2", v2Operations}} {
+		for key := range version.operations {
+			method, route, _ := strings.Cut(key, " ")
+			url, ok := versionedURLs[route]
+			if !ok {
+				t.Errorf("%s has no url to test it with", key)
+				continue
+			}
+			if method != http.MethodGet || url == "" {
+				continue
+			}
+			w := serve(handler, version.prefix+url)
+			if w.Code != http.StatusOK {
+				t.Errorf("expected 200 from %s%s, got %d %s", version.prefix, url, w.Code, w.Body)
+				continue
+			}
+			if w.Header().Get("Deprecation") != "" {
+				t.Errorf("expected %s%s not to be deprecated", version.prefix, url)
+			}
+			var body any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("could not decode %s%s: %v", version.prefix, url, err)
+			}
+			op := paths[version.prefix+route].(map[string]any)["get"].(map[string]any)
+			schema := op["responses"].(map[string]any)["200"].(map[string]any)["content"].(map[string]any)["application/json"].(map[string]any)["schema"]
+			if err := validate(body, schema, schemas); err != nil {
+				t.Errorf("%s%s doesn't match the spec: %v", version.prefix, url, err)
+			}
+
+			if version.prefix != "/v1" {
+				continue
+			}
+			alias := serve(handler, url)
+			if alias.Code != http.StatusOK || alias.Body.String() != w.Body.String() {
+				t.Errorf("expected %s to answer like /v1, got %d %s", url, alias.Code, alias.Body)
+			}
+			sunset, err := http.ParseTime(alias.Header().Get("Sunset"))
+			if err != nil || !sunset.Equal(unversionedSunset) {
+				t.Errorf("expected %s to sunset on %v, got %q", url, unversionedSunset, alias.Header().Get("Sunset"))
+			}
+			if alias.Header().Get("Deprecation") != fmt.Sprintf("@%d", unversionedDeprecation.Unix()) {
+				t.Errorf("expected %s to be deprecated, got %q", url, alias.Header().Get("Deprecation"))
+			}
+			if link := alias.Header().Get("Link"); link != "</v1"+url+`>; rel="successor-version"` {
+				t.Errorf("expected %s to link to /v1, got %q", url, link)
+			}
+		}
+	}
+}
+
+// validate checks v against the subset of OpenAPI schemas schemaOf generates
+func validate(v, schema any, schemas map[string]any) error {
+	s := schema.(map[string]any)
+	if ref, ok := s["$ref"].(string); ok {
+		return validate(v, schemas[strings.TrimPrefix(ref, "#/components/schemas/")], schemas)
+	}
+	if v == nil {
+		if s["nullable"] == true {
+			return nil
+		}
+		return fmt.Errorf("null isn't nullable")
+	}
+	if all, ok := s["allOf"].([]any); ok {
+		for _, sub := range all {
+			if err := validate(v, sub, schemas); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch s["type"] {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("expected an object, got %T", v)
+		}
+		for _, name := range anyOrNone(s["required"]) {
+			if _, ok := obj[name.(string)]; !ok {
+				return fmt.Errorf("missing %s", name)
+			}
+		}
+		props, _ := s["properties"].(map[string]any)
+		for name, field := range obj {
+			sub, ok := props[name]
+			if !ok {
+				sub, ok = s["additionalProperties"]
+			}
+			if !ok {
+				return fmt.Errorf("%s isn't in the schema", name)
+			}
+			if err := validate(field, sub, schemas); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("expected an array, got %T", v)
+		}
+		for i, item := range items {
+			if err := validate(item, s["items"], schemas); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != float64(int64(n)) {
+			return fmt.Errorf("expected an integer, got %v", v)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("expected a number, got %v", v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("expected a boolean, got %v", v)
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("expected a string, got %v", v)
+		}
+		if s["format"] == "date" {
+			if _, err := time.Parse(time.DateOnly, str); err != nil {
+				return err
+			}
+		}
+		if s["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func anyOrNone(v any) []any {
+	list, _ := v.([]any)
+	return list
+}
+
+func TestV2Shapes(t *testing.T) {
+	handler := newTestServer(t)
+	year := strconv.Itoa(servicetest.Year)
+
+	var v1 map[string]any
+	get(t, handler, "/v1/season/"+year+"/", &v1)
+	if _, ok := v1["id"]; !ok {
+		t.Errorf("expected v1 to keep the season's id, got %v", v1)
+	}
+	var v2 struct {
+		Data SeasonV2 `json:"data"`
+	}
+	get(t, handler, "/v2/season/"+year+"/", &v2)
+	if v2.Data.Year != servicetest.Year || v2.Data.SeasonYears != "2015-16" {
+		t.Errorf("expected the season by year in an envelope, got %+v", v2)
+	}
+
+	var games struct {
+		Data []GameV2 `json:"data"`
+	}
+	get(t, handler, "/v2/game/last/1", &games)
+	if len(games.Data) != 1 || len(games.Data[0].GameDate) != len(time.DateOnly) {
+		t.Errorf("expected a game with its date, got %+v", games)
+	}
+
+	for url, code := range map[string]int{
+		"/v2/player/1/":         http.StatusNotFound,
+		"/v2/player/abc/":       http.StatusBadRequest,
+		"/v2/team/1/?season=x":  http.StatusBadRequest,
+		"/v2/shots/?season=bad": http.StatusBadRequest,
+		"/v2/admin/refresh":     http.StatusNotFound,
+	} {
+		w := serve(handler, url)
+		var body ErrorEnvelope
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || w.Code != code || body.Error.Code != code || body.Error.Status == "" {
+			t.Errorf("expected %s to be a %d in an error envelope, got %d %s", url, code, w.Code, w.Body)
+		}
+	}
+	// v1 errors keep their shape
+	w := serve(handler, "/v1/player/abc/")
+	var body ErrResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || w.Code != http.StatusBadRequest || body.StatusText == "" {
+		t.Errorf("expected the v1 error body, got %d %s", w.Code, w.Body)
+	}
+}
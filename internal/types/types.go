@@ -2,6 +2,9 @@ package types
 
 import (
 	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -74,9 +77,19 @@ type PlayerGame struct {
 }
 
 type TeamSeason struct {
-	TeamID     int    `db:"team_id"`
-	SeasonYear int    `db:"season_year"`
-	TeamName   string `db:"team_name"`
+	TeamID       int    `db:"team_id"`
+	SeasonYear   int    `db:"season_year"`
+	TeamName     string `db:"team_name"`
+	Abbreviation string `db:"abbreviation"`
+}
+
+// TeamIdentity is the name and abbreviation a team used for a range of seasons
+type TeamIdentity struct {
+	TeamID       int    `db:"team_id" json:"team_id"`
+	StartSeason  int    `db:"start_season" json:"start_season"`
+	EndSeason    int    `db:"end_season" json:"end_season"`
+	Name         string `db:"name" json:"name"`
+	Abbreviation string `db:"abbreviation" json:"abbreviation"`
 }
 
 type TeamGame struct {
@@ -97,6 +110,77 @@ type PlayerTeamSeason struct {
 	TeamName   string `db:"team_name"`
 }
 
+// SeasonBatch is every row derived from one season file, in the order it gets inserted
+type SeasonBatch struct {
+	Players       []Player
+	Teams         []Team
+	Seasons       []Season
+	Games         []Game
+	Shots         []Shot
+	PlayerTeams   []PlayerTeam
+	PlayerSeasons []PlayerSeason
+	PlayerGames   []PlayerGame
+	TeamSeasons   []TeamSeason
+	TeamGames     []TeamGame
+	GameSeasons   []GameSeason
+}
+
+// TableCount is the number of rows in a table, possibly scoped to a season
+type TableCount struct {
+	Table string `json:"table"`
+	Rows  int    `json:"rows"`
+}
+
+// SeasonQuality is the raw numbers the post-ingest data quality checks are computed from
+type SeasonQuality struct {
+	Year                int
+	Shots               int
+	ShotsPerGame        map[int]int // game id -> shots
+	GamesPerTeam        map[int]int // team id -> games as home or away team
+	PlayersWithoutShots []int
+	ShotTypeMismatches  int   // 2PT/3PT disagreeing with the zone or distance
+	OutOfBoundsShots    int   // outside the half court, not counting backcourt heaves
+	GamesWithoutTeams   []int // games whose home or away team didn't resolve
+}
+
+// DatasetVersion changes every time the loaded data does
+type DatasetVersion struct {
+	Version   int64     `db:"version" json:"version"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// statuses of a MigrationStatus
+const (
+	MigrationApplied = "applied"
+	MigrationPending = "pending"
+	MigrationMissing = "missing" // applied but not embedded in the binary
+)
+
+type MigrationStatus struct {
+	Version   string     `json:"version"`
+	Status    string     `json:"status"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// statuses of a RefreshRun
+const (
+	RefreshRunning   = "running"
+	RefreshSucceeded = "succeeded"
+	RefreshFailed    = "failed"
+)
+
+// RefreshRun is one pass of the scheduled refresh looking for new games
+type RefreshRun struct {
+	ID         int        `db:"id" json:"id"`
+	Status     string     `db:"status" json:"status"`
+	Sources    int        `db:"sources" json:"sources"`
+	GamesAdded int        `db:"games_added" json:"games_added"`
+	ShotsAdded int        `db:"shots_added" json:"shots_added"`
+	Error      string     `db:"error" json:"error,omitempty"`
+	StartedAt  time.Time  `db:"started_at" json:"started_at"`
+	FinishedAt *time.Time `db:"finished_at" json:"finished_at"`
+}
+
 type RequestShotParams struct {
 	PlayerIDs         []int     `json:"player_id" db:"player_id"`
 	TeamIDs           []int     `json:"team_id" db:"team_id"`
@@ -115,10 +199,168 @@ type QueryHistoryRecord struct {
 	ReturnedShots int `json:"returned_shots" db:"returned_shots"`
 }
 
+// QueryFilterUsage is how often a combination of filters shows up in query_history
+type QueryFilterUsage struct {
+	Filters     []string  `db:"filters" json:"filters"`
+	Queries     int       `db:"queries" json:"queries"`
+	AvgShots    float64   `db:"avg_shots" json:"avg_shots"`
+	LastQueried time.Time `db:"last_queried" json:"last_queried"`
+}
+
+// names of the filters in a QueryFilterUsage
+const (
+	FilterPlayer   = "player"
+	FilterTeam     = "team"
+	FilterSeason   = "season"
+	FilterOpponent = "opponent"
+	FilterGameDate = "game_date"
+	FilterLocation = "location"
+	FilterQuarter  = "quarter"
+	FilterTimeLeft = "time_left"
+)
+
 func NewRequestShotParams() *RequestShotParams {
 	return &RequestShotParams{}
 }
 
+// Key is the params in a canonical form, two requests for the same shots have the same key no
+// matter the order or repetition of the ids. Times left outside of a quarter are dropped like
+// the queries drop them.
+func (p *RequestShotParams) Key() string {
+	var b strings.Builder
+	writeInts := func(name string, ids []int) {
+		ids = slices.Clone(ids)
+		slices.Sort(ids)
+		ids = slices.Compact(ids)
+		b.WriteString(name)
+		b.WriteByte('=')
+		for i, id := range ids {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(strconv.Itoa(id))
+		}
+		b.WriteByte(';')
+	}
+	writeDate := func(name string, date time.Time) {
+		b.WriteString(name)
+		b.WriteByte('=')
+		if !date.IsZero() {
+			b.WriteString(date.Format(time.DateOnly))
+		}
+		b.WriteByte(';')
+	}
+	writeTimeLeft := func(name string, secs int) {
+		b.WriteString(name)
+		b.WriteByte('=')
+		if secs >= 0 && secs <= 720 {
+			b.WriteString(strconv.Itoa(secs))
+		}
+		b.WriteByte(';')
+	}
+
+	writeInts("player", p.PlayerIDs)
+	writeInts("team", p.TeamIDs)
+	writeInts("season", p.SeasonYears)
+	writeInts("opponent", p.OpposingTeamIds)
+	writeDate("start_date", p.StartGameDate)
+	writeDate("end_date", p.EndGameDate)
+	b.WriteString("location=" + p.GameLocation + ";")
+	writeInts("quarter", p.Quarters)
+	writeTimeLeft("start_time_left", p.StartTimeLeftSecs)
+	writeTimeLeft("end_time_left", p.EndTimeLeftSecs)
+	return b.String()
+}
+
+// ShotAggregates are the made and missed counts of a set of shots, anything that isn't a two is a three
+type ShotAggregates struct {
+	TotalMadeShots   int64 `json:"total_made_shots" db:"total_made_shots"`
+	TotalMissedShots int64 `json:"total_missed_shots" db:"total_missed_shots"`
+	Made2PtShots     int64 `json:"made_2pt_shots" db:"made_2pt_shots"`
+	Missed2PtShots   int64 `json:"missed_2pt_shots" db:"missed_2pt_shots"`
+	Made3PtShots     int64 `json:"made_3pt_shots" db:"made_3pt_shots"`
+	Missed3PtShots   int64 `json:"missed_3pt_shots" db:"missed_3pt_shots"`
+}
+
+// TwoPtShot is the shot_type of a two, the only other one is "3PT Field Goal"
+const TwoPtShot = "2PT Field Goal"
+
+// AddShot counts one shot
+func (a *ShotAggregates) AddShot(made bool, shotType string) {
+	if made {
+		a.TotalMadeShots++
+		if shotType == TwoPtShot {
+			a.Made2PtShots++
+		} else {
+			a.Made3PtShots++
+		}
+	} else {
+		a.TotalMissedShots++
+		if shotType == TwoPtShot {
+			a.Missed2PtShots++
+		} else {
+			a.Missed3PtShots++
+		}
+	}
+}
+
+// Add adds the counts of other
+func (a *ShotAggregates) Add(other ShotAggregates) {
+	a.TotalMadeShots += other.TotalMadeShots
+	a.TotalMissedShots += other.TotalMissedShots
+	a.Made2PtShots += other.Made2PtShots
+	a.Missed2PtShots += other.Missed2PtShots
+	a.Made3PtShots += other.Made3PtShots
+	a.Missed3PtShots += other.Missed3PtShots
+}
+
+// ZoneAggregates are the ShotAggregates of the shots from one zone
+type ZoneAggregates struct {
+	BasicZone string `json:"basic_zone" db:"basic_zone"`
+	ZoneABB   string `json:"zone_abb" db:"zone_abb"`
+	ShotAggregates
+}
+
+// ShotSummary is the aggregates of a shot query without the shots
+type ShotSummary struct {
+	ShotAggregates
+	Zones []ZoneAggregates `json:"zones"`
+}
+
+// ShotGroup is the column the shots of a query are split by, the ids of the group are the ones
+// of the matching filter of the query
+type ShotGroup string
+
+const (
+	GroupByPlayer ShotGroup = "player_id"
+	GroupByTeam   ShotGroup = "team_id"
+	GroupBySeason ShotGroup = "season_year"
+)
+
+// IDs is the filter of the query the group's ids are in
+func (g ShotGroup) IDs(p *RequestShotParams) *[]int {
+	switch g {
+	case GroupByPlayer:
+		return &p.PlayerIDs
+	case GroupByTeam:
+		return &p.TeamIDs
+	default:
+		return &p.SeasonYears
+	}
+}
+
+// Of is the id of the group s is in
+func (g ShotGroup) Of(s *Shot) int {
+	switch g {
+	case GroupByPlayer:
+		return s.PlayerID
+	case GroupByTeam:
+		return s.TeamID
+	default:
+		return s.SeasonYear
+	}
+}
+
 type ReturnShot struct {
 	ID       int     `json:"id"`
 	LocX     float64 `json:"loc_x"`
@@ -0,0 +1,12 @@
+-- Revert migration 1: Drop the original tables
+DROP TABLE IF EXISTS game_season;
+DROP TABLE IF EXISTS team_season;
+DROP TABLE IF EXISTS team_game;
+DROP TABLE IF EXISTS player_game;
+DROP TABLE IF EXISTS player_season;
+DROP TABLE IF EXISTS player_team;
+DROP TABLE IF EXISTS shot;
+DROP TABLE IF EXISTS game;
+DROP TABLE IF EXISTS season;
+DROP TABLE IF EXISTS player;
+DROP TABLE IF EXISTS team;
@@ -0,0 +1,2 @@
+-- Revert migration 2: Drop the query logs
+DROP TABLE IF EXISTS query_history;
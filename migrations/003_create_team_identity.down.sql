@@ -0,0 +1,3 @@
+-- Revert migration 3: Drop the team eras
+DROP TABLE IF EXISTS team_identity;
+ALTER TABLE team_season DROP COLUMN IF EXISTS abbreviation;
@@ -0,0 +1,33 @@
+-- Migration 3: Track team names and abbreviations per era
+ALTER TABLE team_season ADD COLUMN IF NOT EXISTS abbreviation VARCHAR(10) NOT NULL DEFAULT '';
+
+-- best effort for databases loaded before team_season had an abbreviation, a re-ingest fixes these
+UPDATE team_season ts
+SET abbreviation = t.abbreviation
+FROM team t
+WHERE t.id = ts.team_id AND ts.abbreviation = '';
+
+CREATE TABLE IF NOT EXISTS team_identity (
+  team_id INTEGER REFERENCES team(id) NOT NULL,
+  start_season INTEGER NOT NULL,
+  end_season INTEGER NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  abbreviation VARCHAR(10) NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (team_id, start_season)
+);
+
+CREATE INDEX idx_team_identity_team_id ON team_identity(team_id);
+CREATE INDEX idx_team_identity_seasons ON team_identity(start_season, end_season);
+
+-- consecutive seasons with the same name and abbreviation collapse into one era
+INSERT INTO team_identity (team_id, start_season, end_season, name, abbreviation)
+SELECT team_id, MIN(season_year), MAX(season_year), team_name, abbreviation
+FROM (
+  SELECT team_id, season_year, team_name, abbreviation,
+    season_year - ROW_NUMBER() OVER (PARTITION BY team_id, team_name, abbreviation ORDER BY season_year) AS era
+  FROM team_season
+) eras
+GROUP BY team_id, team_name, abbreviation, era
+ON CONFLICT (team_id, start_season) DO NOTHING;
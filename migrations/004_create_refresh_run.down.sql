@@ -0,0 +1,2 @@
+-- Revert migration 4: Drop the refresh runs
+DROP TABLE IF EXISTS refresh_run;
@@ -0,0 +1,13 @@
+-- Migration 4: Status of the scheduled refreshes run by `ingest watch`
+CREATE TABLE IF NOT EXISTS refresh_run (
+  id SERIAL PRIMARY KEY,
+  status VARCHAR(20) NOT NULL DEFAULT 'running',
+  sources INTEGER NOT NULL DEFAULT 0,
+  games_added INTEGER NOT NULL DEFAULT 0,
+  shots_added INTEGER NOT NULL DEFAULT 0,
+  error TEXT NOT NULL DEFAULT '',
+  started_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_refresh_run_started_at ON refresh_run(started_at DESC);
@@ -0,0 +1,56 @@
+-- Revert migration 5: Move the shots back into a single table
+ALTER TABLE shot RENAME TO shot_partitioned;
+ALTER TABLE shot_partitioned RENAME CONSTRAINT shot_pkey TO shot_partitioned_pkey;
+ALTER SEQUENCE shot_id_seq OWNED BY NONE;
+
+DROP INDEX IF EXISTS idx_shot_player_id;
+DROP INDEX IF EXISTS idx_shot_game_id;
+DROP INDEX IF EXISTS idx_shot_team_id;
+DROP INDEX IF EXISTS idx_shot_home_team_id;
+DROP INDEX IF EXISTS idx_shot_away_team_id;
+DROP INDEX IF EXISTS idx_shot_game_date;
+
+CREATE TABLE shot (
+  id INTEGER PRIMARY KEY DEFAULT nextval('shot_id_seq'),
+  player_id INTEGER REFERENCES player(id),
+  game_id INTEGER REFERENCES game(id),
+  team_id INTEGER REFERENCES team(id),
+  season_year INTEGER REFERENCES season(year),
+  home_team_id INTEGER REFERENCES team(id),
+  away_team_id INTEGER REFERENCES team(id),
+  event_type VARCHAR(50) NOT NULL,
+  shot_made BOOLEAN NOT NULL,
+  action_type VARCHAR(50) NOT NULL,
+  shot_type VARCHAR(50) NOT NULL,
+  basic_zone VARCHAR(50) NOT NULL,
+  zone_name VARCHAR(100) NOT NULL,
+  zone_abb VARCHAR(20) NOT NULL,
+  zone_range VARCHAR(50) NOT NULL,
+  loc_x FLOAT NOT NULL,
+  loc_y FLOAT NOT NULL,
+  shot_distance INTEGER NOT NULL,
+  qtr SMALLINT NOT NULL,
+  total_time_left_secs SMALLINT NOT NULL,
+  mins_left SMALLINT NOT NULL,
+  secs_left SMALLINT NOT NULL,
+  position VARCHAR(20) NOT NULL,
+  position_group VARCHAR(20) NOT NULL,
+  game_date TIMESTAMP WITH TIME ZONE NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER SEQUENCE shot_id_seq OWNED BY shot.id;
+
+INSERT INTO shot SELECT * FROM shot_partitioned;
+
+-- drops every shot_<year> partition along with it
+DROP TABLE shot_partitioned;
+
+CREATE INDEX idx_shot_player_id ON shot(player_id);
+CREATE INDEX idx_shot_game_id ON shot(game_id);
+CREATE INDEX idx_shot_team_id ON shot(team_id);
+CREATE INDEX idx_shot_season_id ON shot(season_year);
+CREATE INDEX idx_shot_home_team_id ON shot(home_team_id);
+CREATE INDEX idx_shot_away_team_id ON shot(away_team_id);
+CREATE INDEX idx_shot_game_date ON shot(game_date);
@@ -0,0 +1,75 @@
+-- Migration 5: Partition shot by season_year, one partition per season named shot_<year>.
+-- Partitions for new seasons are created by the ingest before it copies their shots.
+ALTER TABLE shot RENAME TO shot_unpartitioned;
+-- the primary key index keeps its name through the rename and would clash with the new one
+ALTER TABLE shot_unpartitioned RENAME CONSTRAINT shot_pkey TO shot_unpartitioned_pkey;
+ALTER SEQUENCE shot_id_seq OWNED BY NONE;
+
+-- the partition key has to be part of the primary key, ids still come from the same sequence
+CREATE TABLE shot (
+  id INTEGER NOT NULL DEFAULT nextval('shot_id_seq'),
+  player_id INTEGER REFERENCES player(id),
+  game_id INTEGER REFERENCES game(id),
+  team_id INTEGER REFERENCES team(id),
+  season_year INTEGER REFERENCES season(year) NOT NULL,
+  home_team_id INTEGER REFERENCES team(id),
+  away_team_id INTEGER REFERENCES team(id),
+  event_type VARCHAR(50) NOT NULL,
+  shot_made BOOLEAN NOT NULL,
+  action_type VARCHAR(50) NOT NULL,
+  shot_type VARCHAR(50) NOT NULL,
+  basic_zone VARCHAR(50) NOT NULL,
+  zone_name VARCHAR(100) NOT NULL,
+  zone_abb VARCHAR(20) NOT NULL,
+  zone_range VARCHAR(50) NOT NULL,
+  loc_x FLOAT NOT NULL,
+  loc_y FLOAT NOT NULL,
+  shot_distance INTEGER NOT NULL,
+  qtr SMALLINT NOT NULL,
+  total_time_left_secs SMALLINT NOT NULL,
+  mins_left SMALLINT NOT NULL,
+  secs_left SMALLINT NOT NULL,
+  position VARCHAR(20) NOT NULL,
+  position_group VARCHAR(20) NOT NULL,
+  game_date TIMESTAMP WITH TIME ZONE NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id, season_year)
+) PARTITION BY LIST (season_year);
+
+ALTER SEQUENCE shot_id_seq OWNED BY shot.id;
+
+-- indexes on the parent are created on every partition, a season_year index isn't needed
+-- since each partition only holds one season
+DROP INDEX IF EXISTS idx_shot_player_id;
+DROP INDEX IF EXISTS idx_shot_game_id;
+DROP INDEX IF EXISTS idx_shot_team_id;
+DROP INDEX IF EXISTS idx_shot_season_id;
+DROP INDEX IF EXISTS idx_shot_home_team_id;
+DROP INDEX IF EXISTS idx_shot_away_team_id;
+DROP INDEX IF EXISTS idx_shot_game_date;
+
+CREATE INDEX idx_shot_player_id ON shot(player_id);
+CREATE INDEX idx_shot_game_id ON shot(game_id);
+CREATE INDEX idx_shot_team_id ON shot(team_id);
+CREATE INDEX idx_shot_home_team_id ON shot(home_team_id);
+CREATE INDEX idx_shot_away_team_id ON shot(away_team_id);
+CREATE INDEX idx_shot_game_date ON shot(game_date);
+
+DO $$
+DECLARE
+  season INTEGER;
+BEGIN
+  -- a shot without a season has no partition to go to
+  IF EXISTS (SELECT 1 FROM shot_unpartitioned WHERE season_year IS NULL) THEN
+    RAISE EXCEPTION 'shots without a season_year have to be fixed or deleted before partitioning';
+  END IF;
+
+  FOR season IN SELECT year FROM season LOOP
+    EXECUTE format('CREATE TABLE %I PARTITION OF shot FOR VALUES IN (%s)', 'shot_' || season, season);
+  END LOOP;
+END $$;
+
+INSERT INTO shot SELECT * FROM shot_unpartitioned;
+
+DROP TABLE shot_unpartitioned;
@@ -0,0 +1,6 @@
+-- Revert migration 6: Back to the single column indexes
+DROP INDEX IF EXISTS idx_shot_player_season;
+DROP INDEX IF EXISTS idx_shot_team_season;
+
+CREATE INDEX idx_shot_player_id ON shot(player_id);
+CREATE INDEX idx_shot_team_id ON shot(team_id);
@@ -0,0 +1,12 @@
+-- Migration 6: Composite indexes for the shot queries, see ingest query-report for how often each filter is used.
+-- The INCLUDE columns are everything ShotQuery selects or filters on, so a player or team season query
+-- is answered by an index only scan once the partition is vacuumed.
+-- They replace the single column player_id and team_id indexes, which are their prefixes.
+DROP INDEX IF EXISTS idx_shot_player_id;
+DROP INDEX IF EXISTS idx_shot_team_id;
+
+CREATE INDEX idx_shot_player_season ON shot (player_id, season_year, game_date)
+  INCLUDE (id, loc_x, loc_y, shot_made, shot_type, team_id, home_team_id, away_team_id, qtr, total_time_left_secs);
+
+CREATE INDEX idx_shot_team_season ON shot (team_id, season_year, game_date)
+  INCLUDE (id, loc_x, loc_y, shot_made, shot_type, player_id, home_team_id, away_team_id, qtr, total_time_left_secs);
@@ -0,0 +1,4 @@
+-- Revert migration 7: Drop the shot summaries
+DROP TABLE IF EXISTS league_season_zone_summary;
+DROP TABLE IF EXISTS team_season_zone_summary;
+DROP TABLE IF EXISTS player_season_zone_summary;
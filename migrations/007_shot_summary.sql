@@ -0,0 +1,82 @@
+-- Migration 7: Shot counts per season and zone for players, teams and the league.
+-- The ingest adds every shot it copies to them and clears a season when it's deleted, so aggregate
+-- requests that only filter by player or team and season don't have to scan the shots.
+CREATE TABLE IF NOT EXISTS player_season_zone_summary (
+  player_id INTEGER REFERENCES player(id) NOT NULL,
+  season_year INTEGER REFERENCES season(year) NOT NULL,
+  basic_zone VARCHAR(50) NOT NULL,
+  zone_abb VARCHAR(20) NOT NULL,
+  total_made_shots BIGINT NOT NULL DEFAULT 0,
+  total_missed_shots BIGINT NOT NULL DEFAULT 0,
+  made_2pt_shots BIGINT NOT NULL DEFAULT 0,
+  missed_2pt_shots BIGINT NOT NULL DEFAULT 0,
+  made_3pt_shots BIGINT NOT NULL DEFAULT 0,
+  missed_3pt_shots BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (player_id, season_year, basic_zone, zone_abb)
+);
+
+CREATE TABLE IF NOT EXISTS team_season_zone_summary (
+  team_id INTEGER REFERENCES team(id) NOT NULL,
+  season_year INTEGER REFERENCES season(year) NOT NULL,
+  basic_zone VARCHAR(50) NOT NULL,
+  zone_abb VARCHAR(20) NOT NULL,
+  total_made_shots BIGINT NOT NULL DEFAULT 0,
+  total_missed_shots BIGINT NOT NULL DEFAULT 0,
+  made_2pt_shots BIGINT NOT NULL DEFAULT 0,
+  missed_2pt_shots BIGINT NOT NULL DEFAULT 0,
+  made_3pt_shots BIGINT NOT NULL DEFAULT 0,
+  missed_3pt_shots BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (team_id, season_year, basic_zone, zone_abb)
+);
+
+CREATE TABLE IF NOT EXISTS league_season_zone_summary (
+  season_year INTEGER REFERENCES season(year) NOT NULL,
+  basic_zone VARCHAR(50) NOT NULL,
+  zone_abb VARCHAR(20) NOT NULL,
+  total_made_shots BIGINT NOT NULL DEFAULT 0,
+  total_missed_shots BIGINT NOT NULL DEFAULT 0,
+  made_2pt_shots BIGINT NOT NULL DEFAULT 0,
+  missed_2pt_shots BIGINT NOT NULL DEFAULT 0,
+  made_3pt_shots BIGINT NOT NULL DEFAULT 0,
+  missed_3pt_shots BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (season_year, basic_zone, zone_abb)
+);
+
+CREATE INDEX idx_player_season_zone_summary_season_year ON player_season_zone_summary(season_year);
+CREATE INDEX idx_team_season_zone_summary_season_year ON team_season_zone_summary(season_year);
+
+-- shots that are already loaded
+INSERT INTO player_season_zone_summary (player_id, season_year, basic_zone, zone_abb, total_made_shots, total_missed_shots, made_2pt_shots, missed_2pt_shots, made_3pt_shots, missed_3pt_shots)
+SELECT player_id, season_year, basic_zone, zone_abb,
+  COUNT(*) FILTER (WHERE shot_made),
+  COUNT(*) FILTER (WHERE NOT shot_made),
+  COUNT(*) FILTER (WHERE shot_made AND shot_type = '2PT Field Goal'),
+  COUNT(*) FILTER (WHERE NOT shot_made AND shot_type = '2PT Field Goal'),
+  COUNT(*) FILTER (WHERE shot_made AND shot_type <> '2PT Field Goal'),
+  COUNT(*) FILTER (WHERE NOT shot_made AND shot_type <> '2PT Field Goal')
+FROM shot
+WHERE player_id IS NOT NULL
+GROUP BY player_id, season_year, basic_zone, zone_abb;
+
+INSERT INTO team_season_zone_summary (team_id, season_year, basic_zone, zone_abb, total_made_shots, total_missed_shots, made_2pt_shots, missed_2pt_shots, made_3pt_shots, missed_3pt_shots)
+SELECT team_id, season_year, basic_zone, zone_abb,
+  COUNT(*) FILTER (WHERE shot_made),
+  COUNT(*) FILTER (WHERE NOT shot_made),
+  COUNT(*) FILTER (WHERE shot_made AND shot_type = '2PT Field Goal'),
+  COUNT(*) FILTER (WHERE NOT shot_made AND shot_type = '2PT Field Goal'),
+  COUNT(*) FILTER (WHERE shot_made AND shot_type <> '2PT Field Goal'),
+  COUNT(*) FILTER (WHERE NOT shot_made AND shot_type <> '2PT Field Goal')
+FROM shot
+WHERE team_id IS NOT NULL
+GROUP BY team_id, season_year, basic_zone, zone_abb;
+
+INSERT INTO league_season_zone_summary (season_year, basic_zone, zone_abb, total_made_shots, total_missed_shots, made_2pt_shots, missed_2pt_shots, made_3pt_shots, missed_3pt_shots)
+SELECT season_year, basic_zone, zone_abb,
+  COUNT(*) FILTER (WHERE shot_made),
+  COUNT(*) FILTER (WHERE NOT shot_made),
+  COUNT(*) FILTER (WHERE shot_made AND shot_type = '2PT Field Goal'),
+  COUNT(*) FILTER (WHERE NOT shot_made AND shot_type = '2PT Field Goal'),
+  COUNT(*) FILTER (WHERE shot_made AND shot_type <> '2PT Field Goal'),
+  COUNT(*) FILTER (WHERE NOT shot_made AND shot_type <> '2PT Field Goal')
+FROM shot
+GROUP BY season_year, basic_zone, zone_abb;
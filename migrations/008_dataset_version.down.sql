@@ -0,0 +1,2 @@
+-- Revert migration 8: Drop the dataset version
+DROP TABLE IF EXISTS dataset_version;
@@ -0,0 +1,9 @@
+-- Migration 8: Version of the loaded data, every transaction that changes it bumps the version so
+-- the api can tell clients whether the responses they cached are still current
+CREATE TABLE IF NOT EXISTS dataset_version (
+  id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+  version BIGINT NOT NULL DEFAULT 1,
+  updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO dataset_version DEFAULT VALUES ON CONFLICT DO NOTHING;
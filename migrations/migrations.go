@@ -0,0 +1,8 @@
+// Package migrations embeds the schema migrations so the api and ingest binaries can apply them
+// without the sql files on disk. Every NNN_name.sql has a NNN_name.down.sql that reverts it.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS